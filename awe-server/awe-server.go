@@ -139,8 +139,12 @@ func launchAPI(control chan int, port int) {
 	r.MapRest("/cgroup", c.ClientGroup)
 	r.MapRest("/client", c.Client)
 	r.MapRest("/queue", c.Queue)
+	r.MapRest("/schedule", c.Schedule)
+	r.MapRest("/project", c.Project)
+	r.MapRest("/cache", c.Cache)
 	r.MapRest("/logger", c.Logger)
 	r.MapRest("/awf", c.Awf)
+	r.MapFunc("/metrics", controller.Metrics, goweb.GetMethod)
 	r.MapFunc("*", controller.ResourceDescription, goweb.GetMethod)
 
 	if conf.SSL_ENABLED {
@@ -240,6 +244,29 @@ func main() {
 	logger.Info("InitClientGroupDB...")
 	core.InitClientGroupDB()
 
+	logger.Info("InitHistoryDB...")
+	core.InitHistoryDB()
+
+	logger.Info("InitQuotaDB...")
+	if err := core.InitQuotaDB(); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR initializing quotas: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	logger.Info("InitScheduleDB...")
+	core.InitScheduleDB()
+
+	logger.Info("InitCallCacheDB...")
+	core.InitCallCacheDB()
+
+	logger.Info("InitProjectDB...")
+	core.InitProjectDB()
+
+	logger.Info("InitRuntimeStats...")
+	core.InitRuntimeStats()
+
+	core.InitServerMetrics()
+
 	logger.Info("init auth...")
 	//init auth
 	auth.Initialize()
@@ -256,6 +283,7 @@ func main() {
 	go core.QMgr.NoticeHandle()
 	go core.QMgr.ClientChecker()
 	go core.QMgr.UpdateQueueLoop()
+	go core.ScheduleHandle() // submits jobs of recurring job schedules
 
 	goweb.ConfigureDefaultFormatters()
 	//go launchSite(control, conf.SITE_PORT) // deprecated
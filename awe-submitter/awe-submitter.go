@@ -8,7 +8,7 @@ import (
 	"github.com/MG-RAST/AWE/lib/core"
 	"github.com/MG-RAST/AWE/lib/core/cwl"
 	"github.com/MG-RAST/AWE/lib/logger"
-	shock "github.com/MG-RAST/go-shock-client"
+	"github.com/MG-RAST/AWE/lib/storage"
 
 	//"github.com/MG-RAST/AWE/lib/logger/event"
 
@@ -93,12 +93,15 @@ func mainWrapper() (err error) {
 			return
 		}
 
-		shockClient := shock.NewShockClient(conf.SHOCK_URL, shockAuth, false)
-		shockClient.Debug = true
+		var store storage.Storage
+		store, _, err = submitterStorage(shockAuth)
+		if err != nil {
+			return
+		}
 		var uploadCount int
 		//var jobData []byte
 
-		uploadCount, err = cache.ProcessIOData(jobDoc, inputfilePath, inputfilePath, "upload", shockClient, nil, true, false)
+		uploadCount, err = cache.ProcessIOData(jobDoc, inputfilePath, inputfilePath, "upload", store, nil, true, false)
 		if err != nil {
 			err = fmt.Errorf("(mainWrapper) ProcessIOData(for upload) returned: %s", err.Error())
 			return
@@ -165,6 +168,24 @@ func mainWrapper() (err error) {
 	}
 	logger.Debug(3, "(main_wrapper) B entrypoint: %s", entrypoint)
 	//fmt.Printf("---------- B\n")
+	if conf.SUBMITTER_VALIDATE {
+		var report *core.ValidationReport
+		report, err = ValidateCWLJobOnAWE(workflowTemporaryFile, jobFile, entrypoint, &jobData, aweAuth, shockAuth)
+		if err != nil {
+			err = fmt.Errorf("(main_wrapper) ValidateCWLJobOnAWE returned: %s", err.Error())
+			return
+		}
+		for _, problem := range report.Errors {
+			fmt.Printf("%s\t%s\t%s\n", problem.Stage, problem.ID, problem.Message)
+		}
+		if !report.Valid {
+			err = fmt.Errorf("validation failed, %d problem(s) found", len(report.Errors))
+			return
+		}
+		fmt.Printf("valid\n")
+		return
+	}
+
 	// ### Submit job to AWE
 	var jobid string
 
@@ -198,6 +219,35 @@ func mainWrapper() (err error) {
 	return
 }
 
+// submitterStorage returns the storage selected with --storage and the requirement that tells the server about it
+func submitterStorage(shockAuth string) (store storage.Storage, requirement cwl.Requirement, err error) {
+	if conf.STORAGE_TYPE == storage.TYPE_SHOCK {
+		var shockRequirement *cwl.ShockRequirement
+		shockRequirement, err = cwl.NewShockRequirement(conf.SHOCK_URL)
+		if err != nil {
+			err = fmt.Errorf("(submitterStorage) NewShockRequirement returned: %s", err.Error())
+			return
+		}
+		store = storage.NewShockStorage(conf.SHOCK_URL, shockAuth)
+		requirement = shockRequirement
+		return
+	}
+
+	var storageRequirement *cwl.StorageRequirement
+	storageRequirement, err = cwl.NewStorageRequirement(conf.STORAGE_TYPE, conf.STORAGE_URL, conf.STORAGE_BUCKET, conf.STORAGE_REGION, conf.STORAGE_PATH)
+	if err != nil {
+		err = fmt.Errorf("(submitterStorage) NewStorageRequirement returned: %s", err.Error())
+		return
+	}
+	store, err = storage.New(storageRequirement, shockAuth)
+	if err != nil {
+		err = fmt.Errorf("(submitterStorage) storage.New returned: %s", err.Error())
+		return
+	}
+	requirement = storageRequirement
+	return
+}
+
 func createNormalizedSubmisson(aweAuth string, shockAuth string, workflowFile string, jobFile string, entrypoint string, context *cwl.WorkflowContext) (workflowTemporaryFile string, jobData []byte, newEntrypoint string, err error) {
 
 	//workflowFile := conf.ARGS[0]
@@ -258,18 +308,28 @@ func createNormalizedSubmisson(aweAuth string, shockAuth string, workflowFile st
 
 	// ### upload input files
 
-	shockClient := shock.NewShockClient(conf.SHOCK_URL, shockAuth, false)
+	store, storageRequirement, err := submitterStorage(shockAuth)
+	if err != nil {
+		err = fmt.Errorf("(createNormalizedSubmisson) %s", err.Error())
+		return
+	}
 	//fmt.Printf("createNormalizedSubmisson C\n")
 	uploadCount := 0
 
+	// the dry run (--validate) must not store any data, it only checks that the local input files exist
+	ioType := "upload"
+	if conf.SUBMITTER_VALIDATE {
+		ioType = "check"
+	}
+
 	if jobFile != "" {
-		uploadCount, err = cache.ProcessIOData(jobDoc, inputfilePath, inputfilePath, "upload", shockClient, context, true, false)
+		uploadCount, err = cache.ProcessIOData(jobDoc, inputfilePath, inputfilePath, ioType, store, context, true, false)
 		if err != nil {
 			err = fmt.Errorf("(createNormalizedSubmisson) A) ProcessIOData(for upload) returned: %s", err.Error())
 			return
 		}
 	}
-	logger.Debug(3, "%d files have been uploaded\n", uploadCount)
+	logger.Debug(3, "%d files have been processed (%s)\n", uploadCount, ioType)
 	//time.Sleep(2)
 
 	//spew.Dump(*job_doc)
@@ -335,7 +395,7 @@ func createNormalizedSubmisson(aweAuth string, shockAuth string, workflowFile st
 	// A) search for File objects in Document, e.g. in CommandLineTools
 
 	subUploadCount := 0
-	subUploadCount, err = cache.ProcessIOData(namedObjectArray, inputfilePath, inputfilePath, "upload", shockClient, context, true, false)
+	subUploadCount, err = cache.ProcessIOData(namedObjectArray, inputfilePath, inputfilePath, ioType, store, context, true, false)
 	if err != nil {
 		err = fmt.Errorf("(createNormalizedSubmisson) B) ProcessIOData(for upload) returned: %s", err.Error())
 		return
@@ -344,7 +404,7 @@ func createNormalizedSubmisson(aweAuth string, shockAuth string, workflowFile st
 	//fmt.Printf("createNormalizedSubmisson G\n")
 	if context.Schemas != nil {
 		subUploadCount := 0
-		subUploadCount, err = cache.ProcessIOData(context.Schemas, inputfilePath, inputfilePath, "upload", shockClient, context, true, false)
+		subUploadCount, err = cache.ProcessIOData(context.Schemas, inputfilePath, inputfilePath, ioType, store, context, true, false)
 		if err != nil {
 			err = fmt.Errorf("(createNormalizedSubmisson) C) ProcessIOData(for upload) returned: %s", err.Error())
 			return
@@ -353,19 +413,9 @@ func createNormalizedSubmisson(aweAuth string, shockAuth string, workflowFile st
 
 	}
 
-	logger.Debug(3, "%d files have been uploaded\n", uploadCount)
-
-	var shockRequirement cwl.ShockRequirement
-	var shockRequirementPtr *cwl.ShockRequirement
-	shockRequirementPtr, err = cwl.NewShockRequirement(shockClient.Host)
-	if err != nil {
-		err = fmt.Errorf("(createNormalizedSubmisson) NewShockRequirement returned: %s", err.Error())
-		return
-	}
+	logger.Debug(3, "%d files have been processed (%s)\n", uploadCount, ioType)
 
-	shockRequirement = *shockRequirementPtr
-
-	// B) inject ShockRequirement into CommandLineTools, ExpressionTools and Workflow
+	// B) inject ShockRequirement (or StorageRequirement) into CommandLineTools, ExpressionTools and Workflow
 	for j := range namedObjectArray {
 
 		pair := namedObjectArray[j]
@@ -377,7 +427,7 @@ func createNormalizedSubmisson(aweAuth string, shockAuth string, workflowFile st
 		case *cwl.Workflow:
 			workflow := object.(*cwl.Workflow)
 
-			workflow.Requirements, err = cwl.AddRequirement(&shockRequirement, workflow.Requirements)
+			workflow.Requirements, err = cwl.AddRequirement(storageRequirement, workflow.Requirements)
 			if err != nil {
 				err = fmt.Errorf("(createNormalizedSubmisson) AddRequirement returned: %s", err.Error())
 				return
@@ -392,7 +442,7 @@ func createNormalizedSubmisson(aweAuth string, shockAuth string, workflowFile st
 				continue
 			}
 
-			cmdLineTool.Requirements, err = cwl.AddRequirement(&shockRequirement, cmdLineTool.Requirements)
+			cmdLineTool.Requirements, err = cwl.AddRequirement(storageRequirement, cmdLineTool.Requirements)
 			if err != nil {
 				err = fmt.Errorf("(createNormalizedSubmisson) AddRequirement returned: %s", err.Error())
 			}
@@ -411,7 +461,7 @@ func createNormalizedSubmisson(aweAuth string, shockAuth string, workflowFile st
 				return
 			}
 
-			expressTool.Requirements, err = cwl.AddRequirement(&shockRequirement, expressTool.Requirements)
+			expressTool.Requirements, err = cwl.AddRequirement(storageRequirement, expressTool.Requirements)
 			if err != nil {
 				err = fmt.Errorf("(createNormalizedSubmisson) AddRequirement returned: %s", err.Error())
 			}
@@ -592,7 +642,13 @@ FORLOOP:
 		var outputFilePath string
 		outputFilePath, err = os.Getwd()
 
-		_, err = cache.ProcessIOData(outputReceipt, outputFilePath, outputFilePath, "download", nil, context, true, true)
+		var store storage.Storage
+		store, _, err = submitterStorage(conf.SUBMITTER_SHOCK_AUTH)
+		if err != nil {
+			return
+		}
+
+		_, err = cache.ProcessIOData(outputReceipt, outputFilePath, outputFilePath, "download", store, context, true, true)
 		if err != nil {
 			//spew.Dump(outputReceipt)
 			err = fmt.Errorf("(Wait_for_results) ProcessIOData(for download) returned: %s", err.Error())
@@ -622,37 +678,53 @@ FORLOOP:
 	return
 }
 
-// SubmitCWLJobToAWE _
-func SubmitCWLJobToAWE(workflowFile string, jobFile string, entrypoint string, jobData *[]byte, aweAuth string, shockAuth string) (jobid string, newEntrypoint string, err error) {
+// postCWLSubmission sends workflow and job input to the AWE server and returns the data of the response
+func postCWLSubmission(url string, workflowFile string, jobFile string, entrypoint string, jobData *[]byte, aweAuth string, shockAuth string) (dataBytes []byte, err error) {
 	multipart := core.NewMultipartWriter()
 
 	err = multipart.AddFile("cwl", workflowFile)
 	if err != nil {
-		err = fmt.Errorf("(SubmitCWLJobToAWE) multipart.AddFile returned: %s (workflowFile=%s)", err.Error(), workflowFile)
+		err = fmt.Errorf("(postCWLSubmission) multipart.AddFile returned: %s (workflowFile=%s)", err.Error(), workflowFile)
 		return
 	}
 
 	if jobFile != "" {
-		logger.Debug(3, "(SubmitCWLJobToAWE) jobFile: %s", jobFile)
+		logger.Debug(3, "(postCWLSubmission) jobFile: %s", jobFile)
 
 		err = multipart.AddDataAsFile("job", jobFile, jobData)
 		if err != nil {
-			err = fmt.Errorf("(SubmitCWLJobToAWE) AddDataAsFile returned: %s", err.Error())
+			err = fmt.Errorf("(postCWLSubmission) AddDataAsFile returned: %s", err.Error())
 			return
 		}
 	}
 	//fmt.Fprintf(os.Stderr, "CLIENT_GROUP: %s\n", conf.CLIENT_GROUP)
 	err = multipart.AddForm("CLIENT_GROUP", conf.CLIENT_GROUP)
 	if err != nil {
-		err = fmt.Errorf("(SubmitCWLJobToAWE) AddForm returned: %s", err.Error())
+		err = fmt.Errorf("(postCWLSubmission) AddForm returned: %s", err.Error())
 		return
 	}
 
-	logger.Debug(3, "(SubmitCWLJobToAWE) entrypoint: %s", entrypoint)
+	if conf.SUBMITTER_NO_CACHE {
+		err = multipart.AddForm("NO_CACHE", "true")
+		if err != nil {
+			err = fmt.Errorf("(postCWLSubmission) AddForm returned: %s", err.Error())
+			return
+		}
+	}
+
+	if conf.SUBMITTER_RETENTION != "" {
+		err = multipart.AddForm("RETENTION", conf.SUBMITTER_RETENTION)
+		if err != nil {
+			err = fmt.Errorf("(postCWLSubmission) AddForm returned: %s", err.Error())
+			return
+		}
+	}
+
+	logger.Debug(3, "(postCWLSubmission) entrypoint: %s", entrypoint)
 
 	err = multipart.AddForm("entrypoint", entrypoint)
 	if err != nil {
-		err = fmt.Errorf("(SubmitCWLJobToAWE) AddForm returned: %s", err.Error())
+		err = fmt.Errorf("(postCWLSubmission) AddForm returned: %s", err.Error())
 		return
 	}
 
@@ -664,15 +736,15 @@ func SubmitCWLJobToAWE(workflowFile string, jobFile string, entrypoint string, j
 		header["Datatoken"] = []string{shockAuth}
 	}
 
-	response, err := multipart.Send("POST", conf.SERVER_URL+"/job", header)
+	response, err := multipart.Send("POST", url, header)
 	if err != nil {
-		err = fmt.Errorf("(SubmitCWLJobToAWE) multipart.Send returned: %s", err.Error())
+		err = fmt.Errorf("(postCWLSubmission) multipart.Send returned: %s", err.Error())
 		return
 	}
 
 	responseData, err := ioutil.ReadAll(response.Body)
 	if err != nil {
-		err = fmt.Errorf("(SubmitCWLJobToAWE) ioutil.ReadAll returned: %s", err.Error())
+		err = fmt.Errorf("(postCWLSubmission) ioutil.ReadAll returned: %s", err.Error())
 		return
 	}
 
@@ -683,19 +755,29 @@ func SubmitCWLJobToAWE(workflowFile string, jobFile string, entrypoint string, j
 	err = json.Unmarshal(responseData, &sr)
 	if err != nil {
 		//fmt.Println(string(responseData[:]))
-		err = fmt.Errorf("(SubmitCWLJobToAWE) json.Unmarshal returned: %s (%s) response: %s (response.StatusCode: %d)", err.Error(), conf.SERVER_URL+"/job", responseData, response.StatusCode)
+		err = fmt.Errorf("(postCWLSubmission) json.Unmarshal returned: %s (%s) response: %s (response.StatusCode: %d)", err.Error(), url, responseData, response.StatusCode)
 		return
 	}
 
 	if len(sr.Error) > 0 {
-		err = fmt.Errorf("(SubmitCWLJobToAWE) Response from AWE server contained error: %s", sr.Error[0])
+		err = fmt.Errorf("(postCWLSubmission) Response from AWE server contained error: %s", sr.Error[0])
 		return
 	}
 
+	dataBytes, err = json.Marshal(sr.Data)
+	if err != nil {
+		err = fmt.Errorf("(postCWLSubmission) json.Marshal returned: %s", err.Error())
+		return
+	}
+	return
+}
+
+// SubmitCWLJobToAWE _
+func SubmitCWLJobToAWE(workflowFile string, jobFile string, entrypoint string, jobData *[]byte, aweAuth string, shockAuth string) (jobid string, newEntrypoint string, err error) {
 	var jobBytes []byte
-	jobBytes, err = json.Marshal(sr.Data)
+	jobBytes, err = postCWLSubmission(conf.SERVER_URL+"/job", workflowFile, jobFile, entrypoint, jobData, aweAuth, shockAuth)
 	if err != nil {
-		err = fmt.Errorf("(SubmitCWLJobToAWE) json.Marshal returned: %s", err.Error())
+		err = fmt.Errorf("(SubmitCWLJobToAWE) %s", err.Error())
 		return
 	}
 
@@ -713,6 +795,24 @@ func SubmitCWLJobToAWE(workflowFile string, jobFile string, entrypoint string, j
 
 }
 
+// ValidateCWLJobOnAWE lets the AWE server check workflow and job input without submitting the job
+func ValidateCWLJobOnAWE(workflowFile string, jobFile string, entrypoint string, jobData *[]byte, aweAuth string, shockAuth string) (report *core.ValidationReport, err error) {
+	var reportBytes []byte
+	reportBytes, err = postCWLSubmission(conf.SERVER_URL+"/job?validate", workflowFile, jobFile, entrypoint, jobData, aweAuth, shockAuth)
+	if err != nil {
+		err = fmt.Errorf("(ValidateCWLJobOnAWE) %s", err.Error())
+		return
+	}
+
+	report = &core.ValidationReport{}
+	err = json.Unmarshal(reportBytes, report)
+	if err != nil {
+		err = fmt.Errorf("(ValidateCWLJobOnAWE) json.Unmarshal returned: %s (report_bytes: %s)", err.Error(), reportBytes)
+		return
+	}
+	return
+}
+
 // GetAWEObject _
 func GetAWEObject(resource string, objectid string, aweAuth string, result interface{}) (statusCode int, err error) {
 	statusCode = -1
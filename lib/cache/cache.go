@@ -23,6 +23,7 @@ import (
 	"github.com/MG-RAST/AWE/lib/core/cwl"
 	"github.com/MG-RAST/AWE/lib/logger"
 	"github.com/MG-RAST/AWE/lib/logger/event"
+	"github.com/MG-RAST/AWE/lib/storage"
 	shock "github.com/MG-RAST/go-shock-client"
 	"golang.org/x/text/collate"
 	"golang.org/x/text/language"
@@ -150,8 +151,93 @@ func MoveInputIO(work *core.Workunit, io *core.IO, workPath string) (size int64,
 	return
 }
 
+// localFilePath path of a local input file, relative paths are resolved against inputfilePath.
+// filePath is empty if the file is not local (http, https, ftp or s3 location).
+func localFilePath(file *cwl.File, inputfilePath string) (filePath string, fileInfo os.FileInfo, err error) {
+	filePath = strings.TrimPrefix(file.Path, "file://")
+
+	if filePath == "" {
+
+		pos := strings.Index(file.Location, "://")
+		if pos > 0 {
+			scheme := file.Location[0:pos]
+
+			switch scheme {
+			case "file":
+				filePath = strings.TrimPrefix(file.Location, "file://")
+			case "http", "https", "ftp", "s3":
+				// file already non-local
+				return
+			default:
+				err = fmt.Errorf("(localFilePath) unkown scheme \"%s\"", scheme)
+				return
+			}
+
+		} else {
+			//file.Location has no scheme, must be local file
+			filePath = file.Location
+		}
+
+		if filePath == "" {
+			err = fmt.Errorf("(localFilePath) filePath is empty and could not be derived (Location: %s)", file.Location)
+			return
+		}
+	}
+
+	if !path.IsAbs(filePath) {
+		filePath = path.Join(inputfilePath, filePath)
+	}
+
+	fileInfo, err = os.Stat(filePath)
+	if err != nil {
+		currentWorkingDir, _ := os.Getwd()
+		err = fmt.Errorf("(localFilePath) os.Stat returned: %s (inputfilePath: %s, file.Path: %s, file.Location: %s, currentWorkingDir: %s)", err.Error(), inputfilePath, file.Path, file.Location, currentWorkingDir)
+		return
+	}
+
+	if fileInfo.IsDir() {
+		err = fmt.Errorf("(localFilePath) file_path is a directory: %s", filePath)
+		return
+	}
+	return
+}
+
+// CheckFile checks that a local input file exists without uploading it, for the dry run of the submitter (ProcessIOData "check")
+func CheckFile(file *cwl.File, inputfilePath string) (count int, err error) {
+	if file.Contents != "" {
+		return
+	}
+	var filePath string
+	filePath, _, err = localFilePath(file, inputfilePath)
+	if err != nil {
+		err = fmt.Errorf("(CheckFile) %s", err.Error())
+		return
+	}
+	if filePath != "" {
+		count = 1
+	}
+	return
+}
+
+// CheckDirectory checks that a local input directory exists without uploading it (ProcessIOData "check")
+func CheckDirectory(dir *cwl.Directory, currentPath string) (count int, err error) {
+	dirPathFixed := path.Join(currentPath, dir.Path) // as UploadDirectory
+	var fi os.FileInfo
+	fi, err = os.Stat(dirPathFixed)
+	if err != nil {
+		err = fmt.Errorf("(CheckDirectory) directory %s not found: %s", dirPathFixed, err.Error())
+		return
+	}
+	if !fi.IsDir() {
+		err = fmt.Errorf("(CheckDirectory) %s is not a directory", dirPathFixed)
+		return
+	}
+	count = 1
+	return
+}
+
 // UploadFile _
-func UploadFile(file *cwl.File, inputfilePath string, shockClient *shock.ShockClient, lazyUpload bool) (count int, err error) {
+func UploadFile(file *cwl.File, inputfilePath string, store storage.Storage, lazyUpload bool) (count int, err error) {
 
 	if strings.HasPrefix(inputfilePath, "file:") {
 		err = fmt.Errorf("(UploadFile) prefix file: not allowed in inputfile_path (%s)", inputfilePath)
@@ -163,75 +249,20 @@ func UploadFile(file *cwl.File, inputfilePath string, shockClient *shock.ShockCl
 	//	return
 	//}
 
-	filePath := file.Path
+	filePath := ""
 	var fileSize int64
 	newFileName := ""
 
 	if file.Contents == "" {
 
-		filePath = strings.TrimPrefix(filePath, "file://")
-
-		//fmt.Println("(UploadFile) here")
-		//fmt.Printf("(UploadFile) file_path: %s\n", file_path)
-		//fmt.Printf("(UploadFile) file.Location: %s\n", file.Location)
-		if filePath == "" {
-
-			pos := strings.Index(file.Location, "://")
-			//fmt.Printf("(UploadFile) pos: %d\n", pos)
-			if pos > 0 {
-				scheme := file.Location[0:pos]
-				//fmt.Printf("scheme: %s\n", scheme)
-
-				switch scheme {
-
-				case "file":
-					filePath = strings.TrimPrefix(file.Location, "file://")
-
-				case "http":
-					// file already non-local
-					return
-				case "https":
-					// file already non-local
-					return
-				case "ftp":
-					// file already non-local
-					return
-
-				default:
-					err = fmt.Errorf("(UploadFile) unkown scheme \"%s\"", scheme)
-					return
-				}
-
-			} else {
-				//file.Location has no scheme, must be local file
-				filePath = file.Location
-			}
-
-			if filePath == "" {
-				err = fmt.Errorf("(UploadFile) filePath is empty and could not be derived (Location: %s)", file.Location)
-				return
-			}
-
-		}
-
-		if !path.IsAbs(filePath) {
-			filePath = path.Join(inputfilePath, filePath)
-		}
-
 		var fileInfo os.FileInfo
-		fileInfo, err = os.Stat(filePath)
+		filePath, fileInfo, err = localFilePath(file, inputfilePath)
 		if err != nil {
-
-			var currentWorkingDir string
-			currentWorkingDir, _ = os.Getwd()
-
-			err = fmt.Errorf("(UploadFile) os.Stat returned: %s (inputfilePath: %s, file.Path: %s, file.Location: %s, currentWorkingDir: %s)", err.Error(), inputfilePath, file.Path, file.Location, currentWorkingDir)
+			err = fmt.Errorf("(UploadFile) %s", err.Error())
 			return
 		}
-
-		if fileInfo.IsDir() {
-
-			err = fmt.Errorf("(UploadFile) file_path is a directory: %s", filePath)
+		if filePath == "" {
+			// file already non-local
 			return
 		}
 
@@ -282,36 +313,19 @@ func UploadFile(file *cwl.File, inputfilePath string, shockClient *shock.ShockCl
 		newFileName = file.Basename
 		filePath = ""
 	}
-	//shockClient.Debug = true
-	var nodeid string
-	if lazyUpload {
-		//example: "${SHOCK_SERVER}/node?querynode&file.checksum.md5=37cbb1f811a144158cec0cbd87d97941"
-		//nodeid, err = shockClient.PostFileIf(file_path, new_file_name)
-
-		nodeid, err = shockClient.PostFileLazy(filePath, newFileName, file.Contents, false)
-		if err != nil {
-			err = fmt.Errorf("(UploadFile) shockClient.PostFileLazy returned: %s", err.Error())
-			return
-		}
-	} else {
-
-		nodeid, err = shockClient.PostFile(filePath, file.Contents, newFileName)
-		if err != nil {
-			err = fmt.Errorf("(UploadFile) shockClient.PostFile returned: %s", err.Error())
-			return
-		}
+	if store == nil {
+		err = fmt.Errorf("(UploadFile) no storage to upload %s to", newFileName)
+		return
 	}
-	file.Contents = ""
 
-	var locationURL *url.URL
-	locationURL, err = url.Parse(shockClient.Host) // Host includes a path to the API !
+	var location string
+	location, err = store.Upload(filePath, file.Contents, newFileName, lazyUpload)
 	if err != nil {
-		err = fmt.Errorf("(UploadFile) url.Parse returned: %s", err.Error())
+		err = fmt.Errorf("(UploadFile) store.Upload returned: %s", err.Error())
 		return
 	}
-	locationURL.Path = path.Join(locationURL.Path, "node", nodeid)
-	locationURL.RawQuery = strings.TrimPrefix(shock.DATA_SUFFIX, "?")
-	file.Location = locationURL.String()
+	file.Contents = ""
+	file.Location = location
 
 	//fmt.Printf("file.Path A: %s", file.Path)
 
@@ -334,7 +348,7 @@ func UploadFile(file *cwl.File, inputfilePath string, shockClient *shock.ShockCl
 }
 
 // DownloadFile _
-func DownloadFile(file *cwl.File, downloadPath string, basePath string, shockClient *shock.ShockClient) (err error) {
+func DownloadFile(file *cwl.File, downloadPath string, basePath string, store storage.Storage) (err error) {
 
 	if file.Contents != "" {
 		err = fmt.Errorf("(DownloadFile) File is a literal")
@@ -396,14 +410,6 @@ func DownloadFile(file *cwl.File, downloadPath string, basePath string, shockCli
 
 	//fmt.Printf("Using path %s\n", filePath)
 
-	token := ""
-
-	if shockClient != nil {
-		if strings.HasPrefix(file.Location, shockClient.Host) {
-			token = shockClient.Token
-		}
-	}
-
 	_, err = os.Stat(downloadPath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -418,10 +424,19 @@ func DownloadFile(file *cwl.File, downloadPath string, basePath string, shockCli
 		}
 	}
 
-	_, _, err = shock.FetchFile(filePath, file.Location, token, "", false)
-	if err != nil {
-		err = fmt.Errorf("(DownloadFile) shock.FetchFile returned: %s (download_path: %s, basename: %s, file.Location: %s, TokenLength: %d)", err.Error(), downloadPath, basename, file.Location, len(token))
-		return
+	if store != nil && store.Owns(file.Location) {
+		_, err = store.Download(file.Location, filePath)
+		if err != nil {
+			err = fmt.Errorf("(DownloadFile) store.Download returned: %s (download_path: %s, basename: %s)", err.Error(), downloadPath, basename)
+			return
+		}
+	} else {
+		// data not in the storage of the job, e.g. a public url
+		_, _, err = shock.FetchFile(filePath, file.Location, "", "", false)
+		if err != nil {
+			err = fmt.Errorf("(DownloadFile) shock.FetchFile returned: %s (download_path: %s, basename: %s, file.Location: %s)", err.Error(), downloadPath, basename, file.Location)
+			return
+		}
 	}
 
 	basePath = path.Join(strings.TrimSuffix(basePath, "/"), "/")
@@ -435,7 +450,7 @@ func DownloadFile(file *cwl.File, downloadPath string, basePath string, shockCli
 }
 
 // UploadDirectory _
-func UploadDirectory(dir *cwl.Directory, currentPath string, shockClient *shock.ShockClient, context *cwl.WorkflowContext, lazyUpload bool) (count int, err error) {
+func UploadDirectory(dir *cwl.Directory, currentPath string, store storage.Storage, context *cwl.WorkflowContext, lazyUpload bool) (count int, err error) {
 
 	//pwd, _ := os.Getwd()
 	//fmt.Printf("current working directory: %s\n", pwd)
@@ -506,7 +521,7 @@ func UploadDirectory(dir *cwl.Directory, currentPath string, shockClient *shock.
 			subdir.Path = matchRel
 			subdir.Basename = path.Base(match)
 			var subcount int
-			subcount, err = UploadDirectory(subdir, currentPath, shockClient, context, lazyUpload)
+			subcount, err = UploadDirectory(subdir, currentPath, store, context, lazyUpload)
 			if err != nil {
 				err = fmt.Errorf("(UploadDirectory) UploadDirectory returned: %s", err.Error())
 				return
@@ -524,7 +539,7 @@ func UploadDirectory(dir *cwl.Directory, currentPath string, shockClient *shock.
 		file.SetPath(matchRel)
 		//file.Basename = path.Base(match)
 		var uploadCount int
-		uploadCount, err = ProcessIOData(file, currentPath, currentPath, "upload", shockClient, context, lazyUpload, false)
+		uploadCount, err = ProcessIOData(file, currentPath, currentPath, "upload", store, context, lazyUpload, false)
 		if err != nil {
 			err = fmt.Errorf("(UploadDirectory) ProcessIOData returned: %s", err.Error())
 			return
@@ -545,8 +560,9 @@ func UploadDirectory(dir *cwl.Directory, currentPath string, shockClient *shock.
 }
 
 // ProcessIOData
+// ioType: "upload", "download" or "check" (local files and directories must exist, nothing is uploaded)
 // lazyUpload boolean: get Shock Copy node is data already exists in Shock
-func ProcessIOData(native interface{}, currentPath string, basePath string, ioType string, shockClient *shock.ShockClient, context *cwl.WorkflowContext, lazyUpload bool, removeIDField bool) (count int, err error) {
+func ProcessIOData(native interface{}, currentPath string, basePath string, ioType string, store storage.Storage, context *cwl.WorkflowContext, lazyUpload bool, removeIDField bool) (count int, err error) {
 
 	//fmt.Printf("(processIOData) start (type:  %s) \n", reflect.TypeOf(native))
 
@@ -574,7 +590,7 @@ func ProcessIOData(native interface{}, currentPath string, basePath string, ioTy
 			//	fmt.Printf("location: %s\n", value_file.Location)
 			//}
 
-			sub_count, err = ProcessIOData(value, currentPath, basePath, ioType, shockClient, context, lazyUpload, removeIDField)
+			sub_count, err = ProcessIOData(value, currentPath, basePath, ioType, store, context, lazyUpload, removeIDField)
 			if err != nil {
 				return
 			}
@@ -596,7 +612,7 @@ func ProcessIOData(native interface{}, currentPath string, basePath string, ioTy
 			//id := value.Id
 			//fmt.Printf("recurse into key: %s\n", id)
 			var sub_count int
-			sub_count, err = ProcessIOData(job_doc[i], currentPath, basePath, ioType, shockClient, context, lazyUpload, removeIDField)
+			sub_count, err = ProcessIOData(job_doc[i], currentPath, basePath, ioType, store, context, lazyUpload, removeIDField)
 			if err != nil {
 				return
 			}
@@ -607,7 +623,7 @@ func ProcessIOData(native interface{}, currentPath string, basePath string, ioTy
 	case cwl.NamedCWLType:
 		named := native.(cwl.NamedCWLType)
 		var sub_count int
-		sub_count, err = ProcessIOData(named.Value, currentPath, basePath, ioType, shockClient, context, lazyUpload, removeIDField)
+		sub_count, err = ProcessIOData(named.Value, currentPath, basePath, ioType, store, context, lazyUpload, removeIDField)
 		if err != nil {
 			return
 		}
@@ -616,7 +632,7 @@ func ProcessIOData(native interface{}, currentPath string, basePath string, ioTy
 	case cwl.NamedCWLObject:
 		named := native.(cwl.NamedCWLObject)
 		var sub_count int
-		sub_count, err = ProcessIOData(named.Value, currentPath, basePath, ioType, shockClient, context, lazyUpload, removeIDField)
+		sub_count, err = ProcessIOData(named.Value, currentPath, basePath, ioType, store, context, lazyUpload, removeIDField)
 		if err != nil {
 			return
 		}
@@ -654,7 +670,7 @@ func ProcessIOData(native interface{}, currentPath string, basePath string, ioTy
 			//filePath := file.Path
 			var sub_count int // sub_count is 0 or 1
 
-			sub_count, err = UploadFile(file, currentPath, shockClient, lazyUpload)
+			sub_count, err = UploadFile(file, currentPath, store, lazyUpload)
 			if err != nil {
 
 				err = fmt.Errorf("(ProcessIOData) *cwl.File currentPath:%s UploadFile returned: %s (file: %s)", currentPath, err.Error(), spew.Sdump(*file))
@@ -667,11 +683,19 @@ func ProcessIOData(native interface{}, currentPath string, basePath string, ioTy
 
 			//fmt.Printf("file.Location: %s\n", file.Location)
 			//count += 1
+		} else if ioType == "check" {
+			var sub_count int
+			sub_count, err = CheckFile(file, currentPath)
+			if err != nil {
+				err = fmt.Errorf("(ProcessIOData) *cwl.File CheckFile returned: %s", err.Error())
+				return
+			}
+			count += sub_count
 		} else {
 
 			// download
 
-			err = DownloadFile(file, currentPath, basePath, shockClient)
+			err = DownloadFile(file, currentPath, basePath, store)
 			if err != nil {
 				err = fmt.Errorf("(ProcessIOData) DownloadFile returned: %s (file: %s)", err.Error(), file)
 				return
@@ -684,7 +708,7 @@ func ProcessIOData(native interface{}, currentPath string, basePath string, ioTy
 			for i, _ := range file.SecondaryFiles {
 				value := file.SecondaryFiles[i]
 				var sub_count int
-				sub_count, err = ProcessIOData(value, currentPath, basePath, ioType, shockClient, context, lazyUpload, removeIDField)
+				sub_count, err = ProcessIOData(value, currentPath, basePath, ioType, store, context, lazyUpload, removeIDField)
 				if err != nil {
 					err = fmt.Errorf("(ProcessIOData) (for SecondaryFiles) ProcessIOData returned: %s", err.Error())
 					return
@@ -708,7 +732,7 @@ func ProcessIOData(native interface{}, currentPath string, basePath string, ioTy
 			//id := value.GetID()
 			//fmt.Printf("recurse into key: %s\n", id)
 			var sub_count int
-			sub_count, err = ProcessIOData((*array)[i], currentPath, basePath, ioType, shockClient, context, lazyUpload, removeIDField)
+			sub_count, err = ProcessIOData((*array)[i], currentPath, basePath, ioType, store, context, lazyUpload, removeIDField)
 			if err != nil {
 				err = fmt.Errorf("(ProcessIOData) (for *cwl.Array) currentPath:%s ProcessIOData returned: %s", currentPath, err.Error())
 				return
@@ -724,7 +748,7 @@ func ProcessIOData(native interface{}, currentPath string, basePath string, ioTy
 			//id := value.GetID()
 
 			var sub_count int
-			sub_count, err = ProcessIOData((array)[i], currentPath, basePath, ioType, shockClient, context, lazyUpload, removeIDField)
+			sub_count, err = ProcessIOData((array)[i], currentPath, basePath, ioType, store, context, lazyUpload, removeIDField)
 			if err != nil {
 				err = fmt.Errorf("(ProcessIOData) (for *cwl.Array) currentPath:%s ProcessIOData returned: %s", currentPath, err.Error())
 				return
@@ -784,7 +808,7 @@ func ProcessIOData(native interface{}, currentPath string, basePath string, ioTy
 				//fmt.Printf("XXX *cwl.Directory, Listing %d (%s)\n", k, reflect.TypeOf(value))
 
 				var sub_count int
-				sub_count, err = ProcessIOData(value, path_to_download_to, basePath, ioType, shockClient, context, lazyUpload, removeIDField)
+				sub_count, err = ProcessIOData(value, path_to_download_to, basePath, ioType, store, context, lazyUpload, removeIDField)
 				if err != nil {
 					err = fmt.Errorf("(ProcessIOData) ProcessIOData for Directory.Listing returned (value: %s): %s", value, err.Error())
 					return
@@ -798,7 +822,7 @@ func ProcessIOData(native interface{}, currentPath string, basePath string, ioTy
 		// case: dir.Listing == nil
 
 		logger.Debug(3, "dir.Path: %s", dir.Path)
-		if ioType == "upload" {
+		if ioType == "upload" || ioType == "check" {
 
 			if dir.Path == "" {
 				if dir.Location == "" {
@@ -841,7 +865,17 @@ func ProcessIOData(native interface{}, currentPath string, basePath string, ioTy
 			//}
 			var sub_count int
 
-			sub_count, err = UploadDirectory(dir, currentPath, shockClient, context, lazyUpload)
+			if ioType == "check" {
+				sub_count, err = CheckDirectory(dir, currentPath)
+				if err != nil {
+					err = fmt.Errorf("(ProcessIOData) CheckDirectory returned: %s", err.Error())
+					return
+				}
+				count += sub_count
+				return
+			}
+
+			sub_count, err = UploadDirectory(dir, currentPath, store, context, lazyUpload)
 			if err != nil {
 				err = fmt.Errorf("(ProcessIOData) UploadDirectory returned: %s", err.Error())
 				return
@@ -884,7 +918,7 @@ func ProcessIOData(native interface{}, currentPath string, basePath string, ioTy
 		for _, value := range *rec {
 			//value := rec.Fields[k]
 			var sub_count int
-			sub_count, err = ProcessIOData(value, currentPath, basePath, ioType, shockClient, context, lazyUpload, removeIDField)
+			sub_count, err = ProcessIOData(value, currentPath, basePath, ioType, store, context, lazyUpload, removeIDField)
 			if err != nil {
 				return
 			}
@@ -900,7 +934,7 @@ func ProcessIOData(native interface{}, currentPath string, basePath string, ioTy
 		for _, value := range rec {
 			//value := rec.Fields[k]
 			var sub_count int
-			sub_count, err = ProcessIOData(value, currentPath, basePath, ioType, shockClient, context, lazyUpload, removeIDField)
+			sub_count, err = ProcessIOData(value, currentPath, basePath, ioType, store, context, lazyUpload, removeIDField)
 			if err != nil {
 				return
 			}
@@ -930,7 +964,7 @@ func ProcessIOData(native interface{}, currentPath string, basePath string, ioTy
 		if ok {
 
 			var sub_count int
-			sub_count, err = ProcessIOData(file, currentPath, basePath, ioType, shockClient, context, lazyUpload, removeIDField)
+			sub_count, err = ProcessIOData(file, currentPath, basePath, ioType, store, context, lazyUpload, removeIDField)
 			if err != nil {
 				return
 			}
@@ -943,7 +977,7 @@ func ProcessIOData(native interface{}, currentPath string, basePath string, ioTy
 		if ok {
 
 			var sub_count int
-			sub_count, err = ProcessIOData(dir, currentPath, basePath, ioType, shockClient, context, lazyUpload, removeIDField)
+			sub_count, err = ProcessIOData(dir, currentPath, basePath, ioType, store, context, lazyUpload, removeIDField)
 			if err != nil {
 				return
 			}
@@ -960,7 +994,7 @@ func ProcessIOData(native interface{}, currentPath string, basePath string, ioTy
 			if input.Default != nil {
 
 				var sub_count int
-				sub_count, err = ProcessIOData(&input, currentPath, basePath, ioType, shockClient, context, lazyUpload, removeIDField)
+				sub_count, err = ProcessIOData(&input, currentPath, basePath, ioType, store, context, lazyUpload, removeIDField)
 				if err != nil {
 					return
 				}
@@ -974,7 +1008,7 @@ func ProcessIOData(native interface{}, currentPath string, basePath string, ioTy
 			step := &workflow.Steps[step_pos]
 
 			var sub_count int
-			sub_count, err = ProcessIOData(step, currentPath, basePath, ioType, shockClient, context, lazyUpload, removeIDField)
+			sub_count, err = ProcessIOData(step, currentPath, basePath, ioType, store, context, lazyUpload, removeIDField)
 			if err != nil {
 				return
 			}
@@ -989,7 +1023,7 @@ func ProcessIOData(native interface{}, currentPath string, basePath string, ioTy
 			input := &step.In[pos]
 
 			var sub_count int
-			sub_count, err = ProcessIOData(input, currentPath, basePath, ioType, shockClient, context, lazyUpload, removeIDField)
+			sub_count, err = ProcessIOData(input, currentPath, basePath, ioType, store, context, lazyUpload, removeIDField)
 			if err != nil {
 				return
 			}
@@ -1003,7 +1037,7 @@ func ProcessIOData(native interface{}, currentPath string, basePath string, ioTy
 		process, _, err = step.GetProcess(context)
 		if process != nil {
 			var sub_count int
-			sub_count, err = ProcessIOData(process, currentPath, basePath, ioType, shockClient, context, lazyUpload, removeIDField)
+			sub_count, err = ProcessIOData(process, currentPath, basePath, ioType, store, context, lazyUpload, removeIDField)
 			if err != nil {
 				return
 			}
@@ -1018,7 +1052,7 @@ func ProcessIOData(native interface{}, currentPath string, basePath string, ioTy
 		file, ok = input.Default.(*cwl.File)
 		if ok {
 			var sub_count int
-			sub_count, err = ProcessIOData(file, currentPath, basePath, ioType, shockClient, context, lazyUpload, removeIDField)
+			sub_count, err = ProcessIOData(file, currentPath, basePath, ioType, store, context, lazyUpload, removeIDField)
 			if err != nil {
 				return
 			}
@@ -1033,7 +1067,7 @@ func ProcessIOData(native interface{}, currentPath string, basePath string, ioTy
 			commandInputParameter := &clt.Inputs[i]
 
 			var sub_count int
-			sub_count, err = ProcessIOData(commandInputParameter, currentPath, basePath, ioType, shockClient, context, lazyUpload, removeIDField)
+			sub_count, err = ProcessIOData(commandInputParameter, currentPath, basePath, ioType, store, context, lazyUpload, removeIDField)
 			if err != nil {
 				err = fmt.Errorf("(processIOData) CommandLineTool.Default ProcessIOData(for download) returned: %s", err.Error())
 				return
@@ -1046,7 +1080,7 @@ func ProcessIOData(native interface{}, currentPath string, basePath string, ioTy
 			requirement := &clt.Requirements[i]
 
 			var sub_count int
-			sub_count, err = ProcessIOData(requirement, currentPath, basePath, ioType, shockClient, context, lazyUpload, removeIDField)
+			sub_count, err = ProcessIOData(requirement, currentPath, basePath, ioType, store, context, lazyUpload, removeIDField)
 			if err != nil {
 				err = fmt.Errorf("(processIOData) CommandLineTool.Default ProcessIOData(for download) returned: %s", err.Error())
 				return
@@ -1066,7 +1100,7 @@ func ProcessIOData(native interface{}, currentPath string, basePath string, ioTy
 			if input_parameter.Default != nil {
 
 				var sub_count int
-				sub_count, err = ProcessIOData(input_parameter, currentPath, basePath, ioType, shockClient, context, lazyUpload, removeIDField)
+				sub_count, err = ProcessIOData(input_parameter, currentPath, basePath, ioType, store, context, lazyUpload, removeIDField)
 				if err != nil {
 					err = fmt.Errorf("(processIOData) ExpressionTool,InputParameter ProcessIOData(for download) returned: %s", err.Error())
 					return
@@ -1087,7 +1121,7 @@ func ProcessIOData(native interface{}, currentPath string, basePath string, ioTy
 			var ok bool
 			file, ok = cip.Default.(*cwl.File)
 			if ok {
-				if ioType == "upload" {
+				if ioType == "upload" || ioType == "check" {
 					var file_exists bool
 					file_exists, err = file.Exists(currentPath)
 					if err != nil {
@@ -1111,7 +1145,7 @@ func ProcessIOData(native interface{}, currentPath string, basePath string, ioTy
 			}
 
 			var sub_count int
-			sub_count, err = ProcessIOData(cip.Default, currentPath, basePath, ioType, shockClient, context, lazyUpload, removeIDField)
+			sub_count, err = ProcessIOData(cip.Default, currentPath, basePath, ioType, store, context, lazyUpload, removeIDField)
 			if err != nil {
 				err = fmt.Errorf("(processIOData) CommandInputParameter ProcessIOData(for download) returned: %s", err.Error())
 				return
@@ -1208,7 +1242,7 @@ func ProcessIOData(native interface{}, currentPath string, basePath string, ioTy
 		var ok bool
 		file, ok = ip.Default.(*cwl.File)
 		if ok {
-			if ioType == "upload" {
+			if ioType == "upload" || ioType == "check" {
 				var file_exists bool
 				file_exists, err = file.Exists(currentPath)
 				if err != nil {
@@ -1231,7 +1265,7 @@ func ProcessIOData(native interface{}, currentPath string, basePath string, ioTy
 		}
 
 		var sub_count int
-		sub_count, err = ProcessIOData(ip.Default, currentPath, basePath, ioType, shockClient, context, lazyUpload, removeIDField)
+		sub_count, err = ProcessIOData(ip.Default, currentPath, basePath, ioType, store, context, lazyUpload, removeIDField)
 		if err != nil {
 			err = fmt.Errorf("(processIOData) InputParameter ProcessIOData(for download) returned: %s", err.Error())
 			return
@@ -1244,7 +1278,7 @@ func ProcessIOData(native interface{}, currentPath string, basePath string, ioTy
 			work := native.(*core.CWLWorkunit)
 
 			var sub_count int
-			sub_count, err = ProcessIOData(work.JobInput, currentPath, basePath, "download", shockClient, context, lazyUpload, removeIDField)
+			sub_count, err = ProcessIOData(work.JobInput, currentPath, basePath, "download", store, context, lazyUpload, removeIDField)
 			if err != nil {
 				err = fmt.Errorf("(processIOData) work.Job_input ProcessIOData(for download) returned: %s", err.Error())
 				return
@@ -1252,7 +1286,7 @@ func ProcessIOData(native interface{}, currentPath string, basePath string, ioTy
 			count += sub_count
 
 			sub_count = 0
-			sub_count, err = ProcessIOData(work.Tool, currentPath, basePath, "download", shockClient, context, lazyUpload, removeIDField)
+			sub_count, err = ProcessIOData(work.Tool, currentPath, basePath, "download", store, context, lazyUpload, removeIDField)
 			if err != nil {
 				err = fmt.Errorf("(processIOData) work.Tool ProcessIOData(for download) returned: %s", err.Error())
 				return
@@ -1272,7 +1306,7 @@ func ProcessIOData(native interface{}, currentPath string, basePath string, ioTy
 			entry_array := dirent.Entry.([]interface{})
 			for i, _ := range entry_array {
 				sub_count := 0
-				sub_count, err = ProcessIOData(entry_array[i], currentPath, basePath, ioType, shockClient, context, lazyUpload, removeIDField)
+				sub_count, err = ProcessIOData(entry_array[i], currentPath, basePath, ioType, store, context, lazyUpload, removeIDField)
 				if err != nil {
 					err = fmt.Errorf("(processIOData) work.Tool ProcessIOData(for download) returned: %s", err.Error())
 					return
@@ -1283,7 +1317,7 @@ func ProcessIOData(native interface{}, currentPath string, basePath string, ioTy
 		case cwl.File:
 			file := dirent.Entry.(cwl.File)
 			sub_count := 0
-			sub_count, err = ProcessIOData(file, currentPath, basePath, ioType, shockClient, context, lazyUpload, removeIDField)
+			sub_count, err = ProcessIOData(file, currentPath, basePath, ioType, store, context, lazyUpload, removeIDField)
 			if err != nil {
 				err = fmt.Errorf("(processIOData) work.Tool ProcessIOData(for download) returned: %s", err.Error())
 				return
@@ -1292,7 +1326,7 @@ func ProcessIOData(native interface{}, currentPath string, basePath string, ioTy
 		case *cwl.File:
 			file := dirent.Entry.(*cwl.File)
 			sub_count := 0
-			sub_count, err = ProcessIOData(file, currentPath, basePath, ioType, shockClient, context, lazyUpload, removeIDField)
+			sub_count, err = ProcessIOData(file, currentPath, basePath, ioType, store, context, lazyUpload, removeIDField)
 			if err != nil {
 				err = fmt.Errorf("(processIOData) work.Tool ProcessIOData(for download) returned: %s", err.Error())
 				return
@@ -1321,7 +1355,7 @@ func ProcessIOData(native interface{}, currentPath string, basePath string, ioTy
 					for i, _ := range obj_array {
 
 						sub_count := 0
-						sub_count, err = ProcessIOData(obj_array[i], currentPath, basePath, "download", shockClient, context, lazyUpload, removeIDField)
+						sub_count, err = ProcessIOData(obj_array[i], currentPath, basePath, "download", store, context, lazyUpload, removeIDField)
 						if err != nil {
 							err = fmt.Errorf("(processIOData) []cwl.CWLObject cwl.Requirement/Listing returned: %s", err.Error())
 							return
@@ -1330,7 +1364,7 @@ func ProcessIOData(native interface{}, currentPath string, basePath string, ioTy
 					}
 				case cwl.File:
 					sub_count := 0
-					sub_count, err = ProcessIOData(iwdr.Listing, currentPath, basePath, "download", shockClient, context, lazyUpload, removeIDField)
+					sub_count, err = ProcessIOData(iwdr.Listing, currentPath, basePath, "download", store, context, lazyUpload, removeIDField)
 					if err != nil {
 						err = fmt.Errorf("(processIOData) cwl.File cwl.Requirement/Listing  returned: %s", err.Error())
 						return
@@ -1372,7 +1406,7 @@ func ProcessIOData(native interface{}, currentPath string, basePath string, ioTy
 					//this_file.UpdateComponents(schema_str)
 					nativeArray[i] = this_file
 					sub_count := 0
-					sub_count, err = ProcessIOData(this_file, currentPath, basePath, "download", shockClient, context, lazyUpload, removeIDField)
+					sub_count, err = ProcessIOData(this_file, currentPath, basePath, "download", store, context, lazyUpload, removeIDField)
 					if err != nil {
 						err = fmt.Errorf("(processIOData) []cwl.CWLObject cwl.Requirement/Listing returned: %s", err.Error())
 						return
@@ -1400,6 +1434,19 @@ func ProcessIOData(native interface{}, currentPath string, basePath string, ioTy
 	return
 }
 
+// WorkunitStorage returns the storage of a CWL workunit, Shock if the job has no StorageRequirement
+func WorkunitStorage(work *core.Workunit) (store storage.Storage, err error) {
+	if work.CWLWorkunit != nil && work.CWLWorkunit.Storage != nil {
+		store, err = storage.New(work.CWLWorkunit.Storage, work.Info.DataToken)
+		if err != nil {
+			err = fmt.Errorf("(WorkunitStorage) storage.New returned: %s", err.Error())
+		}
+		return
+	}
+	store = storage.NewShockStorage(work.ShockHost, work.Info.DataToken)
+	return
+}
+
 //fetch input data
 func MoveInputData(work *core.Workunit) (size int64, err error) {
 
@@ -1411,13 +1458,18 @@ func MoveInputData(work *core.Workunit) (size int64, err error) {
 
 	if work.CWLWorkunit != nil {
 
-		shockClient := shock.NewShockClient(work.ShockHost, work.Info.DataToken, false)
+		var store storage.Storage
+		store, err = WorkunitStorage(work)
+		if err != nil {
+			err = fmt.Errorf("(MoveInputData) %s", err.Error())
+			return
+		}
 
 		context := cwl.NewWorkflowContext()
 		//context.Init()
 
 		var count int
-		count, err = ProcessIOData(work.CWLWorkunit, work_path, work_path, "download", shockClient, context, false, false)
+		count, err = ProcessIOData(work.CWLWorkunit, work_path, work_path, "download", store, context, false, false)
 		if err != nil {
 			err = fmt.Errorf("(MoveInputData) ProcessIOData(for download) returned: %s", err.Error())
 			return
@@ -1582,7 +1634,7 @@ func UploadOutputIO(work *core.Workunit, io *core.IO) (size int64, new_node_id s
 }
 
 // UploadOutputData _
-func UploadOutputData(work *core.Workunit, shockClient *shock.ShockClient, context *cwl.WorkflowContext) (size int64, err error) {
+func UploadOutputData(work *core.Workunit, store storage.Storage, context *cwl.WorkflowContext) (size int64, err error) {
 
 	if work.CWLWorkunit != nil {
 
@@ -1593,11 +1645,11 @@ func UploadOutputData(work *core.Workunit, shockClient *shock.ShockClient, conte
 
 			//scs.Dump(work.CWL_workunit.Outputs)
 			var upload_count int
-			upload_count, err = ProcessIOData(work.CWLWorkunit.Outputs, "", "", "upload", shockClient, context, false, false)
+			upload_count, err = ProcessIOData(work.CWLWorkunit.Outputs, "", "", "upload", store, context, false, false)
 			if err != nil {
 				err = fmt.Errorf("(UploadOutputData) ProcessIOData returned: %s", err.Error())
 			}
-			logger.Debug(3, "(UploadOutputData) %d files uploaded to shock", upload_count)
+			logger.Debug(3, "(UploadOutputData) %d files uploaded", upload_count)
 			//fmt.Println("Outputs 2")
 			//scs.Dump(work.CWL_workunit.Outputs)
 		}
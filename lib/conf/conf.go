@@ -28,6 +28,12 @@ const DB_COLL_PERF string = "Perf"
 const DB_COLL_CGS string = "ClientGroups"
 const DB_COLL_USERS string = "Users"
 const DB_COLL_SUBWORKFLOWS string = "SubWorkflows"
+const DB_COLL_HISTORY string = "History"
+const DB_COLL_QUOTAS string = "Quotas"
+const DB_COLL_QUOTA_USAGE string = "QuotaUsage"
+const DB_COLL_SCHEDULES string = "Schedules"
+const DB_COLL_CALL_CACHE string = "CallCache"
+const DB_COLL_PROJECTS string = "Projects"
 
 //prefix for site login
 const LOGIN_PREFIX string = "go4711"
@@ -131,6 +137,29 @@ var (
 	MAX_WORK_FAILURE   int
 	MAX_CLIENT_FAILURE int
 	GOMAXPROCS         int
+	WORK_POLICY        string
+
+	PRIORITY_AGING_RATE int
+	PRIORITY_AGING_MAX  int
+	CHECKOUT_WAIT_MAX   int
+
+	QUOTA_WORKUNITS   int
+	QUOTA_QUEUED_JOBS int
+	QUOTA_CPU_HOURS   int
+
+	NOTIFICATION_RETRIES            int
+	NOTIFICATION_RETRY_WAIT_SECONDS int
+	NOTIFICATION_RETRY_WAIT         time.Duration
+	NOTIFICATION_ALLOWED_HOSTS_STR  string
+	NOTIFICATION_ALLOWED_HOSTS      []string
+
+	SCHEDULE_KEEP_RUNS int
+
+	CALL_CACHE bool
+
+	LOG_STREAM_MAX_SIZE int
+
+	DATA_RETENTION string
 
 	// Client
 	WORK_PATH                   string
@@ -150,11 +179,16 @@ var (
 	CLIENT_GROUP   string
 	CLIENT_DOMAIN  string
 	WORKER_OVERLAP bool
+	WORKER_SLOTS   int
 	PRINT_APP_MSG  bool
 	AUTO_CLEAN_DIR bool
 	NO_SYMLINK     bool
 	CACHE_ENABLED  bool
 
+	LOG_STREAM_INTERVAL int
+	WORKER_METRICS_PORT int
+	CHECKOUT_WAIT       int
+
 	CWL_TOOL  string
 	CWL_JOB   string
 	SHOCK_URL string
@@ -172,6 +206,10 @@ var (
 	DOCKER_WORKUNIT_PREDATA_DIR   string
 	SHOCK_DOCKER_IMAGE_REPOSITORY string
 
+	CONTAINER_RUNTIME   string
+	CONTAINER_BINARY    string
+	CONTAINER_IMAGE_DIR string
+
 	// Other
 	ERROR_LENGTH int
 	DEV_MODE     bool
@@ -194,10 +232,26 @@ var (
 	SUBMITTER_AWE_AUTH       string
 	SUBMITTER_UPLOAD_INPUT   bool
 	SUBMITTER_JOB_NAME       string
+	SUBMITTER_VALIDATE       bool
+	SUBMITTER_NO_CACHE       bool
+	SUBMITTER_RETENTION      string
 
 	// WORKER (CWL)
 	CWL_RUNNER_ARGS string
 
+	// Storage (CWL), STORAGE_* are only used by the submitter, workers use the StorageRequirement of the job
+	STORAGE_TYPE   string
+	STORAGE_URL    string
+	STORAGE_BUCKET string
+	STORAGE_REGION string
+	STORAGE_PATH   string
+	S3_ACCESS_KEY  string
+	S3_SECRET_KEY  string
+
+	// directories that jobs may use as root of posix storage (StorageRequirement path), for the submitter its storage_path
+	POSIX_STORAGE_ROOTS_STR string
+	POSIX_STORAGE_ROOTS     []string
+
 	// used to track changes in data structures
 	VERSIONS = make(map[string]int)
 
@@ -337,13 +391,27 @@ func getConfiguration(c *config.Config, mode string) (c_store *Config_store) {
 		// Server
 		c_store.AddString(&TITLE, "AWE Server", "Server", "title", "", "")
 		c_store.AddInt(&COREQ_LENGTH, 100, "Server", "coreq_length", "length of checkout request queue", "")
+		c_store.AddInt(&CHECKOUT_WAIT_MAX, 60, "Server", "checkout_wait_max", "max seconds a checkout request (GET /work?client=&wait=) waits for a workunit, 0 disables long-polling", "")
 		c_store.AddInt(&EXPIRE_WAIT, 60, "Server", "expire_wait", "wait time for expiration reaper in minutes", "")
 		c_store.AddString(&GLOBAL_EXPIRE, "", "Server", "global_expire", "default number and unit of time after job completion before it expires", "")
 		c_store.AddString(&PIPELINE_EXPIRE, "", "Server", "pipeline_expire", "comma seperated list of pipeline_name=expire_days_unit, overrides global_expire", "")
 		c_store.AddBool(&PERF_LOG_WORKUNIT, false, "Server", "perf_log_workunit", "collecting performance log per workunit (not working)", "")
-		c_store.AddInt(&MAX_WORK_FAILURE, 1, "Server", "max_work_failure", "number of times that one workunit fails before the workunit considered suspend", "")
+		c_store.AddInt(&MAX_WORK_FAILURE, 1, "Server", "max_work_failure", "number of times that one workunit fails before the workunit considered suspend", "can be overwritten per task with a retry policy, task field \"retry\" in the job document or the CWL hint RetryRequirement")
 		c_store.AddInt(&MAX_CLIENT_FAILURE, 0, "Server", "max_client_failure", "number of times that one client consecutively fails running workunits before the client considered suspend", "")
 		c_store.AddInt(&GOMAXPROCS, 0, "Server", "go_max_procs", "", "")
+		c_store.AddInt(&QUOTA_WORKUNITS, 0, "Server", "quota_workunits", "default max number of workunits of one user in progress at the same time, 0 means unlimited", "can be overwritten per user and clientgroup, see /queue?quota")
+		c_store.AddInt(&QUOTA_QUEUED_JOBS, 0, "Server", "quota_queued_jobs", "default max number of unfinished jobs of one user, 0 means unlimited", "can be overwritten per user and clientgroup, see /queue?quota")
+		c_store.AddInt(&QUOTA_CPU_HOURS, 0, "Server", "quota_cpu_hours", "default max CPU-hours (compute time x cores) of one user per day, 0 means unlimited", "can be overwritten per user and clientgroup, see /queue?quota")
+		c_store.AddInt(&NOTIFICATION_RETRIES, 5, "Server", "notification_retries", "number of times a failed job notification (webhook) is repeated", "")
+		c_store.AddInt(&NOTIFICATION_RETRY_WAIT_SECONDS, 30, "Server", "notification_retry_wait", "seconds to wait before the first repetition of a failed job notification, doubled after each attempt", "")
+		c_store.AddString(&NOTIFICATION_ALLOWED_HOSTS_STR, "", "Server", "notification_allowed_hosts", "comma separated list of hosts job notifications may be sent to, entries starting with . include subdomains, empty means any host", "loopback, private and link-local addresses are only reachable if their host is listed")
+		c_store.AddInt(&SCHEDULE_KEEP_RUNS, 10, "Server", "schedule_keep_runs", "number of runs (job ids) a recurring job schedule keeps", "can be overwritten per schedule, see /schedule")
+		c_store.AddBool(&CALL_CACHE, true, "Server", "call_cache", "reuse outputs of CommandLineTool steps that already ran with the same tool, docker image and inputs", "can be turned off per job (info.nocache or form field NO_CACHE) and per tool with the CWL requirement WorkReuse, see /cache")
+		c_store.AddInt(&LOG_STREAM_MAX_SIZE, 4096, "Server", "log_stream_max_size", "max KB of stdout and stderr kept per running workunit, older output is dropped", "the complete logs replace the streamed ones when the workunit finishes, see /work/{id}?report=stderr&follow")
+		c_store.AddString(&WORK_POLICY, "FCFS", "Server", "work_policy", "default workunit selection policy: FCFS, FairShareUser, FairShareProject, SJF or Locality", "can be overwritten per clientgroup or checkout request")
+		c_store.AddInt(&PRIORITY_AGING_RATE, 0, "Server", "priority_aging_rate", "priority points a queued workunit gains per hour of waiting, 0 means disabled", "can be overwritten per clientgroup, see /cgroup/{id}?aging_rate=&aging_max=")
+		c_store.AddInt(&PRIORITY_AGING_MAX, 0, "Server", "priority_aging_max", "max priority points gained by waiting, 0 means unlimited", "")
+		c_store.AddString(&DATA_RETENTION, "all", "Server", "data_retention", "default retention of intermediate step outputs of CWL jobs: all, outputs (deleted on job completion) or a time after job completion, e.g. 7D", "can be overwritten per job (info.retention or form field RETENTION), see /job/{id}?retention")
 		c_store.AddString(&RELOAD, "", "Server", "reload", "path or url to awe job data. WARNING this will drop all current jobs", "")
 		c_store.AddBool(&RECOVER, false, "Server", "recover", "load unfinished jobs from mongodb on startup", "")
 		c_store.AddInt(&RECOVER_MAX, 0, "Server", "recover_max", "max number of jobs to recover, default (0) means recover all", "")
@@ -372,6 +440,9 @@ func getConfiguration(c *config.Config, mode string) (c_store *Config_store) {
 
 		c_store.AddString(&SUBMITTER_JOB_NAME, "", "Client", "job_name", "name of job, default is filename", "")
 		c_store.AddBool(&SUBMITTER_UPLOAD_INPUT, false, "Client", "upload_input", "upload job input files into shock and return new job input structure", "")
+		c_store.AddBool(&SUBMITTER_VALIDATE, false, "Client", "validate", "only validate workflow and job input on the server, no job is submitted and no input file is uploaded", "")
+		c_store.AddBool(&SUBMITTER_NO_CACHE, false, "Client", "no_cache", "do not reuse outputs of previous runs (call cache), all steps of the job are computed", "")
+		c_store.AddString(&SUBMITTER_RETENTION, "", "Client", "retention", "retention of intermediate outputs of the job: all, outputs or a time after completion (e.g. 7D), default is data_retention of the server", "")
 		//c_store.AddString(&SUBMITTER_AUTH_DATATOKEN, "", "Client", "shock_auth_bearer", "bearer for shock", "")
 	}
 
@@ -397,7 +468,11 @@ func getConfiguration(c *config.Config, mode string) (c_store *Config_store) {
 		c_store.AddString(&PRE_WORK_SCRIPT_ARGS_STRING, "", "Client", "pre_work_script_args", "", "")
 
 		c_store.AddBool(&PRINT_APP_MSG, true, "Client", "print_app_msg", "collect stdout/stderr for apps", "")
+		c_store.AddInt(&WORKER_METRICS_PORT, 0, "Client", "metrics_port", "port of the metrics listener (GET /metrics, Prometheus text format), 0 means disabled", "")
+		c_store.AddInt(&LOG_STREAM_INTERVAL, 30, "Client", "log_stream_interval", "seconds between uploads of new stdout/stderr output of a running workunit, 0 means disabled", "")
+		c_store.AddInt(&CHECKOUT_WAIT, 30, "Client", "checkout_wait", "seconds the server may hold a checkout request until a workunit is queued (long-poll), 0 means polling", "the server limits this to its checkout_wait_max")
 		c_store.AddBool(&WORKER_OVERLAP, false, "Client", "worker_overlap", "overlap client side computation and data movement", "")
+		c_store.AddInt(&WORKER_SLOTS, 1, "Client", "slots", "number of workunits to run concurrently", "each workunit reserves the cores and RAM requested by its ResourceRequirement (default: 1 core)")
 		c_store.AddBool(&AUTO_CLEAN_DIR, true, "Client", "auto_clean_dir", "delete workunit directory to save space after completion, turn of for debugging", "")
 		c_store.AddBool(&CACHE_ENABLED, false, "Client", "cache_enabled", "", "")
 		c_store.AddBool(&NO_SYMLINK, false, "Client", "no_symlink", "copy files from predata to work dir, default is to create symlink", "")
@@ -406,14 +481,33 @@ func getConfiguration(c *config.Config, mode string) (c_store *Config_store) {
 
 	}
 
+	// Storage
+	if mode == "submitter" {
+		c_store.AddString(&STORAGE_TYPE, "shock", "Storage", "storage", "storage for input and output files: shock, s3 or posix", "shock uses --shockurl, the choice is added to the workflow as StorageRequirement")
+		c_store.AddString(&STORAGE_URL, "", "Storage", "storage_url", "endpoint of the S3-compatible server, e.g. http://minio:9000", "")
+		c_store.AddString(&STORAGE_BUCKET, "", "Storage", "storage_bucket", "S3 bucket", "")
+		c_store.AddString(&STORAGE_REGION, "us-east-1", "Storage", "storage_region", "S3 region", "")
+		c_store.AddString(&STORAGE_PATH, "", "Storage", "storage_path", "key prefix (s3) or directory on a filesystem shared with the workers (posix)", "")
+	}
+	if mode == "server" || mode == "worker" {
+		c_store.AddString(&POSIX_STORAGE_ROOTS_STR, "", "Storage", "posix_storage_roots", "comma separated list of directories that may be used as posix storage, empty means posix storage is disabled", "the path of the StorageRequirement of a job must be one of them or a subdirectory")
+	}
+	if mode == "worker" || mode == "submitter" {
+		c_store.AddString(&S3_ACCESS_KEY, "", "Storage", "s3_access_key", "access key for S3-compatible storage, default is $AWS_ACCESS_KEY_ID", "")
+		c_store.AddString(&S3_SECRET_KEY, "", "Storage", "s3_secret_key", "secret key for S3-compatible storage, default is $AWS_SECRET_ACCESS_KEY", "")
+	}
+
 	// Docker
 	if mode == "server" || mode == "worker" {
 		c_store.AddString(&USE_DOCKER, "yes", "Docker", "use_docker", "\"yes\", \"no\" or \"only\"", "yes: allow docker tasks, no: do not allow docker tasks, only: allow only docker tasks; if docker is not installed on the clients, choose \"no\"")
 	}
 	if mode == "worker" {
+		c_store.AddString(&CONTAINER_RUNTIME, "docker", "Docker", "container_runtime", "runtime for tasks with a docker image: docker, podman or apptainer", "podman and apptainer are called via their command line and do not need a daemon, apptainer images are converted to SIF files in container_image_dir. apptainer does not detect OOM kills, they fail with exit status 137")
+		c_store.AddString(&CONTAINER_BINARY, "", "Docker", "container_binary", "binary of podman or apptainer, default is the name of the runtime (e.g. use singularity for older installations)", "")
+		c_store.AddString(&CONTAINER_IMAGE_DIR, "", "Docker", "container_image_dir", "directory for the SIF images of apptainer, default is <data>/images", "")
 		c_store.AddString(&DOCKER_BINARY, "API", "Docker", "docker_binary", "docker binary to use, default is the docker API (API recommended)", "")
 		c_store.AddInt(&MEM_CHECK_INTERVAL_SECONDS, 0, "Docker", "mem_check_interval_seconds", "memory check interval in seconds (kernel needs to support that)", "0 seconds means disabled")
-		c_store.AddString(&CGROUP_MEMORY_DOCKER_DIR, "/sys/fs/cgroup/memory/docker/[ID]/memory.stat", "Docker", "cgroup_memory_docker_dir", "path to cgroup directory for docker", "")
+		c_store.AddString(&CGROUP_MEMORY_DOCKER_DIR, "/sys/fs/cgroup/memory/docker/[ID]/memory.stat", "Docker", "cgroup_memory_docker_dir", "path to cgroup directory for docker", "if it does not exist, the default locations of cgroup v1 and v2 (e.g. /sys/fs/cgroup/system.slice/docker-[ID].scope/memory.stat) are tried")
 		c_store.AddString(&DOCKER_SOCKET, "unix:///var/run/docker.sock", "Docker", "docker_socket", "docker socket path", "")
 		c_store.AddString(&DOCKER_WORK_DIR, "/workdir/", "Docker", "docker_workpath", "work dir in docker container started by client", "")
 		c_store.AddString(&DOCKER_WORKUNIT_PREDATA_DIR, "/db/", "Docker", "docker_data", "predata dir in docker container started by client", "")
@@ -561,6 +655,43 @@ func Init_conf(mode string) (err error) {
 				return errors.New("expiration format in global_expire is invalid")
 			}
 		}
+		if DATA_RETENTION != "all" && DATA_RETENTION != "outputs" {
+			if valid, _, _ := parseExpiration(DATA_RETENTION); !valid {
+				return errors.New("data_retention must be all, outputs or a number and unit of time (M, H or D)")
+			}
+		}
+		NOTIFICATION_RETRY_WAIT = time.Duration(NOTIFICATION_RETRY_WAIT_SECONDS) * time.Second
+		NOTIFICATION_ALLOWED_HOSTS = []string{}
+		for _, host := range strings.Split(NOTIFICATION_ALLOWED_HOSTS_STR, ",") {
+			if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+				NOTIFICATION_ALLOWED_HOSTS = append(NOTIFICATION_ALLOWED_HOSTS, host)
+			}
+		}
+	}
+
+	POSIX_STORAGE_ROOTS = []string{}
+	if mode == "submitter" {
+		if STORAGE_TYPE == "posix" && STORAGE_PATH != "" {
+			POSIX_STORAGE_ROOTS = append(POSIX_STORAGE_ROOTS, filepath.Clean(STORAGE_PATH))
+		}
+	} else {
+		for _, root := range strings.Split(POSIX_STORAGE_ROOTS_STR, ",") {
+			if root = strings.TrimSpace(root); root == "" {
+				continue
+			}
+			if !filepath.IsAbs(root) {
+				return fmt.Errorf("posix_storage_roots must be absolute paths: %s", root)
+			}
+			POSIX_STORAGE_ROOTS = append(POSIX_STORAGE_ROOTS, filepath.Clean(root))
+		}
+	}
+
+	if mode == "worker" {
+		switch CONTAINER_RUNTIME {
+		case "docker", "podman", "apptainer", "singularity":
+		default:
+			return fmt.Errorf("\"%s\" is invalid option for container_runtime, use one of: docker, podman, apptainer", CONTAINER_RUNTIME)
+		}
 	}
 
 	if SERVER_URL != "" {
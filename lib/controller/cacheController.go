@@ -0,0 +1,152 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/MG-RAST/AWE/lib/conf"
+	"github.com/MG-RAST/AWE/lib/core"
+	"github.com/MG-RAST/AWE/lib/user"
+	"github.com/MG-RAST/golib/goweb"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// CacheController call cache entries, outputs of CommandLineTool steps that are reused by later jobs of the same user
+type CacheController struct{}
+
+// cacheQuery users only see their own entries, admins all (or those of ?owner=)
+func cacheQuery(u *user.User, query *Query) (q bson.M) {
+	q = bson.M{}
+	if u.Admin == false {
+		q["owner"] = u.Uuid
+	} else if query.Has("owner") {
+		q["owner"] = query.Value("owner")
+	}
+	if query.Has("job") {
+		q["job_id"] = query.Value("job")
+	}
+	if query.Has("tool") {
+		q["tool"] = query.Value("tool")
+	}
+	return
+}
+
+// OPTIONS: /cache
+func (cr *CacheController) Options(cx *goweb.Context) {
+	LogRequest(cx.Request)
+	cx.RespondWithOK()
+	return
+}
+
+// GET: /cache/{key}
+func (cr *CacheController) Read(key string, cx *goweb.Context) {
+	LogRequest(cx.Request)
+
+	u, done := GetAuthorizedUser(cx)
+	if done {
+		return
+	}
+
+	q := cacheQuery(u, &Query{Li: cx.Request.URL.Query()})
+	q["key"] = key
+
+	entries := core.CallCacheEntries{}
+	total, err := entries.GetPaginated(q, conf.DEFAULT_PAGE_SIZE, 0, "created_on", "desc")
+	if err != nil {
+		cx.RespondWithErrorMessage(err.Error(), http.StatusBadRequest)
+		return
+	}
+	if total == 0 {
+		cx.RespondWithNotFound()
+		return
+	}
+
+	cx.RespondWithData(entries)
+	return
+}
+
+// GET: /cache?job=<job_id>&tool=<tool_id>
+func (cr *CacheController) ReadMany(cx *goweb.Context) {
+	LogRequest(cx.Request)
+
+	u, done := GetAuthorizedUser(cx)
+	if done {
+		return
+	}
+
+	query := &Query{Li: cx.Request.URL.Query()}
+	q := cacheQuery(u, query)
+
+	var err error
+	limit := conf.DEFAULT_PAGE_SIZE
+	offset := 0
+	if query.Has("limit") {
+		if limit, err = strconv.Atoi(query.Value("limit")); err != nil {
+			cx.RespondWithErrorMessage(err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if query.Has("offset") {
+		if offset, err = strconv.Atoi(query.Value("offset")); err != nil {
+			cx.RespondWithErrorMessage(err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	entries := core.CallCacheEntries{}
+	total, err := entries.GetPaginated(q, limit, offset, "created_on", "desc")
+	if err != nil {
+		cx.RespondWithErrorMessage(err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cx.RespondWithPaginatedData(entries, limit, offset, total)
+	return
+}
+
+// DELETE: /cache/{key}
+func (cr *CacheController) Delete(key string, cx *goweb.Context) {
+	LogRequest(cx.Request)
+
+	u, done := GetAuthorizedUser(cx)
+	if done {
+		return
+	}
+
+	q := cacheQuery(u, &Query{Li: cx.Request.URL.Query()})
+	q["key"] = key
+
+	count, err := core.DeleteCallCacheEntries(q)
+	if err != nil {
+		cx.RespondWithErrorMessage(err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if count == 0 {
+		cx.RespondWithNotFound()
+		return
+	}
+
+	cx.RespondWithData("call cache entry deleted: " + key)
+	return
+}
+
+// DELETE: /cache?job=<job_id>&tool=<tool_id>, purges all matching entries of the user
+func (cr *CacheController) DeleteMany(cx *goweb.Context) {
+	LogRequest(cx.Request)
+
+	u, done := GetAuthorizedUser(cx)
+	if done {
+		return
+	}
+
+	q := cacheQuery(u, &Query{Li: cx.Request.URL.Query()})
+
+	count, err := core.DeleteCallCacheEntries(q)
+	if err != nil {
+		cx.RespondWithErrorMessage(err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	cx.RespondWithData(map[string]int{"deleted": count})
+	return
+}
@@ -281,6 +281,19 @@ func (cr *ClientController) Update(id string, cx *goweb.Context) {
 		}
 		return
 	}
+	// commands for the worker, delivered with the next heartbeat, the result shows up in command_results of the client
+	for _, op := range core.ClientCommands {
+		if !query.Has(op) {
+			continue
+		}
+		cmd, err := core.QMgr.SendClientCommandByUser(id, op, query.Value(op), u)
+		if err != nil {
+			cx.RespondWithErrorMessage(err.Error(), http.StatusBadRequest)
+		} else {
+			cx.RespondWithData(cmd)
+		}
+		return
+	}
 	cx.RespondWithError(http.StatusNotImplemented)
 	return
 }
@@ -98,7 +98,8 @@ func (cr *ClientGroupController) Read(id string, cx *goweb.Context) {
 	// The other possibility is that public read of clientgroups is enabled and the clientgroup is publicly readable.
 	rights := cg.ACL.Check(u.Uuid)
 	public_rights := cg.ACL.Check("public")
-	if (u.Uuid != "public" && (cg.ACL.Owner == u.Uuid || rights["read"] == true || u.Admin == true || public_rights["read"] == true)) ||
+	project_rights := core.ClientGroupProjectRights(u, cg.Name)
+	if (u.Uuid != "public" && (cg.ACL.Owner == u.Uuid || rights["read"] == true || u.Admin == true || public_rights["read"] == true || project_rights["read"] == true)) ||
 		(u.Uuid == "public" && conf.ANON_CG_READ == true && public_rights["read"] == true) {
 		cx.RespondWithData(cg)
 		return
@@ -235,7 +236,8 @@ func (cr *ClientGroupController) Delete(id string, cx *goweb.Context) {
 	// The other possibility is that public deletion of clientgroups is enabled and the clientgroup is publicly deletable.
 	rights := cg.ACL.Check(u.Uuid)
 	public_rights := cg.ACL.Check("public")
-	if (u.Uuid != "public" && (cg.ACL.Owner == u.Uuid || rights["delete"] == true || u.Admin == true || public_rights["delete"] == true)) ||
+	project_rights := core.ClientGroupProjectRights(u, cg.Name)
+	if (u.Uuid != "public" && (cg.ACL.Owner == u.Uuid || rights["delete"] == true || u.Admin == true || public_rights["delete"] == true || project_rights["delete"] == true)) ||
 		(u.Uuid == "public" && conf.ANON_CG_DELETE == true && public_rights["delete"] == true) {
 		err := core.DeleteClientGroup(id)
 		if err != nil {
@@ -249,3 +251,99 @@ func (cr *ClientGroupController) Delete(id string, cx *goweb.Context) {
 	cx.RespondWithErrorMessage(e.UnAuth, http.StatusUnauthorized)
 	return
 }
+
+// PUT: /cgroup/{id}?policy=<name>
+func (cr *ClientGroupController) Update(id string, cx *goweb.Context) {
+	LogRequest(cx.Request)
+
+	// Try to authenticate user.
+	u, err := request.Authenticate(cx.Request)
+	if err != nil && err.Error() != e.NoAuth {
+		cx.RespondWithErrorMessage(err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	// If no auth was provided and ANON_CG_WRITE is true, use the public user.
+	// Otherwise if no auth was provided, throw an error.
+	// Otherwise, proceed with modification of the clientgroup using the user.
+	if u == nil {
+		if conf.ANON_CG_WRITE == true {
+			u = &user.User{Uuid: "public"}
+		} else {
+			cx.RespondWithErrorMessage(e.UnAuth, http.StatusUnauthorized)
+			return
+		}
+	}
+
+	// Load clientgroup by id
+	cg, err := core.LoadClientGroup(id)
+
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			cx.RespondWithNotFound()
+		} else {
+			// In theory the db connection could be lost between
+			// checking user and load but seems unlikely.
+			cx.RespondWithErrorMessage("clientgroup id not found:"+id, http.StatusBadRequest)
+		}
+		return
+	}
+
+	// User must have write permissions on clientgroup or be clientgroup owner or be an admin or the clientgroup is publicly writable.
+	// The other possibility is that public write of clientgroups is enabled and the clientgroup is publicly writable.
+	rights := cg.ACL.Check(u.Uuid)
+	public_rights := cg.ACL.Check("public")
+	project_rights := core.ClientGroupProjectRights(u, cg.Name)
+	if !((u.Uuid != "public" && (cg.ACL.Owner == u.Uuid || rights["write"] == true || u.Admin == true || public_rights["write"] == true || project_rights["write"] == true)) ||
+		(u.Uuid == "public" && conf.ANON_CG_WRITE == true && public_rights["write"] == true)) {
+		cx.RespondWithErrorMessage(e.UnAuth, http.StatusUnauthorized)
+		return
+	}
+
+	// Gather query params
+	query := &Query{Li: cx.Request.URL.Query()}
+
+	if query.Has("policy") {
+		policy := query.Value("policy")
+		if policy != "" && !core.IsValidWorkPolicy(policy) {
+			cx.RespondWithErrorMessage("unknown policy: "+policy+" (supported: "+strings.Join(core.WorkPolicies, ", ")+")", http.StatusBadRequest)
+			return
+		}
+		cg.Policy = policy
+		if err = cg.Save(); err != nil {
+			cx.RespondWithErrorMessage("Could not save clientgroup.", http.StatusInternalServerError)
+			return
+		}
+		cx.RespondWithData(cg)
+		return
+	}
+
+	// priority aging: empty aging_rate means server default, 0 disables aging for this clientgroup
+	if query.Has("aging_rate") {
+		if query.Value("aging_rate") == "" {
+			cg.Aging = nil
+		} else {
+			aging := &core.AgingPolicy{}
+			if aging.Rate, err = strconv.Atoi(query.Value("aging_rate")); err != nil || aging.Rate < 0 {
+				cx.RespondWithErrorMessage("aging_rate must be a non-negative integer", http.StatusBadRequest)
+				return
+			}
+			if query.Has("aging_max") {
+				if aging.Max, err = strconv.Atoi(query.Value("aging_max")); err != nil || aging.Max < 0 {
+					cx.RespondWithErrorMessage("aging_max must be a non-negative integer", http.StatusBadRequest)
+					return
+				}
+			}
+			cg.Aging = aging
+		}
+		if err = cg.Save(); err != nil {
+			cx.RespondWithErrorMessage("Could not save clientgroup.", http.StatusInternalServerError)
+			return
+		}
+		cx.RespondWithData(cg)
+		return
+	}
+
+	cx.RespondWithErrorMessage("requested clientgroup update operation not supported", http.StatusBadRequest)
+	return
+}
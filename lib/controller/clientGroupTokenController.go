@@ -58,7 +58,8 @@ var ClientGroupTokenController goweb.ControllerFunc = func(cx *goweb.Context) {
 	// The other possibility is that public write of clientgroups is enabled and the clientgroup is publicly writable.
 	rights := cg.ACL.Check(u.Uuid)
 	public_rights := cg.ACL.Check("public")
-	if (u.Uuid != "public" && (cg.ACL.Owner == u.Uuid || rights["write"] == true || u.Admin == true || public_rights["write"] == true)) ||
+	project_rights := core.ClientGroupProjectRights(u, cg.Name)
+	if (u.Uuid != "public" && (cg.ACL.Owner == u.Uuid || rights["write"] == true || u.Admin == true || public_rights["write"] == true || project_rights["write"] == true)) ||
 		(u.Uuid == "public" && conf.ANON_CG_WRITE == true && public_rights["write"] == true) {
 
 		switch cx.Request.Method {
@@ -6,6 +6,7 @@ import (
 
 type ServerController struct {
 	Awf               *AwfController
+	Cache             *CacheController
 	Client            *ClientController
 	ClientGroup       *ClientGroupController
 	ClientGroupAcl    map[string]goweb.ControllerFunc
@@ -13,7 +14,9 @@ type ServerController struct {
 	Job               *JobController
 	JobAcl            map[string]goweb.ControllerFunc
 	Logger            *LoggerController
+	Project           *ProjectController
 	Queue             *QueueController
+	Schedule          *ScheduleController
 	Work              *WorkController
 	WorkflowInstances *WorkflowInstancesController
 }
@@ -21,6 +24,7 @@ type ServerController struct {
 func NewServerController() *ServerController {
 	return &ServerController{
 		Awf:               new(AwfController),
+		Cache:             new(CacheController),
 		Client:            new(ClientController),
 		ClientGroup:       new(ClientGroupController),
 		ClientGroupAcl:    map[string]goweb.ControllerFunc{"base": ClientGroupAclController, "typed": ClientGroupAclControllerTyped},
@@ -28,7 +32,9 @@ func NewServerController() *ServerController {
 		Job:               new(JobController),
 		JobAcl:            map[string]goweb.ControllerFunc{"base": JobAclController, "typed": JobAclControllerTyped},
 		Logger:            new(LoggerController),
+		Project:           new(ProjectController),
 		Queue:             new(QueueController),
+		Schedule:          new(ScheduleController),
 		Work:              new(WorkController),
 		WorkflowInstances: new(WorkflowInstancesController),
 	}
@@ -67,7 +67,7 @@ var JobAclController goweb.ControllerFunc = func(cx *goweb.Context) {
 	//       be "public" when anonymous job creation (ANON_WRITE) is enabled in AWE config.
 
 	rights := acl.Check(u.Uuid)
-	if acl.Owner != u.Uuid && u.Admin == false && acl.Owner != "public" && rights["read"] == false {
+	if acl.Owner != u.Uuid && u.Admin == false && acl.Owner != "public" && rights["read"] == false && core.JobProjectRights(u, jid)["read"] == false {
 		cx.RespondWithErrorMessage(e.UnAuth, http.StatusUnauthorized)
 		return
 	}
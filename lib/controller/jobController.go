@@ -1,15 +1,15 @@
 package controller
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"reflect"
+	"os"
 
 	"github.com/MG-RAST/AWE/lib/conf"
 	"github.com/MG-RAST/AWE/lib/core"
-	"github.com/MG-RAST/AWE/lib/core/cwl"
 	e "github.com/MG-RAST/AWE/lib/errors"
 
 	//"github.com/MG-RAST/AWE/lib/foreign/taverna"
@@ -23,7 +23,6 @@ import (
 
 	//"os"
 
-	"path"
 	"strconv"
 	"strings"
 	"time"
@@ -82,6 +81,41 @@ func (cr *JobController) Create(cx *goweb.Context) {
 	cwlFile, hasCWL := files["cwl"] // TODO I could overload 'upload'
 	jobFile, hasJob := files["job"] // input data for an CWL workflow
 
+	// dry run, reports all problems found in the submission, nothing is saved or enqueued
+	query := &Query{Li: cx.Request.URL.Query()}
+	if query.Has("validate") {
+		defer func() {
+			for _, file := range files {
+				os.Remove(file.Path)
+			}
+		}()
+
+		var report *core.ValidationReport
+		if hasCWL {
+			var yamlstream, jobStream []byte
+			yamlstream, err = ioutil.ReadFile(cwlFile.Path)
+			if err != nil {
+				cx.RespondWithErrorMessage("(JobController/Create) error in reading workflow file: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if hasJob {
+				jobStream, err = ioutil.ReadFile(jobFile.Path)
+				if err != nil {
+					cx.RespondWithErrorMessage("(JobController/Create) error in reading job yaml/json file: "+err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+			report = core.ValidateCWLSubmission(yamlstream, cwlFile.Name, jobStream, params["entrypoint"])
+		} else if hasUpload {
+			report = core.ValidateJobUpload(files)
+		} else {
+			cx.RespondWithErrorMessage("No job script or cwl workflow is submitted", http.StatusBadRequest)
+			return
+		}
+		cx.RespondWithData(report)
+		return
+	}
+
 	var job *core.Job
 	job = nil
 
@@ -105,31 +139,15 @@ func (cr *JobController) Create(cx *goweb.Context) {
 		cwlWorkflowFileName := cwlFile.Name
 
 		//cwlWorkflowFileBase := path.Base(cwlWorkflowFileName)
-		//1) parse job
-
-		var jobInput *cwl.Job_document
-
+		var jobStream []byte
 		if hasJob {
-			jobStream, err := ioutil.ReadFile(jobFile.Path)
+			jobStream, err = ioutil.ReadFile(jobFile.Path)
 			if err != nil {
 				cx.RespondWithErrorMessage("(JobController/Create) error in reading job yaml/json file: "+err.Error(), http.StatusBadRequest)
 				return
 			}
-
-			//job_str := string(job_stream[:])
-
-			jobInput, err = cwl.ParseJob(&jobStream)
-			if err != nil {
-				logger.Error("ParseJob: " + err.Error())
-				cx.RespondWithErrorMessage("(JobController/Create) error in reading job yaml/json file: "+err.Error(), http.StatusBadRequest)
-				return
-			}
-		} else {
-			jobInput = &cwl.Job_document{} // no input
 		}
-		//collection.Job_input = job_input
 
-		// 2) parse cwl
 		logger.Debug(1, "got CWL")
 
 		// get CWL as byte[]
@@ -140,459 +158,14 @@ func (cr *JobController) Create(cx *goweb.Context) {
 			return
 		}
 
-		// convert CWL to string
-		yamlStr := string(yamlstream[:])
-
-		//fmt.Println("yamlStr:")
-		//fmt.Println(yamlStr)
-		//panic("done")
-
-		var schemata []cwl.CWLType_Type
-		var objectArray []cwl.NamedCWLObject
-		//var cwl_version cwl.CWLVersion
-		var context *cwl.WorkflowContext
-		//var namespaces map[string]string
-		//var schemas []interface{}
-
-		entrypoint, ok := params["entrypoint"]
-		if !ok {
-			entrypoint = "#main"
-		}
-		if entrypoint == "" {
-			entrypoint = "#main"
-		}
-
-		var newEntrypoint string
-		// the returning entrypoint should always be empty because only graph documnets are submitted by the submitter
-		objectArray, schemata, context, _, newEntrypoint, err = cwl.ParseCWLDocument(nil, yamlStr, entrypoint, "-", "#"+cwlWorkflowFileName) // TODO need filename. last argument
-		if err != nil {
-			cx.RespondWithErrorMessage(fmt.Sprintf("(JobController/Create) error in parsing cwl workflow yaml file (entrypoint: %s): %s", entrypoint, err.Error()), http.StatusBadRequest)
-			return
-		}
-
-		if newEntrypoint != "" {
-			cx.RespondWithErrorMessage(fmt.Sprintf("(JobController/Create) only graph documents supported currently"), http.StatusBadRequest)
-			return
-		}
-
-		hasWorkflow := false
-
-		// find entrypoint object
-		entrypointIndex := -1
-
-		for i := range objectArray {
-			pair := objectArray[i]
-			object := pair.Value
-			_, isWf := object.(*cwl.Workflow)
-			if isWf {
-				hasWorkflow = true
-			}
-
-			objectID := pair.ID
-
-			if objectID == entrypoint {
-				entrypointIndex = i
-			}
-
-		}
-
-		if entrypointIndex == -1 {
-			cx.RespondWithErrorMessage(fmt.Sprintf("(JobController/Create) entrypoint %s not found", entrypoint), http.StatusBadRequest)
-			return
-		}
-
-		//err = context.AddArray(objectArray)
-		//if err != nil {
-		//	logger.Error("Parse_cwl_document error: " + err.Error())
-		//	cx.RespondWithErrorMessage("error in adding cwl objects to collection: "+err.Error(), http.StatusBadRequest)
-		//	return
-		//}
-		//logger.Debug(1, "Parse_cwl_document done")
-
-		err = context.AddSchemata(schemata, true)
-		if err != nil {
-			cx.RespondWithErrorMessage("error in adding schemata: "+err.Error(), http.StatusBadRequest)
-			return
-		}
-
-		var shockRequirement *cwl.ShockRequirement
-		shockRequirement = nil
-
-		var cwlWorkflow *cwl.Workflow
-
-		logger.Debug(3, "(JobController/Create) context.WorkflowCount: %d", context.WorkflowCount)
-		//spew.Dump(object_array)
-		//panic("done")
-		if !hasWorkflow {
-			// This probably is a simple CommandlineTool or ExpressionTool submission (without workflow)
-			// create new Workflow to wrap around the CommandLineTool/ExpressionTool
-
-			// if len(objectArray) != 1 {
-
-			// 	cx.RespondWithErrorMessage(fmt.Sprintf("Expected exactly one element in objectArray, got %d", len(objectArray)), http.StatusBadRequest)
-			// 	return
-			// }
-
-			wrapperEntrypoint := "#entrypoint"
-
-			// find entrypoint object
-
-			pair := objectArray[entrypointIndex]
-
-			runner := pair.Value
-
-			switch runner.(type) {
-			case *cwl.Workflow:
-				workflow := runner.(*cwl.Workflow)
-				workflow.CwlVersion = context.CwlVersion
-
-			case *cwl.CommandLineTool:
-				entrypoint = wrapperEntrypoint
-				commandlinetoolIf := pair.Value
-
-				commandlinetool, ok := commandlinetoolIf.(*cwl.CommandLineTool)
-				if !ok {
-
-					cx.RespondWithErrorMessage(fmt.Sprintf("(job/create) Error casting CommandLineTool (type: %s)", reflect.TypeOf(commandlinetoolIf)), http.StatusBadRequest)
-					return
-				}
-
-				if shockRequirement == nil {
-					shockRequirement, err = cwl.GetShockRequirement(commandlinetool.Requirements)
-					if err != nil {
-						logger.Debug(1, "(job/create) GetShockRequirement returned: %s", err.Error())
-						shockRequirement = nil
-					}
-				}
-
-				cwlWorkflowInstance := cwl.NewWorkflowEmpty()
-				cwlWorkflow = &cwlWorkflowInstance
-				cwlWorkflow.ID = wrapperEntrypoint
-				cwlWorkflow.CwlVersion = context.CwlVersion
-				cwlWorkflow.Namespaces = context.Namespaces
-				newStep := cwl.WorkflowStep{}
-				stepID := wrapperEntrypoint + "/wrapper_step"
-				newStep.ID = stepID
-				for _, input := range commandlinetool.Inputs { // input is CommandInputParameter
-
-					workflowInputName := wrapperEntrypoint + "/" + path.Base(input.ID) // e.g. #entrypoint/reference
-
-					var workflowStepInput cwl.WorkflowStepInput
-					workflowStepInput.ID = stepID + "/" + path.Base(input.ID)
-					workflowStepInput.Source = workflowInputName
-					workflowStepInput.Default = input.Default
-
-					//fmt.Println("CommandInputParameter and WorkflowStepInput:")
-					//spew.Dump(input)
-					//spew.Dump(workflow_step_input)
-					newStep.In = append(newStep.In, workflowStepInput)
-
-					var workflowInputParameter cwl.InputParameter
-					workflowInputParameter.ID = workflowInputName
-					workflowInputParameter.SecondaryFiles = input.SecondaryFiles
-					workflowInputParameter.Format = input.Format
-					workflowInputParameter.Streamable = input.Streamable
-					workflowInputParameter.InputBinding = input.InputBinding
-					workflowInputParameter.Type = input.Type
-
-					workflowInputParameter.Default = input.Default
-
-					addNull := false
-					if input.Default != nil { // check if this is an optional argument
-						addNull = true
-					}
-
-					if addNull {
-						hasNull := false
-
-						var workflowInputParameterTypes []cwl.CWLType_Type
-
-						workflowInputParameterTypes, err = workflowInputParameter.GetTypes()
-						if err != nil {
-							err = fmt.Errorf("(job/create) (B) workflowInputParameter.GetTypes returned: %s ", err.Error())
-							return
-						}
-						if len(workflowInputParameterTypes) == 0 {
-							err = fmt.Errorf("(job/create) (B) workflowInputParameterTypes empty ")
-							return
-						}
-
-					THISLOOP:
-						for _, t := range workflowInputParameterTypes {
-
-							if t == cwl.CWLNull {
-								hasNull = true
-								break THISLOOP
-							}
-						}
-
-						// for _, t := range workflowInputParameter.Type {
-						// 	if t == cwl.CWLNull {
-						// 		hasNull = true
-						// 		break
-						// 	}
-						// }
-						if !hasNull {
-
-							workflowInputParameter.Type = append(workflowInputParameterTypes, cwl.CWLNull)
-
-						}
-					}
-
-					cwlWorkflow.Inputs = append(cwlWorkflow.Inputs, workflowInputParameter)
-				}
-
-				for _, output := range commandlinetool.Outputs {
-					var workflowStepOutput cwl.WorkflowStepOutput
-					workflowStepOutput.Id = stepID + "/" + path.Base(output.Id)
-
-					newStep.Out = append(newStep.Out, workflowStepOutput)
-
-					var workflowOutputParameter cwl.WorkflowOutputParameter
-
-					workflowOutputParameter.Id = wrapperEntrypoint + "/" + path.Base(output.Id)
-
-					workflowOutputParameter.OutputSource = stepID + "/" + path.Base(output.Id)
-					workflowOutputParameter.SecondaryFiles = output.SecondaryFiles
-					workflowOutputParameter.Format = output.Format
-					workflowOutputParameter.Streamable = output.Streamable
-					//workflowOutputParameter.OutputBinding = output.OutputBinding
-					//workflowOutputParameter.OutputSource = output.OutputSource
-					//workflowOutputParameter.LinkMerge = output.LinkMerge
-					workflowOutputParameter.Type = output.Type
-					cwlWorkflow.Outputs = append(cwlWorkflow.Outputs, workflowOutputParameter)
-				}
-
-				if commandlinetool.Requirements != nil {
-					requirements := commandlinetool.Requirements
-					for i := range requirements {
-						requireType := (requirements)[i].GetClass()
-						if requireType == "ShockRequirement" {
-							shockRequirement := (requirements)[i]
-
-							cwlWorkflow.Requirements, err = cwl.AddRequirement(shockRequirement, requirements)
-							if err != nil {
-								err = fmt.Errorf("(job/create) AddRequirement returned: %s", err.Error())
-								cx.RespondWithErrorMessage(fmt.Sprintf("(job/create) Error in AddRequirement: %s", err.Error()), http.StatusBadRequest)
-								return
-							}
-						}
-					}
-				}
-
-				newStep.Run = commandlinetool.ID
-
-				cwlWorkflow.Steps = []cwl.WorkflowStep{newStep}
-
-				cwlWorkflowNamed := cwl.NamedCWLObject{}
-				cwlWorkflowNamed.ID = cwlWorkflow.ID
-				cwlWorkflowNamed.Value = cwlWorkflow
-
-				objectArray = append(objectArray, cwlWorkflowNamed)
-				//err = context.Add(entrypoint, cwlWorkflow, "job/create")
-				//if err != nil {
-				//	cx.RespondWithErrorMessage("collection.Add returned: "+err.Error(), http.StatusBadRequest)
-				//	return
-				//}
-
-			case *cwl.ExpressionTool:
-				entrypoint = wrapperEntrypoint
-				expressiontoolIf := pair.Value
-
-				expressiontool, ok := expressiontoolIf.(*cwl.ExpressionTool)
-				if !ok {
-
-					cx.RespondWithErrorMessage(fmt.Sprintf("(job/create) Error casting ExpressionTool (type: %s)", reflect.TypeOf(expressiontoolIf)), http.StatusBadRequest)
-					return
-				}
-
-				if shockRequirement == nil {
-					shockRequirement, err = cwl.GetShockRequirement(expressiontool.Requirements)
-					if err != nil {
-						logger.Debug(1, "(job/create) GetShockRequirement returned: %s", err.Error())
-						shockRequirement = nil
-					}
-				}
-
-				cwlWorkflowInstance := cwl.NewWorkflowEmpty()
-				cwlWorkflow = &cwlWorkflowInstance
-				cwlWorkflow.ID = wrapperEntrypoint
-				cwlWorkflow.CwlVersion = context.CwlVersion
-				newStep := cwl.WorkflowStep{}
-				stepID := wrapperEntrypoint + "/wrapper_step"
-				newStep.ID = stepID
-				for _, input := range expressiontool.Inputs { // input is InputParameter
-
-					workflowInputName := wrapperEntrypoint + "/" + path.Base(input.ID)
-
-					var workflowStepInput cwl.WorkflowStepInput
-					workflowStepInput.ID = stepID + "/" + path.Base(input.ID)
-					workflowStepInput.Source = workflowInputName
-					workflowStepInput.Default = input.Default
-
-					//fmt.Println("InputParameter and WorkflowStepInput:")
-					//spew.Dump(input)
-					//spew.Dump(workflowStepInput)
-					newStep.In = append(newStep.In, workflowStepInput)
-
-					var workflowInputParameter cwl.InputParameter
-					workflowInputParameter.ID = workflowInputName
-					workflowInputParameter.SecondaryFiles = input.SecondaryFiles
-					workflowInputParameter.Format = input.Format
-					workflowInputParameter.Streamable = input.Streamable
-					workflowInputParameter.InputBinding = input.InputBinding
-					workflowInputParameter.Type = input.Type
-
-					workflowInputParameter.Default = input.Default
-
-					addNull := false
-					if input.Default != nil { // check if this is an optional argument
-						addNull = true
-					}
-
-					if addNull {
-						hasNull := false
-
-						var workflowInputParameterTypeArray []cwl.CWLType_Type
-						workflowInputParameterTypeArray, err = workflowInputParameter.GetTypes()
-						if err != nil {
-							err = fmt.Errorf("(job/create) (A) workflowInputParameter.GetTypes returned: %s ", err.Error())
-							return
-						}
-
-						if len(workflowInputParameterTypeArray) == 0 {
-							err = fmt.Errorf("(job/create) (A) workflowInputParameterTypeArray empty ")
-							return
-						}
-					MYLOOP:
-						for _, t := range workflowInputParameterTypeArray {
-							if t == cwl.CWLNull {
-								hasNull = true
-								break MYLOOP
-							}
-						}
-						//fmt.Println("workflowInputParameter.Type:")
-						//spew.Dump(workflowInputParameter.Type)
-						if !hasNull {
-							workflowInputParameter.Type = append(workflowInputParameterTypeArray, cwl.CWLNull)
-							//fmt.Println("workflowInputParameter.Type: after")
-							//spew.Dump(workflowInputParameter.Type)
-						}
-					}
-
-					cwlWorkflow.Inputs = append(cwlWorkflow.Inputs, workflowInputParameter)
-				}
-
-				for _, output := range expressiontool.Outputs { // type: ExpressionToolOutputParameter
-
-					outputEtop, ok := output.(*cwl.ExpressionToolOutputParameter)
-					if ok {
-						var workflowStepOutput cwl.WorkflowStepOutput
-						workflowStepOutput.Id = stepID + "/" + path.Base(outputEtop.Id)
-
-						newStep.Out = append(newStep.Out, workflowStepOutput)
-
-						var workflowOutputParameter cwl.WorkflowOutputParameter
-
-						workflowOutputParameter.Id = wrapperEntrypoint + "/" + path.Base(outputEtop.Id)
-						workflowOutputParameter.OutputSource = stepID + "/" + path.Base(outputEtop.Id)
-						workflowOutputParameter.SecondaryFiles = outputEtop.SecondaryFiles
-						workflowOutputParameter.Format = outputEtop.Format
-						workflowOutputParameter.Streamable = outputEtop.Streamable
-						//workflow_output_parameter.OutputBinding = output.OutputBinding
-						//workflow_output_parameter.OutputSource = output.OutputSource
-						//workflow_output_parameter.LinkMerge = output.LinkMerge
-						workflowOutputParameter.Type = outputEtop.Type
-						cwlWorkflow.Outputs = append(cwlWorkflow.Outputs, workflowOutputParameter)
-					} else {
-						err = fmt.Errorf("(job/create) ExpressionToolOutputParameter still required, got: %s", reflect.TypeOf(output))
-						cx.RespondWithErrorMessage(err.Error(), http.StatusBadRequest)
-						return
-					}
-				}
-
-				if expressiontool.Requirements != nil {
-					requirements := expressiontool.Requirements
-					for i := range requirements {
-						requireType := (requirements)[i].GetClass()
-						if requireType == "ShockRequirement" {
-							shockRequirement := (requirements)[i]
-
-							cwlWorkflow.Requirements, err = cwl.AddRequirement(shockRequirement, requirements)
-							if err != nil {
-								err = fmt.Errorf("(job/create) AddRequirement returned: %s", err.Error())
-								cx.RespondWithErrorMessage(err.Error(), http.StatusBadRequest)
-								return
-							}
-						}
-					}
-				}
-
-				newStep.Run = expressiontool.ID
-
-				cwlWorkflow.Steps = []cwl.WorkflowStep{newStep}
-
-				cwlWorkflowNamed := cwl.NamedCWLObject{}
-				cwlWorkflowNamed.ID = cwlWorkflow.ID
-				cwlWorkflowNamed.Value = cwlWorkflow
-
-				objectArray = append(objectArray, cwlWorkflowNamed)
-				//err = context.Add(entrypoint, cwlWorkflow, "job/create2")
-				//if err != nil {
-				//	cx.RespondWithErrorMessage("collection.Add returned: "+err.Error(), http.StatusBadRequest)
-				//	return
-				//}
-			default:
-				cx.RespondWithErrorMessage(fmt.Sprintf("(job/create) Runner type %s not supported", reflect.TypeOf(runner)), http.StatusBadRequest)
-
-				return
-			}
-			//spew.Dump(cwlWorkflow)
-
-		} else { // context.WorkflowCount > 0
-			//entrypoint = "#entrypoint"
-
-			//var ok bool
-			cwlWorkflow, err = context.GetWorkflow(entrypoint)
-			if err != nil {
-				cx.RespondWithErrorMessage(fmt.Sprintf("(job/create) Workflow %s not found (%s)", entrypoint, err.Error()), http.StatusBadRequest)
-				return
-			}
-
-			shockRequirement, err = cwl.GetShockRequirement(cwlWorkflow.Requirements)
-			if err != nil {
-				logger.Debug(1, "(job/create) GetShockRequirement returned: %s", err.Error())
-				shockRequirement = nil
-			}
-
-		}
-
-		// replace interfaces with real objects (inlcuding new wrapper workflow if applicable)
-		context.GraphDocument.Graph = []interface{}{}
+		entrypoint := params["entrypoint"]
 
-		for i := range objectArray {
-			pair := objectArray[i]
-			object := pair.Value
-			logger.Debug(3, "(job/create) adding to context.GraphDocument.Graph: %s", pair.ID)
-			context.GraphDocument.Graph = append(context.GraphDocument.Graph, object)
-		}
-
-		//fmt.Println("\n\n\n--------------------------------- Steps:\n")
-		//for _, step := range cwl_workflow.Steps {
-		//	spew.Dump(step)
-		//}
-
-		//context.CwlVersion = cwl_version
-		//fmt.Println("\n\n\n--------------------------------- Create AWE Job:\n")
-		job, err = core.CWL2AWE(_user, files, jobInput, cwlWorkflow, entrypoint, context)
+		job, err = core.CreateJobFromCWL(_user, files, yamlstream, cwlWorkflowFileName, jobStream, entrypoint)
 		if err != nil {
 			cx.RespondWithErrorMessage("Error: "+err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		job.Entrypoint = entrypoint
-		job.IsCWL = true
-
 		// this ugly conversion is necessary as mongo does not like interface types.
 		//object_array_of_interface := []interface{}{}
 		//for i, _ := range object_array {
@@ -624,10 +197,6 @@ func (cr *JobController) Create(cx *goweb.Context) {
 
 		job.Info.ClientGroups = clientGroup
 
-		if shockRequirement != nil {
-			job.CWL_ShockRequirement = shockRequirement
-		}
-
 		logger.Debug(1, "CWL2AWE done")
 
 	} else if !hasUpload && !hasAWF {
@@ -659,6 +228,61 @@ func (cr *JobController) Create(cx *goweb.Context) {
 		logger.Debug(3, "job %s got token", job.ID)
 	}
 
+	// webhook, the secret for the signature is only accepted as form field
+	if notifyURL, ok := params["NOTIFY_URL"]; ok {
+		job.Info.Notification = &core.Notification{URL: notifyURL}
+	}
+	if job.Info.Notification != nil {
+		if notifyEvents, ok := params["NOTIFY_EVENTS"]; ok {
+			job.Info.Notification.Events = strings.Split(notifyEvents, ",")
+		}
+		if notifySecret, ok := params["NOTIFY_SECRET"]; ok {
+			job.Info.Notification.Secret = notifySecret
+		}
+	}
+
+	// call caching can be turned off per job, for CWL submissions as form field
+	if noCache, ok := params["NO_CACHE"]; ok {
+		job.Info.NoCache, err = strconv.ParseBool(noCache)
+		if err != nil {
+			cx.RespondWithErrorMessage("NO_CACHE must be true or false", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// retention of intermediate step outputs, for CWL submissions as form field
+	if retention, ok := params["RETENTION"]; ok {
+		job.Info.Retention = retention
+	}
+
+	// dependencies on other jobs, for CWL submissions as form fields
+	if afterJobs, ok := params["AFTER_JOBS"]; ok && afterJobs != "" {
+		job.Info.AfterJobs = strings.Split(afterJobs, ",")
+	}
+	if afterJobsCondition, ok := params["AFTER_JOBS_CONDITION"]; ok {
+		job.Info.AfterJobsCond = afterJobsCondition
+	}
+	err = core.ValidateJobSubmission(job, _user)
+	if err != nil {
+		cx.RespondWithErrorMessage(err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// jobs of a project can only be submitted by its submitters, see /project
+	err = core.CheckProjectSubmission(_user, job.Info.Project)
+	if err != nil {
+		cx.RespondWithErrorMessage(err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	err = core.Quotas.CheckSubmission(_user.Uuid, job.Info.ClientGroups)
+	if err != nil {
+		cx.RespondWithErrorMessage(err.Error(), http.StatusTooManyRequests)
+		return
+	}
+
+	core.PinJobDockerImages(job)
+
 	err = job.Save() // note that the job only goes into mongo, not into memory yet (EnqueueTasksByJobId is pulling from mongo, indirectly)
 	if err != nil {
 		cx.RespondWithErrorMessage(fmt.Sprintf("(JobController/Create) job.Save returned: %s", err.Error()), http.StatusBadRequest)
@@ -795,7 +419,7 @@ func (cr *JobController) Read(id string, cx *goweb.Context) {
 	// User must have read permissions on job or be job owner or be an admin
 	rights := job.ACL.Check(u.Uuid)
 	prights := job.ACL.Check("public")
-	if job.ACL.Owner != u.Uuid && rights["read"] == false && u.Admin == false && prights["read"] == false {
+	if job.ACL.Owner != u.Uuid && rights["read"] == false && u.Admin == false && prights["read"] == false && core.ProjectRights(u, job.Info.Project)["read"] == false {
 		cx.RespondWithErrorMessage(e.UnAuth, http.StatusUnauthorized)
 		return
 	}
@@ -853,6 +477,21 @@ func (cr *JobController) Read(id string, cx *goweb.Context) {
 		return
 	}
 
+	if query.Has("history") { // state changes of job, tasks and workunits, optionally only one type
+		historyType := query.Value("history")
+		if historyType != "" && historyType != core.HISTORY_JOB && historyType != core.HISTORY_TASK && historyType != core.HISTORY_WORKUNIT {
+			cx.RespondWithErrorMessage("history type must be job, task or workunit", http.StatusBadRequest)
+			return
+		}
+		changes, err := core.GetJobHistory(id, historyType)
+		if err != nil {
+			cx.RespondWithErrorMessage("job history not found: "+id+" "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		cx.RespondWithData(changes)
+		return
+	}
+
 	if query.Has("report") {
 		jobLogs, err := job.GetJobLogs()
 		if err != nil {
@@ -869,11 +508,43 @@ func (cr *JobController) Read(id string, cx *goweb.Context) {
 		job.Registered = false
 	}
 
+	if query.Has("retention") { // dry run of the deletion of intermediate step outputs
+		report, err := core.CollectIntermediates(job, true)
+		if err != nil {
+			cx.RespondWithErrorMessage(err.Error(), http.StatusBadRequest)
+			return
+		}
+		cx.RespondWithData(report)
+		return
+	}
+
 	if query.Has("export") {
 		target := query.Value("export")
 		if target == "" {
 			cx.RespondWithErrorMessage("lacking stage id from which the recompute starts", http.StatusBadRequest)
 			return
+		} else if target == "ro-crate" {
+			perf := core.LoadROCrateJobPerf(id)
+			// the crate refers to the step outputs of the tasks, the zip is written while the job is locked
+			var archive bytes.Buffer
+			job.RLockRecursive()
+			crate, err := core.NewROCrate(job, perf)
+			if err == nil {
+				err = crate.WriteZip(&archive)
+			}
+			job.RUnlockRecursive()
+			if err != nil {
+				cx.RespondWithErrorMessage("failed to export job to RO-Crate: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			cx.ResponseWriter.Header().Set("Content-Type", "application/zip")
+			cx.ResponseWriter.Header().Set("Content-Disposition", "attachment; filename=\""+id+".crate.zip\"")
+			cx.ResponseWriter.WriteHeader(http.StatusOK)
+			_, err = archive.WriteTo(cx.ResponseWriter)
+			if err != nil {
+				logger.Error("(JobController/Read) export of job %s: %s", id, err.Error())
+			}
+			return
 		} else if target == "taverna" {
 			//wfrun, err := taverna.ExportWorkflowRun(job)
 			//if err != nil {
@@ -883,6 +554,8 @@ func (cr *JobController) Read(id string, cx *goweb.Context) {
 			//	cx.RespondWithData(wfrun)
 			//	return
 		}
+		cx.RespondWithErrorMessage("unsupported export format: "+target+" (supported: ro-crate)", http.StatusBadRequest)
+		return
 	}
 
 	job.RLockRecursive()
@@ -917,6 +590,15 @@ func (cr *JobController) ReadMany(cx *goweb.Context) {
 		// Add authorization checking to query if the user is not an admin
 		if u.Admin == false {
 			q["$or"] = []bson.M{bson.M{"acl.read": "public"}, bson.M{"acl.read": u.Uuid}, bson.M{"acl.owner": u.Uuid}, bson.M{"acl": bson.M{"$exists": "false"}}}
+			// jobs of the projects the user is member of
+			projects, err := core.UserProjectNames(u, core.ROLE_VIEWER)
+			if err != nil {
+				cx.RespondWithErrorMessage(err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if len(projects) > 0 {
+				q["$or"] = append(q["$or"].([]bson.M), bson.M{"info.project": bson.M{"$in": projects}})
+			}
 		}
 	} else {
 		// User is anonymous
@@ -997,18 +679,19 @@ func (cr *JobController) ReadMany(cx *goweb.Context) {
 	// Gather params to make db query. Do not include the
 	// following list.
 	skip := map[string]int{
-		"limit":      1,
-		"offset":     1,
-		"query":      1,
-		"recent":     1,
-		"order":      1,
-		"direction":  1,
-		"active":     1,
-		"suspend":    1,
-		"registered": 1,
-		"verbosity":  1,
-		"userattr":   1,
-		"distinct":   1,
+		"limit":             1,
+		"offset":            1,
+		"query":             1,
+		"recent":            1,
+		"order":             1,
+		"direction":         1,
+		"active":            1,
+		"suspend":           1,
+		"registered":        1,
+		"verbosity":         1,
+		"userattr":          1,
+		"distinct":          1,
+		"aging_clientgroup": 1,
 	}
 	if query.Has("query") {
 		const shortForm = "2006-01-02"
@@ -1086,6 +769,7 @@ func (cr *JobController) ReadMany(cx *goweb.Context) {
 				}
 			}
 		}
+		core.SetJobEffectivePriorities(filtered_jobs, query.Value("aging_clientgroup"))
 		filtered_jobs.RLockRecursive()
 		defer filtered_jobs.RUnlockRecursive()
 		cx.RespondWithPaginatedData(filtered_jobs, limit, offset, len(act_jobs))
@@ -1158,6 +842,7 @@ func (cr *JobController) ReadMany(cx *goweb.Context) {
 		//filtered_jobs.RLockRecursive()
 		//defer filtered_jobs.RUnlockRecursive()
 
+		core.SetJobEffectivePriorities(filtered_jobs, query.Value("aging_clientgroup"))
 		cx.RespondWithPaginatedData(filtered_jobs, limit, offset, len(suspend_jobs))
 		return
 	}
@@ -1193,6 +878,7 @@ func (cr *JobController) ReadMany(cx *goweb.Context) {
 		}
 		//paged_jobs.RLockRecursive()
 		//defer paged_jobs.RUnlockRecursive()
+		core.SetJobEffectivePriorities(paged_jobs, query.Value("aging_clientgroup"))
 		cx.RespondWithPaginatedData(paged_jobs, limit, offset, total)
 		return
 	}
@@ -1331,6 +1017,7 @@ func (cr *JobController) ReadMany(cx *goweb.Context) {
 		}
 		filtered_jobs = append(filtered_jobs, job)
 	}
+	core.SetJobEffectivePriorities(filtered_jobs, query.Value("aging_clientgroup"))
 	cx.RespondWithPaginatedData(filtered_jobs, limit, offset, total)
 	return
 }
@@ -1410,7 +1097,7 @@ func (cr *JobController) Update(id string, cx *goweb.Context) {
 
 	// Load job by id
 	var job *core.Job
-	if query.Has("clientgroup") || query.Has("priority") || query.Has("pipeline") || query.Has("expiration") || query.Has("settoken") {
+	if query.Has("clientgroup") || query.Has("priority") || query.Has("pipeline") || query.Has("expiration") || query.Has("retention") || query.Has("settoken") {
 		job, err = core.GetJob(id)
 		if err != nil {
 			if err == mgo.ErrNotFound {
@@ -1437,8 +1124,9 @@ func (cr *JobController) Update(id string, cx *goweb.Context) {
 		return
 	}
 
+	// operators of the project of the job have write permissions too
 	rights := acl.Check(u.Uuid)
-	if acl.Owner != u.Uuid && rights["write"] == false && u.Admin == false {
+	if acl.Owner != u.Uuid && rights["write"] == false && u.Admin == false && core.JobProjectRights(u, id)["write"] == false {
 		cx.RespondWithErrorMessage(e.UnAuth, http.StatusUnauthorized)
 		return
 	}
@@ -1448,6 +1136,7 @@ func (cr *JobController) Update(id string, cx *goweb.Context) {
 			cx.RespondWithErrorMessage("fail to resume job: "+id+" "+err.Error(), http.StatusBadRequest)
 			return
 		}
+		core.RecordUserAction(id, "resume", u)
 		cx.RespondWithData("job resumed: " + id)
 		return
 	}
@@ -1460,6 +1149,7 @@ func (cr *JobController) Update(id string, cx *goweb.Context) {
 			cx.RespondWithErrorMessage("fail to suspend job: "+id+" "+err.Error(), http.StatusBadRequest)
 			return
 		}
+		core.RecordUserAction(id, "suspend", u)
 		cx.RespondWithData("job suspended: " + id)
 		return
 	}
@@ -1468,6 +1158,7 @@ func (cr *JobController) Update(id string, cx *goweb.Context) {
 			cx.RespondWithErrorMessage("fail to recover job: "+id+" "+err.Error(), http.StatusBadRequest)
 			return
 		}
+		core.RecordUserAction(id, "recover", u)
 		cx.RespondWithData("job recovered: " + id)
 		return
 	}
@@ -1481,6 +1172,7 @@ func (cr *JobController) Update(id string, cx *goweb.Context) {
 			cx.RespondWithErrorMessage("fail to recompute job: "+id+" "+err.Error(), http.StatusBadRequest)
 			return
 		}
+		core.RecordUserAction(id, "recompute "+stage, u)
 		cx.RespondWithData("job recompute started at task " + stage + ": " + id)
 		return
 	}
@@ -1489,6 +1181,7 @@ func (cr *JobController) Update(id string, cx *goweb.Context) {
 			cx.RespondWithErrorMessage("fail to resubmit job: "+id+" "+err.Error(), http.StatusBadRequest)
 			return
 		}
+		core.RecordUserAction(id, "resubmit", u)
 		cx.RespondWithData("job resubmitted: " + id)
 		return
 	}
@@ -1549,6 +1242,28 @@ func (cr *JobController) Update(id string, cx *goweb.Context) {
 		cx.RespondWithData("expiration '" + job.Expiration.String() + "' set for job: " + id)
 		return
 	}
+	if query.Has("retention") { // change the retention policy of intermediate step outputs, collects them if the retention time has passed
+		retention := query.Value("retention")
+		if retention == "" {
+			cx.RespondWithErrorMessage("lacking retention value", http.StatusBadRequest)
+			return
+		}
+		if err := job.SetRetention(retention); err != nil {
+			cx.RespondWithErrorMessage("failed to set the retention for job: "+id+" "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if job.RetainUntil.IsZero() || job.RetainUntil.After(time.Now()) {
+			cx.RespondWithData("retention '" + retention + "' set for job: " + id)
+			return
+		}
+		report, err := core.CollectIntermediates(job, false)
+		if err != nil {
+			cx.RespondWithErrorMessage("retention set, but deleting intermediates failed for job: "+id+" "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		cx.RespondWithData(report)
+		return
+	}
 	if query.Has("settoken") { // set data token
 		token, err := request.RetrieveToken(cx.Request)
 		if err != nil {
@@ -1,7 +1,6 @@
 package controller
 
 import (
-	"github.com/MG-RAST/AWE/lib/conf"
 	e "github.com/MG-RAST/AWE/lib/errors"
 	"github.com/MG-RAST/AWE/lib/logger"
 	"github.com/MG-RAST/AWE/lib/logger/event"
@@ -47,7 +46,7 @@ func (cr *LoggerController) ReadMany(cx *goweb.Context) {
 		return
 	}
 	if query.Has("debug") {
-		cx.RespondWithData(map[string]int{"debuglevel": conf.DEBUG_LEVEL})
+		cx.RespondWithData(map[string]int{"debuglevel": logger.DebugLevel()})
 		return
 	}
 
@@ -87,10 +86,11 @@ func (cr *LoggerController) UpdateMany(cx *goweb.Context) {
 		levelInt, err := strconv.Atoi(levelStr)
 		if err != nil {
 			cx.RespondWithErrorMessage("invalid debug level: "+err.Error(), http.StatusBadRequest)
+			return
 		}
-		conf.DEBUG_LEVEL = levelInt
+		logger.SetDebugLevel(levelInt)
 		logger.Event(event.DEBUG_LEVEL, "level="+levelStr+";user="+u.Username)
-		cx.RespondWithData(map[string]int{"debuglevel": conf.DEBUG_LEVEL})
+		cx.RespondWithData(map[string]int{"debuglevel": logger.DebugLevel()})
 		return
 	}
 
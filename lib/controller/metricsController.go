@@ -0,0 +1,13 @@
+package controller
+
+import (
+	"github.com/MG-RAST/AWE/lib/core"
+	"github.com/MG-RAST/golib/goweb"
+)
+
+// Metrics GET: /metrics, server metrics in the Prometheus text format
+func Metrics(cx *goweb.Context) {
+	LogRequest(cx.Request)
+	core.ServerMetrics.ServeHTTP(cx.ResponseWriter, cx.Request)
+	return
+}
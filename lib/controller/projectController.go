@@ -0,0 +1,255 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/MG-RAST/AWE/lib/conf"
+	"github.com/MG-RAST/AWE/lib/core"
+	e "github.com/MG-RAST/AWE/lib/errors"
+	"github.com/MG-RAST/AWE/lib/user"
+	"github.com/MG-RAST/golib/goweb"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type ProjectController struct{}
+
+// OPTIONS: /project
+func (cr *ProjectController) Options(cx *goweb.Context) {
+	LogRequest(cx.Request)
+	cx.RespondWithOK()
+	return
+}
+
+// POST: /project/{name}?description=
+func (cr *ProjectController) CreateWithId(name string, cx *goweb.Context) {
+	LogRequest(cx.Request)
+
+	u, done := GetAuthorizedUser(cx)
+	if done {
+		return
+	}
+
+	// the project gets rights on all jobs with the name in info.project
+	if !u.Admin {
+		cx.RespondWithErrorMessage("only admins can create projects", http.StatusUnauthorized)
+		return
+	}
+
+	if _, err := core.LoadProject(name); err == nil {
+		cx.RespondWithErrorMessage("project exists already: "+name, http.StatusBadRequest)
+		return
+	}
+
+	query := &Query{Li: cx.Request.URL.Query()}
+	p, err := core.NewProject(name, query.Value("description"), u)
+	if err != nil {
+		cx.RespondWithErrorMessage(err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err = p.Save(); err != nil {
+		cx.RespondWithErrorMessage(err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	cx.RespondWithData(p)
+	return
+}
+
+// loadProject loads the project and checks that the user has the role (global admins have all roles), responds on error
+func loadProject(name string, u *user.User, role string, cx *goweb.Context) (p *core.Project, done bool) {
+	p, err := core.LoadProject(name)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			cx.RespondWithNotFound()
+		} else {
+			cx.RespondWithErrorMessage("project not found: "+name+" "+err.Error(), http.StatusBadRequest)
+		}
+		done = true
+		return
+	}
+
+	if !u.Admin && !p.HasRole(u, role) {
+		cx.RespondWithErrorMessage(e.UnAuth, http.StatusUnauthorized)
+		done = true
+	}
+	return
+}
+
+// findProjectMember accepts a username or uuid, "public" stands for all users
+func findProjectMember(id string) (uuid string, err error) {
+	if id == "public" {
+		uuid = id
+		return
+	}
+	var u *user.User
+	if u, err = user.FindByUuid(id); err == nil {
+		uuid = u.Uuid
+		return
+	}
+	if u, err = user.FindByUsername(id); err == nil {
+		uuid = u.Uuid
+	}
+	return
+}
+
+// GET: /project/{name}
+func (cr *ProjectController) Read(name string, cx *goweb.Context) {
+	LogRequest(cx.Request)
+
+	u, done := GetAuthorizedUser(cx)
+	if done {
+		return
+	}
+
+	p, done := loadProject(name, u, core.ROLE_VIEWER, cx)
+	if done {
+		return
+	}
+
+	cx.RespondWithData(p)
+	return
+}
+
+// GET: /project
+func (cr *ProjectController) ReadMany(cx *goweb.Context) {
+	LogRequest(cx.Request)
+
+	u, done := GetAuthorizedUser(cx)
+	if done {
+		return
+	}
+
+	query := &Query{Li: cx.Request.URL.Query()}
+
+	// members see their projects, admins all
+	q := bson.M{}
+	if u.Admin == false {
+		q = core.MemberQuery(u)
+	}
+	if query.Has("clientgroup") {
+		q = bson.M{"$and": []bson.M{q, bson.M{"clientgroups": query.Value("clientgroup")}}}
+	}
+
+	var err error
+	limit := conf.DEFAULT_PAGE_SIZE
+	offset := 0
+	if query.Has("limit") {
+		if limit, err = strconv.Atoi(query.Value("limit")); err != nil {
+			cx.RespondWithErrorMessage(err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if query.Has("offset") {
+		if offset, err = strconv.Atoi(query.Value("offset")); err != nil {
+			cx.RespondWithErrorMessage(err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	projects := core.Projects{}
+	total, err := projects.GetPaginated(q, limit, offset, "name", "asc")
+	if err != nil {
+		cx.RespondWithErrorMessage(err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cx.RespondWithPaginatedData(projects, limit, offset, total)
+	return
+}
+
+// PUT: /project/{name}?user=&role=, ?remove_user=, ?add_clientgroup=, ?remove_clientgroup=, ?description=
+func (cr *ProjectController) Update(name string, cx *goweb.Context) {
+	LogRequest(cx.Request)
+
+	u, done := GetAuthorizedUser(cx)
+	if done {
+		return
+	}
+
+	p, done := loadProject(name, u, core.ROLE_ADMIN, cx)
+	if done {
+		return
+	}
+
+	query := &Query{Li: cx.Request.URL.Query()}
+
+	if query.Has("user") {
+		uuid, err := findProjectMember(query.Value("user"))
+		if err != nil {
+			cx.RespondWithErrorMessage("user not found: "+query.Value("user"), http.StatusBadRequest)
+			return
+		}
+		if err = p.SetMember(uuid, query.Value("role")); err != nil {
+			cx.RespondWithErrorMessage(err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if query.Has("remove_user") {
+		uuid, err := findProjectMember(query.Value("remove_user"))
+		if err != nil {
+			cx.RespondWithErrorMessage("user not found: "+query.Value("remove_user"), http.StatusBadRequest)
+			return
+		}
+		p.RemoveMember(uuid)
+	}
+	if query.Has("description") {
+		p.Description = query.Value("description")
+	}
+
+	// client groups may run jobs of other projects, only admins assign them
+	if query.Has("add_clientgroup") || query.Has("remove_clientgroup") {
+		if !u.Admin {
+			cx.RespondWithErrorMessage("only admins can change the client groups of a project", http.StatusUnauthorized)
+			return
+		}
+		if query.Has("add_clientgroup") {
+			cgName := query.Value("add_clientgroup")
+			if _, err := core.LoadClientGroupByName(cgName); err != nil {
+				cx.RespondWithErrorMessage("clientgroup not found: "+cgName, http.StatusBadRequest)
+				return
+			}
+			p.AddClientGroup(cgName)
+		}
+		if query.Has("remove_clientgroup") {
+			p.RemoveClientGroup(query.Value("remove_clientgroup"))
+		}
+	}
+
+	if err := p.Save(); err != nil {
+		cx.RespondWithErrorMessage(err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	cx.RespondWithData(p)
+	return
+}
+
+// DELETE: /project/{name}
+func (cr *ProjectController) Delete(name string, cx *goweb.Context) {
+	LogRequest(cx.Request)
+
+	u, done := GetAuthorizedUser(cx)
+	if done {
+		return
+	}
+
+	if !u.Admin {
+		cx.RespondWithErrorMessage("only admins can delete projects", http.StatusUnauthorized)
+		return
+	}
+
+	if _, done = loadProject(name, u, core.ROLE_ADMIN, cx); done {
+		return
+	}
+
+	if err := core.DeleteProject(name); err != nil {
+		cx.RespondWithErrorMessage("fail to delete project "+name+" "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	cx.RespondWithData("project deleted: " + name)
+	return
+}
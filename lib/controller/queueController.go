@@ -2,6 +2,7 @@ package controller
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/MG-RAST/AWE/lib/conf"
 	"github.com/MG-RAST/AWE/lib/core"
@@ -9,6 +10,7 @@ import (
 	"github.com/MG-RAST/AWE/lib/logger"
 	"github.com/MG-RAST/AWE/lib/logger/event"
 	"github.com/MG-RAST/AWE/lib/request"
+	"github.com/MG-RAST/AWE/lib/user"
 	"github.com/MG-RAST/golib/goweb"
 )
 
@@ -67,6 +69,36 @@ func (cr *QueueController) ReadMany(cx *goweb.Context) {
 		cx.RespondWithErrorMessage(err.Error(), http.StatusUnauthorized)
 		return
 	}
+	// quotas and usage of the user, admins can view any user
+	if query.Has("quota") {
+		if u == nil {
+			cx.RespondWithErrorMessage(e.NoAuth, http.StatusUnauthorized)
+			return
+		}
+		if core.Quotas == nil {
+			cx.RespondWithErrorMessage("quotas are not enabled", http.StatusInternalServerError)
+			return
+		}
+		if u.Admin && query.Value("quota") == "all" {
+			cx.RespondWithData(core.Quotas.List())
+			return
+		}
+		uuid := u.Uuid
+		if query.Has("user") && query.Value("user") != "" {
+			if !u.Admin {
+				cx.RespondWithErrorMessage(e.UnAuth, http.StatusUnauthorized)
+				return
+			}
+			uuid = lookupUserUUID(query.Value("user"))
+		}
+		usage, err := core.QMgr.GetQuotaUsage(uuid)
+		if err != nil {
+			cx.RespondWithErrorMessage(err.Error(), http.StatusInternalServerError)
+			return
+		}
+		cx.RespondWithData(usage)
+		return
+	}
 	// must be admin user
 	if u == nil || u.Admin == false {
 		cx.RespondWithErrorMessage(e.NoAuth, http.StatusUnauthorized)
@@ -99,7 +131,8 @@ func (cr *QueueController) ReadMany(cx *goweb.Context) {
 		// The other possibility is that public read of clientgroups is enabled and the clientgroup is publicly readable.
 		rights := cg.ACL.Check(u.Uuid)
 		public_rights := cg.ACL.Check("public")
-		if (u.Uuid != "public" && (cg.ACL.Owner == u.Uuid || rights["read"] == true || u.Admin == true || public_rights["read"] == true)) ||
+		project_rights := core.ClientGroupProjectRights(u, cg.Name)
+		if (u.Uuid != "public" && (cg.ACL.Owner == u.Uuid || rights["read"] == true || u.Admin == true || public_rights["read"] == true || project_rights["read"] == true)) ||
 			(u.Uuid == "public" && conf.ANON_CG_READ == true && public_rights["read"] == true) {
 			// get running jobs for clients for clientgroup
 			jobs := core.Jobs{}
@@ -180,6 +213,48 @@ func (cr *QueueController) UpdateMany(cx *goweb.Context) {
 		cx.RespondWithData("work queue suspended")
 		return
 	}
+	if query.Has("quota") {
+		if core.Quotas == nil {
+			cx.RespondWithErrorMessage("quotas are not enabled", http.StatusInternalServerError)
+			return
+		}
+		quota := &core.Quota{}
+		if query.Has("user") {
+			quota.Type = core.QUOTA_USER
+			quota.Name = lookupUserUUID(query.Value("user"))
+		} else if query.Has("clientgroup") {
+			quota.Type = core.QUOTA_CLIENTGROUP
+			quota.Name = query.Value("clientgroup")
+		} else {
+			cx.RespondWithErrorMessage("quota requires user or clientgroup", http.StatusBadRequest)
+			return
+		}
+		if query.Has("workunits") {
+			if quota.MaxWorkunits, err = strconv.Atoi(query.Value("workunits")); err != nil {
+				cx.RespondWithErrorMessage("workunits must be an integer", http.StatusBadRequest)
+				return
+			}
+		}
+		if query.Has("jobs") {
+			if quota.MaxQueuedJobs, err = strconv.Atoi(query.Value("jobs")); err != nil {
+				cx.RespondWithErrorMessage("jobs must be an integer", http.StatusBadRequest)
+				return
+			}
+		}
+		if query.Has("cpuhours") {
+			if quota.MaxCPUHours, err = strconv.ParseFloat(query.Value("cpuhours"), 64); err != nil {
+				cx.RespondWithErrorMessage("cpuhours must be a number", http.StatusBadRequest)
+				return
+			}
+		}
+		if err = core.Quotas.Set(quota); err != nil {
+			cx.RespondWithErrorMessage(err.Error(), http.StatusBadRequest)
+			return
+		}
+		logger.Event(event.QUEUE_QUOTA, "user="+u.Username+";type="+quota.Type+";name="+quota.Name)
+		cx.RespondWithData(quota)
+		return
+	}
 
 	cx.RespondWithErrorMessage("requested queue operation not supported", http.StatusBadRequest)
 	return
@@ -198,3 +273,11 @@ func (cr *QueueController) DeleteMany(cx *goweb.Context) {
 	cx.RespondWithError(http.StatusNotImplemented)
 	return
 }
+
+// lookupUserUUID accepts a username or uuid
+func lookupUserUUID(name string) string {
+	if u, err := user.FindByUsername(name); err == nil {
+		return u.Uuid
+	}
+	return name
+}
@@ -0,0 +1,292 @@
+package controller
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/MG-RAST/AWE/lib/conf"
+	"github.com/MG-RAST/AWE/lib/core"
+	e "github.com/MG-RAST/AWE/lib/errors"
+	"github.com/MG-RAST/AWE/lib/logger"
+	"github.com/MG-RAST/AWE/lib/request"
+	"github.com/MG-RAST/AWE/lib/user"
+	"github.com/MG-RAST/golib/goweb"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type ScheduleController struct{}
+
+// OPTIONS: /schedule
+func (cr *ScheduleController) Options(cx *goweb.Context) {
+	LogRequest(cx.Request)
+	cx.RespondWithOK()
+	return
+}
+
+// POST: /schedule
+func (cr *ScheduleController) Create(cx *goweb.Context) {
+	LogRequest(cx.Request)
+
+	u, done := GetAuthorizedUser(cx)
+	if done {
+		return
+	}
+
+	params, files, err := ParseMultipartForm(cx.Request)
+	if err != nil {
+		if err.Error() == "request Content-Type isn't multipart/form-data" {
+			cx.RespondWithErrorMessage("No workflow file is submitted", http.StatusBadRequest)
+		} else {
+			logger.Error("(ScheduleController/Create) Error parsing form: " + err.Error())
+			cx.RespondWithErrorMessage("(ScheduleController/Create) Error parsing form: "+err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	cwlFile, hasCWL := files["cwl"]
+	if !hasCWL {
+		cx.RespondWithErrorMessage("No workflow file is submitted", http.StatusBadRequest)
+		return
+	}
+	workflow, err := ioutil.ReadFile(cwlFile.Path)
+	if err != nil {
+		cx.RespondWithErrorMessage("(ScheduleController/Create) error in reading workflow file: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	var jobInput []byte
+	if jobFile, hasJob := files["job"]; hasJob {
+		jobInput, err = ioutil.ReadFile(jobFile.Path)
+		if err != nil {
+			cx.RespondWithErrorMessage("(ScheduleController/Create) error in reading job yaml/json file: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	cron, ok := params["cron"]
+	if !ok {
+		cx.RespondWithErrorMessage("cron expression is missing", http.StatusBadRequest)
+		return
+	}
+
+	s, err := core.NewSchedule(u, params["name"], cron, cwlFile.Name, workflow, jobInput)
+	if err != nil {
+		cx.RespondWithErrorMessage(err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.Entrypoint = params["entrypoint"]
+	if clientGroup, ok := params["CLIENT_GROUP"]; ok {
+		s.ClientGroups = clientGroup
+	}
+	if skip, ok := params["skip_if_active"]; ok {
+		s.SkipIfActive, err = strconv.ParseBool(skip)
+		if err != nil {
+			cx.RespondWithErrorMessage("skip_if_active must be true or false", http.StatusBadRequest)
+			return
+		}
+	}
+	if keep, ok := params["keep_runs"]; ok {
+		s.KeepRuns, err = strconv.Atoi(keep)
+		if err != nil || s.KeepRuns < 1 {
+			cx.RespondWithErrorMessage("keep_runs must be a positive integer", http.StatusBadRequest)
+			return
+		}
+	}
+	if token, err := request.RetrieveToken(cx.Request); err == nil {
+		s.DataToken = token
+	}
+
+	if err = s.Save(); err != nil {
+		cx.RespondWithErrorMessage(err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	cx.RespondWithData(s)
+	return
+}
+
+// loadSchedule loads the schedule and checks that the user has the right, responds on error
+func loadSchedule(id string, u *user.User, right string, cx *goweb.Context) (s *core.Schedule, done bool) {
+	s, err := core.LoadSchedule(id)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			cx.RespondWithNotFound()
+		} else {
+			cx.RespondWithErrorMessage("schedule not found: "+id+" "+err.Error(), http.StatusBadRequest)
+		}
+		done = true
+		return
+	}
+
+	rights := s.ACL.Check(u.Uuid)
+	publicRights := s.ACL.Check("public")
+	if s.ACL.Owner != u.Uuid && rights[right] == false && u.Admin == false && publicRights[right] == false {
+		cx.RespondWithErrorMessage(e.UnAuth, http.StatusUnauthorized)
+		done = true
+	}
+	return
+}
+
+// GET: /schedule/{id}
+func (cr *ScheduleController) Read(id string, cx *goweb.Context) {
+	LogRequest(cx.Request)
+
+	u, done := GetAuthorizedUser(cx)
+	if done {
+		return
+	}
+
+	s, done := loadSchedule(id, u, "read", cx)
+	if done {
+		return
+	}
+
+	cx.RespondWithData(s)
+	return
+}
+
+// GET: /schedule
+func (cr *ScheduleController) ReadMany(cx *goweb.Context) {
+	LogRequest(cx.Request)
+
+	u, done := GetAuthorizedUser(cx)
+	if done {
+		return
+	}
+
+	query := &Query{Li: cx.Request.URL.Query()}
+
+	q := bson.M{}
+	if u.Admin == false {
+		q["$or"] = []bson.M{bson.M{"acl.read": "public"}, bson.M{"acl.read": u.Uuid}, bson.M{"acl.owner": u.Uuid}}
+	}
+	if query.Has("paused") {
+		q["paused"] = query.Value("paused") != "false"
+	}
+
+	var err error
+	limit := conf.DEFAULT_PAGE_SIZE
+	offset := 0
+	if query.Has("limit") {
+		if limit, err = strconv.Atoi(query.Value("limit")); err != nil {
+			cx.RespondWithErrorMessage(err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if query.Has("offset") {
+		if offset, err = strconv.Atoi(query.Value("offset")); err != nil {
+			cx.RespondWithErrorMessage(err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	schedules := core.Schedules{}
+	total, err := schedules.GetPaginated(q, limit, offset, "created_on", "desc")
+	if err != nil {
+		cx.RespondWithErrorMessage(err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cx.RespondWithPaginatedData(schedules, limit, offset, total)
+	return
+}
+
+// PUT: /schedule/{id}?pause, ?resume, ?cron=, ?clientgroup=, ?skip_if_active=, ?keep_runs=
+func (cr *ScheduleController) Update(id string, cx *goweb.Context) {
+	LogRequest(cx.Request)
+
+	u, done := GetAuthorizedUser(cx)
+	if done {
+		return
+	}
+
+	s, done := loadSchedule(id, u, "write", cx)
+	if done {
+		return
+	}
+
+	query := &Query{Li: cx.Request.URL.Query()}
+	var err error
+
+	// only the changed fields are written, the scheduler may record a run at the same time
+	set := bson.M{}
+	keepRuns := 0
+	if query.Has("cron") {
+		s.Cron = query.Value("cron")
+		if err = s.SetNextRun(time.Now()); err != nil {
+			cx.RespondWithErrorMessage(err.Error(), http.StatusBadRequest)
+			return
+		}
+		set["cron"] = s.Cron
+		set["next_run"] = s.NextRun
+	}
+	if query.Has("clientgroup") {
+		set["clientgroups"] = query.Value("clientgroup")
+	}
+	if query.Has("skip_if_active") {
+		var skip bool
+		if skip, err = strconv.ParseBool(query.Value("skip_if_active")); err != nil {
+			cx.RespondWithErrorMessage("skip_if_active must be true or false", http.StatusBadRequest)
+			return
+		}
+		set["skip_if_active"] = skip
+	}
+	if query.Has("keep_runs") {
+		keepRuns, err = strconv.Atoi(query.Value("keep_runs"))
+		if err != nil || keepRuns < 1 {
+			cx.RespondWithErrorMessage("keep_runs must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		set["keep_runs"] = keepRuns
+	}
+	if query.Has("pause") {
+		set["paused"] = true
+	}
+	if query.Has("resume") && s.Paused {
+		// ticks missed while paused are not repeated
+		if err = s.SetNextRun(time.Now()); err != nil {
+			cx.RespondWithErrorMessage(err.Error(), http.StatusBadRequest)
+			return
+		}
+		set["paused"] = false
+		set["next_run"] = s.NextRun
+	}
+
+	if err = core.UpdateSchedule(id, set, keepRuns); err != nil {
+		cx.RespondWithErrorMessage(err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s, err = core.LoadSchedule(id)
+	if err != nil {
+		cx.RespondWithErrorMessage(err.Error(), http.StatusInternalServerError)
+		return
+	}
+	cx.RespondWithData(s)
+	return
+}
+
+// DELETE: /schedule/{id}
+func (cr *ScheduleController) Delete(id string, cx *goweb.Context) {
+	LogRequest(cx.Request)
+
+	u, done := GetAuthorizedUser(cx)
+	if done {
+		return
+	}
+
+	_, done = loadSchedule(id, u, "delete", cx)
+	if done {
+		return
+	}
+
+	if err := core.DeleteSchedule(id); err != nil {
+		cx.RespondWithErrorMessage("fail to delete schedule "+id+" "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	cx.RespondWithData("schedule deleted: " + id)
+	return
+}
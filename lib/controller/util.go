@@ -182,7 +182,7 @@ func ResourceDescription(cx *goweb.Context) {
 	}
 
 	if core.Service == "server" {
-		r.R = []string{"job", "work", "client", "queue", "awf", "event"}
+		r.R = []string{"job", "work", "client", "queue", "schedule", "project", "cache", "metrics", "awf", "event"}
 	} else if core.Service == "proxy" {
 		r.R = []string{"client", "work"}
 	}
@@ -17,11 +17,14 @@ import (
 	"github.com/MG-RAST/golib/goweb"
 
 	//"github.com/davecgh/go-spew/spew"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"os"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	mgo "gopkg.in/mgo.v2"
 )
@@ -144,11 +147,26 @@ func (cr *WorkController) Read(id string, cx *goweb.Context) {
 
 	// User must have read permissions on job or be job owner or be an admin
 	rights := acl.Check(u.Uuid)
-	if acl.Owner != u.Uuid && rights["read"] == false && u.Admin == false {
+	if acl.Owner != u.Uuid && rights["read"] == false && u.Admin == false && core.JobProjectRights(u, jobid)["read"] == false {
 		cx.RespondWithErrorMessage(e.UnAuth, http.StatusUnauthorized)
 		return
 	}
 
+	if query.Has("history") { //retrieve state changes of the workunit
+		changes, err := core.GetWorkHistory(work_id)
+		if err != nil {
+			cx.RespondWithErrorMessage(err.Error(), http.StatusBadRequest)
+			return
+		}
+		cx.RespondWithData(changes)
+		return
+	}
+
+	if query.Has("report") && query.Has("follow") { //tail stdout or stderr of a running workunit
+		followReport(cx, work_id, query.Value("report"))
+		return
+	}
+
 	if query.Has("report") { //retrieve report: stdout or stderr or worknotes
 		reportmsg, err := core.QMgr.GetReportMsg(work_id, query.Value("report"))
 		if err != nil {
@@ -241,6 +259,7 @@ func (cr *WorkController) ReadMany(cx *goweb.Context) {
 		} else {
 			workunits = core.QMgr.ShowWorkunitsByUser("", u)
 		}
+		workunits = core.EffectivePriorities(workunits, query.Value("aging_clientgroup"))
 
 		// if using query syntax then do pagination and sorting
 		if query.Has("query") {
@@ -346,8 +365,50 @@ func (cr *WorkController) ReadMany(cx *goweb.Context) {
 		}
 	}
 
-	//checkout a workunit in FCFS order
-	workunits, err := core.QMgr.CheckoutWorkunits("FCFS", clientid, client, availableBytes, 1)
+	// cores and RAM (MiB) not reserved by other workunits, sent by multi-slot workers
+	var free *core.WorkResources
+	if query.Has("cores") || query.Has("ram") {
+		free = &core.WorkResources{Cores: -1, RAM: -1}
+		if value, errv := strconv.ParseInt(query.Value("cores"), 10, 64); errv == nil {
+			free.Cores = value
+		}
+		if value, errv := strconv.ParseInt(query.Value("ram"), 10, 64); errv == nil {
+			free.RAM = value
+		}
+	}
+
+	if cg == nil {
+		cg, _ = core.LoadClientGroupByName(client.Group)
+	}
+
+	// selection policy: checkout request, then clientgroup, then server default
+	policy := ""
+	if query.Has("policy") {
+		policy = query.Value("policy")
+		if !core.IsValidWorkPolicy(policy) {
+			cx.RespondWithErrorMessage("unknown policy: "+policy+" (supported: "+strings.Join(core.WorkPolicies, ", ")+")", http.StatusBadRequest)
+			return
+		}
+	} else if cg != nil {
+		policy = cg.Policy
+	}
+
+	// priority aging: clientgroup of the client, then server default
+	aging := core.AgingPolicyOfClientGroup(client.Group)
+
+	// long-poll: wait up to this many seconds for a workunit instead of answering "no eligible workunit" right away
+	wait := 0
+	if query.Has("wait") {
+		if value, errv := strconv.Atoi(query.Value("wait")); errv == nil && value > 0 {
+			wait = value
+		}
+		if wait > conf.CHECKOUT_WAIT_MAX {
+			wait = conf.CHECKOUT_WAIT_MAX
+		}
+	}
+
+	//checkout a workunit
+	workunits, err := core.QMgr.CheckoutWorkunits(policy, aging, clientid, client, availableBytes, free, 1, time.Duration(wait)*time.Second)
 
 	if err != nil {
 
@@ -456,6 +517,26 @@ func (cr *WorkController) Update(id string, cx *goweb.Context) {
 		return
 	}
 
+	if query.Has("log") { //chunk of stdout or stderr of a running workunit
+		offset, err := strconv.ParseInt(query.Value("offset"), 10, 64)
+		if err != nil {
+			cx.RespondWithErrorMessage("error parsing offset: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, err := ioutil.ReadAll(cx.Request.Body)
+		if err != nil {
+			cx.RespondWithErrorMessage("error reading log chunk: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = core.QMgr.AppendLiveLog(client, work_id, query.Value("log"), offset, data)
+		if err != nil {
+			cx.RespondWithErrorMessage(err.Error(), http.StatusBadRequest)
+			return
+		}
+		cx.RespondWithData("ok")
+		return
+	}
+
 	// old-style
 	var notice *core.Notice
 	if query.Has("status") && query.Has("client") { //notify execution result: "done" or "fail"
@@ -482,6 +563,12 @@ func (cr *WorkController) Update(id string, cx *goweb.Context) {
 				notice.ComputeTime = comptime
 			}
 		}
+		if query.Has("exitstatus") {
+			if exitstatus, err := strconv.Atoi(query.Value("exitstatus")); err == nil {
+				notice.ExitStatus = exitstatus
+			}
+		}
+		notice.Failure = query.Value("failure")
 	}
 
 	params, files, err := ParseMultipartForm(cx.Request)
@@ -526,6 +613,9 @@ func (cr *WorkController) Update(id string, cx *goweb.Context) {
 		return
 	}
 
+	// no more chunks once the final logs are saved
+	core.QMgr.CloseLiveLogs(work_id)
+
 	// report may also be added for cwl workunit
 	if query.Has("report") { // if "report" is specified in query, parse performance statistics or errlog
 		if _, ok := files["perf"]; ok {
@@ -565,3 +655,95 @@ func (cr *WorkController) Update(id string, cx *goweb.Context) {
 	cx.RespondWithData("ok")
 	return
 }
+
+// followReport streams stdout or stderr of a workunit as chunked HTTP response until the workunit is no longer running or the client disconnects
+func followReport(cx *goweb.Context, work_id core.Workunit_Unique_Identifier, logname string) {
+	if !core.IsLiveLog(logname) {
+		cx.RespondWithErrorMessage("log type '"+logname+"' can not be followed", http.StatusBadRequest)
+		return
+	}
+	logpath, err := core.QMgr.GetReportPath(work_id, logname)
+	if err != nil {
+		cx.RespondWithErrorMessage(err.Error(), http.StatusBadRequest)
+		return
+	}
+	flusher, ok := cx.ResponseWriter.(http.Flusher)
+	if !ok {
+		cx.RespondWithErrorMessage("streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w := cx.ResponseWriter
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+
+	// older output that was already rotated
+	rotated := false
+	if oldfile, err := os.Open(logpath + ".1"); err == nil {
+		io.Copy(w, oldfile)
+		oldfile.Close()
+		rotated = true
+	}
+
+	var logfile *os.File
+	var fileinfo os.FileInfo
+	var pos int64
+	defer func() {
+		if logfile != nil {
+			logfile.Close()
+		}
+	}()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		// checked before reading, so the last chunks are not missed
+		running := core.QMgr.IsWorkRunning(work_id)
+
+		if logfile == nil {
+			if logfile, err = os.Open(logpath); err == nil {
+				fileinfo, err = logfile.Stat()
+				pos = 0
+			}
+			if err != nil {
+				logfile = nil
+			}
+		}
+		if logfile != nil {
+			n, _ := io.Copy(w, logfile)
+			pos += n
+			if current, err := os.Stat(logpath); err == nil && !os.SameFile(fileinfo, current) {
+				logfile.Close()
+				logfile = nil
+				if previous, err := os.Stat(logpath + ".1"); err == nil && os.SameFile(fileinfo, previous) {
+					// rotated, continue with the new file
+					rotated = true
+					continue
+				}
+				// replaced by the complete log of the finished workunit, send what was not streamed yet
+				if !rotated {
+					if logfile, err = os.Open(logpath); err == nil {
+						if _, err = logfile.Seek(pos, io.SeekStart); err == nil {
+							io.Copy(w, logfile)
+						}
+					} else {
+						logfile = nil
+					}
+				}
+				running = false
+			}
+		}
+		flusher.Flush()
+
+		if !running {
+			return
+		}
+		select {
+		case <-cx.Request.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
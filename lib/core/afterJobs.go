@@ -0,0 +1,236 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/MG-RAST/AWE/lib/acl"
+	"github.com/MG-RAST/AWE/lib/conf"
+	"github.com/MG-RAST/AWE/lib/db"
+	"github.com/MG-RAST/AWE/lib/user"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// conditions for Info.AfterJobs
+const (
+	AFTER_JOBS_COMPLETED = "completed" // default, upstream jobs have to complete, a permanent failure is propagated
+	AFTER_JOBS_ANY       = "any"       // upstream jobs only have to reach a terminal state
+)
+
+// JOB_STATS_TERMINAL states a job does not leave without user interaction
+var JOB_STATS_TERMINAL = []string{JOB_STAT_COMPLETED, JOB_STAT_FAILED_PERMANENT, JOB_STAT_DELETED}
+
+type jobDependencyDoc struct {
+	State string  `bson:"state"`
+	ACL   acl.Acl `bson:"acl"`
+	Info  struct {
+		Project   string   `bson:"project"`
+		AfterJobs []string `bson:"after_jobs"`
+	} `bson:"info"`
+}
+
+// dbGetJobDependencies returns state, upstream jobs, acl and project of a job, doc is nil if the job does not exist
+func dbGetJobDependencies(jobID string) (doc *jobDependencyDoc, err error) {
+	session := db.Connection.Session.Copy()
+	defer session.Close()
+
+	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_JOBS)
+
+	doc = &jobDependencyDoc{}
+	err = c.Find(bson.M{"id": jobID}).Select(bson.M{"state": 1, "acl": 1, "info.project": 1, "info.after_jobs": 1}).One(doc)
+	if err != nil {
+		doc = nil
+		if err == mgo.ErrNotFound {
+			err = nil
+			return
+		}
+		err = fmt.Errorf("(dbGetJobDependencies) job %s: %s", jobID, err.Error())
+		return
+	}
+	return
+}
+
+// upstreamStates caches the states of upstream jobs that are not in memory, an entry is
+// removed when the state of the job changes (see Job.SetState) or the job is deleted
+var upstreamStates = struct {
+	sync.RWMutex
+	states map[string]string // empty state for jobs that do not exist
+}{states: make(map[string]string)}
+
+// forgetUpstreamState is called when the state of a job changes
+func forgetUpstreamState(jobID string) {
+	upstreamStates.Lock()
+	delete(upstreamStates.states, jobID)
+	upstreamStates.Unlock()
+}
+
+// getUpstreamJobState prefers the job in memory, then the cache, found is false if the job does not exist
+func getUpstreamJobState(jobID string) (state string, found bool, err error) {
+	job, ok, err := JM.Get(jobID, true)
+	if err != nil {
+		return
+	}
+	if ok {
+		found = true
+		state, err = job.GetState(true)
+		return
+	}
+
+	upstreamStates.RLock()
+	state, ok = upstreamStates.states[jobID]
+	upstreamStates.RUnlock()
+	if ok {
+		found = state != ""
+		return
+	}
+
+	var doc *jobDependencyDoc
+	doc, err = dbGetJobDependencies(jobID)
+	if err != nil {
+		return
+	}
+	if doc != nil {
+		found = true
+		state = doc.State
+	}
+	upstreamStates.Lock()
+	upstreamStates.states[jobID] = state
+	upstreamStates.Unlock()
+	return
+}
+
+// canReadUpstreamJob same rules as reading the job (GET /job/{id}), upstream jobs the user cannot read are reported as not found
+func canReadUpstreamJob(u *user.User, roles *UserRoles, doc *jobDependencyDoc) bool {
+	if u == nil || u.Admin || doc.ACL.Owner == u.Uuid {
+		return true
+	}
+	if doc.ACL.Check(u.Uuid)["read"] || doc.ACL.Check("public")["read"] {
+		return true
+	}
+	return roles.ProjectRights(doc.Info.Project)["read"]
+}
+
+// ValidateAfterJobs checks the condition, that the user can read all upstream jobs and that they do not depend on the job itself.
+// The state of upstream jobs is reported as the reason why a job waits, so jobs of other users cannot be used.
+func ValidateAfterJobs(job *Job, u *user.User) (err error) {
+	info := job.Info
+	if info == nil || len(info.AfterJobs) == 0 {
+		return
+	}
+	if info.AfterJobsCond != "" && info.AfterJobsCond != AFTER_JOBS_COMPLETED && info.AfterJobsCond != AFTER_JOBS_ANY {
+		err = fmt.Errorf("(ValidateAfterJobs) unknown after_jobs_condition \"%s\" (supported: %s, %s)", info.AfterJobsCond, AFTER_JOBS_COMPLETED, AFTER_JOBS_ANY)
+		return
+	}
+
+	roles := NewUserRoles(u)
+	for _, id := range info.AfterJobs {
+		var doc *jobDependencyDoc
+		doc, err = dbGetJobDependencies(id)
+		if err != nil {
+			return
+		}
+		if doc == nil || !canReadUpstreamJob(u, roles, doc) {
+			err = fmt.Errorf("(ValidateAfterJobs) upstream job %s not found", id)
+			return
+		}
+	}
+
+	err = CheckDependencyCycle(job.ID, info.AfterJobs, func(id string) (afterJobs []string, found bool, err error) {
+		var doc *jobDependencyDoc
+		doc, err = dbGetJobDependencies(id)
+		if err != nil || doc == nil {
+			return
+		}
+		found = true
+		afterJobs = doc.Info.AfterJobs
+		return
+	})
+	if err != nil {
+		err = fmt.Errorf("(ValidateAfterJobs) %s", err.Error())
+	}
+	return
+}
+
+// CheckDependencyCycle depth-first search through the upstream jobs, the job must not be reachable from itself.
+// upstream returns the upstream jobs of a job, found is false if the job does not exist.
+func CheckDependencyCycle(jobID string, afterJobs []string, upstream func(id string) (afterJobs []string, found bool, err error)) (err error) {
+	visited := make(map[string]bool)
+	type entry struct {
+		id   string
+		path []string
+	}
+	stack := []entry{}
+	for _, id := range afterJobs {
+		stack = append(stack, entry{id: id, path: []string{jobID}})
+	}
+	for len(stack) > 0 {
+		e := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		path := append(append([]string{}, e.path...), e.id)
+		if e.id == jobID {
+			err = fmt.Errorf("dependency cycle: %s", strings.Join(path, " -> "))
+			return
+		}
+		if visited[e.id] {
+			continue
+		}
+		visited[e.id] = true
+
+		var next []string
+		var found bool
+		next, found, err = upstream(e.id)
+		if err != nil {
+			return
+		}
+		if !found {
+			err = fmt.Errorf("upstream job %s not found", e.id)
+			return
+		}
+		for _, id := range next {
+			stack = append(stack, entry{id: id, path: path})
+		}
+	}
+	return
+}
+
+// CheckAfterJobs returns if the upstream jobs allow the job to start, otherwise reason names the job it waits for.
+// failed is set if an upstream job can no longer complete and the condition is AFTER_JOBS_COMPLETED.
+func (info *Info) CheckAfterJobs() (ready bool, reason string, failed string, err error) {
+	for _, id := range info.AfterJobs {
+		var state string
+		var found bool
+		state, found, err = getUpstreamJobState(id)
+		if err != nil {
+			err = fmt.Errorf("(CheckAfterJobs) getUpstreamJobState returned: %s", err.Error())
+			return
+		}
+		if !found {
+			state = JOB_STAT_DELETED
+		}
+
+		if info.AfterJobsCond == AFTER_JOBS_ANY {
+			if !contains(JOB_STATS_TERMINAL, state) {
+				reason = fmt.Sprintf("waiting for job %s to finish (state: %s)", id, state)
+				return
+			}
+			continue
+		}
+
+		switch state {
+		case JOB_STAT_COMPLETED:
+			continue
+		case JOB_STAT_FAILED_PERMANENT, JOB_STAT_DELETED:
+			failed = fmt.Sprintf("upstream job %s did not complete (state: %s)", id, state)
+			reason = failed
+			return
+		default:
+			reason = fmt.Sprintf("waiting for job %s to complete (state: %s)", id, state)
+			return
+		}
+	}
+	ready = true
+	return
+}
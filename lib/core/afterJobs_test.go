@@ -0,0 +1,49 @@
+package core_test
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/MG-RAST/AWE/lib/core"
+)
+
+func dependencyGraph(graph map[string][]string) func(string) ([]string, bool, error) {
+	return func(id string) (afterJobs []string, found bool, err error) {
+		afterJobs, found = graph[id]
+		return
+	}
+}
+
+func TestCheckDependencyCycle(t *testing.T) {
+	graph := map[string][]string{
+		"a": {"b", "c"},
+		"b": {"c"},
+		"c": {},
+	}
+	if err := CheckDependencyCycle("new", []string{"a", "b"}, dependencyGraph(graph)); err != nil {
+		t.Fatal(err)
+	}
+
+	// new -> a -> b -> new, the job itself is already stored with its upstream jobs
+	graph["b"] = []string{"c", "new"}
+	graph["new"] = []string{"a"}
+	err := CheckDependencyCycle("new", []string{"a"}, dependencyGraph(graph))
+	if err == nil {
+		t.Fatal("expected a dependency cycle")
+	}
+	if !strings.Contains(err.Error(), "new -> a -> b -> new") {
+		t.Fatalf("expected the cycle in the error, got: %s", err.Error())
+	}
+
+	if err := CheckDependencyCycle("new", []string{"new"}, dependencyGraph(graph)); err == nil {
+		t.Fatal("expected a job depending on itself to be rejected")
+	}
+}
+
+func TestCheckDependencyCycleNotFound(t *testing.T) {
+	graph := map[string][]string{"a": {"missing"}}
+	err := CheckDependencyCycle("new", []string{"a"}, dependencyGraph(graph))
+	if err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Fatalf("expected missing upstream job to be reported, got: %v", err)
+	}
+}
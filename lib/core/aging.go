@@ -0,0 +1,150 @@
+package core
+
+import (
+	"strings"
+	"time"
+
+	"github.com/MG-RAST/AWE/lib/conf"
+)
+
+// AgingPolicy raises the priority of queued workunits with the time they wait, so that
+// low-priority jobs are not starved by a constant stream of high-priority jobs
+type AgingPolicy struct {
+	Rate int `bson:"rate" json:"rate"` // priority points per hour in the queue
+	Max  int `bson:"max" json:"max"`   // max points gained by waiting, 0 means unlimited
+}
+
+// DefaultAgingPolicy server default from priority_aging_rate and priority_aging_max, nil if aging is disabled
+func DefaultAgingPolicy() *AgingPolicy {
+	if conf.PRIORITY_AGING_RATE <= 0 {
+		return nil
+	}
+	return &AgingPolicy{Rate: conf.PRIORITY_AGING_RATE, Max: conf.PRIORITY_AGING_MAX}
+}
+
+// GetAgingPolicy aging policy of the clientgroup, server default if the clientgroup has none, nil if disabled
+func (cg *ClientGroup) GetAgingPolicy() *AgingPolicy {
+	if cg == nil || cg.Aging == nil {
+		return DefaultAgingPolicy()
+	}
+	if cg.Aging.Rate <= 0 {
+		return nil
+	}
+	return cg.Aging
+}
+
+// Priority returns the effective priority after waiting since the given time
+func (a *AgingPolicy) Priority(priority int, since time.Time, now time.Time) int {
+	if a == nil || a.Rate <= 0 || since.IsZero() || now.Before(since) {
+		return priority
+	}
+	bonus := int(now.Sub(since).Hours() * float64(a.Rate))
+	if a.Max > 0 && bonus > a.Max {
+		bonus = a.Max
+	}
+	return priority + bonus
+}
+
+// WorkunitPriority effective priority of a workunit, only queued workunits age
+func (a *AgingPolicy) WorkunitPriority(work *Workunit, now time.Time) int {
+	if work.Info == nil {
+		return 0
+	}
+	if work.State != WORK_STAT_QUEUED {
+		return work.Info.Priority
+	}
+	since := work.QueuedTime
+	if since.IsZero() {
+		since = work.Info.SubmitTime
+	}
+	return a.Priority(work.Info.Priority, since, now)
+}
+
+// JobPriority effective priority of a job, jobs age while they have workunits waiting for a client
+func (a *AgingPolicy) JobPriority(job *Job, now time.Time) int {
+	if job.Info == nil {
+		return 0
+	}
+	switch job.State {
+	case JOB_STAT_COMPLETED, JOB_STAT_DELETED, JOB_STAT_SUSPEND, JOB_STAT_FAILED_PERMANENT:
+		return job.Info.Priority
+	}
+	return a.Priority(job.Info.Priority, job.Info.SubmitTime, now)
+}
+
+// AgingPolicyOfClientGroup aging policy that applies to clients of the clientgroup, used for checkout and listings.
+// Server default for "" or an unknown clientgroup.
+func AgingPolicyOfClientGroup(name string) *AgingPolicy {
+	if name == "" {
+		return DefaultAgingPolicy()
+	}
+	cg, err := LoadClientGroupByName(name)
+	if err != nil {
+		cg = nil
+	}
+	return cg.GetAgingPolicy()
+}
+
+// AgingClientGroup the clientgroup whose clients would check out work of a job with these clientgroups: the requested
+// one if the job can run there, otherwise the only clientgroup of the job. "" if that is not known.
+func AgingClientGroup(clientgroups string, requested string) string {
+	var eligible []string
+	for _, name := range strings.Split(clientgroups, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			eligible = append(eligible, name)
+		}
+	}
+	if requested != "" && (len(eligible) == 0 || contains(eligible, requested)) {
+		return requested
+	}
+	if len(eligible) == 1 {
+		return eligible[0]
+	}
+	return ""
+}
+
+// agingCache looks up the aging policy of clientgroups once per listing
+type agingCache map[string]*AgingPolicy
+
+func (c agingCache) get(clientgroups string, requested string) *AgingPolicy {
+	name := AgingClientGroup(clientgroups, requested)
+	aging, ok := c[name]
+	if !ok {
+		aging = AgingPolicyOfClientGroup(name)
+		c[name] = aging
+	}
+	return aging
+}
+
+// EffectivePriorities copies of the workunits with EffectivePriority set, for listings. The workunits are those of the
+// queue and must not be modified. clientgroup is the clientgroup the priorities are computed for (see AgingClientGroup).
+func EffectivePriorities(workunits []*Workunit, clientgroup string) (copies []*Workunit) {
+	cache := agingCache{}
+	now := time.Now()
+	copies = make([]*Workunit, 0, len(workunits))
+	for _, work := range workunits {
+		workCopy := *work
+		if workCopy.Info != nil {
+			workCopy.EffectivePriority = cache.get(workCopy.Info.ClientGroups, clientgroup).WorkunitPriority(&workCopy, now)
+		}
+		copies = append(copies, &workCopy)
+	}
+	return
+}
+
+// SetJobEffectivePriorities sets EffectivePriority of jobs for listings, the jobs have been loaded from the database
+// for the response. clientgroup is the clientgroup the priorities are computed for (see AgingClientGroup).
+func SetJobEffectivePriorities(jobs []*Job, clientgroup string) {
+	cache := agingCache{}
+	now := time.Now()
+	for _, job := range jobs {
+		readLock, err := job.RLockNamed("SetJobEffectivePriorities")
+		if err != nil {
+			continue
+		}
+		if job.Info != nil {
+			job.EffectivePriority = cache.get(job.Info.ClientGroups, clientgroup).JobPriority(job, now)
+		}
+		job.RUnlockNamed(readLock)
+	}
+}
@@ -0,0 +1,426 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/MG-RAST/AWE/lib/conf"
+	"github.com/MG-RAST/AWE/lib/core/cwl"
+	"github.com/MG-RAST/AWE/lib/db"
+	"github.com/MG-RAST/AWE/lib/logger"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// CallCacheEntry outputs of a CommandLineTool workunit, reused by later workunits with the same key instead of running the tool again
+type CallCacheEntry struct {
+	Key       string      `bson:"key" json:"key"`     // see NewCallCacheKey
+	Owner     string      `bson:"owner" json:"owner"` // outputs are only reused for jobs of the same user
+	JobID     string      `bson:"job_id" json:"job_id"`
+	TaskID    string      `bson:"task_id" json:"task_id"`
+	Tool      string      `bson:"tool" json:"tool"`
+	CreatedOn time.Time   `bson:"created_on" json:"created_on"`
+	Hits      int         `bson:"hits" json:"hits"`
+	LastHit   time.Time   `bson:"last_hit" json:"last_hit"`
+	Outputs   interface{} `bson:"outputs" json:"outputs"` // cwl.Job_document
+}
+
+// CallCacheEntries _
+type CallCacheEntries []*CallCacheEntry
+
+// InitCallCacheDB _
+func InitCallCacheDB() {
+	session := db.Connection.Session.Copy()
+	defer session.Close()
+	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_CALL_CACHE)
+	c.EnsureIndex(mgo.Index{Key: []string{"key", "owner"}, Unique: true})
+	c.EnsureIndex(mgo.Index{Key: []string{"owner"}, Background: true})
+	c.EnsureIndex(mgo.Index{Key: []string{"job_id"}, Background: true})
+}
+
+// CallCacheEnabled checks the server config, the job (info.nocache) and the CWL requirement WorkReuse of the tool.
+// Only CommandLineTool workunits are cached.
+func CallCacheEnabled(job *Job, work *Workunit) (enabled bool, err error) {
+	if !conf.CALL_CACHE || job.Info == nil || job.Info.NoCache || work.CWLWorkunit == nil {
+		return
+	}
+	clt, ok := work.CWLWorkunit.Tool.(*cwl.CommandLineTool)
+	if !ok {
+		return
+	}
+
+	wr := cwl.GetWorkReuse(clt.Requirements)
+	if wr == nil {
+		wr = cwl.GetWorkReuse(clt.Hints)
+	}
+	if wr != nil {
+		enabled, err = wr.IsEnabled()
+		if err != nil {
+			err = fmt.Errorf("(CallCacheEnabled) WorkReuse: %s", err.Error())
+		}
+		return
+	}
+
+	enabled = true
+	return
+}
+
+// DockerImageOfTool docker image of the DockerRequirement of the tool (requirements before hints), dockerImageId if set,
+// otherwise dockerPull. Returns "" if the tool has no DockerRequirement.
+func DockerImageOfTool(clt *cwl.CommandLineTool) (dockerImage string) {
+	for _, requirements := range [][]cwl.Requirement{clt.Requirements, clt.Hints} {
+		for _, r := range requirements {
+			dr, isDocker := r.(*cwl.DockerRequirement)
+			if !isDocker {
+				continue
+			}
+			dockerImage = dr.DockerImageId
+			if dockerImage == "" {
+				dockerImage = dr.DockerPull
+			}
+			if dockerImage != "" {
+				return
+			}
+		}
+	}
+	return
+}
+
+// NewCallCacheKey hashes the evaluated CommandLineTool, its docker image and the inputs of the workunit.
+// Files are identified by the checksum computed when they were uploaded, by their location if there is none.
+// dockerImage is the image of the DockerRequirement pinned with ResolveDockerImage, so that outputs of the old
+// image are not reused after a tag was moved.
+func NewCallCacheKey(work *Workunit, dockerImage string) (key string, err error) {
+	if work.CWLWorkunit == nil {
+		err = fmt.Errorf("(NewCallCacheKey) not a CWL workunit")
+		return
+	}
+	clt, ok := work.CWLWorkunit.Tool.(*cwl.CommandLineTool)
+	if !ok {
+		err = fmt.Errorf("(NewCallCacheKey) tool is not a CommandLineTool")
+		return
+	}
+
+	h := sha256.New()
+
+	var toolBytes []byte
+	toolBytes, err = json.Marshal(clt)
+	if err != nil {
+		err = fmt.Errorf("(NewCallCacheKey) json.Marshal returned: %s", err.Error())
+		return
+	}
+	fmt.Fprintf(h, "tool %s\n", toolBytes)
+
+	fmt.Fprintf(h, "docker %s\n", dockerImage)
+
+	if work.CWLWorkunit.JobInput != nil {
+		inputs := map[string]cwl.CWLType{}
+		var ids []string
+		for _, named := range *work.CWLWorkunit.JobInput {
+			id := path.Base(named.ID)
+			inputs[id] = named.Value
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			fmt.Fprintf(h, "input %s\n", id)
+			err = writeCallCacheValue(h, inputs[id])
+			if err != nil {
+				err = fmt.Errorf("(NewCallCacheKey) input %s: %s", id, err.Error())
+				return
+			}
+		}
+	}
+
+	key = hex.EncodeToString(h.Sum(nil))
+	return
+}
+
+func writeCallCacheValue(h hash.Hash, value interface{}) (err error) {
+	switch value.(type) {
+	case *cwl.File:
+		file := value.(*cwl.File)
+		content := file.Checksum
+		if content == "" {
+			content = file.Location
+		}
+		fmt.Fprintf(h, "File %s %s %s\n", file.Basename, content, file.Contents)
+		for _, secondary := range file.SecondaryFiles {
+			err = writeCallCacheValue(h, secondary)
+			if err != nil {
+				return
+			}
+		}
+	case *cwl.Directory:
+		dir := value.(*cwl.Directory)
+		fmt.Fprintf(h, "Directory %s %s %d\n", dir.Basename, dir.Location, len(dir.Listing))
+		for _, elem := range dir.Listing {
+			err = writeCallCacheValue(h, elem)
+			if err != nil {
+				return
+			}
+		}
+	case *cwl.Array:
+		array := value.(*cwl.Array)
+		fmt.Fprintf(h, "Array %d\n", len(*array))
+		for _, elem := range *array {
+			err = writeCallCacheValue(h, elem)
+			if err != nil {
+				return
+			}
+		}
+	case *cwl.Record:
+		record := value.(*cwl.Record)
+		var keys []string
+		for key := range *record {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		fmt.Fprintf(h, "Record %d\n", len(keys))
+		for _, key := range keys {
+			fmt.Fprintf(h, "field %s\n", key)
+			err = writeCallCacheValue(h, (*record)[key])
+			if err != nil {
+				return
+			}
+		}
+	default:
+		var valueBytes []byte
+		valueBytes, err = json.Marshal(value)
+		if err != nil {
+			err = fmt.Errorf("(writeCallCacheValue) json.Marshal returned: %s", err.Error())
+			return
+		}
+		fmt.Fprintf(h, "%s\n", valueBytes)
+	}
+	return
+}
+
+// LoadCallCacheEntry ok is false if there is no entry for the key and owner
+func LoadCallCacheEntry(key string, owner string) (entry *CallCacheEntry, ok bool, err error) {
+	session := db.Connection.Session.Copy()
+	defer session.Close()
+	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_CALL_CACHE)
+
+	entry = &CallCacheEntry{}
+	err = c.Find(bson.M{"key": key, "owner": owner}).One(entry)
+	if err != nil {
+		entry = nil
+		if err == mgo.ErrNotFound {
+			err = nil
+			return
+		}
+		err = fmt.Errorf("(LoadCallCacheEntry) key %s: %s", key, err.Error())
+		return
+	}
+	ok = true
+	return
+}
+
+// Save replaces an existing entry with the same key and owner
+func (entry *CallCacheEntry) Save() (err error) {
+	session := db.Connection.Session.Copy()
+	defer session.Close()
+	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_CALL_CACHE)
+	_, err = c.Upsert(bson.M{"key": entry.Key, "owner": entry.Owner}, entry)
+	if err != nil {
+		err = fmt.Errorf("(CallCacheEntry/Save) key %s: %s", entry.Key, err.Error())
+	}
+	return
+}
+
+// AddHit counts the reuse of the entry
+func (entry *CallCacheEntry) AddHit() (err error) {
+	session := db.Connection.Session.Copy()
+	defer session.Close()
+	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_CALL_CACHE)
+	entry.Hits++
+	entry.LastHit = time.Now()
+	err = c.Update(bson.M{"key": entry.Key, "owner": entry.Owner}, bson.M{"$inc": bson.M{"hits": 1}, "$set": bson.M{"last_hit": entry.LastHit}})
+	if err != nil {
+		err = fmt.Errorf("(CallCacheEntry/AddHit) key %s: %s", entry.Key, err.Error())
+	}
+	return
+}
+
+// GetOutputs _
+func (entry *CallCacheEntry) GetOutputs(context *cwl.WorkflowContext) (outputs *cwl.Job_document, err error) {
+	outputs, err = cwl.NewJob_documentFromNamedTypes(entry.Outputs, context)
+	if err != nil {
+		err = fmt.Errorf("(CallCacheEntry/GetOutputs) NewJob_documentFromNamedTypes returned: %s", err.Error())
+	}
+	return
+}
+
+// DeleteCallCacheEntries purges all entries matching the query, e.g. of one owner or one job
+func DeleteCallCacheEntries(q bson.M) (count int, err error) {
+	session := db.Connection.Session.Copy()
+	defer session.Close()
+	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_CALL_CACHE)
+	var info *mgo.ChangeInfo
+	info, err = c.RemoveAll(q)
+	if err != nil {
+		err = fmt.Errorf("(DeleteCallCacheEntries) %s", err.Error())
+		return
+	}
+	count = info.Removed
+	return
+}
+
+// LoadJobCallCacheEntries entries with the outputs of the job
+func LoadJobCallCacheEntries(jobID string) (entries CallCacheEntries, err error) {
+	session := db.Connection.Session.Copy()
+	defer session.Close()
+	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_CALL_CACHE)
+	err = c.Find(bson.M{"job_id": jobID}).All(&entries)
+	if err != nil {
+		err = fmt.Errorf("(LoadJobCallCacheEntries) job %s: %s", jobID, err.Error())
+	}
+	return
+}
+
+// removeUnused deletes the entry only if it has not been reused, removed is false if it got a hit in the meantime
+func (entry *CallCacheEntry) removeUnused() (removed bool, err error) {
+	session := db.Connection.Session.Copy()
+	defer session.Close()
+	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_CALL_CACHE)
+	err = c.Remove(bson.M{"key": entry.Key, "owner": entry.Owner, "hits": 0})
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			err = nil
+			return
+		}
+		err = fmt.Errorf("(CallCacheEntry/removeUnused) key %s: %s", entry.Key, err.Error())
+		return
+	}
+	removed = true
+	return
+}
+
+// GetPaginated _
+func (entries *CallCacheEntries) GetPaginated(q bson.M, limit int, offset int, order string, direction string) (count int, err error) {
+	if direction == "desc" {
+		order = "-" + order
+	}
+	session := db.Connection.Session.Copy()
+	defer session.Close()
+	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_CALL_CACHE)
+	query := c.Find(q).Select(bson.M{"outputs": 0})
+	if count, err = query.Count(); err != nil {
+		return
+	}
+	err = query.Sort(order).Limit(limit).Skip(offset).All(entries)
+	return
+}
+
+// checkCallCache looks up the outputs of the workunit in the call cache. On a hit the returned internal notice completes
+// the workunit with the cached outputs (same as for skipped steps, see skipTask), the caller adds it with AddInternal
+// instead of queueing it. The workunit must not be in the workQueue yet.
+func (qm *ServerMgr) checkCallCache(job *Job, task *Task, work *Workunit) (notice *Notice, err error) {
+
+	var enabled bool
+	enabled, err = CallCacheEnabled(job, work)
+	if err != nil {
+		err = fmt.Errorf("(checkCallCache) CallCacheEnabled returned: %s", err.Error())
+		return
+	}
+	if !enabled {
+		return
+	}
+
+	// the step is run without call cache if the tag was not resolved at submission
+	image := DockerImageOfTool(work.CWLWorkunit.Tool.(*cwl.CommandLineTool))
+	dockerImage, ok := job.pinnedDockerImage(image)
+	if !ok {
+		logger.Debug(1, "(checkCallCache) call cache disabled for workunit %s: image %s was not resolved", work.ID, image)
+		return
+	}
+
+	var key string
+	key, err = NewCallCacheKey(work, dockerImage)
+	if err != nil {
+		err = fmt.Errorf("(checkCallCache) NewCallCacheKey returned: %s", err.Error())
+		return
+	}
+	work.CallCacheKey = key
+
+	var entry *CallCacheEntry
+	entry, ok, err = LoadCallCacheEntry(key, work.Owner)
+	if err != nil {
+		err = fmt.Errorf("(checkCallCache) LoadCallCacheEntry returned: %s", err.Error())
+		return
+	}
+	if !ok {
+		return
+	}
+
+	var outputs *cwl.Job_document
+	outputs, err = entry.GetOutputs(job.WorkflowContext)
+	if err != nil {
+		err = fmt.Errorf("(checkCallCache) entry.GetOutputs returned: %s", err.Error())
+		return
+	}
+
+	// the entry may have been purged by retention since it was loaded, then the step is run
+	err = entry.AddHit()
+	if err != nil {
+		logger.Warning("(checkCallCache) entry.AddHit returned: %s, running workunit %s", err.Error(), work.ID)
+		err = nil
+		return
+	}
+
+	note := fmt.Sprintf("outputs reused from call cache (job %s, task %s)", entry.JobID, entry.TaskID)
+	// no new entry for a hit
+	work.CallCacheKey = ""
+
+	if entry.JobID != job.ID {
+		err = task.SetReusedFrom(entry.JobID, true)
+		if err != nil {
+			err = fmt.Errorf("(checkCallCache) task.SetReusedFrom returned: %s", err.Error())
+			return
+		}
+	}
+
+	notice = &Notice{}
+	notice.WorkerID = "_internal"
+	notice.ID = work.Workunit_Unique_Identifier
+	notice.Status = WORK_STAT_DONE
+	notice.Notes = note
+	notice.Results = outputs
+	return
+}
+
+// saveCallCache stores the outputs a worker reported for a workunit that has a call cache key
+func saveCallCache(work *Workunit, task *Task, results *cwl.Job_document) (err error) {
+	if work.CallCacheKey == "" || results == nil {
+		return
+	}
+
+	var taskStr string
+	taskStr, err = task.String()
+	if err != nil {
+		err = fmt.Errorf("(saveCallCache) task.String returned: %s", err.Error())
+		return
+	}
+
+	entry := &CallCacheEntry{
+		Key:       work.CallCacheKey,
+		Owner:     work.Owner,
+		JobID:     work.JobId,
+		TaskID:    taskStr,
+		CreatedOn: time.Now(),
+		Outputs:   *results,
+	}
+	if clt, ok := work.CWLWorkunit.Tool.(*cwl.CommandLineTool); ok {
+		entry.Tool = clt.ID
+	}
+
+	err = entry.Save()
+	return
+}
@@ -0,0 +1,89 @@
+package core_test
+
+import (
+	"testing"
+
+	. "github.com/MG-RAST/AWE/lib/core"
+	"github.com/MG-RAST/AWE/lib/core/cwl"
+)
+
+func newCallCacheWorkunit(location string, checksum string) *Workunit {
+	file := cwl.NewFile()
+	file.Basename = "input.fasta"
+	file.Location = location
+	file.Checksum = checksum
+
+	jobInput := cwl.Job_document{
+		cwl.NewNamedCWLType("#main/step/input", file),
+		cwl.NewNamedCWLType("#main/step/threads", cwl.NewString("4")),
+	}
+
+	clt := &cwl.CommandLineTool{}
+	clt.ID = "#tool.cwl"
+	clt.Requirements = []cwl.Requirement{&cwl.DockerRequirement{DockerPull: "ubuntu:18.04"}}
+
+	return &Workunit{CWLWorkunit: &CWLWorkunit{Tool: clt, JobInput: &jobInput}}
+}
+
+func TestNewCallCacheKey(t *testing.T) {
+	key, err := NewCallCacheKey(newCallCacheWorkunit("http://shock/node/a", "sha1$1234"), "ubuntu@sha256:1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// same content uploaded again
+	other, err := NewCallCacheKey(newCallCacheWorkunit("http://shock/node/b", "sha1$1234"), "ubuntu@sha256:1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != other {
+		t.Errorf("expected the same key for files with the same checksum")
+	}
+
+	other, err = NewCallCacheKey(newCallCacheWorkunit("http://shock/node/a", "sha1$5678"), "ubuntu@sha256:1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key == other {
+		t.Errorf("expected a different key for a different checksum")
+	}
+
+	// tag moved to a new image
+	other, err = NewCallCacheKey(newCallCacheWorkunit("http://shock/node/a", "sha1$1234"), "ubuntu@sha256:5678")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key == other {
+		t.Errorf("expected a different key for a different docker image")
+	}
+}
+
+func TestParseDockerReference(t *testing.T) {
+	for _, c := range []struct{ image, domain, repository, tag string }{
+		{"ubuntu", "registry-1.docker.io", "library/ubuntu", "latest"},
+		{"ubuntu:18.04", "registry-1.docker.io", "library/ubuntu", "18.04"},
+		{"mgrast/pipeline:4.04", "registry-1.docker.io", "mgrast/pipeline", "4.04"},
+		{"docker.io/mgrast/pipeline", "registry-1.docker.io", "mgrast/pipeline", "latest"},
+		{"quay.io/biocontainers/samtools:1.9", "quay.io", "biocontainers/samtools", "1.9"},
+		{"registry.example.org:5000/tools/bwa", "registry.example.org:5000", "tools/bwa", "latest"},
+	} {
+		domain, repository, tag, err := ParseDockerReference(c.image)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if domain != c.domain || repository != c.repository || tag != c.tag {
+			t.Errorf("%s: got %s %s %s", c.image, domain, repository, tag)
+		}
+	}
+
+	// pinned images are not resolved
+	for _, image := range []string{"", "ubuntu@sha256:1234", "sha256:1234"} {
+		resolved, err := ResolveDockerImage(image)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resolved != image {
+			t.Errorf("expected %s unchanged, got %s", image, resolved)
+		}
+	}
+}
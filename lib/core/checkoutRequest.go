@@ -3,9 +3,11 @@ package core
 // CheckoutRequest Object used by worker to request a workunit
 type CheckoutRequest struct {
 	policy     string
+	aging      *AgingPolicy // nil means no aging
 	fromclient string
 	//fromclient *Client
 	available int64
+	free      *WorkResources // cores and RAM not reserved by workunits running on the client, nil if not reported
 	count     int
 	response  chan CoAck
 }
@@ -29,6 +29,7 @@ type Client struct {
 	TotalCompleted  int           `bson:"total_completed" json:"total_completed"`
 	TotalFailed     int           `bson:"total_failed" json:"total_failed"`
 	SkipWork        []string      `bson:"skip_work" json:"skip_work"`
+	RecentJobs      []string      `bson:"recent_jobs" json:"recent_jobs"` // jobs of recently checked out workunits, most recent last (used by Locality policy)
 	LastFailed      int           `bson:"-" json:"-"`
 	Tag             bool          `bson:"-" json:"-"`
 	Proxy           bool          `bson:"proxy" json:"proxy"`
@@ -38,6 +39,8 @@ type Client struct {
 	SuspendReason   string        `bson:"suspend_reason" json:"suspend_reason"` // a state
 	Status          string        `bson:"Status" json:"Status"`                 // 0) unhealthy 1) suspended? 2) busy ? 3) online (call is idle) 4) offline
 	AssignedWork    *WorkunitList `bson:"assigned_work" json:"assigned_work"`   // this is for exporting into json
+
+	Commands []*ClientCommand `bson:"commands" json:"commands"` // server only: commands not yet acknowledged by the worker
 }
 
 // WorkerRuntime worker info that does not change at runtime
@@ -53,6 +56,8 @@ type WorkerRuntime struct {
 	Hostname string   `bson:"hostname" json:"hostname"`
 	HostIP   string   `bson:"host_ip" json:"host_ip"` // Host can be physical machine or VM, whatever is helpful for management
 	CPUs     int      `bson:"cores" json:"cores"`
+	Memory   int64    `bson:"memory_mb" json:"memory_mb"` // total RAM in MiB
+	Slots    int      `bson:"slots" json:"slots"`         // number of workunits the worker runs concurrently
 	Apps     []string `bson:"apps" json:"apps"`
 	//GitCommitHash string   `bson:"git_commit_hash" json:"git_commit_hash"`
 	Version string `bson:"version" json:"version"`
@@ -65,6 +70,11 @@ type WorkerState struct {
 	Busy         bool          `bson:"busy" json:"busy"` // a state
 	CurrentWork  *WorkunitList `bson:"current_work" json:"current_work"`
 	ServerUUID   string        `bson:"server_uuid,omitempty" json:"server_uuid,omitempty" ` //this is what the worker thinks its server is / mostly for debugging
+	MemoryFree   int64         `bson:"memory_free_mb" json:"memory_free_mb"`                // available RAM in MiB
+	DiskFree     int64         `bson:"disk_free_mb" json:"disk_free_mb"`                    // available disk space in work directory in MiB
+
+	Draining       bool                   `bson:"draining" json:"draining"`                                   // finishes current workunits, checks out nothing new
+	CommandResults []*ClientCommandResult `bson:"command_results,omitempty" json:"command_results,omitempty"` // commands received with heartbeats, most recent last
 }
 
 // RegistrationResponse _
@@ -227,6 +237,31 @@ func (client *Client) AppendSkipwork(workid Workunit_Unique_Identifier, writeLoc
 	return
 }
 
+// AddRecentJob remembers the job of a checked out workunit, used by the Locality policy
+func (client *Client) AddRecentJob(jobid string, writeLock bool) (err error) {
+	if writeLock {
+		err = client.LockNamed("AddRecentJob")
+		if err != nil {
+			return
+		}
+		defer client.Unlock()
+	}
+
+	recent := []string{}
+	for _, id := range client.RecentJobs {
+		if id != jobid {
+			recent = append(recent, id)
+		}
+	}
+	recent = append(recent, jobid)
+	if len(recent) > recentJobsMax {
+		recent = recent[len(recent)-recentJobsMax:]
+	}
+	client.RecentJobs = recent
+
+	return
+}
+
 // ContainsSkipWorkNolock _
 func (client *Client) ContainsSkipWorkNolock(workid string) (c bool) {
 	c = contains(client.SkipWork, workid)
@@ -405,6 +440,33 @@ func (client *Client) SetBusy(b bool, writeLock bool) (err error) {
 	return
 }
 
+// SetDraining the worker sets it from the heartbeat, the workStealer reads it
+func (client *Client) SetDraining(d bool, writeLock bool) (err error) {
+	if writeLock {
+		err = client.LockNamed("SetDraining")
+		if err != nil {
+			return
+		}
+		defer client.Unlock()
+	}
+	client.Draining = d
+	return
+}
+
+// GetDraining _
+func (client *Client) GetDraining(doReadLock bool) (d bool, err error) {
+	if doReadLock {
+		readLock, xerr := client.RLockNamed("GetDraining")
+		if xerr != nil {
+			err = xerr
+			return
+		}
+		defer client.RUnlockNamed(readLock)
+	}
+	d = client.Draining
+	return
+}
+
 // GetBusy _
 func (client *Client) GetBusy(doReadLock bool) (b bool, err error) {
 	if doReadLock {
@@ -0,0 +1,143 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	uuid "github.com/MG-RAST/golib/go-uuid/uuid"
+)
+
+// commands for workers, issued with PUT /client/{id}?<command> and delivered with the next heartbeat
+const (
+	CLIENT_CMD_DRAIN      = "drain"      // finish current workunits, check out nothing new
+	CLIENT_CMD_UNDRAIN    = "undrain"    // check out workunits again
+	CLIENT_CMD_RESTART    = "restart"    // drain, then restart the worker process
+	CLIENT_CMD_CLEANDISK  = "cleandisk"  // remove work directories of workunits that are not running
+	CLIENT_CMD_DEBUGLEVEL = "debuglevel" // set the debug level of the worker, argument is the level
+)
+
+// ClientCommands all commands a worker understands
+var ClientCommands = []string{CLIENT_CMD_DRAIN, CLIENT_CMD_UNDRAIN, CLIENT_CMD_RESTART, CLIENT_CMD_CLEANDISK, CLIENT_CMD_DEBUGLEVEL}
+
+// number of command results a worker reports
+const clientCommandResultsMax = 10
+
+// ClientCommand a command for a worker, the server sends it with every heartbeat until the worker acknowledges it
+type ClientCommand struct {
+	ID     string    `bson:"id" json:"id"`
+	Op     string    `bson:"op" json:"op"`
+	Arg    string    `bson:"arg,omitempty" json:"arg,omitempty"`
+	Issued time.Time `bson:"issued" json:"issued"`
+	User   string    `bson:"user,omitempty" json:"user,omitempty"` // uuid of the user who issued the command
+}
+
+// ClientCommandResult acknowledgement and result of a command, reported by the worker in WorkerState
+type ClientCommandResult struct {
+	ID       string    `bson:"id" json:"id"`
+	Op       string    `bson:"op" json:"op"`
+	Received time.Time `bson:"received" json:"received"`
+	Result   string    `bson:"result,omitempty" json:"result,omitempty"`
+	Error    string    `bson:"error,omitempty" json:"error,omitempty"`
+}
+
+// NewClientCommand validates the command
+func NewClientCommand(op string, arg string, userID string) (cmd *ClientCommand, err error) {
+	if !contains(ClientCommands, op) {
+		err = fmt.Errorf("(NewClientCommand) unknown command \"%s\" (supported: %s)", op, strings.Join(ClientCommands, ", "))
+		return
+	}
+	if op == CLIENT_CMD_DEBUGLEVEL {
+		level, xerr := strconv.Atoi(arg)
+		if xerr != nil || level < 0 || level > 3 {
+			err = fmt.Errorf("(NewClientCommand) debuglevel must be between 0 and 3")
+			return
+		}
+	} else {
+		arg = ""
+	}
+	cmd = &ClientCommand{ID: uuid.New(), Op: op, Arg: arg, Issued: time.Now(), User: userID}
+	return
+}
+
+// Instruction encodes the command for HeartbeatInstructions, the key is the op
+func (cmd *ClientCommand) Instruction() string {
+	if cmd.Arg == "" {
+		return cmd.ID
+	}
+	return cmd.ID + ":" + cmd.Arg
+}
+
+// ParseClientCommand decodes a command from HeartbeatInstructions, returns nil if op is not a command
+func ParseClientCommand(op string, instruction string) (cmd *ClientCommand) {
+	if !contains(ClientCommands, op) {
+		return
+	}
+	parts := strings.SplitN(instruction, ":", 2)
+	cmd = &ClientCommand{ID: parts[0], Op: op}
+	if len(parts) > 1 {
+		cmd.Arg = parts[1]
+	}
+	return
+}
+
+// AddCommand queues a command for the worker. Only the latest command of each op is kept,
+// drain and undrain replace each other.
+func (client *Client) AddCommand(cmd *ClientCommand, writeLock bool) (err error) {
+	if writeLock {
+		err = client.LockNamed("AddCommand")
+		if err != nil {
+			return
+		}
+		defer client.Unlock()
+	}
+
+	commands := []*ClientCommand{}
+	for _, pending := range client.Commands {
+		if pending.Op == cmd.Op {
+			continue
+		}
+		if (pending.Op == CLIENT_CMD_DRAIN || pending.Op == CLIENT_CMD_UNDRAIN) && (cmd.Op == CLIENT_CMD_DRAIN || cmd.Op == CLIENT_CMD_UNDRAIN) {
+			continue
+		}
+		commands = append(commands, pending)
+	}
+	client.Commands = append(commands, cmd)
+	return
+}
+
+// ackCommands removes the commands the worker has acknowledged and adds the others to the heartbeat instructions
+func (client *Client) ackCommands(hbmsg HeartbeatInstructions) {
+	acknowledged := make(map[string]bool, len(client.CommandResults))
+	for _, result := range client.CommandResults {
+		acknowledged[result.ID] = true
+	}
+	pending := []*ClientCommand{}
+	for _, cmd := range client.Commands {
+		if acknowledged[cmd.ID] {
+			continue
+		}
+		pending = append(pending, cmd)
+		hbmsg[cmd.Op] = cmd.Instruction()
+	}
+	client.Commands = pending
+}
+
+// AddCommandResult records the result of a command in the worker state, the oldest results are dropped
+func (ws *WorkerState) AddCommandResult(result *ClientCommandResult) {
+	ws.CommandResults = append(ws.CommandResults, result)
+	if len(ws.CommandResults) > clientCommandResultsMax {
+		ws.CommandResults = ws.CommandResults[len(ws.CommandResults)-clientCommandResultsMax:]
+	}
+}
+
+// HasCommandResult true if the worker has already executed the command, the server repeats it until it sees the result
+func (ws *WorkerState) HasCommandResult(id string) bool {
+	for _, result := range ws.CommandResults {
+		if result.ID == id {
+			return true
+		}
+	}
+	return false
+}
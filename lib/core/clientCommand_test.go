@@ -0,0 +1,42 @@
+package core_test
+
+import (
+	"testing"
+
+	. "github.com/MG-RAST/AWE/lib/core"
+)
+
+func TestClientCommand(t *testing.T) {
+	if _, err := NewClientCommand("reboot", "", "user"); err == nil {
+		t.Errorf("expected error for unknown command")
+	}
+	if _, err := NewClientCommand(CLIENT_CMD_DEBUGLEVEL, "9", "user"); err == nil {
+		t.Errorf("expected error for invalid debuglevel")
+	}
+
+	cmd, err := NewClientCommand(CLIENT_CMD_DEBUGLEVEL, "2", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed := ParseClientCommand(cmd.Op, cmd.Instruction())
+	if parsed == nil || parsed.ID != cmd.ID || parsed.Arg != "2" {
+		t.Errorf("command did not survive encoding: %+v", parsed)
+	}
+	if ParseClientCommand("server-uuid", "abc") != nil {
+		t.Errorf("server-uuid is not a command")
+	}
+
+	// undrain replaces a drain that has not been delivered yet
+	client := NewClient()
+	drain, _ := NewClientCommand(CLIENT_CMD_DRAIN, "", "user")
+	undrain, _ := NewClientCommand(CLIENT_CMD_UNDRAIN, "", "user")
+	clean, _ := NewClientCommand(CLIENT_CMD_CLEANDISK, "", "user")
+	for _, c := range []*ClientCommand{drain, clean, undrain} {
+		if err = client.AddCommand(c, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(client.Commands) != 2 || client.Commands[0] != clean || client.Commands[1] != undrain {
+		t.Errorf("expected cleandisk and undrain to be pending, got %d commands", len(client.Commands))
+	}
+}
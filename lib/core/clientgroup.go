@@ -26,6 +26,9 @@ type ClientGroup struct {
 	CreatedOn    time.Time                     `bson:"created_on" json:"created_on"`
 	Expiration   time.Time                     `bson:"expiration" json:"expiration"`
 	LastModified time.Time                     `bson:"last_modified" json:"last_modified"`
+	Policy       string                        `bson:"policy" json:"policy"` // workunit selection policy, empty means server default
+
+	Aging *AgingPolicy `bson:"aging,omitempty" json:"aging,omitempty"` // priority aging, nil means server default
 }
 
 var (
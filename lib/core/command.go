@@ -21,6 +21,8 @@ type Command struct {
 	Description   string   `bson:"description" json:"description" mapstructure:"description"`
 	ParsedArgs    []string `bson:"-" json:"-" mapstructure:"-"`
 	Local         bool     // indicates local execution, i.e. working directory is same as current working directory (do not delete !)
+
+	Resources *WorkResources `bson:"resources,omitempty" json:"resources,omitempty" mapstructure:"resources,omitempty"` // same as the CWL ResourceRequirement, used for matching and container limits
 }
 
 // Envs _
@@ -233,6 +233,24 @@ func CreateJobImport(u *user.User, file FormFile) (job *Job, err error) {
 	return
 }
 
+// ValidateJobSubmission checks the settings of a new job that do not depend on how it was submitted (notification,
+// retention and upstream jobs), for JobController.Create and the schedules. The project role and the quotas
+// of the user are checked separately, see CheckProjectSubmission and Quotas.CheckSubmission.
+func ValidateJobSubmission(job *Job, u *user.User) (err error) {
+	if job.Info.Notification != nil {
+		err = job.Info.Notification.Validate()
+		if err != nil {
+			return
+		}
+	}
+	err = ValidateRetention(job.Info.Retention)
+	if err != nil {
+		return
+	}
+	err = ValidateAfterJobs(job, u)
+	return
+}
+
 // ReadJobFile _
 func ReadJobFile(filename string) (job *Job, err error) {
 	job = NewJob()
@@ -520,6 +538,9 @@ func NotifyWorkunitProcessedWithLogs(work *Workunit, perf *WorkPerf, sendstdlogs
 	} else {
 		// old AWE style result reporting (note that nodes had been created by the AWE server)
 		targetURL = fmt.Sprintf("%s/work/%s?status=%s&client=%s&computetime=%d", conf.SERVER_URL, workIDb64, work.State, Self.ID, work.ComputeTime)
+		if work.State == WORK_STAT_ERROR {
+			targetURL += fmt.Sprintf("&exitstatus=%d&failure=%s", work.ExitStatus, work.Failure)
+		}
 	}
 	form := httpclient.NewForm()
 	hasreport := false
@@ -553,6 +574,8 @@ func NotifyWorkunitProcessedWithLogs(work *Workunit, perf *WorkPerf, sendstdlogs
 		cwlResult.Results = work.CWLWorkunit.Outputs
 		cwlResult.Status = work.State
 		cwlResult.ComputeTime = work.ComputeTime
+		cwlResult.ExitStatus = work.ExitStatus
+		cwlResult.Failure = work.Failure
 
 		var resultBytes []byte
 		resultBytes, err = json.Marshal(cwlResult)
@@ -609,6 +632,51 @@ func NotifyWorkunitProcessedWithLogs(work *Workunit, perf *WorkPerf, sendstdlogs
 	return
 }
 
+// ReturnWorkunit worker code to give a workunit back that it has checked out but not started, e.g. because the worker
+// is draining. The server queues it again without counting a failure.
+func ReturnWorkunit(work *Workunit) (err error) {
+	var workIDb64 string
+	workIDb64, err = work.GetIDBase64()
+	if err != nil {
+		err = fmt.Errorf("(ReturnWorkunit) work.GetIDBase64 returned: %s", err.Error())
+		return
+	}
+	targetURL := fmt.Sprintf("%s/work/%s?status=%s&client=%s", conf.SERVER_URL, workIDb64, WORK_STAT_QUEUED, Self.ID)
+
+	form := httpclient.NewForm()
+	err = form.Create()
+	if err != nil {
+		err = fmt.Errorf("(ReturnWorkunit) form.Create returned: %s", err.Error())
+		return
+	}
+	headers := httpclient.Header{
+		"Content-Type":   []string{form.ContentType},
+		"Content-Length": []string{strconv.FormatInt(form.Length, 10)},
+	}
+	if conf.CLIENT_GROUP_TOKEN != "" {
+		headers["Authorization"] = []string{"CG_TOKEN " + conf.CLIENT_GROUP_TOKEN}
+	}
+	logger.Debug(3, "PUT %s", targetURL)
+	res, err := httpclient.Put(targetURL, headers, form.Reader, nil)
+	if err != nil {
+		err = fmt.Errorf("(ReturnWorkunit) httpclient.Put returned: %s", err.Error())
+		return
+	}
+	defer res.Body.Close()
+
+	jsonstream, _ := ioutil.ReadAll(res.Body)
+	response := new(StandardResponse)
+	err = json.Unmarshal(jsonstream, response)
+	if err != nil {
+		err = fmt.Errorf("(ReturnWorkunit) failed to marshal response:\"%s\"", jsonstream)
+		return
+	}
+	if len(response.Error) > 0 {
+		err = errors.New(strings.Join(response.Error, ","))
+	}
+	return
+}
+
 // PushOutputData deprecated, see cache.UploadOutputData
 func PushOutputData(work *Workunit) (size int64, err error) {
 	for _, io := range work.Outputs {
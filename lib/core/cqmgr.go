@@ -33,10 +33,13 @@ type CQMgr struct {
 
 // FilterWorkStats _
 type FilterWorkStats struct {
-	Total            int
-	SkipWork         int
-	WrongClientgroup int
-	WrongApp         int
+	Total                 int
+	SkipWork              int
+	WrongClientgroup      int
+	WrongApp              int
+	InsufficientResources int
+	Backoff               int
+	QuotaExceeded         int
 }
 
 //--------mgr methods-------
@@ -194,6 +197,7 @@ func (qm *CQMgr) CheckClient(client *Client) (ok bool, err error) {
 			logger.Debug(3, "(CheckClient) work.State: %s", work.State)
 			if work.State == WORK_STAT_RESERVED {
 				_ = qm.workQueue.StatusChange(workID, work, WORK_STAT_CHECKOUT, "")
+				Quotas.CheckedOut(work, client.Group)
 			}
 		}
 
@@ -340,6 +344,9 @@ func (qm *CQMgr) ClientHeartBeat(id string, cg *ClientGroup, workerstate WorkerS
 	//	hbmsg["stop"] = id
 	//}
 
+	// commands issued with PUT /client/{id}, repeated until the worker reports the result
+	client.ackCommands(hbmsg)
+
 	hbmsg["server-uuid"] = ServerUUID
 
 	return
@@ -439,6 +446,10 @@ func (qm *CQMgr) GetClientByUser(id string, u *user.User) (client *Client, err e
 			filteredClientGroups[cg.Name] = true
 		}
 	}
+	// client groups of the projects in which the user is operator
+	for name := range UserProjectClientGroups(u, ROLE_OPERATOR) {
+		filteredClientGroups[name] = true
+	}
 
 	client, ok, err := qm.GetClient(id, true)
 	if err != nil {
@@ -466,6 +477,10 @@ func (qm *CQMgr) GetAllClientsByUser(u *user.User) (clients []*Client, err error
 			filteredClientGroups[cg.Name] = true
 		}
 	}
+	// client groups of the projects in which the user is viewer
+	for name := range UserProjectClientGroups(u, ROLE_VIEWER) {
+		filteredClientGroups[name] = true
+	}
 
 	allClients, err := qm.clientMap.GetClients()
 	if err != nil {
@@ -579,6 +594,10 @@ func (qm *CQMgr) SuspendClientByUser(id string, u *user.User, reason string) (er
 			filteredClientGroups[cg.Name] = true
 		}
 	}
+	// client groups of the projects in which the user is operator
+	for name := range UserProjectClientGroups(u, ROLE_OPERATOR) {
+		filteredClientGroups[name] = true
+	}
 
 	client, ok, err := qm.GetClient(id, true)
 	if err != nil {
@@ -615,6 +634,10 @@ func (qm *CQMgr) SuspendAllClientsByUser(u *user.User, reason string) (count int
 			filteredClientGroups[cg.Name] = true
 		}
 	}
+	// client groups of the projects in which the user is operator
+	for name := range UserProjectClientGroups(u, ROLE_OPERATOR) {
+		filteredClientGroups[name] = true
+	}
 
 	clients, err := qm.clientMap.GetClients()
 	if err != nil {
@@ -679,6 +702,10 @@ func (qm *CQMgr) ResumeClientByUser(id string, u *user.User) (err error) {
 			filteredClientGroups[cg.Name] = true
 		}
 	}
+	// client groups of the projects in which the user is operator
+	for name := range UserProjectClientGroups(u, ROLE_OPERATOR) {
+		filteredClientGroups[name] = true
+	}
 
 	client, ok, err := qm.GetClient(id, true)
 	if err != nil {
@@ -742,6 +769,10 @@ func (qm *CQMgr) ResumeSuspendedClientsByUser(u *user.User) (count int) {
 			filteredClientGroups[cg.Name] = true
 		}
 	}
+	// client groups of the projects in which the user is operator
+	for name := range UserProjectClientGroups(u, ROLE_OPERATOR) {
+		filteredClientGroups[name] = true
+	}
 
 	clients, err := qm.clientMap.GetClients()
 	if err != nil {
@@ -801,6 +832,10 @@ func (qm *CQMgr) UpdateSubClientsByUser(id string, count int, u *user.User) {
 			filteredClientGroups[cg.Name] = true
 		}
 	}
+	// client groups of the projects in which the user is operator
+	for name := range UserProjectClientGroups(u, ROLE_OPERATOR) {
+		filteredClientGroups[name] = true
+	}
 
 	client, ok, err := qm.GetClient(id, true)
 	if err != nil {
@@ -814,15 +849,109 @@ func (qm *CQMgr) UpdateSubClientsByUser(id string, count int, u *user.User) {
 	}
 }
 
+// SendClientCommandByUser queues a command for the worker, it is delivered with the next heartbeat
+func (qm *CQMgr) SendClientCommandByUser(id string, op string, arg string, u *user.User) (cmd *ClientCommand, err error) {
+	client, ok, err := qm.GetClient(id, true)
+	if err != nil {
+		return
+	}
+	if !ok {
+		err = errors.New(e.ClientNotFound)
+		return
+	}
+	group, err := client.GetGroup(true)
+	if err != nil {
+		return
+	}
+	if !canCommandClientGroup(u, group) {
+		err = errors.New(e.UnAuth)
+		return
+	}
+	cmd, err = NewClientCommand(op, arg, u.Uuid)
+	if err != nil {
+		return
+	}
+	err = client.AddCommand(cmd, true)
+	if err != nil {
+		err = fmt.Errorf("(SendClientCommandByUser) client.AddCommand returned: %s", err.Error())
+		return
+	}
+	logger.Event(event.CLIENT_COMMAND, "clientid="+id+";command="+op+";user="+u.Username)
+	return
+}
+
+// canCommandClientGroup commands can be sent to the clients of a clientgroup by admins, the owner and the operators of
+// its projects. Clientgroups owned by "public" only by admins.
+func canCommandClientGroup(u *user.User, group string) bool {
+	if u.Admin {
+		return true
+	}
+	cg, err := LoadClientGroupByName(group)
+	if err != nil || cg.ACL.Owner == "public" {
+		return false
+	}
+	if u.Uuid != "public" && cg.ACL.Owner == u.Uuid {
+		return true
+	}
+	return ClientGroupProjectRights(u, group)["write"]
+}
+
 //--------end client methods-------
 
 //-------start of workunit methods---
 
-// CheckoutWorkunits _
-func (qm *CQMgr) CheckoutWorkunits(reqPolicy string, clientID string, client *Client, availableBytes int64, num int) (workunits []*Workunit, err error) {
+// CheckoutWorkunits if wait is positive and there is no eligible workunit, the request waits (long-poll)
+// until a workunit is queued or wait has passed
+func (qm *CQMgr) CheckoutWorkunits(reqPolicy string, aging *AgingPolicy, clientID string, client *Client, availableBytes int64, free *WorkResources, num int, wait time.Duration) (workunits []*Workunit, err error) {
 
 	logger.Debug(3, "run CheckoutWorkunits for client %s", clientID)
 
+	var ack CoAck
+	qm.workQueue.WaitForWork(clientID, wait, func() (retry bool) {
+		ack, err = qm.requestWorkunits(reqPolicy, aging, clientID, client, availableBytes, free, num)
+		return err == nil && ack.err != nil && (ack.err.Error() == e.NoEligibleWorkunitFound || ack.err.Error() == e.QueueEmpty)
+	})
+	if err != nil {
+		return
+	}
+
+	if ack.err != nil {
+		logger.Debug(3, "(CheckoutWorkunits) %s ack.err: %s", clientID, ack.err.Error())
+		return ack.workunits, ack.err
+	}
+
+	clientgroup, _ := client.GetGroup(true)
+
+	addedWork := 0
+	for _, work := range ack.workunits {
+		workID := work.Workunit_Unique_Identifier
+		//work_id, xerr := work.Workunit_Unique_Identifier// New_Workunit_Unique_Identifier_FromString(work.ID)
+		//if xerr != nil {
+		//	return
+		//}
+		err = client.AssignedWork.Add(workID)
+		if err != nil {
+			return
+		}
+		err = client.AddRecentJob(work.JobId, true)
+		if err != nil {
+			return
+		}
+		observeCheckout(work, clientgroup)
+		addedWork++
+	}
+
+	//if added_work > 0 && status == CLIENT_STAT_ACTIVE_IDLE {
+	//	client.Set_Status(CLIENT_STAT_ACTIVE_BUSY, true)
+	//}
+
+	logger.Debug(3, "(CheckoutWorkunits) %s finished", clientID)
+	return ack.workunits, ack.err
+}
+
+// requestWorkunits sends one checkout request to the ClientHandle and waits for the response
+func (qm *CQMgr) requestWorkunits(reqPolicy string, aging *AgingPolicy, clientID string, client *Client, availableBytes int64, free *WorkResources, num int) (ack CoAck, err error) {
+
 	//precheck if the client is registered
 	//client, hasClient, err := qm.GetClient(client_id, true)
 	//if err != nil {
@@ -840,11 +969,17 @@ func (qm *CQMgr) CheckoutWorkunits(reqPolicy string, clientID string, client *Cl
 	responseChannel := client.coAckChannel
 
 	workLength, _ := client.CurrentWork.Length(false)
+	slots := client.Slots
 	client.Unlock()
 
-	if workLength > 0 {
+	if slots < 1 {
+		slots = 1
+	}
+
+	if workLength >= slots {
 		logger.Error("Client %s wants to checkout work, but still has work: workLength=%d", clientID, workLength)
-		return nil, errors.New(e.ClientBusy)
+		err = errors.New(e.ClientBusy)
+		return
 	}
 
 	isSuspended, err := client.GetSuspended(true)
@@ -863,7 +998,7 @@ func (qm *CQMgr) CheckoutWorkunits(reqPolicy string, clientID string, client *Cl
 	//}
 
 	//req := CoReq{policy: req_policy, fromclient: client_id, available: available_bytes, count: num, response: client.coAckChannel}
-	req := CheckoutRequest{policy: reqPolicy, fromclient: clientID, available: availableBytes, count: num, response: responseChannel}
+	req := CheckoutRequest{policy: reqPolicy, aging: aging, fromclient: clientID, available: availableBytes, free: free, count: num, response: responseChannel}
 
 	logger.Debug(3, "(CheckoutWorkunits) %s qm.coReq <- req", clientID)
 	// request workunit
@@ -886,7 +1021,6 @@ func (qm *CQMgr) CheckoutWorkunits(reqPolicy string, clientID string, client *Cl
 	logger.Debug(3, "(CheckoutWorkunits) %s client.Get_Ack()", clientID)
 	//ack := <-qm.coAck
 
-	var ack CoAck
 	// get workunit
 	lock, err := client.RLockNamed("CheckoutWorkunits waiting for ack, clientID: " + clientID)
 	if err != nil {
@@ -896,37 +1030,7 @@ func (qm *CQMgr) CheckoutWorkunits(reqPolicy string, clientID string, client *Cl
 	client.RUnlockNamed(lock)
 
 	logger.Debug(3, "(CheckoutWorkunits) %s got ack", clientID)
-	if err != nil {
-		return
-	}
-
-	logger.Debug(3, "(CheckoutWorkunits) %s got ack", clientID)
-
-	if ack.err != nil {
-		logger.Debug(3, "(CheckoutWorkunits) %s ack.err: %s", clientID, ack.err.Error())
-		return ack.workunits, ack.err
-	}
-
-	addedWork := 0
-	for _, work := range ack.workunits {
-		workID := work.Workunit_Unique_Identifier
-		//work_id, xerr := work.Workunit_Unique_Identifier// New_Workunit_Unique_Identifier_FromString(work.ID)
-		//if xerr != nil {
-		//	return
-		//}
-		err = client.AssignedWork.Add(workID)
-		if err != nil {
-			return
-		}
-		addedWork++
-	}
-
-	//if added_work > 0 && status == CLIENT_STAT_ACTIVE_IDLE {
-	//	client.Set_Status(CLIENT_STAT_ACTIVE_BUSY, true)
-	//}
-
-	logger.Debug(3, "(CheckoutWorkunits) %s finished", clientID)
-	return ack.workunits, ack.err
+	return
 }
 
 // GetWorkByID _
@@ -969,12 +1073,18 @@ func (qm *CQMgr) popWorks(req CheckoutRequest) (clientSpecificWorkunits []*Worku
 
 	logger.Debug(3, "(popWorks) starting for client: %s", clientID)
 
-	filtered, stats, err := qm.filterWorkByClient(client)
+	filtered, stats, err := qm.filterWorkByClient(client, req.free)
 	if err != nil {
 		err = fmt.Errorf("(popWorks) filterWorkByClient returned: %s", err.Error())
 		return
 	}
 
+	filtered, stats.QuotaExceeded, err = qm.filterWorkByQuota(client, filtered)
+	if err != nil {
+		err = fmt.Errorf("(popWorks) filterWorkByQuota returned: %s", err.Error())
+		return
+	}
+
 	if len(filtered) == 0 {
 		var statJSONByte []byte
 		statJSONByte, err = json.Marshal(stats)
@@ -991,7 +1101,7 @@ func (qm *CQMgr) popWorks(req CheckoutRequest) (clientSpecificWorkunits []*Worku
 
 		return
 	}
-	clientSpecificWorkunits, err = qm.workQueue.selectWorkunits(filtered, req.policy, req.available, req.count)
+	clientSpecificWorkunits, err = qm.workQueue.selectWorkunits(filtered, req.policy, req.aging, client, req.available, req.count)
 	if err != nil {
 		err = fmt.Errorf("(popWorks) selectWorkunits returned: %s", err.Error())
 		return
@@ -1002,6 +1112,7 @@ func (qm *CQMgr) popWorks(req CheckoutRequest) (clientSpecificWorkunits []*Worku
 		work.CheckoutTime = time.Now()
 		//qm.workQueue.Put(work) TODO isn't that already in the queue ?
 		qm.workQueue.StatusChange(work.Workunit_Unique_Identifier, work, WORK_STAT_CHECKOUT, "")
+		Quotas.CheckedOut(work, client.Group)
 	}
 
 	logger.Debug(3, "(popWorks) done with client: %s ", clientID)
@@ -1009,9 +1120,9 @@ func (qm *CQMgr) popWorks(req CheckoutRequest) (clientSpecificWorkunits []*Worku
 }
 
 // client has to be read-locked
-func (qm *CQMgr) filterWorkByClient(client *Client) (workunits WorkList, s FilterWorkStats, err error) {
+func (qm *CQMgr) filterWorkByClient(client *Client, free *WorkResources) (workunits WorkList, s FilterWorkStats, err error) {
 
-	s = FilterWorkStats{0, 0, 0, 0}
+	s = FilterWorkStats{0, 0, 0, 0, 0, 0, 0}
 
 	if client == nil {
 		err = fmt.Errorf("(filterWorkByClient) client == nil")
@@ -1044,6 +1155,12 @@ func (qm *CQMgr) filterWorkByClient(client *Client) (workunits WorkList, s Filte
 		id := workunit.ID
 		logger.Debug(3, "check if job %s would fit client %s", id, clientid)
 
+		//skip works that wait for a retry
+		if !workunit.NotBefore.IsZero() && time.Now().Before(workunit.NotBefore) {
+			logger.Debug(3, "1) workunit %s waits for retry until %s", id, workunit.NotBefore)
+			s.Backoff++
+			continue
+		}
 		//skip works that are in the client's skip-list
 		if client.ContainsSkipWorkNolock(workunit.ID) {
 			logger.Debug(3, "2) workunit %s is in Skip_work list of the client %s)", id, clientid)
@@ -1059,6 +1176,12 @@ func (qm *CQMgr) filterWorkByClient(client *Client) (workunits WorkList, s Filte
 				continue
 			}
 		}
+		//skip works that need more cores, memory or disk than the client has
+		if fits, reason := workunit.Resources.Fits(client, free); !fits {
+			logger.Debug(3, "4) workunit %s does not fit client %s: %s", id, clientid, reason)
+			s.InsufficientResources++
+			continue
+		}
 		//append works whos apps are supported by the client
 		if contains(client.Apps, workunit.Cmd.Name) || contains(client.Apps, conf.ALL_APP) {
 			logger.Debug(3, "append job %s to list of client %s", id, clientid)
@@ -1121,6 +1244,7 @@ func (qm *CQMgr) ShowWorkunitsByUser(status string, u *user.User) (workunits []*
 	if err != nil {
 		return
 	}
+	roles := NewUserRoles(u)
 	for _, work := range workunitList {
 		// skip loading jobs from db if user is admin
 		if u.Admin == true {
@@ -1132,7 +1256,7 @@ func (qm *CQMgr) ShowWorkunitsByUser(status string, u *user.User) (workunits []*
 
 			if job, err := GetJob(jobid); err == nil {
 				rights := job.ACL.Check(u.Uuid)
-				if job.ACL.Owner == u.Uuid || rights["read"] == true {
+				if job.ACL.Owner == u.Uuid || rights["read"] == true || roles.ProjectRights(job.Info.Project)["read"] == true {
 					if work.State == status || status == "" {
 						workunits = append(workunits, work)
 					}
@@ -0,0 +1,180 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronExpression parsed cron expression with the fields minute, hour, day of month, month and day of week.
+// Fields support *, lists, ranges and steps, months and weekdays also their three letter names. Times are UTC.
+type CronExpression struct {
+	minute  uint64
+	hour    uint64
+	dom     uint64
+	month   uint64
+	dow     uint64
+	domStar bool
+	dowStar bool
+}
+
+var cronShortcuts = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var cronMonthNames = []string{"", "jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+var cronDayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// ParseCron _
+func ParseCron(expr string) (c *CronExpression, err error) {
+	spec := strings.TrimSpace(expr)
+	if shortcut, ok := cronShortcuts[strings.ToLower(spec)]; ok {
+		spec = shortcut
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		err = fmt.Errorf("(ParseCron) expected 5 fields (minute hour day-of-month month day-of-week), got %d in \"%s\"", len(fields), expr)
+		return
+	}
+
+	c = &CronExpression{}
+	if c.minute, _, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		err = fmt.Errorf("(ParseCron) minute: %s", err.Error())
+		return
+	}
+	if c.hour, _, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		err = fmt.Errorf("(ParseCron) hour: %s", err.Error())
+		return
+	}
+	if c.dom, c.domStar, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		err = fmt.Errorf("(ParseCron) day of month: %s", err.Error())
+		return
+	}
+	if c.month, _, err = parseCronField(fields[3], 1, 12, cronMonthNames); err != nil {
+		err = fmt.Errorf("(ParseCron) month: %s", err.Error())
+		return
+	}
+	if c.dow, c.dowStar, err = parseCronField(fields[4], 0, 7, cronDayNames); err != nil {
+		err = fmt.Errorf("(ParseCron) day of week: %s", err.Error())
+		return
+	}
+	// 7 is an alias for sunday
+	if c.dow&(1<<7) != 0 {
+		c.dow = (c.dow | 1) &^ (1 << 7)
+	}
+
+	if c.Next(time.Now()).IsZero() {
+		err = fmt.Errorf("(ParseCron) \"%s\" never matches", expr)
+		c = nil
+	}
+	return
+}
+
+// parseCronField returns the allowed values as bits, star is true if the field starts with *
+func parseCronField(field string, min int, max int, names []string) (bits uint64, star bool, err error) {
+	star = strings.HasPrefix(field, "*")
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		rangePart := part
+		if i := strings.Index(part, "/"); i >= 0 {
+			rangePart = part[:i]
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step < 1 {
+				err = fmt.Errorf("invalid step in \"%s\"", part)
+				return
+			}
+		}
+
+		var low, high int
+		switch {
+		case rangePart == "*":
+			low, high = min, max
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			if low, err = parseCronValue(bounds[0], min, max, names); err != nil {
+				return
+			}
+			if high, err = parseCronValue(bounds[1], min, max, names); err != nil {
+				return
+			}
+			if low > high {
+				err = fmt.Errorf("invalid range \"%s\"", rangePart)
+				return
+			}
+		default:
+			if low, err = parseCronValue(rangePart, min, max, names); err != nil {
+				return
+			}
+			high = low
+			if step > 1 {
+				high = max
+			}
+		}
+
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return
+}
+
+func parseCronValue(value string, min int, max int, names []string) (v int, err error) {
+	for i, name := range names {
+		if name != "" && strings.ToLower(value) == name {
+			v = i
+			return
+		}
+	}
+	v, err = strconv.Atoi(value)
+	if err != nil {
+		err = fmt.Errorf("invalid value \"%s\"", value)
+		return
+	}
+	if v < min || v > max {
+		err = fmt.Errorf("value %d out of range %d-%d", v, min, max)
+	}
+	return
+}
+
+// dayMatches follows cron: if both day fields are restricted, either of them has to match
+func (c *CronExpression) dayMatches(t time.Time) bool {
+	domMatch := c.dom&(1<<uint(t.Day())) != 0
+	dowMatch := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// Next returns the first matching minute after t, zero time if there is none within five years
+func (c *CronExpression) Next(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, time.UTC)
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
@@ -0,0 +1,41 @@
+package core_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/MG-RAST/AWE/lib/core"
+)
+
+func TestCronNext(t *testing.T) {
+	start := time.Date(2019, time.January, 31, 22, 30, 0, 0, time.UTC) // a thursday
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2019, time.January, 31, 22, 45, 0, 0, time.UTC)},
+		{"@daily", time.Date(2019, time.February, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 2 * * mon-fri", time.Date(2019, time.February, 1, 2, 0, 0, 0, time.UTC)},
+		{"0 2 * * sat,sun", time.Date(2019, time.February, 2, 2, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2020, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 12 13 * 5", time.Date(2019, time.February, 1, 12, 0, 0, 0, time.UTC)}, // day of month or day of week
+	}
+	for _, test := range tests {
+		c, err := ParseCron(test.expr)
+		if err != nil {
+			t.Fatalf("%s: %s", test.expr, err.Error())
+		}
+		if got := c.Next(start); !got.Equal(test.want) {
+			t.Errorf("%s: expected %s, got %s", test.expr, test.want, got)
+		}
+	}
+}
+
+func TestCronInvalid(t *testing.T) {
+	for _, expr := range []string{"* * * *", "60 * * * *", "* * * foo *", "5-1 * * * *", "*/0 * * * *", "0 0 31 2 *"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("%s: expected an error", expr)
+		}
+	}
+}
@@ -31,6 +31,9 @@ type CWLObjectGeneric map[string]interface{}
 // LinkMergeMethod _
 type LinkMergeMethod string // merge_nested or merge_flattened
 
+// PickValueMethod _
+type PickValueMethod string // first_non_null, the_only_non_null or all_non_null
+
 // func Add_to_collection_deprecated(context *WorkflowContext, object_array CWLObjectArray) (err error) {
 
 // 	for i, object := range object_array {
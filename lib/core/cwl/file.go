@@ -209,7 +209,7 @@ func MakeFile(obj interface{}, context *WorkflowContext, external_id string) (fi
 					componentsUpdated = true
 				}
 			}
-		case "file":
+		case "file", "s3":
 
 			file.UpdateComponents(fileURL.Path)
 		case "":
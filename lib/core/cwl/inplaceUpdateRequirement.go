@@ -0,0 +1,32 @@
+package cwl
+
+import (
+	"github.com/mitchellh/mapstructure"
+)
+
+// InplaceUpdateRequirement CWL v1.1, the tool may modify input files and directories that are marked writable.
+// AWE downloads the inputs of every workunit into its own work directory, so in-place updates never affect other steps.
+// https://www.commonwl.org/v1.1/CommandLineTool.html#InplaceUpdateRequirement
+type InplaceUpdateRequirement struct {
+	BaseRequirement `bson:",inline" yaml:",inline" json:",inline" mapstructure:",squash"`
+	InplaceUpdate   bool `yaml:"inplaceUpdate" bson:"inplaceUpdate" json:"inplaceUpdate" mapstructure:"inplaceUpdate"`
+}
+
+// GetID _
+func (r InplaceUpdateRequirement) GetID() string { return "None" }
+
+// NewInplaceUpdateRequirementFromInterface _
+func NewInplaceUpdateRequirementFromInterface(original interface{}) (r *InplaceUpdateRequirement, err error) {
+	var requirement InplaceUpdateRequirement
+	r = &requirement
+
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{WeaklyTypedInput: true, Result: &requirement})
+	if err != nil {
+		return
+	}
+	err = decoder.Decode(original)
+
+	requirement.Class = "InplaceUpdateRequirement"
+
+	return
+}
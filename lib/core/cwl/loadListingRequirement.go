@@ -0,0 +1,101 @@
+package cwl
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// LoadListing values of LoadListingRequirement
+const (
+	LoadListingNo      = "no_listing"      // listing of input directories is not loaded
+	LoadListingShallow = "shallow_listing" // only the top level of input directories is listed
+	LoadListingDeep    = "deep_listing"    // input directories are listed recursively
+)
+
+// LoadListingRequirement CWL v1.1, controls how the listing of input directories is loaded
+// https://www.commonwl.org/v1.1/CommandLineTool.html#LoadListingRequirement
+type LoadListingRequirement struct {
+	BaseRequirement `bson:",inline" yaml:",inline" json:",inline" mapstructure:",squash"`
+	LoadListing     string `yaml:"loadListing,omitempty" bson:"loadListing,omitempty" json:"loadListing,omitempty" mapstructure:"loadListing,omitempty"` // no_listing, shallow_listing or deep_listing
+}
+
+// GetID _
+func (r LoadListingRequirement) GetID() string { return "None" }
+
+// NewLoadListingRequirementFromInterface _
+func NewLoadListingRequirementFromInterface(original interface{}) (r *LoadListingRequirement, err error) {
+	var requirement LoadListingRequirement
+	r = &requirement
+
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{WeaklyTypedInput: true, Result: &requirement})
+	if err != nil {
+		return
+	}
+	err = decoder.Decode(original)
+	if err != nil {
+		return
+	}
+
+	switch requirement.LoadListing {
+	case "", LoadListingNo, LoadListingShallow, LoadListingDeep:
+	default:
+		err = fmt.Errorf("(NewLoadListingRequirementFromInterface) loadListing \"%s\" not supported (supported: %s, %s, %s)", requirement.LoadListing, LoadListingNo, LoadListingShallow, LoadListingDeep)
+		return
+	}
+
+	requirement.Class = "LoadListingRequirement"
+
+	return
+}
+
+// GetLoadListingRequirement returns the LoadListingRequirement from the list, nil if there is none
+func GetLoadListingRequirement(arrayPtr []Requirement) (r *LoadListingRequirement) {
+	for i := range arrayPtr {
+		switch arrayPtr[i].(type) {
+		case *LoadListingRequirement:
+			r = arrayPtr[i].(*LoadListingRequirement)
+			return
+		}
+	}
+	return
+}
+
+// ApplyLoadListing truncates the listing of all directories in value (job document, array, record or directory).
+// deep_listing and an empty loadListing keep the listing as it is.
+func ApplyLoadListing(value interface{}, loadListing string) {
+
+	switch value.(type) {
+	case *Job_document:
+		ApplyLoadListing(*value.(*Job_document), loadListing)
+	case Job_document:
+		for _, named := range value.(Job_document) {
+			ApplyLoadListing(named.Value, loadListing)
+		}
+	case *Array:
+		ApplyLoadListing(*value.(*Array), loadListing)
+	case Array:
+		for _, elem := range value.(Array) {
+			ApplyLoadListing(elem, loadListing)
+		}
+	case *Record:
+		ApplyLoadListing(*value.(*Record), loadListing)
+	case Record:
+		for _, elem := range value.(Record) {
+			ApplyLoadListing(elem, loadListing)
+		}
+	case *Directory:
+		dir := value.(*Directory)
+		switch loadListing {
+		case LoadListingNo:
+			dir.Listing = nil
+		case LoadListingShallow:
+			for _, elem := range dir.Listing {
+				subdir, ok := elem.(*Directory)
+				if ok {
+					subdir.Listing = nil
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package cwl
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// NetworkAccess CWL v1.1, indicates whether a CommandLineTool needs outgoing network access
+// https://www.commonwl.org/v1.1/CommandLineTool.html#NetworkAccess
+type NetworkAccess struct {
+	BaseRequirement `bson:",inline" yaml:",inline" json:",inline" mapstructure:",squash"`
+	NetworkAccess   interface{} `yaml:"networkAccess" bson:"networkAccess" json:"networkAccess" mapstructure:"networkAccess"` // boolean | Expression
+}
+
+// GetID _
+func (r NetworkAccess) GetID() string { return "None" }
+
+// NewNetworkAccessFromInterface _
+func NewNetworkAccessFromInterface(original interface{}, context *WorkflowContext) (r *NetworkAccess, err error) {
+
+	original, err = MakeStringMap(original, context)
+	if err != nil {
+		return
+	}
+
+	var requirement NetworkAccess
+	r = &requirement
+
+	err = mapstructure.Decode(original, &requirement)
+	if err != nil {
+		err = fmt.Errorf("(NewNetworkAccessFromInterface) mapstructure.Decode returned: %s", err.Error())
+		return
+	}
+
+	requirement.Class = "NetworkAccess"
+
+	return
+}
+
+// Evaluate _
+func (r *NetworkAccess) Evaluate(inputs interface{}, context *WorkflowContext) (err error) {
+	r.NetworkAccess, err = evaluateRequirementValue(r.NetworkAccess, inputs, context)
+	if err != nil {
+		err = fmt.Errorf("(NetworkAccess/Evaluate) networkAccess: %s", err.Error())
+	}
+	return
+}
+
+// IsEnabled returns the evaluated networkAccess field
+func (r *NetworkAccess) IsEnabled() (enabled bool, err error) {
+	enabled, err = getRequirementBool(r.NetworkAccess)
+	if err != nil {
+		err = fmt.Errorf("(NetworkAccess/IsEnabled) %s", err.Error())
+	}
+	return
+}
+
+// GetNetworkAccess returns the NetworkAccess from the list, nil if there is none
+func GetNetworkAccess(arrayPtr []Requirement) (r *NetworkAccess) {
+	for i := range arrayPtr {
+		switch arrayPtr[i].(type) {
+		case *NetworkAccess:
+			r = arrayPtr[i].(*NetworkAccess)
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,53 @@
+package cwl
+
+import (
+	"fmt"
+)
+
+// PickValue methods of CWL v1.2
+const (
+	PickValueFirstNonNull   PickValueMethod = "first_non_null"
+	PickValueTheOnlyNonNull PickValueMethod = "the_only_non_null"
+	PickValueAllNonNull     PickValueMethod = "all_non_null"
+)
+
+// PickValue applies a pickValue method to the (already merged) value of one or more sources,
+// a value that is not an array is treated like an array with one element
+func PickValue(method PickValueMethod, value CWLType) (result CWLType, err error) {
+
+	var values []CWLType
+	valueArray, isArray := value.(*Array)
+	if isArray {
+		values = *valueArray
+	} else {
+		values = []CWLType{value}
+	}
+
+	nonNull := Array{}
+	for _, v := range values {
+		if v == nil || v.GetType() == CWLNull {
+			continue
+		}
+		nonNull = append(nonNull, v)
+	}
+
+	switch method {
+	case PickValueFirstNonNull:
+		if len(nonNull) == 0 {
+			err = fmt.Errorf("(PickValue) first_non_null: all values are null")
+			return
+		}
+		result = nonNull[0]
+	case PickValueTheOnlyNonNull:
+		if len(nonNull) != 1 {
+			err = fmt.Errorf("(PickValue) the_only_non_null: expected exactly one value that is not null, got %d", len(nonNull))
+			return
+		}
+		result = nonNull[0]
+	case PickValueAllNonNull:
+		result = &nonNull
+	default:
+		err = fmt.Errorf("(PickValue) pickValue method %s not supported", method)
+	}
+	return
+}
@@ -0,0 +1,65 @@
+package cwl_test
+
+import (
+	"testing"
+
+	. "github.com/MG-RAST/AWE/lib/core/cwl"
+)
+
+func TestPickValue(t *testing.T) {
+	values := &Array{NewNull(), NewString("a"), NewNull(), NewString("b")}
+
+	first, err := PickValue(PickValueFirstNonNull, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.String() != "a" {
+		t.Errorf("first_non_null: expected a, got %s", first.String())
+	}
+
+	all, err := PickValue(PickValueAllNonNull, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all.(*Array).Len() != 2 {
+		t.Errorf("all_non_null: expected 2 values, got %d", all.(*Array).Len())
+	}
+
+	if _, err = PickValue(PickValueTheOnlyNonNull, values); err == nil {
+		t.Errorf("the_only_non_null: expected an error for two values")
+	}
+	only, err := PickValue(PickValueTheOnlyNonNull, &Array{NewNull(), NewString("c")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if only.String() != "c" {
+		t.Errorf("the_only_non_null: expected c, got %s", only.String())
+	}
+
+	if _, err = PickValue(PickValueFirstNonNull, &Array{NewNull()}); err == nil {
+		t.Errorf("first_non_null: expected an error if all values are null")
+	}
+}
+
+func TestEvaluateWhen(t *testing.T) {
+	step := &WorkflowStep{ID: "#main/step1"}
+	run, err := step.EvaluateWhen(nil, nil)
+	if err != nil || !run {
+		t.Fatalf("step without when has to run (err: %v)", err)
+	}
+
+	step.When = "$(inputs.count > 1)"
+	inputs := JobDocMap{"count": NewLong(1)}
+	run, err = step.EvaluateWhen(inputs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run {
+		t.Errorf("expected when to evaluate to false")
+	}
+
+	step.When = "$(inputs.count)"
+	if _, err = step.EvaluateWhen(inputs, nil); err == nil {
+		t.Errorf("expected an error for a non-boolean result")
+	}
+}
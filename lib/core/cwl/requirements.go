@@ -120,6 +120,20 @@ func NewRequirement(class string, obj interface{}, inputs interface{}, context *
 			return
 		}
 		return
+	case "StorageRequirement":
+		r, err = NewStorageRequirementFromInterface(obj)
+		if err != nil {
+			err = fmt.Errorf("(NewRequirement) NewStorageRequirementFromInterface returns: %s", err.Error())
+			return
+		}
+		return
+	case "RetryRequirement":
+		r, err = NewRetryRequirementFromInterface(obj)
+		if err != nil {
+			err = fmt.Errorf("(NewRequirement) NewRetryRequirementFromInterface returns: %s", err.Error())
+			return
+		}
+		return
 	case "InitialWorkDirRequirement":
 		r, err = NewInitialWorkDirRequirement(obj, context)
 		if err != nil {
@@ -148,6 +162,41 @@ func NewRequirement(class string, obj interface{}, inputs interface{}, context *
 			return
 		}
 		return
+	case "ToolTimeLimit":
+		r, err = NewToolTimeLimitFromInterface(obj, context)
+		if err != nil {
+			err = fmt.Errorf("(NewRequirement) NewToolTimeLimitFromInterface returns: %s", err.Error())
+			return
+		}
+		return
+	case "WorkReuse":
+		r, err = NewWorkReuseFromInterface(obj, context)
+		if err != nil {
+			err = fmt.Errorf("(NewRequirement) NewWorkReuseFromInterface returns: %s", err.Error())
+			return
+		}
+		return
+	case "NetworkAccess":
+		r, err = NewNetworkAccessFromInterface(obj, context)
+		if err != nil {
+			err = fmt.Errorf("(NewRequirement) NewNetworkAccessFromInterface returns: %s", err.Error())
+			return
+		}
+		return
+	case "LoadListingRequirement":
+		r, err = NewLoadListingRequirementFromInterface(obj)
+		if err != nil {
+			err = fmt.Errorf("(NewRequirement) NewLoadListingRequirementFromInterface returns: %s", err.Error())
+			return
+		}
+		return
+	case "InplaceUpdateRequirement":
+		r, err = NewInplaceUpdateRequirementFromInterface(obj)
+		if err != nil {
+			err = fmt.Errorf("(NewRequirement) NewInplaceUpdateRequirementFromInterface returns: %s", err.Error())
+			return
+		}
+		return
 
 	case "SubworkflowFeatureRequirement":
 		thisR := DummyRequirement{}
@@ -470,3 +519,41 @@ func CreateRequirementArray(original interface{}, optional bool, inputs interfac
 
 	return
 }
+
+// evaluateRequirementValue evaluates a requirement field that can be an expression, other values are returned as they are
+func evaluateRequirementValue(value interface{}, inputs interface{}, context *WorkflowContext) (result interface{}, err error) {
+
+	result = value
+
+	valueStr, ok := value.(string)
+	if !ok {
+		return
+	}
+
+	expr := NewExpressionFromString(valueStr)
+
+	result, err = expr.EvaluateExpression(nil, inputs, context)
+	if err != nil {
+		err = fmt.Errorf("(evaluateRequirementValue) EvaluateExpression returned: %s", err.Error())
+		return
+	}
+	return
+}
+
+// getRequirementBool converts an evaluated boolean field of a requirement
+func getRequirementBool(value interface{}) (result bool, err error) {
+
+	switch value.(type) {
+	case bool:
+		result = value.(bool)
+	case Boolean:
+		result = bool(value.(Boolean))
+	case *Boolean:
+		result = bool(*value.(*Boolean))
+	case string:
+		err = fmt.Errorf("(getRequirementBool) expression \"%s\" has not been evaluated", value.(string))
+	default:
+		err = fmt.Errorf("(getRequirementBool) type invalid %s", reflect.TypeOf(value))
+	}
+	return
+}
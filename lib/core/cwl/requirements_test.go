@@ -0,0 +1,82 @@
+package cwl_test
+
+import (
+	"testing"
+
+	. "github.com/MG-RAST/AWE/lib/core/cwl"
+)
+
+func TestNewRequirementV11(t *testing.T) {
+	r, err := NewRequirement("ToolTimeLimit", map[string]interface{}{"class": "ToolTimeLimit", "timelimit": 60}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ttl := GetToolTimeLimit([]Requirement{r})
+	if ttl == nil {
+		t.Fatalf("ToolTimeLimit not found")
+	}
+	seconds, err := ttl.GetSeconds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seconds != 60 {
+		t.Errorf("timelimit: expected 60, got %d", seconds)
+	}
+
+	r, err = NewRequirement("NetworkAccess", map[string]interface{}{"class": "NetworkAccess", "networkAccess": false}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enabled, err := GetNetworkAccess([]Requirement{r}).IsEnabled()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enabled {
+		t.Errorf("networkAccess: expected false")
+	}
+
+	for _, class := range []string{"WorkReuse", "InplaceUpdateRequirement"} {
+		r, err = NewRequirement(class, map[string]interface{}{"class": class}, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.GetClass() != class {
+			t.Errorf("expected class %s, got %s", class, r.GetClass())
+		}
+	}
+
+	if _, err = NewRequirement("LoadListingRequirement", map[string]interface{}{"class": "LoadListingRequirement", "loadListing": "some_listing"}, nil, nil); err == nil {
+		t.Errorf("loadListing: expected an error for an unknown value")
+	}
+}
+
+func TestApplyLoadListing(t *testing.T) {
+	newTree := func() *Directory {
+		sub := NewDirectory()
+		sub.Listing = []CWLType{NewFile()}
+		dir := NewDirectory()
+		dir.Listing = []CWLType{sub, NewFile()}
+		return dir
+	}
+
+	dir := newTree()
+	ApplyLoadListing(&Array{dir}, LoadListingShallow)
+	if len(dir.Listing) != 2 {
+		t.Errorf("shallow_listing: expected 2 entries, got %d", len(dir.Listing))
+	}
+	if dir.Listing[0].(*Directory).Listing != nil {
+		t.Errorf("shallow_listing: expected no listing for the subdirectory")
+	}
+
+	dir = newTree()
+	ApplyLoadListing(dir, LoadListingNo)
+	if dir.Listing != nil {
+		t.Errorf("no_listing: expected no listing")
+	}
+
+	dir = newTree()
+	ApplyLoadListing(dir, LoadListingDeep)
+	if len(dir.Listing[0].(*Directory).Listing) != 1 {
+		t.Errorf("deep_listing: expected the listing of the subdirectory")
+	}
+}
@@ -2,6 +2,7 @@ package cwl
 
 import (
 	"fmt"
+	"math"
 	"reflect"
 	"strings"
 
@@ -64,6 +65,10 @@ func (r *ResourceRequirement) Evaluate(inputs interface{}, context *WorkflowCont
 
 			case int64:
 
+			case float64:
+
+			case Int, Long, Float, Double: // already evaluated
+
 			default:
 				err = fmt.Errorf("(ResourceRequirement/Evaluate) type invalid for field %s", reflect.TypeOf(value_if))
 				return
@@ -127,3 +132,57 @@ func NewResourceRequirement(original interface{}, inputs interface{}, context *W
 	r.Class = "ResourceRequirement"
 	return
 }
+
+// GetResourceRequirement returns the ResourceRequirement from the list, nil if there is none
+func GetResourceRequirement(arrayPtr []Requirement) (r *ResourceRequirement) {
+	for i := range arrayPtr {
+		switch arrayPtr[i].(type) {
+		case *ResourceRequirement:
+			r = arrayPtr[i].(*ResourceRequirement)
+			return
+		}
+	}
+	return
+}
+
+// GetResourceValue converts an evaluated field like coresMin into a number (cores or mebibytes), fractions are rounded up
+func GetResourceValue(value interface{}) (result int64, ok bool, err error) {
+
+	var f float64
+	switch value.(type) {
+	case nil:
+		return
+	case int:
+		f = float64(value.(int))
+	case int64:
+		f = float64(value.(int64))
+	case float64:
+		f = value.(float64)
+	case Int:
+		f = float64(value.(Int))
+	case Long:
+		f = float64(value.(Long))
+	case Float:
+		f = float64(value.(Float))
+	case Double:
+		f = float64(value.(Double))
+	case *Int:
+		f = float64(*value.(*Int))
+	case *Long:
+		f = float64(*value.(*Long))
+	case *Float:
+		f = float64(*value.(*Float))
+	case *Double:
+		f = float64(*value.(*Double))
+	case string:
+		err = fmt.Errorf("(GetResourceValue) expression \"%s\" has not been evaluated", value.(string))
+		return
+	default:
+		err = fmt.Errorf("(GetResourceValue) type invalid %s", reflect.TypeOf(value))
+		return
+	}
+
+	result = int64(math.Ceil(f))
+	ok = true
+	return
+}
@@ -0,0 +1,46 @@
+package cwl
+
+import (
+	"github.com/mitchellh/mapstructure"
+)
+
+// RetryRequirement AWE extension, controls if and when a failed workunit of the tool is requeued
+type RetryRequirement struct {
+	BaseRequirement `bson:",inline" yaml:",inline" json:",inline" mapstructure:",squash"`
+	MaxAttempts     int      `yaml:"maxAttempts,omitempty" bson:"maxAttempts,omitempty" json:"maxAttempts,omitempty" mapstructure:"maxAttempts,omitempty"`
+	Backoff         int      `yaml:"backoff,omitempty" bson:"backoff,omitempty" json:"backoff,omitempty" mapstructure:"backoff,omitempty"`             // seconds
+	MaxBackoff      int      `yaml:"maxBackoff,omitempty" bson:"maxBackoff,omitempty" json:"maxBackoff,omitempty" mapstructure:"maxBackoff,omitempty"` // seconds
+	ExitCodes       []int    `yaml:"exitCodes,omitempty" bson:"exitCodes,omitempty" json:"exitCodes,omitempty" mapstructure:"exitCodes,omitempty"`
+	RetryOn         []string `yaml:"retryOn,omitempty" bson:"retryOn,omitempty" json:"retryOn,omitempty" mapstructure:"retryOn,omitempty"` // app, infrastructure, timeout
+}
+
+// GetID _
+func (r RetryRequirement) GetID() string { return "None" }
+
+// NewRetryRequirementFromInterface _
+func NewRetryRequirementFromInterface(original interface{}) (r *RetryRequirement, err error) {
+	var requirement RetryRequirement
+	r = &requirement
+
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{WeaklyTypedInput: true, Result: &requirement})
+	if err != nil {
+		return
+	}
+	err = decoder.Decode(original)
+
+	requirement.Class = "RetryRequirement"
+
+	return
+}
+
+// GetRetryRequirement returns the RetryRequirement from the list, nil if there is none
+func GetRetryRequirement(arrayPtr []Requirement) (r *RetryRequirement) {
+	for i := range arrayPtr {
+		switch arrayPtr[i].(type) {
+		case *RetryRequirement:
+			r = arrayPtr[i].(*RetryRequirement)
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,79 @@
+package cwl
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// StorageRequirement AWE extension, selects where input and output files of the job are stored.
+// Type is "shock" (Url is the Shock API url), "s3" (Url is the endpoint of an S3-compatible server, Path a key prefix)
+// or "posix" (Path is a directory on a filesystem shared by submitter and workers).
+type StorageRequirement struct {
+	BaseRequirement `bson:",inline" yaml:",inline" json:",inline" mapstructure:",squash"`
+	Type            string `yaml:"type,omitempty" bson:"type,omitempty" json:"type,omitempty" mapstructure:"type,omitempty"`
+	Url             string `yaml:"url,omitempty" bson:"url,omitempty" json:"url,omitempty" mapstructure:"url,omitempty"`
+	Bucket          string `yaml:"bucket,omitempty" bson:"bucket,omitempty" json:"bucket,omitempty" mapstructure:"bucket,omitempty"`
+	Region          string `yaml:"region,omitempty" bson:"region,omitempty" json:"region,omitempty" mapstructure:"region,omitempty"`
+	Path            string `yaml:"path,omitempty" bson:"path,omitempty" json:"path,omitempty" mapstructure:"path,omitempty"`
+}
+
+// GetID _
+func (r StorageRequirement) GetID() string { return "None" }
+
+// NewStorageRequirement _
+func NewStorageRequirement(storageType string, url string, bucket string, region string, path string) (r *StorageRequirement, err error) {
+	r = &StorageRequirement{Type: storageType, Url: url, Bucket: bucket, Region: region, Path: path}
+	r.Class = "StorageRequirement"
+	err = r.Check()
+	return
+}
+
+// NewStorageRequirementFromInterface _
+func NewStorageRequirementFromInterface(original interface{}) (r *StorageRequirement, err error) {
+	var requirement StorageRequirement
+	r = &requirement
+	err = mapstructure.Decode(original, &requirement)
+	if err != nil {
+		err = fmt.Errorf("(NewStorageRequirementFromInterface) mapstructure.Decode returned: %s", err.Error())
+		return
+	}
+
+	requirement.Class = "StorageRequirement"
+
+	err = requirement.Check()
+	return
+}
+
+// Check verifies that the fields needed by the storage type are set
+func (r *StorageRequirement) Check() (err error) {
+	switch r.Type {
+	case "shock":
+		if r.Url == "" {
+			err = fmt.Errorf("(StorageRequirement) type shock requires url")
+		}
+	case "s3":
+		if r.Url == "" || r.Bucket == "" {
+			err = fmt.Errorf("(StorageRequirement) type s3 requires url and bucket")
+		}
+	case "posix":
+		if r.Path == "" {
+			err = fmt.Errorf("(StorageRequirement) type posix requires path")
+		}
+	default:
+		err = fmt.Errorf("(StorageRequirement) unknown type \"%s\" (shock, s3 or posix)", r.Type)
+	}
+	return
+}
+
+// GetStorageRequirement returns the StorageRequirement from the list, nil if there is none
+func GetStorageRequirement(arrayPtr []Requirement) (r *StorageRequirement) {
+	for i := range arrayPtr {
+		switch arrayPtr[i].(type) {
+		case *StorageRequirement:
+			r = arrayPtr[i].(*StorageRequirement)
+			return
+		}
+	}
+	return
+}
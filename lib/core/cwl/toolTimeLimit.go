@@ -0,0 +1,74 @@
+package cwl
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// ToolTimeLimit CWL v1.1, upper limit of the execution time of a CommandLineTool in seconds
+// https://www.commonwl.org/v1.1/CommandLineTool.html#ToolTimeLimit
+type ToolTimeLimit struct {
+	BaseRequirement `bson:",inline" yaml:",inline" json:",inline" mapstructure:",squash"`
+	Timelimit       interface{} `yaml:"timelimit,omitempty" bson:"timelimit,omitempty" json:"timelimit,omitempty" mapstructure:"timelimit,omitempty"` // long | Expression, 0 means no limit
+}
+
+// GetID _
+func (r ToolTimeLimit) GetID() string { return "None" }
+
+// NewToolTimeLimitFromInterface _
+func NewToolTimeLimitFromInterface(original interface{}, context *WorkflowContext) (r *ToolTimeLimit, err error) {
+
+	original, err = MakeStringMap(original, context)
+	if err != nil {
+		return
+	}
+
+	var requirement ToolTimeLimit
+	r = &requirement
+
+	err = mapstructure.Decode(original, &requirement)
+	if err != nil {
+		err = fmt.Errorf("(NewToolTimeLimitFromInterface) mapstructure.Decode returned: %s", err.Error())
+		return
+	}
+
+	requirement.Class = "ToolTimeLimit"
+
+	return
+}
+
+// Evaluate _
+func (r *ToolTimeLimit) Evaluate(inputs interface{}, context *WorkflowContext) (err error) {
+	r.Timelimit, err = evaluateRequirementValue(r.Timelimit, inputs, context)
+	if err != nil {
+		err = fmt.Errorf("(ToolTimeLimit/Evaluate) timelimit: %s", err.Error())
+	}
+	return
+}
+
+// GetSeconds returns the evaluated time limit, 0 means no limit
+func (r *ToolTimeLimit) GetSeconds() (seconds int64, err error) {
+	seconds, _, err = GetResourceValue(r.Timelimit)
+	if err != nil {
+		err = fmt.Errorf("(ToolTimeLimit/GetSeconds) %s", err.Error())
+		return
+	}
+	if seconds < 0 {
+		err = fmt.Errorf("(ToolTimeLimit/GetSeconds) timelimit must not be negative")
+		return
+	}
+	return
+}
+
+// GetToolTimeLimit returns the ToolTimeLimit from the list, nil if there is none
+func GetToolTimeLimit(arrayPtr []Requirement) (r *ToolTimeLimit) {
+	for i := range arrayPtr {
+		switch arrayPtr[i].(type) {
+		case *ToolTimeLimit:
+			r = arrayPtr[i].(*ToolTimeLimit)
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,69 @@
+package cwl
+
+import (
+	"fmt"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// WorkReuse CWL v1.1, allows to disable the reuse of results of previous runs of a CommandLineTool
+// https://www.commonwl.org/v1.1/CommandLineTool.html#WorkReuse
+type WorkReuse struct {
+	BaseRequirement `bson:",inline" yaml:",inline" json:",inline" mapstructure:",squash"`
+	EnableReuse     interface{} `yaml:"enableReuse" bson:"enableReuse" json:"enableReuse" mapstructure:"enableReuse"` // boolean | Expression
+}
+
+// GetID _
+func (r WorkReuse) GetID() string { return "None" }
+
+// NewWorkReuseFromInterface _
+func NewWorkReuseFromInterface(original interface{}, context *WorkflowContext) (r *WorkReuse, err error) {
+
+	original, err = MakeStringMap(original, context)
+	if err != nil {
+		return
+	}
+
+	var requirement WorkReuse
+	r = &requirement
+
+	err = mapstructure.Decode(original, &requirement)
+	if err != nil {
+		err = fmt.Errorf("(NewWorkReuseFromInterface) mapstructure.Decode returned: %s", err.Error())
+		return
+	}
+
+	requirement.Class = "WorkReuse"
+
+	return
+}
+
+// Evaluate _
+func (r *WorkReuse) Evaluate(inputs interface{}, context *WorkflowContext) (err error) {
+	r.EnableReuse, err = evaluateRequirementValue(r.EnableReuse, inputs, context)
+	if err != nil {
+		err = fmt.Errorf("(WorkReuse/Evaluate) enableReuse: %s", err.Error())
+	}
+	return
+}
+
+// IsEnabled returns the evaluated enableReuse field
+func (r *WorkReuse) IsEnabled() (enabled bool, err error) {
+	enabled, err = getRequirementBool(r.EnableReuse)
+	if err != nil {
+		err = fmt.Errorf("(WorkReuse/IsEnabled) %s", err.Error())
+	}
+	return
+}
+
+// GetWorkReuse returns the WorkReuse from the list, nil if there is none
+func GetWorkReuse(arrayPtr []Requirement) (r *WorkReuse) {
+	for i := range arrayPtr {
+		switch arrayPtr[i].(type) {
+		case *WorkReuse:
+			r = arrayPtr[i].(*WorkReuse)
+			return
+		}
+	}
+	return
+}
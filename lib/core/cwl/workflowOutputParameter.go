@@ -14,6 +14,7 @@ type WorkflowOutputParameter struct {
 	Doc             string                                                                `yaml:"doc,omitempty" bson:"doc,omitempty" json:"doc,omitempty"`
 	OutputSource    interface{}                                                           `yaml:"outputSource,omitempty" bson:"outputSource,omitempty" json:"outputSource,omitempty"` //string or []string
 	LinkMerge       LinkMergeMethod                                                       `yaml:"linkMerge,omitempty" bson:"linkMerge,omitempty" json:"linkMerge,omitempty"`
+	PickValue       PickValueMethod                                                       `yaml:"pickValue,omitempty" bson:"pickValue,omitempty" json:"pickValue,omitempty"`
 }
 
 // NewWorkflowOutputParameter _
@@ -26,6 +26,7 @@ type WorkflowStep struct {
 	Doc           string               `yaml:"doc,omitempty" bson:"doc,omitempty" json:"doc,omitempty" mapstructure:"doc,omitempty"`
 	Scatter       []string             `yaml:"scatter,omitempty" bson:"scatter,omitempty" json:"scatter,omitempty" mapstructure:"scatter,omitempty"`                         // ScatterFeatureRequirement
 	ScatterMethod string               `yaml:"scatterMethod,omitempty" bson:"scatterMethod,omitempty" json:"scatterMethod,omitempty" mapstructure:"scatterMethod,omitempty"` // ScatterFeatureRequirement
+	When          Expression           `yaml:"when,omitempty" bson:"when,omitempty" json:"when,omitempty" mapstructure:"when,omitempty"`
 	//CwlVersion    CWLVersion           `bson:"cwlVersion,omitempty"  mapstructure:"cwlVersion,omitempty"`
 	//Namespaces    map[string]string    `yaml:"$namespaces,omitempty" bson:"_DOLLAR_namespaces,omitempty" json:"$namespaces,omitempty" mapstructure:"$namespaces,omitempty"`
 }
@@ -396,6 +397,30 @@ func (ws *WorkflowStep) GetOutput(id string) (output *WorkflowStepOutput, err er
 	return
 }
 
+// EvaluateWhen evaluates the conditional of CWL v1.2, inputs are the step inputs (after valueFrom).
+// Steps without a when expression always run.
+func (ws *WorkflowStep) EvaluateWhen(inputs interface{}, context *WorkflowContext) (run bool, err error) {
+	if ws.When == "" {
+		run = true
+		return
+	}
+
+	var result interface{}
+	result, err = ws.When.EvaluateExpression(nil, inputs, context)
+	if err != nil {
+		err = fmt.Errorf("(WorkflowStep/EvaluateWhen) EvaluateExpression returned: %s", err.Error())
+		return
+	}
+
+	resultBool, ok := result.(*Boolean)
+	if !ok {
+		err = fmt.Errorf("(WorkflowStep/EvaluateWhen) when expression of step %s has to return a boolean, got %s", ws.ID, reflect.TypeOf(result))
+		return
+	}
+	run = bool(*resultBool)
+	return
+}
+
 // CreateWorkflowStepsArray _
 func CreateWorkflowStepsArray(original interface{}, workflowID string, injectedRequirements []Requirement, context *WorkflowContext) (schemata []CWLType_Type, arrayPtr *[]WorkflowStep, err error) {
 
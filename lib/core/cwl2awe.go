@@ -64,112 +64,122 @@ func CWLInputCheck(jobInput *cwl.Job_document, cwlWorkflow *cwl.Workflow, contex
 
 	for _, input := range cwlWorkflow.Inputs {
 		// input is a cwl.InputParameter object
+		jobInput, err = checkWorkflowInput(input, jobInput, jobInputMap, context)
+		if err != nil {
+			return
+		}
+	}
 
-		//spew.Dump(input)
+	jobInputNew = jobInput
 
-		id := input.ID
-		logger.Debug(3, "(CWLInputCheck) Parsing workflow input %s", id)
+	return
+}
 
-		idBase := path.Base(id)
+// checkWorkflowInput checks the type of one workflow input in the job input document, a missing input is replaced by its default
+func checkWorkflowInput(input cwl.InputParameter, jobInput *cwl.Job_document, jobInputMap cwl.JobDocMap, context *cwl.WorkflowContext) (jobInputNew *cwl.Job_document, err error) {
 
-		var expectedTypes []cwl.CWLType_Type
-		expectedTypes, err = input.GetTypes()
-		if err != nil {
-			err = fmt.Errorf("(CWLInputCheck) input.GetTypes returned: %s", err.Error())
-			return
-		}
-		// expectedTypesIf := input.Type
-
-		// switch expectedTypesIf.(type) {
-		// case []interface{}:
-		// 	expectedTypesArrayIf := expectedTypesIf.([]interface{})
-		// 	for _, tIf := range expectedTypesArrayIf {
-		// 		t, ok := tIf.(cwl.CWLType_Type)
-		// 		if !ok {
-		// 			err = fmt.Errorf("(CWLInputCheck) could not convert array element")
-		// 			return
-		// 		}
-		// 		expectedTypes = append(expectedTypes, t)
-		// 	}
-		// default:
-		// 	t, ok := expectedTypesIf.(cwl.CWLType_Type)
-		// 	if !ok {
-		// 		err = fmt.Errorf("(CWLInputCheck) could not convert expectedTypesIf element")
-		// 		return
-		// 	}
-		// 	expectedTypes = []cwl.CWLType_Type{t}
-		// }
-
-		if len(expectedTypes) == 0 {
-			err = fmt.Errorf("(CWLInputCheck) (len(expected_types) == 0 ")
-			return
-		}
+	//spew.Dump(input)
 
-		// find workflow input in jobInputMap
-		inputObjRef, ok := jobInputMap[idBase] // returns CWLType
-		if !ok {
-			// not found, we can skip it it is optional anyway
+	id := input.ID
+	logger.Debug(3, "(CWLInputCheck) Parsing workflow input %s", id)
 
-			if input.Default != nil {
-				logger.Debug(3, "input %s not found, replace with Default object", idBase)
-				inputObjRef = input.Default
+	idBase := path.Base(id)
 
-				//input_obj_ref_file, ok := input_obj_ref.(*cwl.File)
+	var expectedTypes []cwl.CWLType_Type
+	expectedTypes, err = input.GetTypes()
+	if err != nil {
+		err = fmt.Errorf("(CWLInputCheck) input.GetTypes returned: %s", err.Error())
+		return
+	}
+	// expectedTypesIf := input.Type
+
+	// switch expectedTypesIf.(type) {
+	// case []interface{}:
+	// 	expectedTypesArrayIf := expectedTypesIf.([]interface{})
+	// 	for _, tIf := range expectedTypesArrayIf {
+	// 		t, ok := tIf.(cwl.CWLType_Type)
+	// 		if !ok {
+	// 			err = fmt.Errorf("(CWLInputCheck) could not convert array element")
+	// 			return
+	// 		}
+	// 		expectedTypes = append(expectedTypes, t)
+	// 	}
+	// default:
+	// 	t, ok := expectedTypesIf.(cwl.CWLType_Type)
+	// 	if !ok {
+	// 		err = fmt.Errorf("(CWLInputCheck) could not convert expectedTypesIf element")
+	// 		return
+	// 	}
+	// 	expectedTypes = []cwl.CWLType_Type{t}
+	// }
+
+	if len(expectedTypes) == 0 {
+		err = fmt.Errorf("(CWLInputCheck) (len(expected_types) == 0 ")
+		return
+	}
 
-				jobInput = jobInput.Add(idBase, inputObjRef)
-			} else {
-				inputObjRef = cwl.NewNull()
-				logger.Debug(3, "input %s not found, replace with Null object (no Default found)", idBase)
-			}
-		}
+	// find workflow input in jobInputMap
+	inputObjRef, ok := jobInputMap[idBase] // returns CWLType
+	if !ok {
+		// not found, we can skip it it is optional anyway
 
-		if inputObjRef == nil {
-			err = fmt.Errorf("(CWLInputCheck) input_obj_ref == nil")
-			return
-		}
-		// Get type of CWL_Type we found
-		inputType := inputObjRef.GetType()
-		if inputType == nil {
+		if input.Default != nil {
+			logger.Debug(3, "input %s not found, replace with Default object", idBase)
+			inputObjRef = input.Default
 
-			err = fmt.Errorf("(CWLInputCheck) input_type == nil %s", spew.Sdump(inputObjRef))
-			return
+			//input_obj_ref_file, ok := input_obj_ref.(*cwl.File)
+
+			jobInput = jobInput.Add(idBase, inputObjRef)
+		} else {
+			inputObjRef = cwl.NewNull()
+			logger.Debug(3, "input %s not found, replace with Null object (no Default found)", idBase)
 		}
-		//input_type_str := "unknown"
-		logger.Debug(1, "(CWLInputCheck) input_type: %s (%s)", inputType, inputType.Type2String())
+	}
 
-		// Check if type of input we have matches one of the allowed types
-		hasType, xerr := cwl.TypeIsCorrect(expectedTypes, inputObjRef, context)
-		if xerr != nil {
-			err = fmt.Errorf("(CWLInputCheck) (B) HasInputParameterType returns: %s", xerr.Error())
+	if inputObjRef == nil {
+		err = fmt.Errorf("(CWLInputCheck) input_obj_ref == nil")
+		return
+	}
+	// Get type of CWL_Type we found
+	inputType := inputObjRef.GetType()
+	if inputType == nil {
 
-			return
-		}
-		if !hasType {
+		err = fmt.Errorf("(CWLInputCheck) input_type == nil %s", spew.Sdump(inputObjRef))
+		return
+	}
+	//input_type_str := "unknown"
+	logger.Debug(1, "(CWLInputCheck) input_type: %s (%s)", inputType, inputType.Type2String())
 
-			if inputObjRef.GetType() == cwl.CWLNull && input.Default != nil {
-				// work-around to make sure ExpressionTool can use Default if it gets Null
+	// Check if type of input we have matches one of the allowed types
+	hasType, xerr := cwl.TypeIsCorrect(expectedTypes, inputObjRef, context)
+	if xerr != nil {
+		err = fmt.Errorf("(CWLInputCheck) (B) HasInputParameterType returns: %s", xerr.Error())
 
-			} else {
+		return
+	}
+	if !hasType {
 
-				//if strings.ToLower(obj_type) != strings.ToLower(expected_types) {
-				fmt.Printf("object found: ")
-				spew.Dump(inputObjRef)
+		if inputObjRef.GetType() == cwl.CWLNull && input.Default != nil {
+			// work-around to make sure ExpressionTool can use Default if it gets Null
 
-				expectedTypesStr := ""
-				for _, elem := range expectedTypes {
-					expectedTypesStr += "," + elem.Type2String()
-				}
-				//fmt.Printf("cwl_workflow.Inputs")
-				//spew.Dump(cwl_workflow.Inputs)
-				err = fmt.Errorf("(CWLInputCheck) Input %s has type %s, but this does not match the expected types(%s)", id, inputType, expectedTypesStr)
-				return
+		} else {
+
+			//if strings.ToLower(obj_type) != strings.ToLower(expected_types) {
+			fmt.Printf("object found: ")
+			spew.Dump(inputObjRef)
+
+			expectedTypesStr := ""
+			for _, elem := range expectedTypes {
+				expectedTypesStr += "," + elem.Type2String()
 			}
+			//fmt.Printf("cwl_workflow.Inputs")
+			//spew.Dump(cwl_workflow.Inputs)
+			err = fmt.Errorf("(CWLInputCheck) Input %s has type %s, but this does not match the expected types(%s)", id, inputType, expectedTypesStr)
+			return
 		}
-
 	}
 
 	jobInputNew = jobInput
-
 	return
 }
 
@@ -285,13 +295,25 @@ func CWL2AWE(_user *user.User, files FormFiles, jobInput *cwl.Job_document, cwlW
 
 				job.ShockHost = sr.Shock_api_url
 				foundShockRequirement = true
+			case "StorageRequirement":
+				sr, ok := r.(*cwl.StorageRequirement)
+				if !ok {
+					err = fmt.Errorf("(CWL2AWE) Could not assert StorageRequirement (type: %s)", reflect.TypeOf(r))
+					return
+				}
+
+				if sr.Type == "shock" {
+					job.ShockHost = sr.Url
+				}
+				job.CWL_StorageRequirement = sr
+				foundShockRequirement = true
 
 			}
 		}
 	}
 
 	if !foundShockRequirement {
-		err = fmt.Errorf("(CWL2AWE) ShockRequirement has to be provided in the workflow object")
+		err = fmt.Errorf("(CWL2AWE) ShockRequirement or StorageRequirement has to be provided in the workflow object")
 		return
 		//job.ShockHost = "http://shock:7445" // TODO make this different
 
@@ -0,0 +1,496 @@
+package core
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+
+	"github.com/MG-RAST/AWE/lib/core/cwl"
+	"github.com/MG-RAST/AWE/lib/logger"
+	"github.com/MG-RAST/AWE/lib/user"
+)
+
+// CreateJobFromCWL parses a CWL graph document and its job input document and creates the AWE job.
+// Tools without a workflow are wrapped in a single-step workflow. The workflow file in files is attached to the job.
+func CreateJobFromCWL(u *user.User, files FormFiles, yamlstream []byte, cwlWorkflowFileName string, jobStream []byte, entrypoint string) (job *Job, err error) {
+
+	var jobInput *cwl.Job_document
+	jobInput, err = parseCWLJobInput(jobStream)
+	if err != nil {
+		err = fmt.Errorf("(CreateJobFromCWL) %s", err.Error())
+		return
+	}
+
+	var cwlWorkflow *cwl.Workflow
+	var context *cwl.WorkflowContext
+	var shockRequirement *cwl.ShockRequirement
+	cwlWorkflow, entrypoint, context, shockRequirement, err = parseCWLWorkflow(yamlstream, cwlWorkflowFileName, entrypoint)
+	if err != nil {
+		err = fmt.Errorf("(CreateJobFromCWL) %s", err.Error())
+		return
+	}
+
+	job, err = CWL2AWE(u, files, jobInput, cwlWorkflow, entrypoint, context)
+	if err != nil {
+		err = fmt.Errorf("(CreateJobFromCWL) CWL2AWE returned: %s", err.Error())
+		return
+	}
+
+	job.Entrypoint = entrypoint
+	job.IsCWL = true
+
+	if shockRequirement != nil {
+		job.CWL_ShockRequirement = shockRequirement
+	}
+	return
+}
+
+// parseCWLJobInput returns an empty job input document if there is none
+func parseCWLJobInput(jobStream []byte) (jobInput *cwl.Job_document, err error) {
+	if jobStream == nil {
+		jobInput = &cwl.Job_document{} // no input
+		return
+	}
+	jobInput, err = cwl.ParseJob(&jobStream)
+	if err != nil {
+		err = fmt.Errorf("error in reading job yaml/json file: %s", err.Error())
+	}
+	return
+}
+
+// parseCWLWorkflow parses the graph document and returns the workflow of the entrypoint (default: #main).
+// A CommandLineTool or ExpressionTool is wrapped in a new workflow, then the returned entrypoint is the one of the wrapper.
+func parseCWLWorkflow(yamlstream []byte, cwlWorkflowFileName string, entrypoint string) (cwlWorkflow *cwl.Workflow, workflowEntrypoint string, context *cwl.WorkflowContext, shockRequirement *cwl.ShockRequirement, err error) {
+
+	if entrypoint == "" {
+		entrypoint = "#main"
+	}
+
+	// convert CWL to string
+	yamlStr := string(yamlstream[:])
+
+	//fmt.Println("yamlStr:")
+	//fmt.Println(yamlStr)
+	//panic("done")
+
+	var schemata []cwl.CWLType_Type
+	var objectArray []cwl.NamedCWLObject
+	//var cwl_version cwl.CWLVersion
+	//var namespaces map[string]string
+	//var schemas []interface{}
+
+	var newEntrypoint string
+	// the returning entrypoint should always be empty because only graph documnets are submitted by the submitter
+	objectArray, schemata, context, _, newEntrypoint, err = cwl.ParseCWLDocument(nil, yamlStr, entrypoint, "-", "#"+cwlWorkflowFileName) // TODO need filename. last argument
+	if err != nil {
+		err = fmt.Errorf("(parseCWLWorkflow) error in parsing cwl workflow yaml file (entrypoint: %s): %s", entrypoint, err.Error())
+		return
+	}
+
+	if newEntrypoint != "" {
+		err = fmt.Errorf("(parseCWLWorkflow) only graph documents supported currently")
+		return
+	}
+
+	hasWorkflow := false
+
+	// find entrypoint object
+	entrypointIndex := -1
+
+	for i := range objectArray {
+		pair := objectArray[i]
+		object := pair.Value
+		_, isWf := object.(*cwl.Workflow)
+		if isWf {
+			hasWorkflow = true
+		}
+
+		objectID := pair.ID
+
+		if objectID == entrypoint {
+			entrypointIndex = i
+		}
+
+	}
+
+	if entrypointIndex == -1 {
+		err = fmt.Errorf("(parseCWLWorkflow) entrypoint %s not found", entrypoint)
+		return
+	}
+
+	//err = context.AddArray(objectArray)
+	//if err != nil {
+	//	logger.Error("Parse_cwl_document error: " + err.Error())
+	//	cx.RespondWithErrorMessage("error in adding cwl objects to collection: "+err.Error(), http.StatusBadRequest)
+	//	return
+	//}
+	//logger.Debug(1, "Parse_cwl_document done")
+
+	err = context.AddSchemata(schemata, true)
+	if err != nil {
+		err = fmt.Errorf("(parseCWLWorkflow) error in adding schemata: %s", err.Error())
+		return
+	}
+
+	logger.Debug(3, "(parseCWLWorkflow) context.WorkflowCount: %d", context.WorkflowCount)
+	//spew.Dump(object_array)
+	//panic("done")
+	if !hasWorkflow {
+		// This probably is a simple CommandlineTool or ExpressionTool submission (without workflow)
+		// create new Workflow to wrap around the CommandLineTool/ExpressionTool
+
+		// if len(objectArray) != 1 {
+
+		// 	cx.RespondWithErrorMessage(fmt.Sprintf("Expected exactly one element in objectArray, got %d", len(objectArray)), http.StatusBadRequest)
+		// 	return
+		// }
+
+		wrapperEntrypoint := "#entrypoint"
+
+		// find entrypoint object
+
+		pair := objectArray[entrypointIndex]
+
+		runner := pair.Value
+
+		switch runner.(type) {
+		case *cwl.Workflow:
+			workflow := runner.(*cwl.Workflow)
+			workflow.CwlVersion = context.CwlVersion
+
+		case *cwl.CommandLineTool:
+			entrypoint = wrapperEntrypoint
+			commandlinetoolIf := pair.Value
+
+			commandlinetool, ok := commandlinetoolIf.(*cwl.CommandLineTool)
+			if !ok {
+
+				err = fmt.Errorf("(parseCWLWorkflow) Error casting CommandLineTool (type: %s)", reflect.TypeOf(commandlinetoolIf))
+				return
+			}
+
+			if shockRequirement == nil {
+				shockRequirement, err = cwl.GetShockRequirement(commandlinetool.Requirements)
+				if err != nil {
+					logger.Debug(1, "(parseCWLWorkflow) GetShockRequirement returned: %s", err.Error())
+					shockRequirement = nil
+				}
+			}
+
+			cwlWorkflowInstance := cwl.NewWorkflowEmpty()
+			cwlWorkflow = &cwlWorkflowInstance
+			cwlWorkflow.ID = wrapperEntrypoint
+			cwlWorkflow.CwlVersion = context.CwlVersion
+			cwlWorkflow.Namespaces = context.Namespaces
+			newStep := cwl.WorkflowStep{}
+			stepID := wrapperEntrypoint + "/wrapper_step"
+			newStep.ID = stepID
+			for _, input := range commandlinetool.Inputs { // input is CommandInputParameter
+
+				workflowInputName := wrapperEntrypoint + "/" + path.Base(input.ID) // e.g. #entrypoint/reference
+
+				var workflowStepInput cwl.WorkflowStepInput
+				workflowStepInput.ID = stepID + "/" + path.Base(input.ID)
+				workflowStepInput.Source = workflowInputName
+				workflowStepInput.Default = input.Default
+
+				//fmt.Println("CommandInputParameter and WorkflowStepInput:")
+				//spew.Dump(input)
+				//spew.Dump(workflow_step_input)
+				newStep.In = append(newStep.In, workflowStepInput)
+
+				var workflowInputParameter cwl.InputParameter
+				workflowInputParameter.ID = workflowInputName
+				workflowInputParameter.SecondaryFiles = input.SecondaryFiles
+				workflowInputParameter.Format = input.Format
+				workflowInputParameter.Streamable = input.Streamable
+				workflowInputParameter.InputBinding = input.InputBinding
+				workflowInputParameter.Type = input.Type
+
+				workflowInputParameter.Default = input.Default
+
+				addNull := false
+				if input.Default != nil { // check if this is an optional argument
+					addNull = true
+				}
+
+				if addNull {
+					hasNull := false
+
+					var workflowInputParameterTypes []cwl.CWLType_Type
+
+					workflowInputParameterTypes, err = workflowInputParameter.GetTypes()
+					if err != nil {
+						err = fmt.Errorf("(parseCWLWorkflow) (B) workflowInputParameter.GetTypes returned: %s ", err.Error())
+						return
+					}
+					if len(workflowInputParameterTypes) == 0 {
+						err = fmt.Errorf("(parseCWLWorkflow) (B) workflowInputParameterTypes empty ")
+						return
+					}
+
+				THISLOOP:
+					for _, t := range workflowInputParameterTypes {
+
+						if t == cwl.CWLNull {
+							hasNull = true
+							break THISLOOP
+						}
+					}
+
+					// for _, t := range workflowInputParameter.Type {
+					// 	if t == cwl.CWLNull {
+					// 		hasNull = true
+					// 		break
+					// 	}
+					// }
+					if !hasNull {
+
+						workflowInputParameter.Type = append(workflowInputParameterTypes, cwl.CWLNull)
+
+					}
+				}
+
+				cwlWorkflow.Inputs = append(cwlWorkflow.Inputs, workflowInputParameter)
+			}
+
+			for _, output := range commandlinetool.Outputs {
+				var workflowStepOutput cwl.WorkflowStepOutput
+				workflowStepOutput.Id = stepID + "/" + path.Base(output.Id)
+
+				newStep.Out = append(newStep.Out, workflowStepOutput)
+
+				var workflowOutputParameter cwl.WorkflowOutputParameter
+
+				workflowOutputParameter.Id = wrapperEntrypoint + "/" + path.Base(output.Id)
+
+				workflowOutputParameter.OutputSource = stepID + "/" + path.Base(output.Id)
+				workflowOutputParameter.SecondaryFiles = output.SecondaryFiles
+				workflowOutputParameter.Format = output.Format
+				workflowOutputParameter.Streamable = output.Streamable
+				//workflowOutputParameter.OutputBinding = output.OutputBinding
+				//workflowOutputParameter.OutputSource = output.OutputSource
+				//workflowOutputParameter.LinkMerge = output.LinkMerge
+				workflowOutputParameter.Type = output.Type
+				cwlWorkflow.Outputs = append(cwlWorkflow.Outputs, workflowOutputParameter)
+			}
+
+			if commandlinetool.Requirements != nil {
+				requirements := commandlinetool.Requirements
+				for i := range requirements {
+					requireType := (requirements)[i].GetClass()
+					if requireType == "ShockRequirement" || requireType == "StorageRequirement" {
+						requirement := (requirements)[i]
+
+						cwlWorkflow.Requirements, err = cwl.AddRequirement(requirement, requirements)
+						if err != nil {
+							err = fmt.Errorf("(parseCWLWorkflow) AddRequirement returned: %s", err.Error())
+							return
+						}
+					}
+				}
+			}
+
+			newStep.Run = commandlinetool.ID
+
+			cwlWorkflow.Steps = []cwl.WorkflowStep{newStep}
+
+			cwlWorkflowNamed := cwl.NamedCWLObject{}
+			cwlWorkflowNamed.ID = cwlWorkflow.ID
+			cwlWorkflowNamed.Value = cwlWorkflow
+
+			objectArray = append(objectArray, cwlWorkflowNamed)
+			//err = context.Add(entrypoint, cwlWorkflow, "job/create")
+			//if err != nil {
+			//	cx.RespondWithErrorMessage("collection.Add returned: "+err.Error(), http.StatusBadRequest)
+			//	return
+			//}
+
+		case *cwl.ExpressionTool:
+			entrypoint = wrapperEntrypoint
+			expressiontoolIf := pair.Value
+
+			expressiontool, ok := expressiontoolIf.(*cwl.ExpressionTool)
+			if !ok {
+
+				err = fmt.Errorf("(parseCWLWorkflow) Error casting ExpressionTool (type: %s)", reflect.TypeOf(expressiontoolIf))
+				return
+			}
+
+			if shockRequirement == nil {
+				shockRequirement, err = cwl.GetShockRequirement(expressiontool.Requirements)
+				if err != nil {
+					logger.Debug(1, "(parseCWLWorkflow) GetShockRequirement returned: %s", err.Error())
+					shockRequirement = nil
+				}
+			}
+
+			cwlWorkflowInstance := cwl.NewWorkflowEmpty()
+			cwlWorkflow = &cwlWorkflowInstance
+			cwlWorkflow.ID = wrapperEntrypoint
+			cwlWorkflow.CwlVersion = context.CwlVersion
+			newStep := cwl.WorkflowStep{}
+			stepID := wrapperEntrypoint + "/wrapper_step"
+			newStep.ID = stepID
+			for _, input := range expressiontool.Inputs { // input is InputParameter
+
+				workflowInputName := wrapperEntrypoint + "/" + path.Base(input.ID)
+
+				var workflowStepInput cwl.WorkflowStepInput
+				workflowStepInput.ID = stepID + "/" + path.Base(input.ID)
+				workflowStepInput.Source = workflowInputName
+				workflowStepInput.Default = input.Default
+
+				//fmt.Println("InputParameter and WorkflowStepInput:")
+				//spew.Dump(input)
+				//spew.Dump(workflowStepInput)
+				newStep.In = append(newStep.In, workflowStepInput)
+
+				var workflowInputParameter cwl.InputParameter
+				workflowInputParameter.ID = workflowInputName
+				workflowInputParameter.SecondaryFiles = input.SecondaryFiles
+				workflowInputParameter.Format = input.Format
+				workflowInputParameter.Streamable = input.Streamable
+				workflowInputParameter.InputBinding = input.InputBinding
+				workflowInputParameter.Type = input.Type
+
+				workflowInputParameter.Default = input.Default
+
+				addNull := false
+				if input.Default != nil { // check if this is an optional argument
+					addNull = true
+				}
+
+				if addNull {
+					hasNull := false
+
+					var workflowInputParameterTypeArray []cwl.CWLType_Type
+					workflowInputParameterTypeArray, err = workflowInputParameter.GetTypes()
+					if err != nil {
+						err = fmt.Errorf("(parseCWLWorkflow) (A) workflowInputParameter.GetTypes returned: %s ", err.Error())
+						return
+					}
+
+					if len(workflowInputParameterTypeArray) == 0 {
+						err = fmt.Errorf("(parseCWLWorkflow) (A) workflowInputParameterTypeArray empty ")
+						return
+					}
+				MYLOOP:
+					for _, t := range workflowInputParameterTypeArray {
+						if t == cwl.CWLNull {
+							hasNull = true
+							break MYLOOP
+						}
+					}
+					//fmt.Println("workflowInputParameter.Type:")
+					//spew.Dump(workflowInputParameter.Type)
+					if !hasNull {
+						workflowInputParameter.Type = append(workflowInputParameterTypeArray, cwl.CWLNull)
+						//fmt.Println("workflowInputParameter.Type: after")
+						//spew.Dump(workflowInputParameter.Type)
+					}
+				}
+
+				cwlWorkflow.Inputs = append(cwlWorkflow.Inputs, workflowInputParameter)
+			}
+
+			for _, output := range expressiontool.Outputs { // type: ExpressionToolOutputParameter
+
+				outputEtop, ok := output.(*cwl.ExpressionToolOutputParameter)
+				if ok {
+					var workflowStepOutput cwl.WorkflowStepOutput
+					workflowStepOutput.Id = stepID + "/" + path.Base(outputEtop.Id)
+
+					newStep.Out = append(newStep.Out, workflowStepOutput)
+
+					var workflowOutputParameter cwl.WorkflowOutputParameter
+
+					workflowOutputParameter.Id = wrapperEntrypoint + "/" + path.Base(outputEtop.Id)
+					workflowOutputParameter.OutputSource = stepID + "/" + path.Base(outputEtop.Id)
+					workflowOutputParameter.SecondaryFiles = outputEtop.SecondaryFiles
+					workflowOutputParameter.Format = outputEtop.Format
+					workflowOutputParameter.Streamable = outputEtop.Streamable
+					//workflow_output_parameter.OutputBinding = output.OutputBinding
+					//workflow_output_parameter.OutputSource = output.OutputSource
+					//workflow_output_parameter.LinkMerge = output.LinkMerge
+					workflowOutputParameter.Type = outputEtop.Type
+					cwlWorkflow.Outputs = append(cwlWorkflow.Outputs, workflowOutputParameter)
+				} else {
+					err = fmt.Errorf("(parseCWLWorkflow) ExpressionToolOutputParameter still required, got: %s", reflect.TypeOf(output))
+					return
+				}
+			}
+
+			if expressiontool.Requirements != nil {
+				requirements := expressiontool.Requirements
+				for i := range requirements {
+					requireType := (requirements)[i].GetClass()
+					if requireType == "ShockRequirement" || requireType == "StorageRequirement" {
+						requirement := (requirements)[i]
+
+						cwlWorkflow.Requirements, err = cwl.AddRequirement(requirement, requirements)
+						if err != nil {
+							err = fmt.Errorf("(parseCWLWorkflow) AddRequirement returned: %s", err.Error())
+							return
+						}
+					}
+				}
+			}
+
+			newStep.Run = expressiontool.ID
+
+			cwlWorkflow.Steps = []cwl.WorkflowStep{newStep}
+
+			cwlWorkflowNamed := cwl.NamedCWLObject{}
+			cwlWorkflowNamed.ID = cwlWorkflow.ID
+			cwlWorkflowNamed.Value = cwlWorkflow
+
+			objectArray = append(objectArray, cwlWorkflowNamed)
+			//err = context.Add(entrypoint, cwlWorkflow, "job/create2")
+			//if err != nil {
+			//	cx.RespondWithErrorMessage("collection.Add returned: "+err.Error(), http.StatusBadRequest)
+			//	return
+			//}
+		default:
+			err = fmt.Errorf("(parseCWLWorkflow) Runner type %s not supported", reflect.TypeOf(runner))
+
+			return
+		}
+		//spew.Dump(cwlWorkflow)
+
+	} else { // context.WorkflowCount > 0
+		//entrypoint = "#entrypoint"
+
+		//var ok bool
+		cwlWorkflow, err = context.GetWorkflow(entrypoint)
+		if err != nil {
+			err = fmt.Errorf("(parseCWLWorkflow) Workflow %s not found (%s)", entrypoint, err.Error())
+			return
+		}
+
+		shockRequirement, err = cwl.GetShockRequirement(cwlWorkflow.Requirements)
+		if err != nil {
+			logger.Debug(1, "(parseCWLWorkflow) GetShockRequirement returned: %s", err.Error())
+			shockRequirement = nil
+		}
+
+	}
+
+	// replace interfaces with real objects (inlcuding new wrapper workflow if applicable)
+	context.GraphDocument.Graph = []interface{}{}
+
+	for i := range objectArray {
+		pair := objectArray[i]
+		object := pair.Value
+		logger.Debug(3, "(parseCWLWorkflow) adding to context.GraphDocument.Graph: %s", pair.ID)
+		context.GraphDocument.Graph = append(context.GraphDocument.Graph, object)
+	}
+
+	//fmt.Println("\n\n\n--------------------------------- Steps:\n")
+	//for _, step := range cwl_workflow.Steps {
+	//	spew.Dump(step)
+	//}
+
+	workflowEntrypoint = entrypoint
+	return
+}
@@ -0,0 +1,177 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/MG-RAST/AWE/lib/core/cwl"
+)
+
+// stages of a submission in which a ValidationError was found
+const (
+	VALIDATION_JOB_INPUT   = "job_input"   // job input document could not be parsed
+	VALIDATION_PARSE       = "parse"       // workflow document or job script could not be parsed
+	VALIDATION_REQUIREMENT = "requirement" // requirement needed by AWE is missing
+	VALIDATION_INPUT       = "input"       // workflow input missing or of the wrong type
+	VALIDATION_SOURCE      = "source"      // source of a step input or workflow output does not resolve
+	VALIDATION_RUN         = "run"         // process of a step not found
+)
+
+// ValidationError one problem found in a submission, ID is the CWL object (or task) it refers to
+type ValidationError struct {
+	Stage   string `json:"stage"`
+	ID      string `json:"id,omitempty"`
+	Message string `json:"message"`
+}
+
+// ValidationReport result of a dry-run submission, nothing is saved or enqueued
+type ValidationReport struct {
+	Valid  bool              `json:"valid"`
+	Errors []ValidationError `json:"errors"`
+}
+
+func (r *ValidationReport) add(stage string, id string, message string) {
+	r.Errors = append(r.Errors, ValidationError{Stage: stage, ID: id, Message: message})
+}
+
+func (r *ValidationReport) finish() *ValidationReport {
+	r.Valid = len(r.Errors) == 0
+	return r
+}
+
+// ValidateCWLSubmission runs the checks of CreateJobFromCWL and reports all problems instead of the first one:
+// parsing, ShockRequirement or StorageRequirement, types of the workflow inputs and sources of all steps and outputs of every workflow in the graph
+func ValidateCWLSubmission(yamlstream []byte, cwlWorkflowFileName string, jobStream []byte, entrypoint string) (report *ValidationReport) {
+	report = &ValidationReport{Errors: []ValidationError{}}
+
+	jobInput, err := parseCWLJobInput(jobStream)
+	if err != nil {
+		report.add(VALIDATION_JOB_INPUT, "", err.Error())
+	}
+
+	cwlWorkflow, _, context, _, err := parseCWLWorkflow(yamlstream, cwlWorkflowFileName, entrypoint)
+	if err != nil {
+		report.add(VALIDATION_PARSE, entrypoint, err.Error())
+		return report.finish()
+	}
+
+	if _, err = cwl.GetShockRequirement(cwlWorkflow.Requirements); err != nil && cwl.GetStorageRequirement(cwlWorkflow.Requirements) == nil {
+		report.add(VALIDATION_REQUIREMENT, cwlWorkflow.ID, "ShockRequirement or StorageRequirement has to be provided in the workflow object")
+	}
+
+	if jobInput != nil {
+		jobInputMap := jobInput.GetMap()
+		for _, input := range cwlWorkflow.Inputs {
+			checked, xerr := checkWorkflowInput(input, jobInput, jobInputMap, context)
+			if xerr != nil {
+				report.add(VALIDATION_INPUT, input.ID, xerr.Error())
+				continue
+			}
+			jobInput = checked
+		}
+	}
+
+	for _, object := range context.GraphDocument.Graph {
+		workflow, ok := object.(*cwl.Workflow)
+		if !ok {
+			continue
+		}
+		validateWorkflowSources(workflow, context, report)
+	}
+
+	return report.finish()
+}
+
+// validateWorkflowSources checks that every source refers to an input of the workflow or an output of one of its steps
+func validateWorkflowSources(workflow *cwl.Workflow, context *cwl.WorkflowContext, report *ValidationReport) {
+	workflowName := strings.TrimPrefix(workflow.ID, "#")
+	relative := func(id string) string {
+		return strings.TrimPrefix(strings.TrimPrefix(id, "#"), workflowName+"/")
+	}
+
+	known := make(map[string]bool)
+	for _, input := range workflow.Inputs {
+		known[relative(input.ID)] = true
+	}
+	for _, step := range workflow.Steps {
+		for _, output := range step.Out {
+			name := relative(output.Id)
+			if !strings.Contains(name, "/") {
+				name = relative(step.ID) + "/" + name
+			}
+			known[name] = true
+		}
+	}
+
+	check := func(id string, sourceIf interface{}) {
+		sources, err := sourceList(sourceIf)
+		if err != nil {
+			report.add(VALIDATION_SOURCE, id, err.Error())
+			return
+		}
+		for _, source := range sources {
+			if !known[relative(source)] {
+				report.add(VALIDATION_SOURCE, id, fmt.Sprintf("source %s is neither an input nor a step output of workflow %s", source, workflow.ID))
+			}
+		}
+	}
+
+	for _, step := range workflow.Steps {
+		for _, input := range step.In {
+			check(input.ID, input.Source)
+		}
+
+		if run, ok := step.Run.(string); ok {
+			if _, found, _ := context.Get(run, true); !found {
+				report.add(VALIDATION_RUN, step.ID, fmt.Sprintf("process %s not found in the graph document", run))
+			}
+		}
+	}
+	for _, output := range workflow.Outputs {
+		check(output.Id, output.OutputSource)
+	}
+}
+
+// sourceList converts a source field (string or list of strings) into a list
+func sourceList(sourceIf interface{}) (sources []string, err error) {
+	switch source := sourceIf.(type) {
+	case nil:
+	case string:
+		sources = []string{source}
+	case []string:
+		sources = source
+	case []interface{}:
+		for _, s := range source {
+			str, ok := s.(string)
+			if !ok {
+				err = fmt.Errorf("source element is not a string (type: %T)", s)
+				return
+			}
+			sources = append(sources, str)
+		}
+	default:
+		err = fmt.Errorf("source is not a string or list of strings (type: %T)", sourceIf)
+	}
+	return
+}
+
+// ValidateJobUpload parses and initializes an AWE job script like CreateJobUpload, without saving it
+func ValidateJobUpload(files FormFiles) (report *ValidationReport) {
+	report = &ValidationReport{Errors: []ValidationError{}}
+
+	uploadFile, hasUpload := files["upload"]
+	if !hasUpload {
+		report.add(VALIDATION_PARSE, "", "no job script submitted")
+		return report.finish()
+	}
+
+	job, err := ReadJobFile(uploadFile.Path)
+	if err != nil {
+		report.add(VALIDATION_PARSE, "", err.Error())
+		return report.finish()
+	}
+	if _, err = job.Init(); err != nil {
+		report.add(VALIDATION_PARSE, job.ID, err.Error())
+	}
+	return report.finish()
+}
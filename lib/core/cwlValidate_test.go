@@ -0,0 +1,64 @@
+package core_test
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/MG-RAST/AWE/lib/core"
+)
+
+var validateWorkflow = `cwlVersion: v1.0
+$graph:
+- id: "#tool"
+  class: CommandLineTool
+  baseCommand: echo
+  inputs:
+    message:
+      type: string
+      inputBinding:
+        position: 1
+  outputs:
+    out:
+      type: stdout
+- id: "#main"
+  class: Workflow
+  requirements:
+  - class: ShockRequirement
+    shock_api_url: http://localhost:7445
+  inputs:
+    message: string
+    count: int
+  outputs:
+    result:
+      type: File
+      outputSource: "#main/echo/out"
+  steps:
+  - id: "#main/echo"
+    run: "#tool"
+    in:
+      message: "#main/mesage"
+    out: [out]
+`
+
+func TestValidateCWLSubmission(t *testing.T) {
+	report := ValidateCWLSubmission([]byte(validateWorkflow), "workflow.cwl", []byte("message: hello\ncount: one\n"), "#main")
+	if report.Valid {
+		t.Fatal("expected an invalid submission")
+	}
+	stages := map[string]int{}
+	for _, e := range report.Errors {
+		stages[e.Stage]++
+		t.Logf("%s %s: %s", e.Stage, e.ID, e.Message)
+	}
+	if stages[VALIDATION_INPUT] != 1 || stages[VALIDATION_SOURCE] != 1 || len(report.Errors) != 2 {
+		t.Fatalf("expected one input and one source error, got %v", stages)
+	}
+}
+
+func TestValidateCWLSubmissionValid(t *testing.T) {
+	workflow := strings.Replace(validateWorkflow, "#main/mesage", "#main/message", 1)
+	report := ValidateCWLSubmission([]byte(workflow), "workflow.cwl", []byte("message: hello\ncount: 1\n"), "#main")
+	if !report.Valid {
+		t.Fatalf("expected a valid submission, got %v", report.Errors)
+	}
+}
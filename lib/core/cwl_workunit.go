@@ -17,6 +17,7 @@ type CWLWorkunit struct {
 	ToolFilename    string                    `bson:"tool_filename,omitempty" json:"tool_filename,omitempty" mapstructure:"tool_filename,omitempty"`
 	Outputs         *cwl.Job_document         `bson:"outputs,omitempty" json:"outputs,omitempty" mapstructure:"outputs,omitempty"`
 	OutputsExpected *[]cwl.WorkflowStepOutput `bson:"outputs_expected,omitempty" json:"outputs_expected,omitempty" mapstructure:"outputs_expected,omitempty"` // this is the subset of outputs that are needed by the workflow
+	Storage         *cwl.StorageRequirement   `bson:"storage,omitempty" json:"storage,omitempty" mapstructure:"storage,omitempty"`                            // storage of inputs and outputs, Shock (ShockHost of the workunit) if nil
 	Notice          `bson:",inline" json:",inline" mapstructure:",squash"`
 }
 
@@ -20,6 +20,8 @@ type Notice struct {
 	ComputeTime int                        `bson:"computetime,omitempty" json:"computetime,omitempty" mapstructure:"computetime,omitempty"`
 	Notes       string
 	Stderr      string
+	ExitStatus  int    `bson:"exitstatus,omitempty" json:"exitstatus,omitempty" mapstructure:"exitstatus,omitempty"`
+	Failure     string `bson:"failure,omitempty" json:"failure,omitempty" mapstructure:"failure,omitempty"` // FAILURE_APP, FAILURE_INFRASTRUCTURE, FAILURE_TIMEOUT or FAILURE_OOM
 }
 
 //type Notice struct {
@@ -82,6 +84,10 @@ func NewNotice(native interface{}, context *cwl.WorkflowContext) (workunitResult
 		}
 		workunitResult.Status, _ = status.(string)
 		workunitResult.ComputeTime, _ = nativeMap["computetime"].(int)
+		if exitStatus, ok := nativeMap["exitstatus"].(float64); ok {
+			workunitResult.ExitStatus = int(exitStatus)
+		}
+		workunitResult.Failure, _ = nativeMap["failure"].(string)
 
 	default:
 		err = fmt.Errorf("(NewNotice) wrong type, map expected")
@@ -2,6 +2,7 @@ package core
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/MG-RAST/AWE/lib/conf"
 	"github.com/MG-RAST/AWE/lib/db"
@@ -29,6 +30,7 @@ func dbDelete(q bson.M, coll string) (err error) {
 	session := db.Connection.Session.Copy()
 	defer session.Close()
 	c := session.DB(conf.MONGODB_DATABASE).C(coll)
+	defer observeMongo(coll, "delete", time.Now())
 	_, err = c.RemoveAll(q)
 	return
 }
@@ -47,6 +49,7 @@ func dbUpsert(t interface{}) (err error) {
 	switch t := t.(type) {
 	case *Job:
 		c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_JOBS)
+		defer observeMongo(conf.DB_COLL_JOBS, "upsert", time.Now())
 		_, err = c.Upsert(bson.M{"id": t.ID}, &t)
 	case *WorkflowInstance:
 
@@ -55,9 +58,11 @@ func dbUpsert(t interface{}) (err error) {
 
 	case *JobPerf:
 		c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_PERF)
+		defer observeMongo(conf.DB_COLL_PERF, "upsert", time.Now())
 		_, err = c.Upsert(bson.M{"id": t.Id}, &t)
 	case *ClientGroup:
 		c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_CGS)
+		defer observeMongo(conf.DB_COLL_CGS, "upsert", time.Now())
 		_, err = c.Upsert(bson.M{"id": t.ID}, &t)
 	default:
 		fmt.Printf("invalid database entry type\n")
@@ -80,6 +85,7 @@ func dbInsert(t interface{}) (err error) {
 
 	case *WorkflowInstance:
 		c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_SUBWORKFLOWS)
+		defer observeMongo(conf.DB_COLL_SUBWORKFLOWS, "insert", time.Now())
 		//var info *mgo.ChangeInfo
 
 		//id, _ := t.GetID(false)
@@ -117,6 +123,7 @@ func dbUpdate(t interface{}) (err error) {
 
 	case *WorkflowInstance:
 		c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_SUBWORKFLOWS)
+		defer observeMongo(conf.DB_COLL_SUBWORKFLOWS, "update", time.Now())
 		//var info *mgo.ChangeInfo
 
 		//id, _ := t.GetID(false)
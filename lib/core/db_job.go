@@ -55,6 +55,7 @@ func dbCount(q bson.M) (count int, err error) {
 	session := db.Connection.Session.Copy()
 	defer session.Close()
 	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_JOBS)
+	defer observeMongo(conf.DB_COLL_JOBS, "count", time.Now())
 	if count, err = c.Find(q).Count(); err != nil {
 		return 0, err
 	}
@@ -97,6 +98,7 @@ func dbFindSort(filterQuery bson.M, results *Jobs, options map[string]int, sortb
 	session := db.Connection.Session.Copy()
 	defer session.Close()
 	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_JOBS)
+	defer observeMongo(conf.DB_COLL_JOBS, "find", time.Now())
 
 	query := c.Find(filterQuery)
 
@@ -212,6 +214,7 @@ func dbFind(q bson.M, results *Jobs, options map[string]int) (count int, err err
 	session := db.Connection.Session.Copy()
 	defer session.Close()
 	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_JOBS)
+	defer observeMongo(conf.DB_COLL_JOBS, "find", time.Now())
 	query := c.Find(q)
 	if count, err = query.Count(); err != nil {
 		return 0, err
@@ -246,6 +249,7 @@ func dbUpdateJobFields(jobID string, updateValue bson.M) (err error) {
 	defer session.Close()
 
 	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_JOBS)
+	defer observeMongo(conf.DB_COLL_JOBS, "update", time.Now())
 	selector := bson.M{"id": jobID}
 
 	err = c.Update(selector, bson.M{"$set": updateValue})
@@ -291,6 +295,7 @@ func dbUpdateJobTaskFields(jobID string, workflowInstanceID string, taskID strin
 	}
 
 	c := session.DB(conf.MONGODB_DATABASE).C(database)
+	defer observeMongo(database, "update", time.Now())
 
 	var selector bson.M
 	var updateOp bson.M
@@ -389,6 +394,7 @@ func dbIncrementJobTaskField(jobID string, taskID string, fieldname string, incr
 	defer session.Close()
 
 	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_JOBS)
+	defer observeMongo(conf.DB_COLL_JOBS, "update", time.Now())
 
 	selector := bson.M{"id": jobID, "tasks.taskid": taskID}
 
@@ -430,6 +436,7 @@ func LoadJob(id string) (job *Job, err error) {
 	session := db.Connection.Session.Copy()
 	defer session.Close()
 	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_JOBS)
+	defer observeMongo(conf.DB_COLL_JOBS, "find", time.Now())
 
 	// A) get job document
 	err = c.Find(bson.M{"id": id}).One(&job)
@@ -657,6 +664,7 @@ func dbGetJobField(jobID string, fieldname string, result interface{}) (err erro
 	defer session.Close()
 
 	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_JOBS)
+	defer observeMongo(conf.DB_COLL_JOBS, "find", time.Now())
 	selector := bson.M{"id": jobID}
 
 	err = c.Find(selector).Select(bson.M{fieldname: 1}).One(&result)
@@ -692,6 +700,26 @@ func DBGetJobACL(jobID string) (_acl acl.Acl, err error) {
 	return
 }
 
+// dbGetJobProject info.project of the job
+func dbGetJobProject(jobID string) (project string, err error) {
+	session := db.Connection.Session.Copy()
+	defer session.Close()
+
+	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_JOBS)
+	result := struct {
+		Info struct {
+			Project string `bson:"project"`
+		} `bson:"info"`
+	}{}
+	err = c.Find(bson.M{"id": jobID}).Select(bson.M{"info.project": 1}).One(&result)
+	if err != nil {
+		err = fmt.Errorf("(dbGetJobProject) job %s: %s", jobID, err.Error())
+		return
+	}
+	project = result.Info.Project
+	return
+}
+
 func dbGetJobFieldTime(jobID string, fieldname string) (result time.Time, err error) {
 	session := db.Connection.Session.Copy()
 	defer session.Close()
@@ -707,11 +735,30 @@ func dbGetJobFieldTime(jobID string, fieldname string) (result time.Time, err er
 	return
 }
 
+// dbGetJobIntermediatesDeleted zero time if the intermediates of the job have not been deleted
+func dbGetJobIntermediatesDeleted(jobID string) (deleted time.Time, err error) {
+	session := db.Connection.Session.Copy()
+	defer session.Close()
+
+	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_JOBS)
+	result := struct {
+		IntermediatesDeleted time.Time `bson:"intermediates_deleted"`
+	}{}
+	err = c.Find(bson.M{"id": jobID}).Select(bson.M{"intermediates_deleted": 1}).One(&result)
+	if err != nil {
+		err = fmt.Errorf("(dbGetJobIntermediatesDeleted) job %s: %s", jobID, err.Error())
+		return
+	}
+	deleted = result.IntermediatesDeleted
+	return
+}
+
 func dbPushJobTask(jobID string, task *Task) (err error) {
 	session := db.Connection.Session.Copy()
 	defer session.Close()
 
 	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_JOBS)
+	defer observeMongo(conf.DB_COLL_JOBS, "update", time.Now())
 
 	selector := bson.M{"id": jobID}
 	change := bson.M{"$push": bson.M{"tasks": task}}
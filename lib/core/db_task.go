@@ -53,6 +53,7 @@ func dbUpdateTaskFields(workflowInstanceUUID string, taskID string, updateValue
 	database := conf.DB_COLL_SUBWORKFLOWS
 
 	c := session.DB(conf.MONGODB_DATABASE).C(database)
+	defer observeMongo(database, "update", time.Now())
 
 	selector := bson.M{"id": workflowInstanceUUID, "tasks.taskid": taskID}
 	updateOp := bson.M{"$set": updateValue}
@@ -2,6 +2,7 @@ package core
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/MG-RAST/AWE/lib/conf"
 	"github.com/MG-RAST/AWE/lib/core/cwl"
@@ -16,6 +17,7 @@ func DBGetJobWorkflowInstances(q bson.M, options *DefaultQueryOptions, doInit bo
 	session := db.Connection.Session.Copy()
 	defer session.Close()
 	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_SUBWORKFLOWS)
+	defer observeMongo(conf.DB_COLL_SUBWORKFLOWS, "find", time.Now())
 	query := c.Find(q)
 	count, err = query.Count()
 	if err != nil {
@@ -115,6 +117,7 @@ func dbPushTask(subworkflowIdentifier string, task *Task) (err error) {
 	defer session.Close()
 
 	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_SUBWORKFLOWS)
+	defer observeMongo(conf.DB_COLL_SUBWORKFLOWS, "update", time.Now())
 
 	//unique_id := jobID + "_" + subworkflow_id
 	selector := bson.M{"id": subworkflowIdentifier}
@@ -165,6 +168,7 @@ func dbIncrementWorkflowInstancesField(subworkflowIdentifier string, field strin
 	defer session.Close()
 
 	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_SUBWORKFLOWS)
+	defer observeMongo(conf.DB_COLL_SUBWORKFLOWS, "update", time.Now())
 	//selector := bson.M{"id": job_id, "workflow_instances.id": subworkflow_id}
 	//unique_id := job_id + "_" + subworkflow_id
 	selector := bson.M{"id": subworkflowIdentifier}
@@ -198,6 +202,7 @@ func dbUpdateWorkflowInstancesFields(subworkflowIdentifier string, updateValue b
 	defer session.Close()
 
 	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_SUBWORKFLOWS)
+	defer observeMongo(conf.DB_COLL_SUBWORKFLOWS, "update", time.Now())
 	//unique_id := job_id + "_" + subworkflow_id
 	selector := bson.M{"id": subworkflowIdentifier}
 
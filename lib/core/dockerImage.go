@@ -0,0 +1,284 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MG-RAST/AWE/lib/conf"
+	"github.com/MG-RAST/AWE/lib/core/cwl"
+	"github.com/MG-RAST/AWE/lib/logger"
+)
+
+// dockerDigestTTL how long a tag resolved by ResolveDockerImage is reused before the registry is asked again
+const dockerDigestTTL = time.Minute
+
+type dockerDigest struct {
+	digest   string
+	resolved time.Time
+}
+
+var dockerDigests = struct {
+	sync.Mutex
+	m map[string]dockerDigest
+}{m: map[string]dockerDigest{}}
+
+var dockerManifestTypes = []string{
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.oci.image.manifest.v1+json",
+}
+
+// ResolveDockerImage returns an image reference that does not change when a tag is moved: images that are pinned
+// (a dockerImageId or a reference with a digest) are returned as they are, tags are resolved to
+// "registry/repository@digest" with the registry (Docker Registry HTTP API V2, anonymous access). Only registries on
+// public addresses are asked, images of other registries have to be pinned.
+func ResolveDockerImage(image string) (resolved string, err error) {
+	if dockerImagePinned(image) {
+		resolved = image
+		return
+	}
+
+	var domain, repository, tag string
+	domain, repository, tag, err = ParseDockerReference(image)
+	if err != nil {
+		err = fmt.Errorf("(ResolveDockerImage) %s", err.Error())
+		return
+	}
+	name := domain + "/" + repository
+
+	dockerDigests.Lock()
+	cached, ok := dockerDigests.m[name+":"+tag]
+	dockerDigests.Unlock()
+	if ok && time.Since(cached.resolved) < dockerDigestTTL {
+		resolved = name + "@" + cached.digest
+		return
+	}
+
+	var digest string
+	digest, err = getDockerManifestDigest(domain, repository, tag)
+	if err != nil {
+		err = fmt.Errorf("(ResolveDockerImage) image %s: %s", image, err.Error())
+		return
+	}
+
+	dockerDigests.Lock()
+	dockerDigests.m[name+":"+tag] = dockerDigest{digest: digest, resolved: time.Now()}
+	dockerDigests.Unlock()
+
+	resolved = name + "@" + digest
+	return
+}
+
+// dockerImagePinned true if the image cannot change: a dockerImageId or a reference with digest
+func dockerImagePinned(image string) bool {
+	return image == "" || strings.Contains(image, "@") || strings.HasPrefix(image, "sha256:")
+}
+
+// PinnedDockerImage a docker image of a job and the reference with digest it was resolved to at submission
+type PinnedDockerImage struct {
+	Image    string `bson:"image" json:"image"`
+	Resolved string `bson:"resolved" json:"resolved"`
+}
+
+// PinJobDockerImages resolves the docker images of the CommandLineTools of a CWL job when it is submitted, so that the
+// call cache does not have to ask the registry when the workunits are enqueued. All steps of the job use the same
+// digest of a tag. Steps with an image that cannot be resolved are run without call cache.
+func PinJobDockerImages(job *Job) {
+	if !conf.CALL_CACHE || !job.IsCWL || job.WorkflowContext == nil || (job.Info != nil && job.Info.NoCache) {
+		return
+	}
+	seen := map[string]bool{}
+	for _, object := range job.WorkflowContext.Objects {
+		clt, ok := object.(*cwl.CommandLineTool)
+		if !ok {
+			continue
+		}
+		image := DockerImageOfTool(clt)
+		if dockerImagePinned(image) || seen[image] {
+			continue
+		}
+		seen[image] = true
+		resolved, err := ResolveDockerImage(image)
+		if err != nil {
+			logger.Warning("(PinJobDockerImages) job %s: %s, steps with this image are run without call cache", job.ID, err.Error())
+			continue
+		}
+		job.DockerImages = append(job.DockerImages, PinnedDockerImage{Image: image, Resolved: resolved})
+	}
+}
+
+// pinnedDockerImage the image as resolved by PinJobDockerImages
+func (job *Job) pinnedDockerImage(image string) (resolved string, ok bool) {
+	if dockerImagePinned(image) {
+		return image, true
+	}
+	for _, pinned := range job.DockerImages {
+		if pinned.Image == image {
+			return pinned.Resolved, true
+		}
+	}
+	return
+}
+
+// ParseDockerReference splits an image reference without digest into registry domain, repository and tag,
+// with the defaults of docker: Docker Hub, "library/" for official images and tag "latest"
+func ParseDockerReference(image string) (domain string, repository string, tag string, err error) {
+	repository = image
+	tag = "latest"
+	if i := strings.LastIndex(repository, ":"); i > strings.LastIndex(repository, "/") {
+		tag = repository[i+1:]
+		repository = repository[:i]
+	}
+
+	domain = "registry-1.docker.io"
+	if i := strings.Index(repository, "/"); i >= 0 {
+		first := repository[:i]
+		if strings.ContainsAny(first, ".:") || first == "localhost" {
+			domain = first
+			repository = repository[i+1:]
+			if domain == "docker.io" || domain == "index.docker.io" {
+				domain = "registry-1.docker.io"
+			}
+		}
+	}
+	if domain == "registry-1.docker.io" && !strings.Contains(repository, "/") {
+		repository = "library/" + repository
+	}
+
+	if repository == "" || tag == "" {
+		err = fmt.Errorf("(ParseDockerReference) invalid image reference %s", image)
+	}
+	return
+}
+
+func dockerRegistryClient() *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: publicAddressControl("registry requests")}
+	return &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+	}
+}
+
+// getDockerManifestDigest asks the registry for the digest of the manifest the tag points to. If the registry
+// requires a token, an anonymous one is requested from the realm named in the WWW-Authenticate header.
+func getDockerManifestDigest(domain string, repository string, tag string) (digest string, err error) {
+	client := dockerRegistryClient()
+	manifestURL := fmt.Sprintf("https://%s/v2/%s/manifests/%s", domain, repository, tag)
+
+	var res *http.Response
+	res, err = headDockerManifest(client, manifestURL, "")
+	if err != nil {
+		return
+	}
+	res.Body.Close()
+
+	if res.StatusCode == http.StatusUnauthorized {
+		var token string
+		token, err = getDockerRegistryToken(client, res.Header.Get("WWW-Authenticate"))
+		if err != nil {
+			return
+		}
+		res, err = headDockerManifest(client, manifestURL, token)
+		if err != nil {
+			return
+		}
+		res.Body.Close()
+	}
+
+	if res.StatusCode != http.StatusOK {
+		err = fmt.Errorf("(getDockerManifestDigest) %s returned %s", manifestURL, res.Status)
+		return
+	}
+	digest = res.Header.Get("Docker-Content-Digest")
+	if !strings.HasPrefix(digest, "sha256:") {
+		err = fmt.Errorf("(getDockerManifestDigest) %s returned no digest", manifestURL)
+	}
+	return
+}
+
+func headDockerManifest(client *http.Client, manifestURL string, token string) (res *http.Response, err error) {
+	var req *http.Request
+	req, err = http.NewRequest("HEAD", manifestURL, nil)
+	if err != nil {
+		err = fmt.Errorf("(headDockerManifest) %s", err.Error())
+		return
+	}
+	req.Header.Set("Accept", strings.Join(dockerManifestTypes, ", "))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res, err = client.Do(req)
+	if err != nil {
+		err = fmt.Errorf("(headDockerManifest) %s", err.Error())
+	}
+	return
+}
+
+// getDockerRegistryToken challenge: Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/ubuntu:pull"
+func getDockerRegistryToken(client *http.Client, challenge string) (token string, err error) {
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		err = fmt.Errorf("(getDockerRegistryToken) unsupported authentication: %s", challenge)
+		return
+	}
+	params := map[string]string{}
+	for _, param := range strings.Split(strings.TrimPrefix(challenge, "Bearer "), ",") {
+		kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+		if len(kv) == 2 {
+			params[kv[0]] = strings.Trim(kv[1], "\"")
+		}
+	}
+
+	var realm *url.URL
+	realm, err = url.Parse(params["realm"])
+	if err != nil || realm.Scheme != "https" {
+		err = fmt.Errorf("(getDockerRegistryToken) invalid realm: %s", params["realm"])
+		return
+	}
+	query := realm.Query()
+	for _, key := range []string{"service", "scope"} {
+		if params[key] != "" {
+			query.Set(key, params[key])
+		}
+	}
+	realm.RawQuery = query.Encode()
+
+	var res *http.Response
+	res, err = client.Get(realm.String())
+	if err != nil {
+		err = fmt.Errorf("(getDockerRegistryToken) %s", err.Error())
+		return
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		err = fmt.Errorf("(getDockerRegistryToken) %s returned %s", realm.Host, res.Status)
+		return
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&body)
+	if err != nil {
+		err = fmt.Errorf("(getDockerRegistryToken) %s", err.Error())
+		return
+	}
+	token = body.Token
+	if token == "" {
+		token = body.AccessToken
+	}
+	if token == "" {
+		err = fmt.Errorf("(getDockerRegistryToken) %s returned no token", realm.Host)
+	}
+	return
+}
@@ -36,6 +36,8 @@ func (jr *JobReaper) Handle() {
 	for {
 		// sleep
 		time.Sleep(waitDuration)
+		// delete intermediates of jobs whose retention time has passed
+		collectRetainedJobs()
 		// query to get expired jobs
 		jobs := Jobs{}
 		query := jr.getQuery()
@@ -43,6 +45,10 @@ func (jr *JobReaper) Handle() {
 		// delete expired jobs
 		for _, j := range jobs {
 			logger.Event(event.JOB_EXPIRED, "jobid="+j.ID)
+			// the intermediates of CWL jobs are deleted with the job, unless the retention policy keeps all
+			if j.IsCWL && j.IntermediatesDeleted.IsZero() && j.GetRetention() != RETENTION_ALL {
+				collectJobIntermediates(j.ID)
+			}
 			if err := j.Delete(); err != nil {
 				logger.Error("Err@job_delete: " + err.Error())
 			}
@@ -0,0 +1,142 @@
+package core
+
+import (
+	"path"
+	"runtime"
+	"time"
+
+	"github.com/MG-RAST/AWE/lib/conf"
+	"github.com/MG-RAST/AWE/lib/db"
+	"github.com/MG-RAST/AWE/lib/logger"
+	"github.com/MG-RAST/AWE/lib/user"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// object types in the state history
+const (
+	HISTORY_JOB      = "job"
+	HISTORY_TASK     = "task"
+	HISTORY_WORKUNIT = "workunit"
+)
+
+// StateChange is one state transition of a job, task or workunit, stored in the History collection
+type StateChange struct {
+	JobID    string    `bson:"job_id" json:"job_id"`
+	Type     string    `bson:"type" json:"type"` // job, task or workunit
+	ID       string    `bson:"id" json:"id"`     // id of the job, task or workunit
+	OldState string    `bson:"old_state" json:"old_state"`
+	NewState string    `bson:"new_state" json:"new_state"` // empty for user actions that do not change the state directly
+	Caller   string    `bson:"caller" json:"caller"`       // function or API request that triggered the change
+	User     string    `bson:"user,omitempty" json:"user,omitempty"`
+	Client   string    `bson:"client,omitempty" json:"client,omitempty"`
+	Reason   string    `bson:"reason,omitempty" json:"reason,omitempty"`
+	Time     time.Time `bson:"time" json:"time"`
+}
+
+// historyQueue decouples database writes from the state changes, nil if the history is not enabled (e.g. on the worker)
+var historyQueue chan *StateChange
+
+// InitHistoryDB creates indexes and starts writing the state history, server only
+func InitHistoryDB() {
+	session := db.Connection.Session.Copy()
+	defer session.Close()
+	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_HISTORY)
+	c.EnsureIndex(mgo.Index{Key: []string{"job_id"}, Background: true})
+	c.EnsureIndex(mgo.Index{Key: []string{"id"}, Background: true})
+	c.EnsureIndex(mgo.Index{Key: []string{"time"}, Background: true})
+
+	historyQueue = make(chan *StateChange, 1000)
+	go historyWriter()
+}
+
+func historyWriter() {
+	for change := range historyQueue {
+		err := dbInsertStateChange(change)
+		if err != nil {
+			logger.Error("(historyWriter) could not record state change of %s %s: %s", change.Type, change.ID, err.Error())
+		}
+	}
+}
+
+func dbInsertStateChange(change *StateChange) (err error) {
+	session := db.Connection.Session.Copy()
+	defer session.Close()
+	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_HISTORY)
+	err = c.Insert(change)
+	return
+}
+
+// RecordStateChange queues a state change for the history, missing caller and time are filled in
+func RecordStateChange(change *StateChange) {
+	if historyQueue == nil {
+		return
+	}
+	if change.Caller == "" {
+		change.Caller = callerName()
+	}
+	if change.Time.IsZero() {
+		change.Time = time.Now()
+	}
+	// called while holding task and job locks, a slow database must not stall the scheduler
+	select {
+	case historyQueue <- change:
+	default:
+		metricHistoryDropped.Inc(change.Type)
+		logger.Error("(RecordStateChange) history queue is full, state change of %s %s to %s not recorded", change.Type, change.ID, change.NewState)
+	}
+}
+
+// RecordUserAction records a job manipulation requested via the API, the resulting state changes are recorded separately
+func RecordUserAction(jobid string, action string, u *user.User) {
+	username := ""
+	if u != nil {
+		username = u.Username
+		if username == "" {
+			username = u.Uuid
+		}
+	}
+	RecordStateChange(&StateChange{JobID: jobid, Type: HISTORY_JOB, ID: jobid, Caller: "API " + action, User: username})
+}
+
+// callerName returns the name of the function that called the SetState-like function, e.g. "core.(*ServerMgr).SuspendJob"
+func callerName() string {
+	pc, _, _, ok := runtime.Caller(3)
+	if !ok {
+		return "unknown"
+	}
+	f := runtime.FuncForPC(pc)
+	if f == nil {
+		return "unknown"
+	}
+	return path.Base(f.Name())
+}
+
+// GetJobHistory returns the state changes of a job and its tasks and workunits in chronological order, historyType can limit it to one type
+func GetJobHistory(jobid string, historyType string) (changes []StateChange, err error) {
+	q := bson.M{"job_id": jobid}
+	if historyType != "" {
+		q["type"] = historyType
+	}
+	changes, err = dbFindStateChanges(q)
+	return
+}
+
+// GetWorkHistory returns the state changes of a workunit in chronological order
+func GetWorkHistory(id Workunit_Unique_Identifier) (changes []StateChange, err error) {
+	workStr, err := id.String()
+	if err != nil {
+		return
+	}
+	changes, err = dbFindStateChanges(bson.M{"type": HISTORY_WORKUNIT, "id": workStr})
+	return
+}
+
+func dbFindStateChanges(q bson.M) (changes []StateChange, err error) {
+	session := db.Connection.Session.Copy()
+	defer session.Close()
+	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_HISTORY)
+	changes = []StateChange{}
+	err = c.Find(q).Sort("time").All(&changes)
+	return
+}
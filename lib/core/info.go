@@ -22,10 +22,16 @@ type Info struct {
 	Auth          bool                   `bson:"auth" json:"auth" mapstructure:"auth"`
 	DataToken     string                 `bson:"datatoken" json:"-" mapstructure:"-"`
 	NoRetry       bool                   `bson:"noretry" json:"noretry" mapstructure:"noretry"`
+	NoCache       bool                   `bson:"nocache" json:"nocache" mapstructure:"nocache"`
 	UserAttr      map[string]interface{} `bson:"userattr" json:"userattr" mapstructure:"userattr"`
 	Description   string                 `bson:"description" json:"description" mapstructure:"description"`
 	Tracking      bool                   `bson:"tracking" json:"tracking" mapstructure:"tracking"`
 	StartAt       time.Time              `bson:"start_at" json:"start_at" mapstructure:"start_at"` // will start tasks at this timepoint or shortly after
+	Notification  *Notification          `bson:"notification,omitempty" json:"notification,omitempty" mapstructure:"notification,omitempty"`
+	AfterJobs     []string               `bson:"after_jobs,omitempty" json:"after_jobs,omitempty" mapstructure:"after_jobs,omitempty"`                               // will start tasks only after these jobs
+	AfterJobsCond string                 `bson:"after_jobs_condition,omitempty" json:"after_jobs_condition,omitempty" mapstructure:"after_jobs_condition,omitempty"` // completed (default) or any
+
+	Retention string `bson:"retention,omitempty" json:"retention,omitempty" mapstructure:"retention,omitempty"` // all, outputs or time after completion (e.g. 7D) to keep intermediate step outputs
 }
 
 // NewInfo _
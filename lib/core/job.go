@@ -78,12 +78,20 @@ type JobRaw struct {
 	IsCWL                   bool                         `bson:"is_cwl" json:"is_cwl"`
 	CWL_job_input           interface{}                  `bson:"cwl_job_input" json:"cwl_job_input"` // has to be an array for mongo (id as key would not work)
 	CWL_ShockRequirement    *cwl.ShockRequirement        `bson:"cwl_shock_requirement" json:"cwl_shock_requirement"`
+	CWL_StorageRequirement  *cwl.StorageRequirement      `bson:"cwl_storage_requirement,omitempty" json:"cwl_storage_requirement,omitempty"`
 	CWL_workflow            *cwl.Workflow                `bson:"-" json:"-" yaml:"-" mapstructure:"-"`
 	WorkflowInstancesMap    map[string]*WorkflowInstance `bson:"-" json:"-" yaml:"-" mapstructure:"-"`
 	WorkflowInstancesRemain int                          `bson:"workflow_instances_remain" json:"workflow_instances_remain"`
 	Entrypoint              string                       `bson:"entrypoint" json:"entrypoint"` // name of main workflow (typically has name #main or #entrypoint)
 	Root                    string                       `bson:"root" json:"root"`             // UUID of root workflow instance
 	WorkflowContext         *cwl.WorkflowContext         `bson:"context" json:"context" yaml:"context" mapstructure:"context"`
+	Notifications           []*NotificationDelivery      `bson:"notifications" json:"notifications"`                     // delivery log of Info.Notification
+	DockerImages            []PinnedDockerImage          `bson:"docker_images,omitempty" json:"docker_images,omitempty"` // tags resolved at submission, see PinJobDockerImages
+
+	EffectivePriority int `bson:"-" json:"effective_priority,omitempty"` // priority including aging, set in listings
+
+	RetainUntil          time.Time `bson:"retain_until,omitempty" json:"retain_until,omitempty"`                   // intermediate step outputs are deleted after this time
+	IntermediatesDeleted time.Time `bson:"intermediates_deleted,omitempty" json:"intermediates_deleted,omitempty"` // time the intermediate step outputs were deleted
 }
 
 // GetID _
@@ -621,6 +629,7 @@ func (job *Job) Delete() (err error) {
 	if err = dbDelete(bson.M{"id": job.ID}, conf.DB_COLL_JOBS); err != nil {
 		return err
 	}
+	forgetUpstreamState(job.ID)
 	if err = job.Rmdir(); err != nil {
 		return err
 	}
@@ -813,6 +822,8 @@ func (job *Job) SetState(newState string, oldstates []string) (err error) {
 		return
 	}
 	job.State = newState
+	RecordStateChange(&StateChange{JobID: job.ID, Type: HISTORY_JOB, ID: job.ID, OldState: job_state, NewState: newState})
+	forgetUpstreamState(job.ID)
 
 	// set time if completed
 	switch newState {
@@ -963,29 +974,38 @@ func (job *Job) SetExpiration(expire string) (err error) {
 	}
 	defer job.Unlock()
 
+	var expireTime time.Duration
+	expireTime, err = ExpireDuration(expire)
+	if err != nil {
+		return
+	}
+
+	newExpiration := time.Now().Add(expireTime)
+	err = dbUpdateJobFieldTime(job.ID, "expiration", newExpiration)
+	if err != nil {
+		return
+	}
+	job.Expiration = newExpiration
+	return
+}
+
+// ExpireDuration parses a number and unit of time (M, H or D), e.g. 7D
+func ExpireDuration(expire string) (d time.Duration, err error) {
 	parts := ExpireRegex.FindStringSubmatch(expire)
 	if len(parts) == 0 {
-		return errors.New("expiration format '" + expire + "' is invalid")
+		err = errors.New("expiration format '" + expire + "' is invalid")
+		return
 	}
-	var expireTime time.Duration
 	expireNum, _ := strconv.Atoi(parts[1])
-	currTime := time.Now()
 
 	switch parts[2] {
 	case "M":
-		expireTime = time.Duration(expireNum) * time.Minute
+		d = time.Duration(expireNum) * time.Minute
 	case "H":
-		expireTime = time.Duration(expireNum) * time.Hour
+		d = time.Duration(expireNum) * time.Hour
 	case "D":
-		expireTime = time.Duration(expireNum*24) * time.Hour
+		d = time.Duration(expireNum*24) * time.Hour
 	}
-
-	newExpiration := currTime.Add(expireTime)
-	err = dbUpdateJobFieldTime(job.ID, "expiration", newExpiration)
-	if err != nil {
-		return
-	}
-	job.Expiration = newExpiration
 	return
 }
 
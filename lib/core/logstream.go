@@ -0,0 +1,251 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/MG-RAST/AWE/lib/conf"
+	"github.com/MG-RAST/AWE/lib/logger"
+	"github.com/MG-RAST/golib/httpclient"
+)
+
+// LiveLogs logs that can be streamed by the worker while the workunit is running
+var LiveLogs = []string{"stdout", "stderr"}
+
+// liveLog state of one streamed log on the server
+type liveLog struct {
+	Received int64 // bytes of the worker log file received so far
+	Size     int64 // bytes in the current log file on the server, older output is rotated into <log>.1
+	Closed   bool  // the final log was saved, later chunks are rejected
+}
+
+// LiveLogMap streamed logs by log path
+type LiveLogMap struct {
+	sync.Mutex
+	_map map[string]*liveLog
+}
+
+// GlobalLiveLogMap _
+var GlobalLiveLogMap = &LiveLogMap{_map: make(map[string]*liveLog)}
+
+// IsLiveLog _
+func IsLiveLog(logname string) bool {
+	for _, name := range LiveLogs {
+		if name == logname {
+			return true
+		}
+	}
+	return false
+}
+
+// AppendLiveLog appends a chunk of a running workunit log. offset is the position of the chunk in the log file of the worker,
+// chunks that were already received (retries) are skipped, missing parts (e.g. after a server restart) are marked in the log.
+// At most conf.LOG_STREAM_MAX_SIZE KB are kept, older output is dropped.
+// Only the client that has the workunit checked out can append, until it reports the workunit as finished.
+func (qm *ServerMgr) AppendLiveLog(client *Client, id Workunit_Unique_Identifier, logname string, offset int64, data []byte) (err error) {
+	if !IsLiveLog(logname) {
+		err = fmt.Errorf("(AppendLiveLog) log type '%s' can not be streamed", logname)
+		return
+	}
+
+	var logpath string
+	logpath, err = getStdLogPathByWorkID(id, logname)
+	if err != nil {
+		err = fmt.Errorf("(AppendLiveLog) getStdLogPathByWorkID returned: %s", err.Error())
+		return
+	}
+
+	m := GlobalLiveLogMap
+	m.Lock()
+	defer m.Unlock()
+
+	state, ok := m._map[logpath]
+	if ok && state.Closed {
+		err = fmt.Errorf("(AppendLiveLog) workunit has finished, %s is final", logname)
+		return
+	}
+	err = qm.checkLiveLogClient(client, id)
+	if err != nil {
+		return
+	}
+	if !ok || offset == 0 {
+		// first chunk of a new attempt of the workunit, log of previous attempt is replaced
+		if !ok && offset > 0 {
+			// server lost the state
+			state = &liveLog{Received: offset}
+			if fi, serr := os.Stat(logpath); serr == nil {
+				state.Size = fi.Size()
+			}
+		} else {
+			os.Remove(logpath)
+			os.Remove(logpath + ".1")
+			state = &liveLog{}
+		}
+		m._map[logpath] = state
+	}
+
+	if offset > state.Received {
+		data = append([]byte(fmt.Sprintf("\n[... %d bytes not received ...]\n", offset-state.Received)), data...)
+		state.Received = offset
+	} else if offset < state.Received {
+		skip := state.Received - offset
+		if skip >= int64(len(data)) {
+			return
+		}
+		data = data[skip:]
+		offset = state.Received
+	}
+
+	maxSize := int64(conf.LOG_STREAM_MAX_SIZE) * 1024
+	if maxSize > 0 && state.Size > 0 && state.Size+int64(len(data)) > maxSize/2 {
+		err = os.Rename(logpath, logpath+".1")
+		if err != nil {
+			err = fmt.Errorf("(AppendLiveLog) os.Rename returned: %s", err.Error())
+			return
+		}
+		state.Size = 0
+	}
+
+	var logfile *os.File
+	logfile, err = os.OpenFile(logpath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		err = fmt.Errorf("(AppendLiveLog) os.OpenFile returned: %s", err.Error())
+		return
+	}
+	defer logfile.Close()
+
+	var n int
+	n, err = logfile.Write(data)
+	state.Size += int64(n)
+	state.Received = offset + int64(len(data))
+	if err != nil {
+		err = fmt.Errorf("(AppendLiveLog) logfile.Write returned: %s", err.Error())
+		return
+	}
+	return
+}
+
+// checkLiveLogClient the workunit has to be checked out by the client
+func (qm *ServerMgr) checkLiveLogClient(client *Client, id Workunit_Unique_Identifier) (err error) {
+	workStr, err := id.String()
+	if err != nil {
+		err = fmt.Errorf("(AppendLiveLog) id.String returned: %s", err.Error())
+		return
+	}
+	workunit, ok, err := qm.workQueue.Get(id)
+	if err != nil {
+		err = fmt.Errorf("(AppendLiveLog) workQueue.Get returned: %s", err.Error())
+		return
+	}
+	if !ok || workunit.State != WORK_STAT_CHECKOUT {
+		err = fmt.Errorf("(AppendLiveLog) workunit %s is not running", workStr)
+		return
+	}
+	assigned, err := client.AssignedWork.Has(id)
+	if err != nil {
+		err = fmt.Errorf("(AppendLiveLog) client.AssignedWork.Has returned: %s", err.Error())
+		return
+	}
+	if !assigned {
+		err = fmt.Errorf("(AppendLiveLog) workunit %s is not checked out by client %s", workStr, client.ID)
+	}
+	return
+}
+
+// CloseLiveLogs called when the worker reports the workunit as finished, before its final logs are saved.
+// Chunks that arrive later are rejected, the stream state is kept until the workunit leaves checkout.
+func (qm *ServerMgr) CloseLiveLogs(id Workunit_Unique_Identifier) {
+	m := GlobalLiveLogMap
+	m.Lock()
+	defer m.Unlock()
+
+	for _, logname := range LiveLogs {
+		logpath, err := getStdLogPathByWorkID(id, logname)
+		if err != nil {
+			continue
+		}
+		m._map[logpath] = &liveLog{Closed: true}
+	}
+	return
+}
+
+// forgetLiveLogs removes the stream state of a workunit that left checkout (finished, failed, requeued, suspended or deleted)
+func forgetLiveLogs(id Workunit_Unique_Identifier) {
+	m := GlobalLiveLogMap
+	m.Lock()
+	defer m.Unlock()
+
+	for _, logname := range LiveLogs {
+		logpath, err := getStdLogPathByWorkID(id, logname)
+		if err != nil {
+			continue
+		}
+		delete(m._map, logpath)
+	}
+	return
+}
+
+// IsWorkRunning true while the workunit is queued or checked out, i.e. its logs may still grow
+func (qm *ServerMgr) IsWorkRunning(id Workunit_Unique_Identifier) (running bool) {
+	workunit, ok, err := qm.workQueue.Get(id)
+	if err != nil || !ok {
+		return
+	}
+	switch workunit.State {
+	case WORK_STAT_QUEUED, WORK_STAT_RESERVED, WORK_STAT_CHECKOUT:
+		running = true
+	}
+	return
+}
+
+// GetReportPath path of a saved or streamed log
+func (qm *ServerMgr) GetReportPath(id Workunit_Unique_Identifier, logname string) (logpath string, err error) {
+	return getStdLogPathByWorkID(id, logname)
+}
+
+// PushWorkunitLog sends a chunk of the stdout or stderr of a running workunit to the server (worker side)
+func PushWorkunitLog(work *Workunit, logname string, offset int64, data []byte) (err error) {
+	var workIDb64 string
+	workIDb64, err = work.GetIDBase64()
+	if err != nil {
+		err = fmt.Errorf("(PushWorkunitLog) work.GetIDBase64 returned: %s", err.Error())
+		return
+	}
+
+	targetURL := fmt.Sprintf("%s/work/%s?client=%s&log=%s&offset=%d", conf.SERVER_URL, workIDb64, Self.ID, logname, offset)
+
+	headers := httpclient.Header{
+		"Content-Type":   []string{"application/octet-stream"},
+		"Content-Length": []string{strconv.Itoa(len(data))},
+	}
+	if conf.CLIENT_GROUP_TOKEN != "" {
+		headers["Authorization"] = []string{"CG_TOKEN " + conf.CLIENT_GROUP_TOKEN}
+	}
+	logger.Debug(3, "PUT %s (%d bytes)", targetURL, len(data))
+	res, err := httpclient.Put(targetURL, headers, bytes.NewReader(data), nil)
+	if err != nil {
+		err = fmt.Errorf("(PushWorkunitLog) httpclient.Put returned: %s", err.Error())
+		return
+	}
+	defer res.Body.Close()
+
+	jsonstream, _ := ioutil.ReadAll(res.Body)
+	response := new(StandardResponse)
+	err = json.Unmarshal(jsonstream, response)
+	if err != nil {
+		err = fmt.Errorf("(PushWorkunitLog) failed to marshal response:\"%s\"", jsonstream)
+		return
+	}
+	if len(response.Error) > 0 {
+		err = errors.New(strings.Join(response.Error, ","))
+		return
+	}
+	return
+}
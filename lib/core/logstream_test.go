@@ -0,0 +1,124 @@
+package core_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/MG-RAST/AWE/lib/conf"
+	. "github.com/MG-RAST/AWE/lib/core"
+)
+
+func TestAppendLiveLog(t *testing.T) {
+	jobid := "abcdef01-0000-0000-0000-000000000000"
+	dir, err := ioutil.TempDir("", "awe-logstream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	conf.DATA_PATH = dir
+	err = os.MkdirAll(path.Join(conf.DATA_PATH, "ab", "cd", "ef", jobid), 0777)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.LOG_STREAM_MAX_SIZE = 1 // KB, rotates after 512 bytes
+
+	qm := NewServerMgr()
+	id := Workunit_Unique_Identifier{Task_Unique_Identifier: Task_Unique_Identifier{JobId: jobid, TaskName: "#main/step"}}
+	work := &Workunit{Workunit_Unique_Identifier: id, ID: "work"}
+	client := NewClient()
+	other := NewClient()
+
+	if err = qm.AppendLiveLog(client, id, "worknotes", 0, []byte("x")); err == nil {
+		t.Fatal("expected error for log type worknotes")
+	}
+	if err = qm.AppendLiveLog(client, id, "stderr", 0, []byte("x")); err == nil {
+		t.Fatal("expected error for a workunit that is not in the queue")
+	}
+
+	if err = qm.EnqueueWorkunit(work); err != nil {
+		t.Fatal(err)
+	}
+	if err = qm.AppendLiveLog(client, id, "stderr", 0, []byte("x")); err == nil {
+		t.Fatal("expected error for a workunit that is not checked out")
+	}
+	// as checked out by CheckoutWorkunits
+	if err = work.SetState(WORK_STAT_CHECKOUT, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err = client.AssignedWork.Add(id); err != nil {
+		t.Fatal(err)
+	}
+	if err = qm.AppendLiveLog(other, id, "stderr", 0, []byte("x")); err == nil {
+		t.Fatal("expected error for a client that does not have the workunit")
+	}
+
+	steps := []struct {
+		offset   int64
+		data     string
+		expected string
+	}{
+		{0, "hello ", "hello "},
+		{0, "hello ", "hello "},       // new attempt starts over
+		{6, "world", "hello world"},   // next chunk
+		{6, "world!", "hello world!"}, // retry, only new part is appended
+		{20, "end", "hello world!\n[... 8 bytes not received ...]\nend"},
+	}
+	for _, step := range steps {
+		err = qm.AppendLiveLog(client, id, "stderr", step.offset, []byte(step.data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		report, err := qm.GetReportMsg(id, "stderr")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report != step.expected {
+			t.Fatalf("offset %d: expected %q, got %q", step.offset, step.expected, report)
+		}
+	}
+
+	// older output is dropped once the limit is reached
+	chunk := strings.Repeat("a", 400)
+	offset := int64(23)
+	for i := 0; i < 3; i++ {
+		err = qm.AppendLiveLog(client, id, "stderr", offset, []byte(chunk))
+		if err != nil {
+			t.Fatal(err)
+		}
+		offset += int64(len(chunk))
+	}
+	report, err := qm.GetReportMsg(id, "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report != chunk+chunk {
+		t.Fatalf("expected last two chunks (%d bytes), got %d bytes", 2*len(chunk), len(report))
+	}
+
+	// the final log is not touched by later chunks
+	qm.CloseLiveLogs(id)
+	if err = qm.AppendLiveLog(client, id, "stderr", 0, []byte("x")); err == nil {
+		t.Fatal("expected error after the logs were closed")
+	}
+	report, err = qm.GetReportMsg(id, "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report != chunk+chunk {
+		t.Fatalf("final log changed, got %d bytes", len(report))
+	}
+
+	// a new attempt starts once the workunit was queued again
+	if err = qm.EnqueueWorkunit(work); err != nil {
+		t.Fatal(err)
+	}
+	if err = work.SetState(WORK_STAT_CHECKOUT, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err = qm.AppendLiveLog(client, id, "stderr", 0, []byte("again")); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,116 @@
+package core
+
+import (
+	"time"
+
+	"github.com/MG-RAST/AWE/lib/logger"
+	"github.com/MG-RAST/AWE/lib/metrics"
+)
+
+// ServerMetrics metrics of the AWE server, see GET /metrics
+var ServerMetrics = metrics.NewRegistry()
+
+var (
+	metricJobs      = ServerMetrics.NewGaugeVec("awe_jobs", "number of jobs in memory by state", "state")
+	metricTasks     = ServerMetrics.NewGaugeVec("awe_tasks", "number of tasks in memory by state", "state")
+	metricWorkunits = ServerMetrics.NewGaugeVec("awe_workunits", "number of workunits by state and clientgroup (group of the worker for checked out workunits, otherwise the clientgroups requested by the job)", "state", "clientgroup")
+	metricClients   = ServerMetrics.NewGaugeVec("awe_clients", "number of registered clients by clientgroup and status", "clientgroup", "status")
+
+	metricCheckouts          = ServerMetrics.NewCounterVec("awe_workunit_checkouts_total", "number of workunits checked out by workers", "clientgroup")
+	metricWorkunitsCompleted = ServerMetrics.NewCounterVec("awe_workunits_completed_total", "number of workunits reported done by workers", "clientgroup")
+	metricWorkunitsFailed    = ServerMetrics.NewCounterVec("awe_workunits_failed_total", "number of workunits reported failed by workers", "clientgroup", "failure")
+	metricHistoryDropped     = ServerMetrics.NewCounterVec("awe_history_dropped_total", "number of state changes not recorded in the history because the queue was full", "type")
+
+	metricQueueLatency = ServerMetrics.NewHistogramVec("awe_task_queue_latency_seconds", "time workunits of a task wait in the queue until a worker checks them out", metrics.DefaultBuckets, "clientgroup")
+	metricMongo        = ServerMetrics.NewHistogramVec("awe_mongo_operation_duration_seconds", "duration of mongodb operations", metrics.DefaultBuckets, "collection", "operation")
+)
+
+// InitServerMetrics sets the gauges from the current state of the server whenever the metrics are collected
+func InitServerMetrics() {
+	ServerMetrics.OnCollect(func() {
+		if QMgr == nil {
+			return
+		}
+		err := QMgr.collectMetrics()
+		if err != nil {
+			logger.Error("(InitServerMetrics) collectMetrics returned: %s", err.Error())
+		}
+	})
+}
+
+func (qm *ServerMgr) collectMetrics() (err error) {
+	status, err := qm.GetJSONStatus()
+	if err != nil {
+		return
+	}
+	metricJobs.Reset()
+	for state, count := range status["jobs"] {
+		if state != "total" {
+			metricJobs.Set(float64(count), state)
+		}
+	}
+	metricTasks.Reset()
+	for state, count := range status["tasks"] {
+		if state != "total" {
+			metricTasks.Set(float64(count), state)
+		}
+	}
+
+	clientList, err := qm.clientMap.GetClients()
+	if err != nil {
+		return
+	}
+	clientGroups := map[string]string{}
+	metricClients.Reset()
+	for _, client := range clientList {
+		rlock, lerr := client.RLockNamed("collectMetrics")
+		if lerr != nil {
+			continue
+		}
+		clientGroups[client.ID] = client.Group
+		metricClients.Add(1, client.Group, client.Status)
+		client.RUnlockNamed(rlock)
+	}
+
+	workunits, err := qm.workQueue.GetAll()
+	if err != nil {
+		return
+	}
+	metricWorkunits.Reset()
+	for _, work := range workunits {
+		clientgroup := ""
+		if work.Client != "" {
+			clientgroup = clientGroups[work.Client]
+		} else if work.Info != nil {
+			clientgroup = work.Info.ClientGroups
+		}
+		metricWorkunits.Add(1, work.State, clientgroup)
+	}
+	return
+}
+
+// observeCheckout called when a worker checked out a workunit
+func observeCheckout(work *Workunit, clientgroup string) {
+	metricCheckouts.Inc(clientgroup)
+	if !work.QueuedTime.IsZero() {
+		metricQueueLatency.Observe(time.Since(work.QueuedTime).Seconds(), clientgroup)
+	}
+}
+
+// observeWorkunitResult called when a worker reported a workunit as done or failed
+func observeWorkunitResult(clientgroup string, status string, failure string) {
+	switch status {
+	case WORK_STAT_DONE:
+		metricWorkunitsCompleted.Inc(clientgroup)
+	case WORK_STAT_ERROR, WORK_STAT_FAILED_PERMANENT:
+		if failure == "" {
+			failure = "unknown"
+		}
+		metricWorkunitsFailed.Inc(clientgroup, failure)
+	}
+}
+
+// observeMongo records the duration of a mongodb operation, use with defer
+func observeMongo(collection string, operation string, start time.Time) {
+	metricMongo.Observe(time.Since(start).Seconds(), collection, operation)
+}
@@ -0,0 +1,291 @@
+package core
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/MG-RAST/AWE/lib/conf"
+	"github.com/MG-RAST/AWE/lib/db"
+	"github.com/MG-RAST/AWE/lib/logger"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// NotificationEvents job states a notification can be sent for
+var NotificationEvents = []string{JOB_STAT_COMPLETED, JOB_STAT_SUSPEND, JOB_STAT_FAILED_PERMANENT}
+
+// Notification webhook that is called when the job reaches one of the events
+type Notification struct {
+	URL    string   `bson:"url" json:"url" mapstructure:"url"`
+	Events []string `bson:"events" json:"events" mapstructure:"events"` // empty means all NotificationEvents
+	Secret string   `bson:"secret" json:"-" mapstructure:"-"`           // key for the HMAC-SHA256 signature, only accepted as form field on submission
+}
+
+// NotificationDelivery is one attempt to deliver a notification, the delivery log is part of the job
+type NotificationDelivery struct {
+	Event   string    `bson:"event" json:"event"`
+	Attempt int       `bson:"attempt" json:"attempt"`
+	Status  int       `bson:"status" json:"status"` // HTTP status code, 0 if the request failed
+	Error   string    `bson:"error,omitempty" json:"error,omitempty"`
+	Time    time.Time `bson:"time" json:"time"`
+}
+
+// NotificationPayload is posted as JSON to the notification URL
+type NotificationPayload struct {
+	Event    string    `json:"event"`
+	JobID    string    `json:"job_id"`
+	Name     string    `json:"name"`
+	User     string    `json:"user"`
+	Project  string    `json:"project"`
+	Pipeline string    `json:"pipeline"`
+	State    string    `json:"state"`
+	Error    *JobError `json:"error,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// Validate _
+func (n *Notification) Validate() (err error) {
+	u, err := url.Parse(n.URL)
+	if err != nil {
+		err = fmt.Errorf("(Notification/Validate) notification url invalid: %s", err.Error())
+		return
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		err = fmt.Errorf("(Notification/Validate) notification url requires http or https: %s", n.URL)
+		return
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		err = fmt.Errorf("(Notification/Validate) notification url has no host: %s", n.URL)
+		return
+	}
+	if !notificationHostListed(host) {
+		if len(conf.NOTIFICATION_ALLOWED_HOSTS) > 0 {
+			err = fmt.Errorf("(Notification/Validate) notifications to host %s are not allowed (see notification_allowed_hosts)", host)
+			return
+		}
+		if ip := net.ParseIP(host); ip != nil && !publicIP(ip) {
+			err = fmt.Errorf("(Notification/Validate) notifications to address %s are not allowed", host)
+			return
+		}
+	}
+	for _, event := range n.Events {
+		if !contains(NotificationEvents, event) {
+			err = fmt.Errorf("(Notification/Validate) unknown event \"%s\" (supported: %s)", event, strings.Join(NotificationEvents, ", "))
+			return
+		}
+	}
+	return
+}
+
+// notificationHostListed true if the host is in notification_allowed_hosts, entries starting with . match subdomains
+func notificationHostListed(host string) bool {
+	for _, allowed := range conf.NOTIFICATION_ALLOWED_HOSTS {
+		if host == allowed || (strings.HasPrefix(allowed, ".") && (strings.HasSuffix(host, allowed) || host == allowed[1:])) {
+			return true
+		}
+	}
+	return false
+}
+
+// privateNetworks RFC 1918 and RFC 4193 address ranges
+var privateNetworks = parseCIDRs("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7")
+
+func parseCIDRs(cidrs ...string) (networks []*net.IPNet) {
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return
+}
+
+// privateIP _
+func privateIP(ip net.IP) bool {
+	for _, network := range privateNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// publicIP false for loopback, private, link-local and unspecified addresses, the server must not be
+// used to reach internal services
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || privateIP(ip) || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsUnspecified())
+}
+
+// publicAddressControl net.Dialer Control func that refuses connections to addresses that are not public, checked after DNS resolution
+func publicAddressControl(what string) func(network string, address string, c syscall.RawConn) error {
+	return func(network string, address string, c syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+			return fmt.Errorf("%s to address %s are not allowed", what, host)
+		}
+		return nil
+	}
+}
+
+// notificationClient does not follow redirects and, unless the host is listed in notification_allowed_hosts,
+// only connects to public addresses. The address is checked when connecting, after name resolution.
+func notificationClient(notification *Notification) *http.Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	if u, err := url.Parse(notification.URL); err != nil || !notificationHostListed(strings.ToLower(u.Hostname())) {
+		dialer.Control = publicAddressControl("notifications")
+	}
+	transport := &http.Transport{
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+	return &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Wants returns true if the notification is registered for this event
+func (n *Notification) Wants(event string) bool {
+	if len(n.Events) == 0 {
+		return contains(NotificationEvents, event)
+	}
+	return contains(n.Events, event)
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of the body, sent in header X-AWE-Signature as "sha256=<signature>"
+func (n *Notification) Sign(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(n.Secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Notify sends the notification of the job for this event in the background, if the job has one
+func (job *Job) Notify(event string, jerror *JobError) {
+	if job.Info == nil || job.Info.Notification == nil || job.Info.Notification.URL == "" {
+		return
+	}
+	notification := *job.Info.Notification
+	if !notification.Wants(event) {
+		return
+	}
+	payload := &NotificationPayload{
+		Event:    event,
+		JobID:    job.ID,
+		Name:     job.Info.Name,
+		User:     job.Info.User,
+		Project:  job.Info.Project,
+		Pipeline: job.Info.Pipeline,
+		State:    job.State,
+		Error:    jerror,
+		Time:     time.Now(),
+	}
+	go job.deliverNotification(&notification, payload)
+}
+
+// deliverNotification posts the payload, failed attempts are repeated with exponential backoff
+func (job *Job) deliverNotification(notification *Notification, payload *NotificationPayload) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		logger.Error("(deliverNotification) json.Marshal returned: %s", err.Error())
+		return
+	}
+
+	client := notificationClient(notification)
+	wait := conf.NOTIFICATION_RETRY_WAIT
+	for attempt := 1; attempt <= conf.NOTIFICATION_RETRIES+1; attempt++ {
+		delivery := &NotificationDelivery{Event: payload.Event, Attempt: attempt, Time: time.Now()}
+
+		delivery.Status, err = postNotification(client, notification, body)
+		if err != nil {
+			delivery.Error = err.Error()
+		}
+
+		xerr := job.AddNotificationDelivery(delivery)
+		if xerr != nil {
+			logger.Error("(deliverNotification) AddNotificationDelivery returned: %s", xerr.Error())
+		}
+
+		if err == nil {
+			logger.Debug(1, "(deliverNotification) job %s event %s delivered to %s", payload.JobID, payload.Event, notification.URL)
+			return
+		}
+		logger.Warning("(deliverNotification) job %s event %s, attempt %d failed: %s", payload.JobID, payload.Event, attempt, err.Error())
+
+		if attempt <= conf.NOTIFICATION_RETRIES {
+			time.Sleep(wait)
+			wait = wait * 2
+		}
+	}
+	logger.Error("(deliverNotification) job %s event %s could not be delivered to %s", payload.JobID, payload.Event, notification.URL)
+}
+
+func postNotification(client *http.Client, notification *Notification, body []byte) (status int, err error) {
+	req, err := http.NewRequest("POST", notification.URL, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "AWE/"+conf.VERSION)
+	if notification.Secret != "" {
+		req.Header.Set("X-AWE-Signature", "sha256="+notification.Sign(body))
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+
+	status = res.StatusCode
+	if status < 200 || status >= 300 {
+		err = fmt.Errorf("response status %d", status)
+	}
+	return
+}
+
+// AddNotificationDelivery appends to the delivery log of the job
+func (job *Job) AddNotificationDelivery(delivery *NotificationDelivery) (err error) {
+	err = job.LockNamed("AddNotificationDelivery")
+	if err != nil {
+		return
+	}
+	defer job.Unlock()
+
+	err = dbPushJobField(job.ID, "notifications", delivery)
+	if err != nil {
+		return
+	}
+	job.Notifications = append(job.Notifications, delivery)
+	return
+}
+
+func dbPushJobField(jobID string, fieldname string, value interface{}) (err error) {
+	session := db.Connection.Session.Copy()
+	defer session.Close()
+
+	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_JOBS)
+
+	err = c.Update(bson.M{"id": jobID}, bson.M{"$push": bson.M{fieldname: value}})
+	if err != nil {
+		err = fmt.Errorf("(dbPushJobField) error adding to %s of job %s: %s", fieldname, jobID, err.Error())
+		return
+	}
+	return
+}
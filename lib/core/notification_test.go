@@ -0,0 +1,40 @@
+package core_test
+
+import (
+	"testing"
+
+	"github.com/MG-RAST/AWE/lib/conf"
+	. "github.com/MG-RAST/AWE/lib/core"
+)
+
+func TestNotificationValidateAddress(t *testing.T) {
+	conf.NOTIFICATION_ALLOWED_HOSTS = []string{}
+	for _, url := range []string{"http://127.0.0.1/hook", "http://10.0.0.5:8080/hook", "http://169.254.169.254/latest/meta-data", "http://[::1]/hook", "http://0.0.0.0/", "http://172.20.1.1/hook", "http://192.168.1.10/hook", "http://[fd00::1]/hook"} {
+		n := &Notification{URL: url}
+		if err := n.Validate(); err == nil {
+			t.Fatalf("expected %s to be rejected", url)
+		}
+	}
+	n := &Notification{URL: "https://hooks.example.org/awe"}
+	if err := n.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNotificationValidateAllowedHosts(t *testing.T) {
+	conf.NOTIFICATION_ALLOWED_HOSTS = []string{".example.org", "10.0.0.5"}
+	defer func() { conf.NOTIFICATION_ALLOWED_HOSTS = []string{} }()
+
+	for _, url := range []string{"https://hooks.example.org/awe", "https://example.org/awe", "http://10.0.0.5:8080/hook"} {
+		n := &Notification{URL: url}
+		if err := n.Validate(); err != nil {
+			t.Fatalf("expected %s to be allowed: %s", url, err.Error())
+		}
+	}
+	for _, url := range []string{"https://example.com/awe", "https://badexample.org/awe", "http://127.0.0.1/hook"} {
+		n := &Notification{URL: url}
+		if err := n.Validate(); err == nil {
+			t.Fatalf("expected %s to be rejected", url)
+		}
+	}
+}
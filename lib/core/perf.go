@@ -42,6 +42,10 @@ type WorkPerf struct {
 	PreDataSize        int64   `bson:"size_predata" json:"size_predata"` //predata moved over network
 	InFileSize         int64   `bson:"size_infile" json:"size_infile"`   //input file moved over network
 	OutFileSize        int64   `bson:"size_outfile" json:"size_outfile"` //outpuf file moved over network
+
+	DockerImage   string `bson:"docker_image,omitempty" json:"docker_image,omitempty"`       // name of the docker image the workunit ran in
+	DockerImageId string `bson:"docker_image_id,omitempty" json:"docker_image_id,omitempty"` // image id (sha256 digest) reported by the container runtime
+	Tool          string `bson:"tool,omitempty" json:"tool,omitempty"`                       // key of the runtime statistics of the SJF policy, set by the server
 }
 
 func NewJobPerf(id string) *JobPerf {
@@ -58,6 +58,10 @@ type ProcessInstanceBase struct {
 	State           string            `bson:"state" json:"state" mapstructure:"state"`
 	ProcessType     string            `bson:"processtype" json:"processtype" mapstructure:"processtype"`
 	ScatterChildren []string          `bson:"scatterChildren" json:"scatterChildren" mapstructure:"scatterChildren"` // use simple TaskName/WorkflowInstance id  , list of all children in a subworkflow task
+	// nested_crossproduct only: length of each scatter array, needed to nest the outputs
+	ScatterShape []int `bson:"scatterShape,omitempty" json:"scatterShape,omitempty" mapstructure:"scatterShape,omitempty"`
+	// CWL v1.2: the when expression of the step evaluated to false, all outputs are null
+	Skipped bool `bson:"skipped,omitempty" json:"skipped,omitempty" mapstructure:"skipped,omitempty"`
 	//ParentWorkflow     string
 	//ParentWorkflowStep string
 	// or use *cwl.WorkflowStep in cache ?
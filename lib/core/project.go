@@ -0,0 +1,376 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MG-RAST/AWE/lib/acl"
+	"github.com/MG-RAST/AWE/lib/conf"
+	"github.com/MG-RAST/AWE/lib/db"
+	"github.com/MG-RAST/AWE/lib/logger"
+	"github.com/MG-RAST/AWE/lib/user"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// roles of users in a project, each role has the rights of the roles before it
+const (
+	ROLE_VIEWER    = "viewer"    // read jobs and client groups of the project
+	ROLE_SUBMITTER = "submitter" // submit jobs to the project
+	ROLE_OPERATOR  = "operator"  // suspend, resume and change jobs and client groups of the project
+	ROLE_ADMIN     = "admin"     // delete jobs and client groups of the project, manage the members
+)
+
+// ProjectRoles all roles, lowest first
+var ProjectRoles = []string{ROLE_VIEWER, ROLE_SUBMITTER, ROLE_OPERATOR, ROLE_ADMIN}
+
+// Project grants roles to users on all jobs with info.project set to the name of the project
+// and on the client groups of the project. Only global admins create projects and assign client groups.
+type Project struct {
+	Name         string            `bson:"name" json:"name"`
+	Description  string            `bson:"description" json:"description"`
+	Members      map[string]string `bson:"members" json:"members"`           // user uuid (or public) -> role
+	ClientGroups []string          `bson:"clientgroups" json:"clientgroups"` // names of client groups the roles apply to
+	CreatedBy    string            `bson:"created_by" json:"created_by"`
+	CreatedOn    time.Time         `bson:"created_on" json:"created_on"`
+	LastModified time.Time         `bson:"last_modified" json:"last_modified"`
+}
+
+// Projects _
+type Projects []*Project
+
+// InitProjectDB _
+func InitProjectDB() {
+	session := db.Connection.Session.Copy()
+	defer session.Close()
+	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_PROJECTS)
+	c.EnsureIndex(mgo.Index{Key: []string{"name"}, Unique: true})
+	c.EnsureIndex(mgo.Index{Key: []string{"clientgroups"}, Background: true})
+}
+
+// NewProject the creator becomes admin of the project, the project is not saved yet
+func NewProject(name string, description string, u *user.User) (p *Project, err error) {
+	if name == "" || strings.ContainsAny(name, "$.") {
+		err = errors.New("(NewProject) project name must not be empty or contain $ or .")
+		return
+	}
+	p = &Project{
+		Name:         name,
+		Description:  description,
+		Members:      map[string]string{},
+		ClientGroups: []string{},
+		CreatedBy:    u.Uuid,
+		CreatedOn:    time.Now(),
+	}
+	if u.Uuid != "public" {
+		p.Members[u.Uuid] = ROLE_ADMIN
+	}
+	return
+}
+
+// ValidateRole _
+func ValidateRole(role string) (err error) {
+	if roleLevel(role) == 0 {
+		err = fmt.Errorf("unknown role \"%s\" (supported: %s)", role, strings.Join(ProjectRoles, ", "))
+	}
+	return
+}
+
+// roleLevel 0 for no or an unknown role, higher roles have higher levels
+func roleLevel(role string) int {
+	for i, r := range ProjectRoles {
+		if r == role {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// RoleRights rights of a role on the jobs and client groups of the project
+func RoleRights(role string) (rights acl.Rights) {
+	level := roleLevel(role)
+	rights = acl.Rights{
+		"read":   level >= roleLevel(ROLE_VIEWER),
+		"write":  level >= roleLevel(ROLE_OPERATOR),
+		"delete": level >= roleLevel(ROLE_ADMIN),
+	}
+	return
+}
+
+// Role highest role of the user in the project, roles of the member "public" apply to everybody
+func (p *Project) Role(u *user.User) (role string) {
+	role = p.Members["public"]
+	if r, ok := p.Members[u.Uuid]; ok && roleLevel(r) > roleLevel(role) {
+		role = r
+	}
+	return
+}
+
+// HasRole true if the user has at least the role in the project
+func (p *Project) HasRole(u *user.User, role string) bool {
+	return roleLevel(p.Role(u)) >= roleLevel(role)
+}
+
+// SetMember adds the user or changes the role of the user
+func (p *Project) SetMember(uuid string, role string) (err error) {
+	if err = ValidateRole(role); err != nil {
+		return
+	}
+	p.Members[uuid] = role
+	return
+}
+
+// RemoveMember _
+func (p *Project) RemoveMember(uuid string) {
+	delete(p.Members, uuid)
+}
+
+// AddClientGroup _
+func (p *Project) AddClientGroup(name string) {
+	if !contains(p.ClientGroups, name) {
+		p.ClientGroups = append(p.ClientGroups, name)
+	}
+}
+
+// RemoveClientGroup _
+func (p *Project) RemoveClientGroup(name string) {
+	clientgroups := []string{}
+	for _, cg := range p.ClientGroups {
+		if cg != name {
+			clientgroups = append(clientgroups, cg)
+		}
+	}
+	p.ClientGroups = clientgroups
+}
+
+// Save _
+func (p *Project) Save() (err error) {
+	p.LastModified = time.Now()
+	session := db.Connection.Session.Copy()
+	defer session.Close()
+	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_PROJECTS)
+	_, err = c.Upsert(bson.M{"name": p.Name}, p)
+	if err != nil {
+		err = fmt.Errorf("(Project/Save) error saving project %s: %s", p.Name, err.Error())
+	}
+	return
+}
+
+// LoadProject _
+func LoadProject(name string) (p *Project, err error) {
+	p = new(Project)
+	session := db.Connection.Session.Copy()
+	defer session.Close()
+	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_PROJECTS)
+	if err = c.Find(bson.M{"name": name}).One(p); err != nil {
+		p = nil
+	}
+	return
+}
+
+// DeleteProject removes the project and the roles, jobs keep their info.project
+func DeleteProject(name string) (err error) {
+	session := db.Connection.Session.Copy()
+	defer session.Close()
+	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_PROJECTS)
+	err = c.Remove(bson.M{"name": name})
+	return
+}
+
+// GetPaginated _
+func (p *Projects) GetPaginated(q bson.M, limit int, offset int, order string, direction string) (count int, err error) {
+	if direction == "desc" {
+		order = "-" + order
+	}
+	session := db.Connection.Session.Copy()
+	defer session.Close()
+	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_PROJECTS)
+	query := c.Find(q)
+	if count, err = query.Count(); err != nil {
+		return
+	}
+	err = query.Sort(order).Limit(limit).Skip(offset).All(p)
+	return
+}
+
+// MemberQuery selects the projects in which the user has a role
+func MemberQuery(u *user.User) bson.M {
+	return bson.M{"$or": []bson.M{bson.M{"members." + u.Uuid: bson.M{"$exists": true}}, bson.M{"members.public": bson.M{"$exists": true}}}}
+}
+
+// userProjects projects in which the user has at least the role
+func userProjects(u *user.User, role string, q bson.M) (projects Projects, err error) {
+	session := db.Connection.Session.Copy()
+	defer session.Close()
+	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_PROJECTS)
+	var all Projects
+	err = c.Find(bson.M{"$and": []bson.M{MemberQuery(u), q}}).All(&all)
+	if err != nil {
+		err = fmt.Errorf("(userProjects) %s", err.Error())
+		return
+	}
+	for _, p := range all {
+		if p.HasRole(u, role) {
+			projects = append(projects, p)
+		}
+	}
+	return
+}
+
+// UserProjectNames names of the projects in which the user has at least the role, e.g. to list their jobs
+func UserProjectNames(u *user.User, role string) (names []string, err error) {
+	var projects Projects
+	projects, err = userProjects(u, role, bson.M{})
+	if err != nil {
+		return
+	}
+	names = []string{}
+	for _, p := range projects {
+		names = append(names, p.Name)
+	}
+	return
+}
+
+// UserRoles the projects in which a user has a role, loaded with the first question. One UserRoles per request
+// answers the rights on many jobs and client groups with a single query. Not safe for concurrent use.
+type UserRoles struct {
+	user     *user.User
+	projects Projects
+	loaded   bool
+}
+
+// NewUserRoles _
+func NewUserRoles(u *user.User) *UserRoles {
+	return &UserRoles{user: u}
+}
+
+// UserRolesOf roles of the user in the given projects
+func UserRolesOf(u *user.User, projects Projects) *UserRoles {
+	return &UserRoles{user: u, projects: projects, loaded: true}
+}
+
+func (r *UserRoles) load() {
+	if r.loaded {
+		return
+	}
+	r.loaded = true
+	if r.user == nil || r.user.Admin { // admins have all rights without a role
+		return
+	}
+	projects, err := userProjects(r.user, ROLE_VIEWER, bson.M{})
+	if err != nil {
+		logger.Error("(UserRoles) %s", err.Error())
+		return
+	}
+	r.projects = projects
+}
+
+// ProjectRights rights of the user on the jobs of the project, no rights if the project does not exist
+func (r *UserRoles) ProjectRights(project string) (rights acl.Rights) {
+	if project == "" {
+		return RoleRights("")
+	}
+	r.load()
+	for _, p := range r.projects {
+		if p.Name == project {
+			return RoleRights(p.Role(r.user))
+		}
+	}
+	return RoleRights("")
+}
+
+// ClientGroups names of the client groups of projects in which the user has at least the role
+func (r *UserRoles) ClientGroups(role string) (clientgroups map[string]bool) {
+	r.load()
+	clientgroups = map[string]bool{}
+	for _, p := range r.projects {
+		if !p.HasRole(r.user, role) {
+			continue
+		}
+		for _, cg := range p.ClientGroups {
+			clientgroups[cg] = true
+		}
+	}
+	return
+}
+
+// ClientGroupRights rights of the user on the client group from the highest role in the projects of the client group
+func (r *UserRoles) ClientGroupRights(clientgroup string) (rights acl.Rights) {
+	r.load()
+	role := ""
+	for _, p := range r.projects {
+		if !contains(p.ClientGroups, clientgroup) {
+			continue
+		}
+		if pr := p.Role(r.user); roleLevel(pr) > roleLevel(role) {
+			role = pr
+		}
+	}
+	return RoleRights(role)
+}
+
+// UserProjectClientGroups names of the client groups of projects in which the user has at least the role
+func UserProjectClientGroups(u *user.User, role string) (clientgroups map[string]bool) {
+	return NewUserRoles(u).ClientGroups(role)
+}
+
+// ProjectRights rights of the user on the jobs of the project from the role in the project,
+// no rights if the project does not exist
+func ProjectRights(u *user.User, project string) (rights acl.Rights) {
+	if u == nil || project == "" {
+		return RoleRights("")
+	}
+	p, err := LoadProject(project)
+	if err != nil {
+		if err != mgo.ErrNotFound {
+			logger.Error("(ProjectRights) LoadProject returned: %s", err.Error())
+		}
+		return RoleRights("")
+	}
+	return RoleRights(p.Role(u))
+}
+
+// JobProjectRights ProjectRights for the project of the job
+func JobProjectRights(u *user.User, jobID string) (rights acl.Rights) {
+	project, err := dbGetJobProject(jobID)
+	if err != nil {
+		return RoleRights("")
+	}
+	return ProjectRights(u, project)
+}
+
+// ClientGroupProjectRights rights of the user on the client group from the highest role in the projects of the client group
+func ClientGroupProjectRights(u *user.User, clientgroup string) (rights acl.Rights) {
+	if u == nil {
+		return RoleRights("")
+	}
+	projects, err := userProjects(u, ROLE_VIEWER, bson.M{"clientgroups": clientgroup})
+	if err != nil {
+		logger.Error("(ClientGroupProjectRights) %s", err.Error())
+		return RoleRights("")
+	}
+	return UserRolesOf(u, projects).ClientGroupRights(clientgroup)
+}
+
+// CheckProjectSubmission jobs of a project can only be submitted by its submitters, any user can submit to unknown projects
+func CheckProjectSubmission(u *user.User, project string) (err error) {
+	if project == "" || u.Admin {
+		return
+	}
+	var p *Project
+	p, err = LoadProject(project)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			err = nil
+			return
+		}
+		err = fmt.Errorf("(CheckProjectSubmission) LoadProject returned: %s", err.Error())
+		return
+	}
+	if !p.HasRole(u, ROLE_SUBMITTER) {
+		err = fmt.Errorf("role %s in project %s is required to submit jobs to it", ROLE_SUBMITTER, project)
+	}
+	return
+}
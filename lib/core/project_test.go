@@ -0,0 +1,90 @@
+package core_test
+
+import (
+	"testing"
+
+	. "github.com/MG-RAST/AWE/lib/core"
+	"github.com/MG-RAST/AWE/lib/user"
+)
+
+func TestProjectRoles(t *testing.T) {
+	creator := &user.User{Uuid: "creator"}
+	operator := &user.User{Uuid: "operator"}
+	other := &user.User{Uuid: "other"}
+
+	p, err := NewProject("metagenomics", "", creator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewProject("a.b", "", creator); err == nil {
+		t.Errorf("expected error for project name with .")
+	}
+	if err = p.SetMember(operator.Uuid, "owner"); err == nil {
+		t.Errorf("expected error for unknown role")
+	}
+	if err = p.SetMember(operator.Uuid, ROLE_OPERATOR); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Role(creator) != ROLE_ADMIN {
+		t.Errorf("creator should be admin, got %s", p.Role(creator))
+	}
+	if !p.HasRole(operator, ROLE_SUBMITTER) || p.HasRole(operator, ROLE_ADMIN) {
+		t.Errorf("operator has wrong roles")
+	}
+	if p.HasRole(other, ROLE_VIEWER) {
+		t.Errorf("non-member should have no role")
+	}
+
+	// public applies to everybody, a member keeps the higher role
+	p.SetMember("public", ROLE_VIEWER)
+	if !p.HasRole(other, ROLE_VIEWER) || p.Role(operator) != ROLE_OPERATOR {
+		t.Errorf("public role not applied")
+	}
+
+	rights := RoleRights(ROLE_OPERATOR)
+	if !rights["read"] || !rights["write"] || rights["delete"] {
+		t.Errorf("wrong operator rights: %v", rights)
+	}
+	rights = RoleRights(ROLE_SUBMITTER)
+	if !rights["read"] || rights["write"] {
+		t.Errorf("wrong submitter rights: %v", rights)
+	}
+	rights = RoleRights("")
+	if rights["read"] {
+		t.Errorf("no role must not have rights: %v", rights)
+	}
+}
+
+func TestUserRoles(t *testing.T) {
+	operator := &user.User{Uuid: "operator"}
+
+	assembly, _ := NewProject("assembly", "", &user.User{Uuid: "creator"})
+	assembly.SetMember(operator.Uuid, ROLE_OPERATOR)
+	assembly.AddClientGroup("big-memory")
+	annotation, _ := NewProject("annotation", "", &user.User{Uuid: "creator"})
+	annotation.SetMember("public", ROLE_VIEWER)
+	annotation.AddClientGroup("big-memory")
+	annotation.AddClientGroup("gpu")
+
+	roles := UserRolesOf(operator, Projects{assembly, annotation})
+	if !roles.ProjectRights("assembly")["write"] || roles.ProjectRights("annotation")["write"] || !roles.ProjectRights("annotation")["read"] {
+		t.Errorf("wrong project rights")
+	}
+	if roles.ProjectRights("unknown")["read"] || roles.ProjectRights("")["read"] {
+		t.Errorf("unknown projects must not give rights")
+	}
+
+	clientgroups := roles.ClientGroups(ROLE_OPERATOR)
+	if len(clientgroups) != 1 || !clientgroups["big-memory"] {
+		t.Errorf("expected only big-memory for operator, got %v", clientgroups)
+	}
+	if !roles.ClientGroups(ROLE_VIEWER)["gpu"] {
+		t.Errorf("gpu missing for viewer")
+	}
+
+	// the highest role in the projects of the client group
+	if !roles.ClientGroupRights("big-memory")["write"] || roles.ClientGroupRights("gpu")["write"] || !roles.ClientGroupRights("gpu")["read"] {
+		t.Errorf("wrong client group rights")
+	}
+}
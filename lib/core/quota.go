@@ -0,0 +1,450 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MG-RAST/AWE/lib/conf"
+	"github.com/MG-RAST/AWE/lib/db"
+	"github.com/MG-RAST/AWE/lib/logger"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// quota types
+const (
+	QUOTA_USER        = "user"        // limits of one user (job owner), server-wide
+	QUOTA_CLIENTGROUP = "clientgroup" // limits of each user on the clients of one clientgroup
+)
+
+// Quota limits usage of a user, zero means unlimited
+type Quota struct {
+	Type          string  `bson:"type" json:"type"`
+	Name          string  `bson:"name" json:"name"`                       // user uuid or clientgroup name
+	MaxWorkunits  int     `bson:"max_workunits" json:"max_workunits"`     // workunits in progress at the same time
+	MaxQueuedJobs int     `bson:"max_queued_jobs" json:"max_queued_jobs"` // jobs that are not completed, suspended or deleted
+	MaxCPUHours   float64 `bson:"max_cpu_hours" json:"max_cpu_hours"`     // compute time times cores per day (UTC)
+}
+
+// QuotaUsage current usage of one user, server-wide or on one clientgroup
+type QuotaUsage struct {
+	User        string   `json:"user"`
+	Clientgroup string   `json:"clientgroup,omitempty"`
+	Workunits   int      `json:"workunits"`
+	QueuedJobs  int      `json:"queued_jobs"`
+	CPUHours    float64  `json:"cpu_hours"`
+	Quota       *Quota   `json:"quota"`
+	Exceeded    []string `json:"exceeded,omitempty"` // reasons why work of the user is held
+}
+
+// cpuTimeDoc daily CPU time of a user in the QuotaUsage collection
+type cpuTimeDoc struct {
+	Day         string  `bson:"day"`
+	User        string  `bson:"user"`
+	Clientgroup string  `bson:"clientgroup"` // empty for the server-wide total
+	Seconds     float64 `bson:"seconds"`
+}
+
+// QuotaMgr keeps the configured quotas, the workunits in progress and the CPU time used today
+type QuotaMgr struct {
+	sync.RWMutex
+	quotas     map[string]*Quota  // key: type/name
+	day        string             // UTC day of cpuSeconds
+	cpuSeconds map[string]float64 // key: user/clientgroup
+	workunits  map[string]int     // key: user/clientgroup, workunits checked out
+	checkouts  map[Workunit_Unique_Identifier]quotaCheckout
+	persist    bool // quotas and CPU time are stored in the database
+
+	Clock           func() time.Time                                   // time.Now if nil
+	CountQueuedJobs func(user string, q *Quota) (count int, err error) // counts in the jobs collection if nil
+}
+
+// quotaCheckout whose workunit is counted, and on which clientgroup
+type quotaCheckout struct {
+	user        string
+	clientgroup string
+}
+
+// Quotas is nil if quotas are not enforced (e.g. on the worker)
+var Quotas *QuotaMgr
+
+func quotaKey(a string, b string) string {
+	return a + "/" + b
+}
+
+func quotaDay(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+// NewQuotaMgr quotas and usage are only kept in memory, InitQuotaDB loads and stores them in the database
+func NewQuotaMgr(quotas []*Quota) (qm *QuotaMgr) {
+	qm = &QuotaMgr{quotas: make(map[string]*Quota), cpuSeconds: make(map[string]float64)}
+	qm.workunits = make(map[string]int)
+	qm.checkouts = make(map[Workunit_Unique_Identifier]quotaCheckout)
+	qm.day = quotaDay(qm.now())
+	for _, q := range quotas {
+		qm.quotas[quotaKey(q.Type, q.Name)] = q
+	}
+	return
+}
+
+func (qm *QuotaMgr) now() time.Time {
+	if qm.Clock != nil {
+		return qm.Clock()
+	}
+	return time.Now()
+}
+
+// InitQuotaDB loads quotas and the CPU time used today, server only
+func InitQuotaDB() (err error) {
+	session := db.Connection.Session.Copy()
+	defer session.Close()
+	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_QUOTAS)
+	c.EnsureIndex(mgo.Index{Key: []string{"type", "name"}, Unique: true})
+	u := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_QUOTA_USAGE)
+	u.EnsureIndex(mgo.Index{Key: []string{"day", "user", "clientgroup"}, Unique: true})
+
+	var quotas []*Quota
+	err = c.Find(nil).All(&quotas)
+	if err != nil {
+		err = fmt.Errorf("(InitQuotaDB) could not load quotas: %s", err.Error())
+		return
+	}
+	qm := NewQuotaMgr(quotas)
+	qm.persist = true
+
+	var usage []*cpuTimeDoc
+	err = u.Find(bson.M{"day": qm.day}).All(&usage)
+	if err != nil {
+		err = fmt.Errorf("(InitQuotaDB) could not load quota usage: %s", err.Error())
+		return
+	}
+	for _, doc := range usage {
+		qm.cpuSeconds[quotaKey(doc.User, doc.Clientgroup)] = doc.Seconds
+	}
+
+	Quotas = qm
+	return
+}
+
+// Active returns false if no quota is configured, then usage does not have to be collected
+func (qm *QuotaMgr) Active() bool {
+	if qm == nil {
+		return false
+	}
+	if conf.QUOTA_WORKUNITS > 0 || conf.QUOTA_QUEUED_JOBS > 0 || conf.QUOTA_CPU_HOURS > 0 {
+		return true
+	}
+	qm.RLock()
+	defer qm.RUnlock()
+	return len(qm.quotas) > 0
+}
+
+// Get returns the quota, for users without an entry the server defaults apply. Returns nil if there is no limit.
+func (qm *QuotaMgr) Get(quotaType string, name string) (q *Quota) {
+	qm.RLock()
+	defer qm.RUnlock()
+	if stored, ok := qm.quotas[quotaKey(quotaType, name)]; ok {
+		copy := *stored
+		q = &copy
+		return
+	}
+	if quotaType == QUOTA_USER && (conf.QUOTA_WORKUNITS > 0 || conf.QUOTA_QUEUED_JOBS > 0 || conf.QUOTA_CPU_HOURS > 0) {
+		q = &Quota{Type: QUOTA_USER, Name: name, MaxWorkunits: conf.QUOTA_WORKUNITS, MaxQueuedJobs: conf.QUOTA_QUEUED_JOBS, MaxCPUHours: float64(conf.QUOTA_CPU_HOURS)}
+	}
+	return
+}
+
+// List returns all configured quotas
+func (qm *QuotaMgr) List() (quotas []*Quota) {
+	qm.RLock()
+	defer qm.RUnlock()
+	quotas = []*Quota{}
+	for _, q := range qm.quotas {
+		copy := *q
+		quotas = append(quotas, &copy)
+	}
+	return
+}
+
+// Set stores the quota, a quota without limits is removed
+func (qm *QuotaMgr) Set(q *Quota) (err error) {
+	if q.Type != QUOTA_USER && q.Type != QUOTA_CLIENTGROUP {
+		err = fmt.Errorf("(QuotaMgr/Set) unknown quota type %s", q.Type)
+		return
+	}
+	if q.Name == "" {
+		err = fmt.Errorf("(QuotaMgr/Set) quota name empty")
+		return
+	}
+	if q.MaxWorkunits < 0 || q.MaxQueuedJobs < 0 || q.MaxCPUHours < 0 {
+		err = fmt.Errorf("(QuotaMgr/Set) quota limits must not be negative")
+		return
+	}
+
+	qm.Lock()
+	defer qm.Unlock()
+	remove := q.MaxWorkunits == 0 && q.MaxQueuedJobs == 0 && q.MaxCPUHours == 0
+	if qm.persist {
+		session := db.Connection.Session.Copy()
+		defer session.Close()
+		c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_QUOTAS)
+
+		selector := bson.M{"type": q.Type, "name": q.Name}
+		if remove {
+			_, err = c.RemoveAll(selector)
+			if err != nil {
+				err = fmt.Errorf("(QuotaMgr/Set) RemoveAll returned: %s", err.Error())
+				return
+			}
+		} else {
+			_, err = c.Upsert(selector, q)
+			if err != nil {
+				err = fmt.Errorf("(QuotaMgr/Set) Upsert returned: %s", err.Error())
+				return
+			}
+		}
+	}
+	if remove {
+		delete(qm.quotas, quotaKey(q.Type, q.Name))
+		return
+	}
+	qm.quotas[quotaKey(q.Type, q.Name)] = q
+	return
+}
+
+// rollover resets the CPU time at the start of a new day, caller has to hold the write lock
+func (qm *QuotaMgr) rollover() {
+	today := quotaDay(qm.now())
+	if qm.day != today {
+		qm.day = today
+		qm.cpuSeconds = make(map[string]float64)
+	}
+}
+
+// AddCPUTime adds the CPU time of a finished or failed workunit to the usage of the user, server-wide and on the clientgroup
+func (qm *QuotaMgr) AddCPUTime(user string, clientgroup string, seconds float64) {
+	if qm == nil || user == "" || seconds <= 0 {
+		return
+	}
+	qm.Lock()
+	qm.rollover()
+	day := qm.day
+	qm.cpuSeconds[quotaKey(user, "")] += seconds
+	if clientgroup != "" {
+		qm.cpuSeconds[quotaKey(user, clientgroup)] += seconds
+	}
+	qm.Unlock()
+
+	if !qm.persist {
+		return
+	}
+	session := db.Connection.Session.Copy()
+	defer session.Close()
+	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_QUOTA_USAGE)
+	groups := []string{""}
+	if clientgroup != "" {
+		groups = append(groups, clientgroup)
+	}
+	for _, group := range groups {
+		_, err := c.Upsert(bson.M{"day": day, "user": user, "clientgroup": group}, bson.M{"$inc": bson.M{"seconds": seconds}})
+		if err != nil {
+			logger.Error("(QuotaMgr/AddCPUTime) could not store usage of user %s: %s", user, err.Error())
+		}
+	}
+}
+
+// CPUHours returns the CPU-hours used today by the user, server-wide if clientgroup is empty
+func (qm *QuotaMgr) CPUHours(user string, clientgroup string) float64 {
+	qm.Lock()
+	defer qm.Unlock()
+	qm.rollover()
+	return qm.cpuSeconds[quotaKey(user, clientgroup)] / 3600
+}
+
+// CheckedOut counts the workunit as in progress for its owner until it is released, a workunit is only counted once
+func (qm *QuotaMgr) CheckedOut(work *Workunit, clientgroup string) {
+	if qm == nil || work.Owner == "" {
+		return
+	}
+	qm.Lock()
+	defer qm.Unlock()
+	id := work.Workunit_Unique_Identifier
+	if _, ok := qm.checkouts[id]; ok {
+		return
+	}
+	qm.checkouts[id] = quotaCheckout{user: work.Owner, clientgroup: clientgroup}
+	qm.workunits[quotaKey(work.Owner, "")]++
+	if clientgroup != "" {
+		qm.workunits[quotaKey(work.Owner, clientgroup)]++
+	}
+}
+
+// Released the workunit is not in progress anymore, e.g. delivered, requeued or deleted
+func (qm *QuotaMgr) Released(id Workunit_Unique_Identifier) {
+	if qm == nil {
+		return
+	}
+	qm.Lock()
+	defer qm.Unlock()
+	checkout, ok := qm.checkouts[id]
+	if !ok {
+		return
+	}
+	delete(qm.checkouts, id)
+	qm.decrement(quotaKey(checkout.user, ""))
+	if checkout.clientgroup != "" {
+		qm.decrement(quotaKey(checkout.user, checkout.clientgroup))
+	}
+}
+
+// decrement caller has to hold the write lock
+func (qm *QuotaMgr) decrement(key string) {
+	if qm.workunits[key] <= 1 {
+		delete(qm.workunits, key)
+		return
+	}
+	qm.workunits[key]--
+}
+
+// Workunits returns the number of workunits of the user in progress, server-wide if clientgroup is empty
+func (qm *QuotaMgr) Workunits(user string, clientgroup string) int {
+	qm.RLock()
+	defer qm.RUnlock()
+	return qm.workunits[quotaKey(user, clientgroup)]
+}
+
+// Held returns why workunits of the user cannot be checked out by a client of the clientgroup, nil if they can
+func (qm *QuotaMgr) Held(user string, clientgroup string) (reasons []string) {
+	reasons = qm.Get(QUOTA_USER, user).exceeded(qm.Workunits(user, ""), qm.CPUHours(user, ""))
+	if clientgroup != "" {
+		reasons = append(reasons, qm.Get(QUOTA_CLIENTGROUP, clientgroup).exceeded(qm.Workunits(user, clientgroup), qm.CPUHours(user, clientgroup))...)
+	}
+	return
+}
+
+func (qm *QuotaMgr) countQueuedJobs(user string, q *Quota) (count int, err error) {
+	if qm.CountQueuedJobs != nil {
+		return qm.CountQueuedJobs(user, q)
+	}
+	return dbCountQueuedJobs(user, q)
+}
+
+// CheckSubmission returns an error if the user cannot submit another job with these clientgroups
+func (qm *QuotaMgr) CheckSubmission(user string, clientgroups string) (err error) {
+	if !qm.Active() {
+		return
+	}
+	subjects := []*Quota{qm.Get(QUOTA_USER, user)}
+	if clientgroups != "" {
+		for _, group := range strings.Split(clientgroups, ",") {
+			subjects = append(subjects, qm.Get(QUOTA_CLIENTGROUP, group))
+		}
+	}
+	for _, q := range subjects {
+		if q == nil || q.MaxQueuedJobs == 0 {
+			continue
+		}
+		var count int
+		count, err = qm.countQueuedJobs(user, q)
+		if err != nil {
+			err = fmt.Errorf("(CheckSubmission) countQueuedJobs returned: %s", err.Error())
+			return
+		}
+		if count >= q.MaxQueuedJobs {
+			err = fmt.Errorf("quota exceeded: %d of %d unfinished jobs (%s %s)", count, q.MaxQueuedJobs, q.Type, q.Name)
+			return
+		}
+	}
+	return
+}
+
+// dbCountQueuedJobs counts the unfinished jobs of the user, for a clientgroup quota only jobs restricted to that clientgroup
+func dbCountQueuedJobs(user string, q *Quota) (count int, err error) {
+	query := bson.M{"acl.owner": user, "state": bson.M{"$in": []string{JOB_STAT_INIT, JOB_STAT_QUEUING, JOB_STAT_QUEUED, JOB_STAT_INPROGRESS}}}
+	if q.Type == QUOTA_CLIENTGROUP {
+		query["info.clientgroups"] = bson.RegEx{Pattern: "(^|,)" + regexp.QuoteMeta(q.Name) + "(,|$)"}
+	}
+	count, err = dbCount(query)
+	return
+}
+
+// exceeded returns why the usage does not allow to check out another workunit
+func (q *Quota) exceeded(workunits int, cpuHours float64) (reasons []string) {
+	if q == nil {
+		return
+	}
+	if q.MaxWorkunits > 0 && workunits >= q.MaxWorkunits {
+		reasons = append(reasons, fmt.Sprintf("%d of %d workunits in progress (%s %s)", workunits, q.MaxWorkunits, q.Type, q.Name))
+	}
+	if q.MaxCPUHours > 0 && cpuHours >= q.MaxCPUHours {
+		reasons = append(reasons, fmt.Sprintf("%.2f of %.2f CPU-hours used today (%s %s)", cpuHours, q.MaxCPUHours, q.Type, q.Name))
+	}
+	return
+}
+
+// filterWorkByQuota removes workunits of users that reached a quota on this client
+func (qm *CQMgr) filterWorkByQuota(client *Client, workunits WorkList) (allowed WorkList, held int, err error) {
+	if !Quotas.Active() {
+		allowed = workunits
+		return
+	}
+	userQuota := make(map[string][]string) // cached reasons per user
+	for _, work := range workunits {
+		if work.Owner == "" {
+			allowed = append(allowed, work)
+			continue
+		}
+		reasons, ok := userQuota[work.Owner]
+		if !ok {
+			reasons = Quotas.Held(work.Owner, client.Group)
+			userQuota[work.Owner] = reasons
+		}
+		if len(reasons) > 0 {
+			logger.Debug(3, "(filterWorkByQuota) workunit %s held: %s", work.ID, strings.Join(reasons, ", "))
+			held++
+			continue
+		}
+		allowed = append(allowed, work)
+	}
+	return
+}
+
+// GetQuotaUsage returns the server-wide usage of the user and the usage on each clientgroup with a quota
+func (qm *CQMgr) GetQuotaUsage(user string) (usage []*QuotaUsage, err error) {
+	groups := []string{""}
+	for _, q := range Quotas.List() {
+		if q.Type == QUOTA_CLIENTGROUP {
+			groups = append(groups, q.Name)
+		}
+	}
+
+	for _, group := range groups {
+		u := &QuotaUsage{User: user, Clientgroup: group}
+		if group == "" {
+			u.Quota = Quotas.Get(QUOTA_USER, user)
+		} else {
+			u.Quota = Quotas.Get(QUOTA_CLIENTGROUP, group)
+		}
+		u.Workunits = Quotas.Workunits(user, group)
+		u.CPUHours = Quotas.CPUHours(user, group)
+		q := u.Quota
+		if q == nil {
+			q = &Quota{Type: QUOTA_USER, Name: user}
+		}
+		u.QueuedJobs, err = Quotas.countQueuedJobs(user, q)
+		if err != nil {
+			err = fmt.Errorf("(GetQuotaUsage) countQueuedJobs returned: %s", err.Error())
+			return
+		}
+		u.Exceeded = u.Quota.exceeded(u.Workunits, u.CPUHours)
+		if u.Quota != nil && u.Quota.MaxQueuedJobs > 0 && u.QueuedJobs >= u.Quota.MaxQueuedJobs {
+			u.Exceeded = append(u.Exceeded, fmt.Sprintf("%d of %d unfinished jobs, no new submissions (%s %s)", u.QueuedJobs, u.Quota.MaxQueuedJobs, u.Quota.Type, u.Quota.Name))
+		}
+		usage = append(usage, u)
+	}
+	return
+}
@@ -0,0 +1,134 @@
+package core_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MG-RAST/AWE/lib/conf"
+	. "github.com/MG-RAST/AWE/lib/core"
+)
+
+func quotaWorkunit(owner string, rank int) *Workunit {
+	work := &Workunit{Owner: owner}
+	work.JobId = "job1"
+	work.TaskName = "task"
+	work.Rank = rank
+	return work
+}
+
+func TestQuotaWorkunits(t *testing.T) {
+	qm := NewQuotaMgr([]*Quota{
+		{Type: QUOTA_USER, Name: "alice", MaxWorkunits: 2},
+		{Type: QUOTA_CLIENTGROUP, Name: "gpu", MaxWorkunits: 1},
+	})
+	if !qm.Active() {
+		t.Fatal("quotas should be active")
+	}
+
+	first := quotaWorkunit("alice", 0)
+	qm.CheckedOut(first, "gpu")
+	qm.CheckedOut(first, "gpu") // counted once
+	if n := qm.Workunits("alice", ""); n != 1 {
+		t.Errorf("expected 1 workunit in progress, got %d", n)
+	}
+	if reasons := qm.Held("alice", "cpu"); len(reasons) != 0 {
+		t.Errorf("alice should not be held on cpu: %v", reasons)
+	}
+	if reasons := qm.Held("alice", "gpu"); len(reasons) != 1 {
+		t.Errorf("alice should be held by the gpu quota: %v", reasons)
+	}
+
+	qm.CheckedOut(quotaWorkunit("alice", 1), "cpu")
+	if reasons := qm.Held("alice", "cpu"); len(reasons) != 1 {
+		t.Errorf("alice should be held by the user quota: %v", reasons)
+	}
+	if reasons := qm.Held("bob", "gpu"); len(reasons) != 0 {
+		t.Errorf("bob has no workunits in progress: %v", reasons)
+	}
+
+	qm.Released(first.Workunit_Unique_Identifier)
+	qm.Released(first.Workunit_Unique_Identifier) // released once
+	if qm.Workunits("alice", "") != 1 || qm.Workunits("alice", "gpu") != 0 {
+		t.Errorf("wrong counts after release: %d, %d", qm.Workunits("alice", ""), qm.Workunits("alice", "gpu"))
+	}
+	if reasons := qm.Held("alice", "gpu"); len(reasons) != 0 {
+		t.Errorf("alice should not be held after the release: %v", reasons)
+	}
+
+	// workunits without owner are not counted
+	qm.CheckedOut(quotaWorkunit("", 2), "gpu")
+	if n := qm.Workunits("", ""); n != 0 {
+		t.Errorf("workunit without owner counted")
+	}
+}
+
+func TestQuotaCPUHours(t *testing.T) {
+	now := time.Date(2020, 3, 1, 23, 0, 0, 0, time.UTC)
+	qm := NewQuotaMgr([]*Quota{{Type: QUOTA_USER, Name: "alice", MaxCPUHours: 2}})
+	qm.Clock = func() time.Time { return now }
+
+	qm.AddCPUTime("alice", "gpu", 3600)
+	if h := qm.CPUHours("alice", ""); h != 1 {
+		t.Errorf("expected 1 CPU-hour, got %f", h)
+	}
+	if h := qm.CPUHours("alice", "gpu"); h != 1 {
+		t.Errorf("expected 1 CPU-hour on gpu, got %f", h)
+	}
+	if reasons := qm.Held("alice", ""); len(reasons) != 0 {
+		t.Errorf("alice should not be held: %v", reasons)
+	}
+	qm.AddCPUTime("alice", "", 3600)
+	if reasons := qm.Held("alice", ""); len(reasons) != 1 {
+		t.Errorf("alice should be held by the CPU-hours: %v", reasons)
+	}
+
+	// the day is in UTC, 23:30 in UTC-2 is still the same day
+	now = time.Date(2020, 3, 1, 21, 30, 0, 0, time.FixedZone("UTC-2", -2*3600))
+	if h := qm.CPUHours("alice", ""); h != 2 {
+		t.Errorf("expected 2 CPU-hours before midnight UTC, got %f", h)
+	}
+	now = time.Date(2020, 3, 2, 0, 0, 1, 0, time.UTC)
+	if h := qm.CPUHours("alice", ""); h != 0 {
+		t.Errorf("expected no CPU-hours on the next day, got %f", h)
+	}
+	if reasons := qm.Held("alice", ""); len(reasons) != 0 {
+		t.Errorf("alice should not be held on the next day: %v", reasons)
+	}
+}
+
+func TestQuotaCheckSubmission(t *testing.T) {
+	qm := NewQuotaMgr([]*Quota{
+		{Type: QUOTA_USER, Name: "alice", MaxQueuedJobs: 3},
+		{Type: QUOTA_CLIENTGROUP, Name: "gpu", MaxQueuedJobs: 1},
+	})
+	queued := map[string]int{QUOTA_USER: 2, QUOTA_CLIENTGROUP: 1}
+	qm.CountQueuedJobs = func(user string, q *Quota) (count int, err error) {
+		if user != "alice" {
+			return
+		}
+		count = queued[q.Type]
+		return
+	}
+
+	if err := qm.CheckSubmission("alice", "cpu"); err != nil {
+		t.Errorf("alice can submit to cpu: %s", err.Error())
+	}
+	if err := qm.CheckSubmission("alice", "cpu,gpu"); err == nil {
+		t.Errorf("expected the gpu quota to be exceeded")
+	}
+	queued[QUOTA_USER] = 3
+	if err := qm.CheckSubmission("alice", ""); err == nil {
+		t.Errorf("expected the user quota to be exceeded")
+	}
+
+	// server defaults apply to users without a quota
+	conf.QUOTA_QUEUED_JOBS = 1
+	defer func() { conf.QUOTA_QUEUED_JOBS = 0 }()
+	if err := qm.CheckSubmission("bob", ""); err != nil {
+		t.Errorf("bob has no unfinished jobs: %s", err.Error())
+	}
+	qm.CountQueuedJobs = func(user string, q *Quota) (int, error) { return 1, nil }
+	if err := qm.CheckSubmission("bob", ""); err == nil {
+		t.Errorf("expected the default quota to be exceeded")
+	}
+}
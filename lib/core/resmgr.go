@@ -1,6 +1,8 @@
 package core
 
 import (
+	"time"
+
 	"github.com/MG-RAST/AWE/lib/user"
 )
 
@@ -26,13 +28,14 @@ type ClientMgr interface {
 	ClientChecker()
 	UpdateSubClients(string, int) error
 	UpdateSubClientsByUser(string, int, *user.User)
+	SendClientCommandByUser(string, string, string, *user.User) (*ClientCommand, error)
 }
 
 type WorkMgr interface {
 	GetWorkById(Workunit_Unique_Identifier) (*Workunit, error)
 	ShowWorkunits(string) ([]*Workunit, error)
 	ShowWorkunitsByUser(string, *user.User) []*Workunit
-	CheckoutWorkunits(string, string, *Client, int64, int) ([]*Workunit, error)
+	CheckoutWorkunits(string, *AgingPolicy, string, *Client, int64, *WorkResources, int, time.Duration) ([]*Workunit, error)
 	NotifyWorkStatus(Notice)
 	EnqueueWorkunit(*Workunit) error
 	FetchDataToken(Workunit_Unique_Identifier, string) (string, error)
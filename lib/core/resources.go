@@ -0,0 +1,115 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/MG-RAST/AWE/lib/core/cwl"
+)
+
+// WorkResources resources a workunit needs to run, zero means no requirement
+type WorkResources struct {
+	Cores    int64 `bson:"cores,omitempty" json:"cores,omitempty" mapstructure:"cores,omitempty"`
+	RAM      int64 `bson:"ram_mb,omitempty" json:"ram_mb,omitempty" mapstructure:"ram_mb,omitempty"`    // MiB
+	Disk     int64 `bson:"disk_mb,omitempty" json:"disk_mb,omitempty" mapstructure:"disk_mb,omitempty"` // MiB, tmpdirMin + outdirMin
+	CoresMax int64 `bson:"cores_max,omitempty" json:"cores_max,omitempty" mapstructure:"cores_max,omitempty"`
+	RAMMax   int64 `bson:"ram_max_mb,omitempty" json:"ram_max_mb,omitempty" mapstructure:"ram_max_mb,omitempty"` // MiB
+	Tmpdir   int64 `bson:"tmpdir_mb,omitempty" json:"tmpdir_mb,omitempty" mapstructure:"tmpdir_mb,omitempty"`    // MiB, tmpdirMax or tmpdirMin, size of /tmp in the container
+}
+
+// NewWorkResources reads the minimums and maximums of a CWL ResourceRequirement, a requirement has precedence over a hint.
+// The requirement has to be evaluated already. Returns nil if there is nothing to check.
+func NewWorkResources(requirements []cwl.Requirement, hints []cwl.Requirement) (r *WorkResources, err error) {
+
+	rr := cwl.GetResourceRequirement(requirements)
+	if rr == nil {
+		rr = cwl.GetResourceRequirement(hints)
+	}
+	if rr == nil {
+		return
+	}
+
+	r = &WorkResources{}
+
+	r.Cores, _, err = cwl.GetResourceValue(rr.CoresMin)
+	if err != nil {
+		err = fmt.Errorf("(NewWorkResources) coresMin: %s", err.Error())
+		return
+	}
+	r.RAM, _, err = cwl.GetResourceValue(rr.RamMin)
+	if err != nil {
+		err = fmt.Errorf("(NewWorkResources) ramMin: %s", err.Error())
+		return
+	}
+	var tmpdir, outdir int64
+	tmpdir, _, err = cwl.GetResourceValue(rr.TmpdirMin)
+	if err != nil {
+		err = fmt.Errorf("(NewWorkResources) tmpdirMin: %s", err.Error())
+		return
+	}
+	outdir, _, err = cwl.GetResourceValue(rr.OutdirMin)
+	if err != nil {
+		err = fmt.Errorf("(NewWorkResources) outdirMin: %s", err.Error())
+		return
+	}
+	r.Disk = tmpdir + outdir
+
+	r.CoresMax, _, err = cwl.GetResourceValue(rr.CoresMax)
+	if err != nil {
+		err = fmt.Errorf("(NewWorkResources) coresMax: %s", err.Error())
+		return
+	}
+	r.RAMMax, _, err = cwl.GetResourceValue(rr.RamMax)
+	if err != nil {
+		err = fmt.Errorf("(NewWorkResources) ramMax: %s", err.Error())
+		return
+	}
+	r.Tmpdir, _, err = cwl.GetResourceValue(rr.TmpdirMax)
+	if err != nil {
+		err = fmt.Errorf("(NewWorkResources) tmpdirMax: %s", err.Error())
+		return
+	}
+	if r.Tmpdir <= 0 {
+		r.Tmpdir = tmpdir
+	}
+
+	if r.Cores <= 0 && r.RAM <= 0 && r.Disk <= 0 && r.CoresMax <= 0 && r.RAMMax <= 0 {
+		r = nil
+	}
+	return
+}
+
+// Fits checks the resources of the client, resources the client does not report (e.g. older workers) are not checked.
+// free are the cores and RAM not yet reserved on a multi-slot worker (negative if unknown), if nil the totals of the client are used.
+func (r *WorkResources) Fits(client *Client, free *WorkResources) (ok bool, reason string) {
+	if r == nil {
+		ok = true
+		return
+	}
+
+	cores := int64(client.CPUs)
+	ram := client.Memory
+	checkCores := cores > 0
+	checkRAM := ram > 0
+	if free != nil {
+		cores = free.Cores
+		ram = free.RAM
+		checkCores = cores >= 0
+		checkRAM = ram >= 0
+	}
+	disk := client.DiskFree
+
+	if r.Cores > 0 && checkCores && r.Cores > cores {
+		reason = fmt.Sprintf("requires %d cores, client has %d", r.Cores, cores)
+		return
+	}
+	if r.RAM > 0 && checkRAM && r.RAM > ram {
+		reason = fmt.Sprintf("requires %d MiB RAM, client has %d MiB", r.RAM, ram)
+		return
+	}
+	if r.Disk > 0 && disk > 0 && r.Disk > disk {
+		reason = fmt.Sprintf("requires %d MiB disk, client has %d MiB free", r.Disk, disk)
+		return
+	}
+	ok = true
+	return
+}
@@ -0,0 +1,30 @@
+package core_test
+
+import (
+	"testing"
+
+	. "github.com/MG-RAST/AWE/lib/core"
+	"github.com/MG-RAST/AWE/lib/core/cwl"
+)
+
+func TestNewWorkResources(t *testing.T) {
+	requirements := []cwl.Requirement{&cwl.ResourceRequirement{CoresMin: 2, CoresMax: 4, RamMin: 1024, RamMax: 2048.5, TmpdirMin: 100, OutdirMin: 50}}
+
+	r, err := NewWorkResources(requirements, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := WorkResources{Cores: 2, RAM: 1024, Disk: 150, CoresMax: 4, RAMMax: 2049, Tmpdir: 100}
+	if r == nil || *r != expected {
+		t.Fatalf("expected %+v, got %+v", expected, r)
+	}
+
+	// maximums alone are enough to set limits
+	r, err = NewWorkResources(nil, []cwl.Requirement{&cwl.ResourceRequirement{RamMax: 512}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r == nil || r.RAMMax != 512 {
+		t.Fatalf("expected ramMax 512, got %+v", r)
+	}
+}
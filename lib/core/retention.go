@@ -0,0 +1,415 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/MG-RAST/AWE/lib/conf"
+	"github.com/MG-RAST/AWE/lib/logger"
+	"github.com/MG-RAST/AWE/lib/logger/event"
+	"github.com/MG-RAST/AWE/lib/storage"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// data retention policies of CWL jobs (info.retention), any other value is a time after completion, e.g. 7D
+const (
+	RETENTION_ALL     = "all"     // keep all step outputs until the job is deleted
+	RETENTION_OUTPUTS = "outputs" // keep only the workflow outputs, intermediate step outputs are deleted on completion
+)
+
+// only one collection at a time, a job can be collected on completion and by the reaper
+var retentionLock sync.Mutex
+
+// RetentionItem one file considered by the collection
+type RetentionItem struct {
+	Location string `json:"location"`
+	Task     string `json:"task,omitempty"`
+	Size     int64  `json:"size"`
+	Reason   string `json:"reason,omitempty"` // why the file is kept, or why the deletion failed
+}
+
+// RetentionReport result of CollectIntermediates, with dry_run nothing has been deleted
+type RetentionReport struct {
+	JobID            string           `json:"job_id"`
+	Policy           string           `json:"policy"`
+	RetainUntil      time.Time        `json:"retain_until,omitempty"`
+	Deleted          time.Time        `json:"intermediates_deleted,omitempty"` // set if the intermediates have been deleted before
+	DryRun           bool             `json:"dry_run"`
+	Removed          []*RetentionItem `json:"removed"`
+	Kept             []*RetentionItem `json:"kept"`
+	Failed           []*RetentionItem `json:"failed,omitempty"`
+	Bytes            int64            `json:"bytes"`              // reclaimed by the removed files
+	CallCacheEntries int              `json:"call_cache_entries"` // entries purged because their outputs are removed
+}
+
+// ValidateRetention _
+func ValidateRetention(retention string) (err error) {
+	if retention == "" || retention == RETENTION_ALL || retention == RETENTION_OUTPUTS {
+		return
+	}
+	if _, xerr := ExpireDuration(retention); xerr != nil {
+		err = fmt.Errorf("retention must be %s, %s or a number and unit of time (M, H or D), e.g. 7D", RETENTION_ALL, RETENTION_OUTPUTS)
+	}
+	return
+}
+
+// GetRetention retention policy of the job, server default (data_retention) if the job has none
+func (job *Job) GetRetention() string {
+	if job.Info != nil && job.Info.Retention != "" {
+		return job.Info.Retention
+	}
+	return conf.DATA_RETENTION
+}
+
+// retentionDelay time after completion the intermediates are kept, keepAll is true for policy all
+func retentionDelay(policy string) (delay time.Duration, keepAll bool, err error) {
+	switch policy {
+	case RETENTION_ALL:
+		keepAll = true
+		return
+	case RETENTION_OUTPUTS:
+		return
+	}
+	delay, err = ExpireDuration(policy)
+	return
+}
+
+// SetRetention changes the retention policy of the job, for completed jobs the deadline is computed from the completion time
+func (job *Job) SetRetention(policy string) (err error) {
+	err = ValidateRetention(policy)
+	if err != nil {
+		return
+	}
+	err = DbUpdateJobField(job.ID, "info.retention", policy)
+	if err != nil {
+		err = fmt.Errorf("(SetRetention) DbUpdateJobField returned: %s", err.Error())
+		return
+	}
+	job.Info.Retention = policy
+
+	var state string
+	state, err = job.GetState(true)
+	if err != nil {
+		err = fmt.Errorf("(SetRetention) job.GetState returned: %s", err.Error())
+		return
+	}
+	if state != JOB_STAT_COMPLETED {
+		return
+	}
+	err = job.setRetainUntil(job.Info.CompletedTime)
+	if err != nil {
+		err = fmt.Errorf("(SetRetention) %s", err.Error())
+	}
+	return
+}
+
+// setRetainUntil sets the time the intermediates are deleted after, removes it for policy all
+func (job *Job) setRetainUntil(completed time.Time) (err error) {
+	var delay time.Duration
+	var keepAll bool
+	delay, keepAll, err = retentionDelay(job.GetRetention())
+	if err != nil {
+		err = fmt.Errorf("(setRetainUntil) retentionDelay returned: %s", err.Error())
+		return
+	}
+	retainUntil := time.Time{}
+	if !keepAll {
+		retainUntil = completed.Add(delay)
+	}
+	err = dbUpdateJobFieldTime(job.ID, "retain_until", retainUntil)
+	if err != nil {
+		err = fmt.Errorf("(setRetainUntil) dbUpdateJobFieldTime returned: %s", err.Error())
+		return
+	}
+	job.RetainUntil = retainUntil
+	return
+}
+
+// scheduleRetention is called when a CWL job completes, with policy outputs the intermediates are deleted right away
+func (job *Job) scheduleRetention() (err error) {
+	if !job.IsCWL {
+		return
+	}
+	completed := time.Now()
+	if job.Info != nil && !job.Info.CompletedTime.IsZero() {
+		completed = job.Info.CompletedTime
+	}
+	err = job.setRetainUntil(completed)
+	if err != nil {
+		err = fmt.Errorf("(scheduleRetention) %s", err.Error())
+		return
+	}
+	if !job.RetainUntil.IsZero() && !job.RetainUntil.After(time.Now()) {
+		go collectJobIntermediates(job.ID)
+	}
+	return
+}
+
+// collectJobIntermediates deletes the intermediates of a completed job, errors are only logged
+func collectJobIntermediates(jobID string) {
+	job, err := GetJob(jobID)
+	if err != nil {
+		logger.Error("(collectJobIntermediates) GetJob returned: %s", err.Error())
+		return
+	}
+	report, err := CollectIntermediates(job, false)
+	if err != nil {
+		logger.Error("(collectJobIntermediates) job %s: %s", jobID, err.Error())
+		return
+	}
+	if len(report.Failed) > 0 {
+		logger.Error("(collectJobIntermediates) job %s: %d files could not be deleted, first: %s (%s)", jobID, len(report.Failed), report.Failed[0].Location, report.Failed[0].Reason)
+	}
+}
+
+// collectRetainedJobs deletes the intermediates of all completed jobs whose retention time has passed
+func collectRetainedJobs() {
+	query := bson.M{
+		"state":                 JOB_STAT_COMPLETED,
+		"retain_until":          bson.M{"$gt": time.Time{}, "$lt": time.Now()}, // zero time for policy all
+		"intermediates_deleted": bson.M{"$exists": false},
+	}
+	jobs := Jobs{}
+	err := jobs.GetAllUnsorted(query)
+	if err != nil {
+		logger.Error("(collectRetainedJobs) GetAllUnsorted returned: %s", err.Error())
+		return
+	}
+	for _, j := range jobs {
+		collectJobIntermediates(j.ID)
+	}
+}
+
+// retentionStorage storage of the job, the same the workers upload to (see cache.WorkunitStorage)
+func retentionStorage(job *Job) (store storage.Storage, err error) {
+	token := ""
+	if job.Info != nil {
+		token = job.Info.DataToken
+	}
+	if job.CWL_StorageRequirement != nil {
+		store, err = storage.New(job.CWL_StorageRequirement, token)
+		return
+	}
+	store = storage.NewShockStorage(job.ShockHost, token)
+	return
+}
+
+// CollectIntermediates deletes the step outputs of a CWL job that are not workflow inputs or outputs.
+// Outputs reused from the call cache belong to another job and outputs that other jobs have reused are kept,
+// call cache entries of deleted outputs are purged. With dryRun the report lists what would be removed.
+func CollectIntermediates(job *Job, dryRun bool) (report *RetentionReport, err error) {
+	if !job.IsCWL {
+		err = fmt.Errorf("(CollectIntermediates) job %s is not a CWL job", job.ID)
+		return
+	}
+
+	report = &RetentionReport{JobID: job.ID, Policy: job.GetRetention(), RetainUntil: job.RetainUntil, DryRun: dryRun, Removed: []*RetentionItem{}, Kept: []*RetentionItem{}}
+
+	if !dryRun {
+		retentionLock.Lock()
+		defer retentionLock.Unlock()
+
+		if report.Policy == RETENTION_ALL {
+			err = fmt.Errorf("(CollectIntermediates) retention policy of job %s is %s", job.ID, RETENTION_ALL)
+			return
+		}
+		var state string
+		state, err = job.GetState(true)
+		if err != nil {
+			err = fmt.Errorf("(CollectIntermediates) job.GetState returned: %s", err.Error())
+			return
+		}
+		if state != JOB_STAT_COMPLETED && state != JOB_STAT_DELETED {
+			err = fmt.Errorf("(CollectIntermediates) job %s is %s, intermediates are deleted only for completed jobs", job.ID, state)
+			return
+		}
+	}
+
+	// the job document may have been updated by another collection
+	report.Deleted, err = dbGetJobIntermediatesDeleted(job.ID)
+	if err != nil {
+		err = fmt.Errorf("(CollectIntermediates) %s", err.Error())
+		return
+	}
+	if !report.Deleted.IsZero() {
+		return
+	}
+
+	var entries CallCacheEntries
+	entries, err = LoadJobCallCacheEntries(job.ID)
+	if err != nil {
+		err = fmt.Errorf("(CollectIntermediates) %s", err.Error())
+		return
+	}
+
+	var store storage.Storage
+	store, err = retentionStorage(job)
+	if err != nil {
+		err = fmt.Errorf("(CollectIntermediates) storage: %s", err.Error())
+		return
+	}
+
+	var remove []*RetentionItem
+	report.Kept, remove = ClassifyIntermediates(job, entries, store)
+
+	// call cache entries must not point to deleted data, they are purged first
+	removeSet := map[string]bool{}
+	for _, item := range remove {
+		removeSet[item.Location] = true
+	}
+	for _, entry := range entries {
+		if entry.Hits > 0 {
+			continue
+		}
+		references := false
+		walkDataFiles(entry.Outputs, func(class string, location string, v map[string]interface{}) {
+			if removeSet[location] {
+				references = true
+			}
+		})
+		if !references {
+			continue
+		}
+		if dryRun {
+			report.CallCacheEntries++
+			continue
+		}
+		var removed bool
+		removed, err = entry.removeUnused()
+		if err != nil {
+			err = fmt.Errorf("(CollectIntermediates) %s", err.Error())
+			return
+		}
+		if removed {
+			report.CallCacheEntries++
+			continue
+		}
+		// reused in the meantime
+		walkDataFiles(entry.Outputs, func(class string, location string, v map[string]interface{}) {
+			removeSet[location] = false
+		})
+	}
+
+	for _, item := range remove {
+		if !removeSet[item.Location] {
+			item.Reason = "reused by other jobs from the call cache"
+			report.Kept = append(report.Kept, item)
+			continue
+		}
+		if !dryRun {
+			if xerr := store.(storage.Deleter).Delete(item.Location); xerr != nil {
+				item.Reason = xerr.Error()
+				report.Failed = append(report.Failed, item)
+				continue
+			}
+		}
+		report.Removed = append(report.Removed, item)
+		report.Bytes += item.Size
+	}
+
+	if dryRun {
+		return
+	}
+
+	report.Deleted = time.Now()
+	err = dbUpdateJobFieldTime(job.ID, "intermediates_deleted", report.Deleted)
+	if err != nil {
+		err = fmt.Errorf("(CollectIntermediates) dbUpdateJobFieldTime returned: %s", err.Error())
+		return
+	}
+	job.IntermediatesDeleted = report.Deleted
+	logger.Event(event.JOB_DATA_COLLECTED, fmt.Sprintf("jobid=%s;files=%d;bytes=%d;failed=%d", job.ID, len(report.Removed), report.Bytes, len(report.Failed)))
+	return
+}
+
+// ClassifyIntermediates splits the intermediate files of the job into the ones that are kept, with the reason,
+// and the ones that can be removed from store. entries are the call cache entries of the job.
+func ClassifyIntermediates(job *Job, entries CallCacheEntries, store storage.Storage) (kept []*RetentionItem, remove []*RetentionItem) {
+	kept = []*RetentionItem{}
+	_, canDelete := store.(storage.Deleter)
+
+	// outputs of call cache entries that have been reused by other jobs are kept
+	reused := map[string]bool{}
+	for _, entry := range entries {
+		if entry.Hits == 0 {
+			continue
+		}
+		walkDataFiles(entry.Outputs, func(class string, location string, v map[string]interface{}) {
+			reused[location] = true
+		})
+	}
+
+	for _, item := range job.intermediateFiles() {
+		switch {
+		case item.Reason != "":
+		case reused[item.Location]:
+			item.Reason = "reused by other jobs from the call cache"
+		case !store.Owns(item.Location):
+			item.Reason = "not in the storage of the job"
+		case !canDelete:
+			item.Reason = "storage of the job does not support deletion, data may be shared between jobs"
+		}
+		if item.Reason != "" {
+			kept = append(kept, item)
+			continue
+		}
+		remove = append(remove, item)
+	}
+	return
+}
+
+// intermediateFiles files in the step outputs of the job, files that are workflow inputs or outputs or
+// that have been reused from the call cache already have a reason to be kept
+func (job *Job) intermediateFiles() (files []*RetentionItem) {
+	job.RLockRecursive()
+	defer job.RUnlockRecursive()
+
+	keep := map[string]string{}
+	walkDataFiles(job.CWL_job_input, func(class string, location string, v map[string]interface{}) {
+		keep[location] = "workflow input"
+	})
+	if rootWI, ok := job.WorkflowInstancesMap[job.Entrypoint]; ok {
+		walkDataFiles(rootWI.Inputs, func(class string, location string, v map[string]interface{}) {
+			keep[location] = "workflow input"
+		})
+		walkDataFiles(rootWI.Outputs, func(class string, location string, v map[string]interface{}) {
+			keep[location] = "workflow output"
+		})
+	}
+
+	// sorted so that reports do not change between requests
+	var wiNames []string
+	for localID := range job.WorkflowInstancesMap {
+		wiNames = append(wiNames, localID)
+	}
+	sort.Strings(wiNames)
+
+	seen := map[string]bool{}
+	for _, localID := range wiNames {
+		wi := job.WorkflowInstancesMap[localID]
+		for _, task := range wi.Tasks {
+			var outputs interface{} = task.StepOutputInterface
+			if task.StepOutput != nil {
+				outputs = task.StepOutput
+			}
+			walkDataFiles(outputs, func(class string, location string, v map[string]interface{}) {
+				// the files of a Directory are in its listing
+				if class != "File" || seen[location] {
+					return
+				}
+				seen[location] = true
+				item := &RetentionItem{Location: location, Task: task.TaskName, Reason: keep[location]}
+				if size, ok := v["size"].(float64); ok {
+					item.Size = int64(size)
+				}
+				if item.Reason == "" && task.ReusedFrom != "" {
+					item.Reason = "reused from the call cache (job " + task.ReusedFrom + ")"
+				}
+				files = append(files, item)
+			})
+		}
+	}
+	return
+}
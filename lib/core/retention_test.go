@@ -0,0 +1,109 @@
+package core_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/MG-RAST/AWE/lib/core"
+	"github.com/MG-RAST/AWE/lib/core/cwl"
+	"github.com/MG-RAST/AWE/lib/storage"
+)
+
+func TestValidateRetention(t *testing.T) {
+	for _, retention := range []string{"", RETENTION_ALL, RETENTION_OUTPUTS, "7D", "12H", "30M"} {
+		if err := ValidateRetention(retention); err != nil {
+			t.Errorf("%s: %s", retention, err.Error())
+		}
+	}
+	for _, retention := range []string{"none", "7", "D", "7W", "-1D"} {
+		if err := ValidateRetention(retention); err == nil {
+			t.Errorf("%s: expected error", retention)
+		}
+	}
+
+	d, err := ExpireDuration("2D")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != 48*time.Hour {
+		t.Errorf("expected 48h, got %s", d)
+	}
+}
+
+func retentionFile(location string) *cwl.File {
+	file := cwl.NewFile()
+	file.Location = location
+	return file
+}
+
+func retentionJob() *Job {
+	job := NewJob()
+	job.ID = "job1"
+	job.IsCWL = true
+	job.Entrypoint = "#main"
+
+	first := &Task{}
+	first.TaskName = "#main/first"
+	first.StepOutput = &cwl.Job_document{
+		cwl.NewNamedCWLType("#main/first/input", retentionFile("http://shock/node/input")),
+		cwl.NewNamedCWLType("#main/first/tmp", retentionFile("http://shock/node/tmp")),
+		cwl.NewNamedCWLType("#main/first/hit", retentionFile("http://shock/node/hit")),
+		cwl.NewNamedCWLType("#main/first/other", retentionFile("http://other/node/other")),
+	}
+	last := &Task{}
+	last.TaskName = "#main/last"
+	last.StepOutput = &cwl.Job_document{cwl.NewNamedCWLType("#main/last/output", retentionFile("http://shock/node/output"))}
+	cached := &Task{}
+	cached.TaskName = "#main/cached"
+	cached.ReusedFrom = "job0"
+	cached.StepOutput = &cwl.Job_document{cwl.NewNamedCWLType("#main/cached/output", retentionFile("http://shock/node/cached"))}
+
+	wi := &WorkflowInstance{LocalID: "#main", JobID: job.ID, Tasks: []*Task{first, last, cached}}
+	wi.Inputs = cwl.Job_document{cwl.NewNamedCWLType("#main/input", retentionFile("http://shock/node/input"))}
+	wi.Outputs = cwl.Job_document{cwl.NewNamedCWLType("#main/output", retentionFile("http://shock/node/output"))}
+	job.WorkflowInstancesMap = map[string]*WorkflowInstance{"#main": wi}
+	return job
+}
+
+// sharedStorage hides the Delete method of the storage
+type sharedStorage struct {
+	storage.Storage
+}
+
+func TestClassifyIntermediates(t *testing.T) {
+	job := retentionJob()
+	entries := CallCacheEntries{
+		{JobID: job.ID, Hits: 1, Outputs: cwl.Job_document{cwl.NewNamedCWLType("hit", retentionFile("http://shock/node/hit"))}},
+		{JobID: job.ID, Hits: 0, Outputs: cwl.Job_document{cwl.NewNamedCWLType("tmp", retentionFile("http://shock/node/tmp"))}},
+	}
+
+	kept, remove := ClassifyIntermediates(job, entries, storage.NewShockStorage("http://shock", ""))
+	reasons := map[string]string{}
+	for _, item := range kept {
+		reasons[item.Location] = item.Reason
+	}
+	expected := map[string]string{
+		"http://shock/node/input":  "workflow input",
+		"http://shock/node/output": "workflow output",
+		"http://shock/node/hit":    "reused by other jobs from the call cache",
+		"http://other/node/other":  "not in the storage of the job",
+		"http://shock/node/cached": "reused from the call cache (job job0)",
+	}
+	for location, reason := range expected {
+		if reasons[location] != reason {
+			t.Errorf("%s: expected reason \"%s\", got \"%s\"", location, reason, reasons[location])
+		}
+	}
+	if len(kept) != len(expected) {
+		t.Errorf("expected %d files kept, got %d", len(expected), len(kept))
+	}
+	if len(remove) != 1 || remove[0].Location != "http://shock/node/tmp" || remove[0].Task != "#main/first" {
+		t.Errorf("expected only the tmp file of #main/first to be removed, got %v", remove)
+	}
+
+	// nothing is removed from a storage that cannot delete the data of one job
+	kept, remove = ClassifyIntermediates(job, entries, sharedStorage{storage.NewShockStorage("http://shock", "")})
+	if len(remove) != 0 || len(kept) != 6 {
+		t.Errorf("expected all 6 files kept, got %d kept and %d removed", len(kept), len(remove))
+	}
+}
@@ -0,0 +1,129 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MG-RAST/AWE/lib/core/cwl"
+)
+
+// failure types reported by the worker with a failed workunit
+const (
+	FAILURE_APP            = "app"            // the tool exited with a non-zero exit code
+	FAILURE_INFRASTRUCTURE = "infrastructure" // download, upload, container or environment setup failed
+	FAILURE_TIMEOUT        = "timeout"        // the tool was killed after the time limit of the CWL ToolTimeLimit
+	FAILURE_OOM            = "oom"            // the container was killed by the kernel after reaching its memory limit (ramMax of the ResourceRequirement)
+)
+
+// RETRY_BACKOFF_LIMIT upper limit of the retry delay in seconds (one week), also keeps the doubling from overflowing
+const RETRY_BACKOFF_LIMIT = 7 * 24 * 3600
+
+// RetryPolicy decides if a failed workunit is requeued, from the job document (task "retry") or the CWL hint RetryRequirement
+type RetryPolicy struct {
+	MaxAttempts int      `bson:"max_attempts,omitempty" json:"max_attempts,omitempty" mapstructure:"max_attempts,omitempty"` // total number of attempts, 0 uses max_work_failure
+	Backoff     int      `bson:"backoff,omitempty" json:"backoff,omitempty" mapstructure:"backoff,omitempty"`                // seconds before the first retry, doubled with every failed attempt
+	MaxBackoff  int      `bson:"max_backoff,omitempty" json:"max_backoff,omitempty" mapstructure:"max_backoff,omitempty"`    // upper limit of the delay in seconds, 0 means RETRY_BACKOFF_LIMIT
+	ExitCodes   []int    `bson:"exit_codes,omitempty" json:"exit_codes,omitempty" mapstructure:"exit_codes,omitempty"`       // app failures are only retried for these exit codes, empty means all
+	RetryOn     []string `bson:"retry_on,omitempty" json:"retry_on,omitempty" mapstructure:"retry_on,omitempty"`             // failure types that are retried (app, infrastructure), empty means all
+}
+
+// NewRetryPolicyFromCWL reads the RetryRequirement of a tool, a requirement has precedence over a hint. Returns nil if there is none.
+func NewRetryPolicyFromCWL(requirements []cwl.Requirement, hints []cwl.Requirement) (p *RetryPolicy, err error) {
+	rr := cwl.GetRetryRequirement(requirements)
+	if rr == nil {
+		rr = cwl.GetRetryRequirement(hints)
+	}
+	if rr == nil {
+		return
+	}
+	p = &RetryPolicy{
+		MaxAttempts: rr.MaxAttempts,
+		Backoff:     rr.Backoff,
+		MaxBackoff:  rr.MaxBackoff,
+		ExitCodes:   rr.ExitCodes,
+		RetryOn:     rr.RetryOn,
+	}
+	err = p.Validate()
+	if err != nil {
+		err = fmt.Errorf("(NewRetryPolicyFromCWL) %s", err.Error())
+		p = nil
+	}
+	return
+}
+
+// Validate _
+func (p *RetryPolicy) Validate() (err error) {
+	if p.MaxAttempts < 0 || p.Backoff < 0 || p.MaxBackoff < 0 {
+		err = fmt.Errorf("(RetryPolicy/Validate) max_attempts, backoff and max_backoff must not be negative")
+		return
+	}
+	for _, failure := range p.RetryOn {
+		if failure != FAILURE_APP && failure != FAILURE_INFRASTRUCTURE && failure != FAILURE_TIMEOUT && failure != FAILURE_OOM {
+			err = fmt.Errorf("(RetryPolicy/Validate) unknown failure type \"%s\" in retry_on (supported: %s, %s, %s, %s)", failure, FAILURE_APP, FAILURE_INFRASTRUCTURE, FAILURE_TIMEOUT, FAILURE_OOM)
+			return
+		}
+	}
+	return
+}
+
+// Check decides if a workunit that has failed the given number of times is retried and how long to wait before it is queued again.
+// The policy may be nil, then maxFailure (from max_work_failure) applies to all failures. note describes the decision for the workunit notes.
+func (p *RetryPolicy) Check(failed int, maxFailure int, failure string, exitStatus int) (retry bool, delay time.Duration, note string) {
+	if failure == "" {
+		failure = FAILURE_APP
+	}
+	what := fmt.Sprintf("attempt %d failed (%s error", failed, failure)
+	if failure == FAILURE_APP {
+		what += fmt.Sprintf(", exit code %d", exitStatus)
+	}
+	what += ")"
+
+	maxAttempts := maxFailure
+	if p != nil && p.MaxAttempts > 0 {
+		maxAttempts = p.MaxAttempts
+	}
+
+	if failed >= maxAttempts {
+		note = fmt.Sprintf("[retry] %s, %d of %d attempts used, not retried", what, failed, maxAttempts)
+		return
+	}
+
+	if p != nil {
+		if len(p.RetryOn) > 0 && !contains(p.RetryOn, failure) {
+			note = fmt.Sprintf("[retry] %s, policy retries only %s errors, not retried", what, strings.Join(p.RetryOn, ", "))
+			return
+		}
+		if failure == FAILURE_APP && len(p.ExitCodes) > 0 && !containsInt(p.ExitCodes, exitStatus) {
+			note = fmt.Sprintf("[retry] %s, exit code not in %v, not retried", what, p.ExitCodes)
+			return
+		}
+		if p.Backoff > 0 {
+			limit := RETRY_BACKOFF_LIMIT
+			if p.MaxBackoff > 0 && p.MaxBackoff < limit {
+				limit = p.MaxBackoff
+			}
+			seconds := p.Backoff
+			for i := 1; i < failed && seconds < limit; i++ {
+				seconds = seconds * 2
+			}
+			if seconds > limit {
+				seconds = limit
+			}
+			delay = time.Duration(seconds) * time.Second
+		}
+	}
+
+	retry = true
+	note = fmt.Sprintf("[retry] %s, requeued (attempt %d of %d) after %s", what, failed+1, maxAttempts, delay)
+	return
+}
+
+func containsInt(list []int, elem int) bool {
+	for _, t := range list {
+		if t == elem {
+			return true
+		}
+	}
+	return false
+}
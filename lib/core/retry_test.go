@@ -0,0 +1,86 @@
+package core_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/MG-RAST/AWE/lib/core"
+)
+
+func TestRetryPolicyDefault(t *testing.T) {
+	var policy *RetryPolicy
+
+	retry, delay, _ := policy.Check(1, 3, FAILURE_APP, 1)
+	if !retry || delay != 0 {
+		t.Fatalf("expected immediate retry, got retry=%t delay=%s", retry, delay)
+	}
+	retry, _, _ = policy.Check(3, 3, FAILURE_APP, 1)
+	if retry {
+		t.Fatal("expected no retry after max_work_failure attempts")
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := &RetryPolicy{MaxAttempts: 5, Backoff: 10, MaxBackoff: 30}
+
+	expected := []time.Duration{10 * time.Second, 20 * time.Second, 30 * time.Second, 30 * time.Second}
+	for i, want := range expected {
+		retry, delay, note := policy.Check(i+1, 1, FAILURE_INFRASTRUCTURE, -1)
+		if !retry {
+			t.Fatalf("attempt %d: expected retry (%s)", i+1, note)
+		}
+		if delay != want {
+			t.Fatalf("attempt %d: expected delay %s, got %s", i+1, want, delay)
+		}
+	}
+	retry, _, _ := policy.Check(5, 1, FAILURE_INFRASTRUCTURE, -1)
+	if retry {
+		t.Fatal("expected no retry after max_attempts")
+	}
+}
+
+func TestRetryPolicyBackoffLimit(t *testing.T) {
+	policy := &RetryPolicy{MaxAttempts: 1000, Backoff: 60}
+
+	for _, failed := range []int{70, 999} {
+		retry, delay, note := policy.Check(failed, 1, FAILURE_APP, 1)
+		if !retry {
+			t.Fatalf("attempt %d: expected retry (%s)", failed, note)
+		}
+		if delay != RETRY_BACKOFF_LIMIT*time.Second {
+			t.Fatalf("attempt %d: expected delay %s, got %s", failed, RETRY_BACKOFF_LIMIT*time.Second, delay)
+		}
+	}
+}
+
+func TestRetryPolicyClassification(t *testing.T) {
+	policy := &RetryPolicy{MaxAttempts: 3, ExitCodes: []int{137}}
+
+	if retry, _, note := policy.Check(1, 1, FAILURE_APP, 1); retry {
+		t.Fatalf("exit code 1 should not be retried (%s)", note)
+	}
+	if retry, _, note := policy.Check(1, 1, FAILURE_APP, 137); !retry {
+		t.Fatalf("exit code 137 should be retried (%s)", note)
+	}
+	if retry, _, note := policy.Check(1, 1, FAILURE_INFRASTRUCTURE, -1); !retry {
+		t.Fatalf("infrastructure errors should be retried (%s)", note)
+	}
+
+	policy = &RetryPolicy{MaxAttempts: 3, RetryOn: []string{FAILURE_INFRASTRUCTURE}}
+	if retry, _, note := policy.Check(1, 1, FAILURE_APP, 137); retry {
+		t.Fatalf("app errors should not be retried (%s)", note)
+	}
+}
+
+func TestRetryPolicyOOM(t *testing.T) {
+	policy := &RetryPolicy{MaxAttempts: 3, RetryOn: []string{FAILURE_OOM}}
+	if err := policy.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if retry, _, note := policy.Check(1, 1, FAILURE_OOM, 137); !retry {
+		t.Fatalf("oom errors should be retried (%s)", note)
+	}
+	if retry, _, note := policy.Check(1, 1, FAILURE_APP, 137); retry {
+		t.Fatalf("app errors should not be retried (%s)", note)
+	}
+}
@@ -0,0 +1,576 @@
+package core
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MG-RAST/AWE/lib/conf"
+	"github.com/MG-RAST/AWE/lib/core/cwl"
+)
+
+// Export of the provenance of a CWL job as Workflow Run RO-Crate (Provenance Run Crate profile,
+// https://www.researchobject.org/workflow-run-crate/), the successor of CWLProv. The files are
+// laid out as in a CWLProv research object:
+//
+//   ro-crate-metadata.json           JSON-LD description of workflow, steps, runs, containers and workers
+//   workflow/packed.cwl              the packed workflow ($graph) as submitted
+//   workflow/primary-job.json        job inputs
+//   workflow/primary-output.json     job outputs
+//   provenance/workflow-instances.json  inputs and outputs of every (sub)workflow instance
+//   provenance/steps/<step>.json     resolved inputs, outputs, docker images, workers and timings of each step
+
+const (
+	roCrateContext  = "https://w3id.org/ro/crate/1.1/context"
+	roCrateSpec     = "https://w3id.org/ro/crate/1.1"
+	roCrateWorkflow = "workflow/packed.cwl"
+	roCrateJobFile  = "workflow/primary-job.json"
+	roCrateOutFile  = "workflow/primary-output.json"
+	roCrateWIFile   = "provenance/workflow-instances.json"
+	roCrateCWL      = "https://w3id.org/workflowhub/workflow-ro-crate#cwl"
+	roCrateEngine   = "#awe"
+)
+
+var roCrateProfiles = []string{
+	"https://w3id.org/ro/wfrun/process/0.4",
+	"https://w3id.org/ro/wfrun/workflow/0.4",
+	"https://w3id.org/ro/wfrun/provenance/0.4",
+	"https://w3id.org/workflowhub/workflow-ro-crate/1.0",
+}
+
+// roEntity a JSON-LD entity of the @graph of ro-crate-metadata.json
+type roEntity map[string]interface{}
+
+func roRef(id string) roEntity {
+	return roEntity{"@id": id}
+}
+
+func roTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// set adds a property, empty values are left out
+func (e roEntity) set(key string, value interface{}) {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return
+		}
+	case []roEntity:
+		if len(v) == 0 {
+			return
+		}
+	case nil:
+		return
+	}
+	e[key] = value
+}
+
+// ROCrateStep provenance of one step (task) of the job, written to provenance/steps/
+type ROCrateStep struct {
+	Task             string                 `json:"task"`
+	Step             string                 `json:"step,omitempty"`
+	WorkflowInstance string                 `json:"workflow_instance,omitempty"`
+	State            string                 `json:"state"`
+	CreatedDate      time.Time              `json:"created"`
+	StartedDate      time.Time              `json:"started"`
+	CompletedDate    time.Time              `json:"completed"`
+	ComputeTime      int                    `json:"computetime"`
+	Inputs           map[string]interface{} `json:"inputs,omitempty"`
+	InputsError      string                 `json:"inputs_error,omitempty"`
+	Outputs          map[string]interface{} `json:"outputs,omitempty"`
+	Workunits        map[string]*WorkPerf   `json:"workunits,omitempty"` // by rank, with worker, docker image and timings
+}
+
+// ROCrate provenance of a CWL job, write it with WriteZip
+type ROCrate struct {
+	Graph []roEntity
+
+	files     map[string]interface{} // json documents of the crate by path
+	fileNames []string
+	entities  map[string]roEntity // by @id, to avoid duplicates
+}
+
+func (crate *ROCrate) add(e roEntity) roEntity {
+	id := e["@id"].(string)
+	if existing, ok := crate.entities[id]; ok {
+		return existing
+	}
+	crate.entities[id] = e
+	crate.Graph = append(crate.Graph, e)
+	return e
+}
+
+func (crate *ROCrate) addFile(name string, content interface{}, description string) roEntity {
+	crate.files[name] = content
+	crate.fileNames = append(crate.fileNames, name)
+	e := roEntity{"@id": name, "@type": "File", "encodingFormat": "application/json"}
+	e.set("description", description)
+	return crate.add(e)
+}
+
+// NewROCrate collects the provenance of a CWL job. perf may be nil, per-workunit performance data is only
+// available with perf_log_workunit.
+func NewROCrate(job *Job, perf *JobPerf) (crate *ROCrate, err error) {
+	if !job.IsCWL || job.WorkflowContext == nil {
+		err = fmt.Errorf("(NewROCrate) job %s is not a CWL job", job.ID)
+		return
+	}
+
+	crate = &ROCrate{files: map[string]interface{}{}, entities: map[string]roEntity{}}
+
+	descriptor := crate.add(roEntity{"@id": "ro-crate-metadata.json", "@type": "CreativeWork"})
+	descriptor["conformsTo"] = roRef(roCrateSpec)
+	descriptor["about"] = roRef("./")
+
+	name := job.ID
+	if job.Info != nil && job.Info.Name != "" {
+		name = job.Info.Name
+	}
+
+	root := crate.add(roEntity{"@id": "./", "@type": "Dataset", "name": "Run of " + name, "datePublished": roTime(time.Now())})
+	var profiles []roEntity
+	for _, profile := range roCrateProfiles {
+		profiles = append(profiles, roRef(profile))
+		crate.add(roEntity{"@id": profile, "@type": "CreativeWork", "name": "Workflow Run Crate profile", "version": path.Base(profile)})
+	}
+	root["conformsTo"] = profiles
+	root["mainEntity"] = roRef(roCrateWorkflow)
+
+	// the workflow
+	crate.files[roCrateWorkflow] = &job.WorkflowContext.GraphDocument
+	crate.fileNames = append(crate.fileNames, roCrateWorkflow)
+	entrypoint := strings.TrimPrefix(job.Entrypoint, "#")
+	workflow := crate.add(roEntity{
+		"@id":                 roCrateWorkflow,
+		"@type":               []string{"File", "SoftwareSourceCode", "ComputationalWorkflow", "HowTo"},
+		"name":                name,
+		"programmingLanguage": roRef(roCrateCWL),
+	})
+	workflow.set("alternateName", entrypoint)
+	language := crate.add(roEntity{"@id": roCrateCWL, "@type": "ComputerLanguage", "name": "Common Workflow Language", "alternateName": "CWL"})
+	language.set("version", string(job.WorkflowContext.CwlVersion))
+	language["url"] = roRef("https://www.commonwl.org/")
+
+	engine := crate.add(roEntity{"@id": roCrateEngine, "@type": "SoftwareApplication", "name": "AWE"})
+	engine["url"] = roRef("https://github.com/MG-RAST/AWE")
+	engine.set("softwareVersion", conf.VERSION)
+
+	// workflow instances, sorted so that the crate does not change between exports
+	var wiNames []string
+	for localID := range job.WorkflowInstancesMap {
+		wiNames = append(wiNames, localID)
+	}
+	sort.Strings(wiNames)
+
+	var rootWI *WorkflowInstance
+	var instances []map[string]interface{}
+	for _, localID := range wiNames {
+		wi := job.WorkflowInstancesMap[localID]
+		if localID == job.Entrypoint {
+			rootWI = wi
+		}
+		instances = append(instances, map[string]interface{}{
+			"id":       localID,
+			"workflow": wi.WorkflowDefinition,
+			"state":    wi.State,
+			"inputs":   jobDocumentMap(&wi.Inputs),
+			"outputs":  jobDocumentMap(&wi.Outputs),
+		})
+	}
+
+	// job inputs and outputs
+	var jobInputs interface{} = job.CWL_job_input
+	var jobOutputs map[string]interface{}
+	if rootWI != nil {
+		jobInputs = jobDocumentMap(&rootWI.Inputs)
+		jobOutputs = jobDocumentMap(&rootWI.Outputs)
+	}
+	inputFile := crate.addFile(roCrateJobFile, jobInputs, "inputs of the job")
+	outputFile := crate.addFile(roCrateOutFile, jobOutputs, "outputs of the job")
+	crate.addFile(roCrateWIFile, instances, "inputs and outputs of all workflow instances")
+
+	run := crate.add(roEntity{"@id": "#" + job.ID, "@type": "CreateAction", "name": "Run of " + name})
+	run["instrument"] = roRef(roCrateWorkflow)
+	run["object"] = append([]roEntity{roRef(inputFile["@id"].(string))}, crate.dataFiles(jobInputs)...)
+	run["result"] = append([]roEntity{roRef(outputFile["@id"].(string))}, crate.dataFiles(jobOutputs)...)
+	run["actionStatus"] = roActionStatus(job.State)
+	if job.Info != nil {
+		run.set("startTime", roTime(job.Info.StartedTime))
+		run.set("endTime", roTime(job.Info.CompletedTime))
+	}
+
+	// steps
+	var steps []roEntity
+	var controls []roEntity
+	var stepFiles []roEntity
+	stepNames := map[string]bool{}
+	for _, localID := range wiNames {
+		wi := job.WorkflowInstancesMap[localID]
+		for _, task := range wi.Tasks {
+			var step *ROCrateStep
+			step, err = newROCrateStep(job, wi, task, perf)
+			if err != nil {
+				err = fmt.Errorf("(NewROCrate) %s", err.Error())
+				return
+			}
+
+			taskID := roStepName(task.TaskName, stepNames)
+			stepFile := crate.addFile("provenance/steps/"+taskID+".json", step, "provenance of "+task.TaskName)
+			stepFiles = append(stepFiles, roRef(stepFile["@id"].(string)))
+
+			action := crate.add(roEntity{"@id": "#" + taskID, "@type": "CreateAction", "name": task.TaskName})
+			action["actionStatus"] = roActionStatus(task.State)
+			action.set("startTime", roTime(task.StartedDate))
+			action.set("endTime", roTime(task.CompletedDate))
+			action["object"] = crate.dataFiles(step.Inputs)
+			action["result"] = append([]roEntity{roRef(stepFile["@id"].(string))}, crate.dataFiles(step.Outputs)...)
+			if tool := roStepRun(task); tool != "" {
+				action["instrument"] = roRef(roCrateWorkflow + tool)
+				crate.add(roEntity{"@id": roCrateWorkflow + tool, "@type": "SoftwareApplication", "name": strings.TrimPrefix(tool, "#")})
+			}
+			crate.addWorkunits(action, step.Workunits)
+
+			if task.WorkflowStepID == "" {
+				continue
+			}
+			howToStep := roCrateWorkflow + "#" + strings.TrimPrefix(task.WorkflowStepID, "#")
+			if _, ok := crate.entities[howToStep]; !ok {
+				crate.add(roEntity{"@id": howToStep, "@type": "HowToStep", "name": path.Base(task.WorkflowStepID)})
+				steps = append(steps, roRef(howToStep))
+			}
+			control := crate.add(roEntity{"@id": "#control-" + taskID, "@type": "ControlAction", "name": "orchestrate " + task.TaskName})
+			control["instrument"] = roRef(howToStep)
+			control["object"] = roRef("#" + taskID)
+			controls = append(controls, roRef("#control-"+taskID))
+		}
+	}
+	workflow.set("step", steps)
+
+	orchestrate := crate.add(roEntity{"@id": "#orchestrate-" + job.ID, "@type": "OrganizeAction", "name": "Orchestration of " + name})
+	orchestrate["instrument"] = roRef(roCrateEngine)
+	orchestrate["result"] = roRef("#" + job.ID)
+	orchestrate.set("object", controls)
+	if job.Info != nil {
+		orchestrate.set("startTime", roTime(job.Info.StartedTime))
+		orchestrate.set("endTime", roTime(job.Info.CompletedTime))
+	}
+
+	parts := []roEntity{roRef(roCrateWorkflow), roRef(roCrateJobFile), roRef(roCrateOutFile), roRef(roCrateWIFile)}
+	root["hasPart"] = append(parts, stepFiles...)
+	root["mentions"] = []roEntity{roRef("#" + job.ID)}
+	return
+}
+
+// addWorkunits adds the workers and docker images of the workunits of a step
+func (crate *ROCrate) addWorkunits(action roEntity, workunits map[string]*WorkPerf) {
+	var ranks []string
+	for rank := range workunits {
+		ranks = append(ranks, rank)
+	}
+	sort.Strings(ranks)
+
+	var agents []roEntity
+	var images []roEntity
+	for _, rank := range ranks {
+		wp := workunits[rank]
+		if wp.ClientId != "" {
+			id := "#client-" + wp.ClientId
+			if _, ok := crate.entities[id]; !ok {
+				crate.add(roEntity{"@id": id, "@type": "SoftwareApplication", "name": "AWE worker " + wp.ClientId, "identifier": wp.ClientId})
+			}
+			agents = append(agents, roRef(id))
+		}
+		if wp.DockerImage != "" || wp.DockerImageId != "" {
+			id := "#image-" + strings.TrimPrefix(wp.DockerImageId, "sha256:")
+			if wp.DockerImageId == "" {
+				id = "#image-" + wp.DockerImage
+			}
+			if _, ok := crate.entities[id]; !ok {
+				image := crate.add(roEntity{"@id": id, "@type": "ContainerImage"})
+				image["additionalType"] = roRef("https://w3id.org/ro/terms/workflow-run#DockerImage")
+				image.set("name", wp.DockerImage)
+				if strings.HasPrefix(wp.DockerImageId, "sha256:") {
+					image["sha256"] = strings.TrimPrefix(wp.DockerImageId, "sha256:")
+				}
+			}
+			images = append(images, roRef(id))
+		}
+	}
+	switch len(agents) {
+	case 0:
+	case 1:
+		action["agent"] = agents[0]
+	default:
+		action["agent"] = agents
+	}
+	switch len(images) {
+	case 0:
+	case 1:
+		action["containerImage"] = images[0]
+	default:
+		action["containerImage"] = images
+	}
+}
+
+// dataFiles adds an entity for every CWL File and Directory with a location in the value
+func (crate *ROCrate) dataFiles(value interface{}) (refs []roEntity) {
+	seen := map[string]bool{}
+	walkDataFiles(value, func(class string, location string, v map[string]interface{}) {
+		if seen[location] {
+			return
+		}
+		seen[location] = true
+		e := roEntity{"@id": location, "@type": class}
+		if class == "Directory" {
+			e["@type"] = "Dataset"
+		}
+		if basename, ok := v["basename"].(string); ok {
+			e.set("name", basename)
+		}
+		if size, ok := v["size"].(float64); ok {
+			e["contentSize"] = strconv.FormatInt(int64(size), 10)
+		}
+		if checksum, ok := v["checksum"].(string); ok && strings.HasPrefix(checksum, "sha1$") {
+			e["sha1"] = strings.TrimPrefix(checksum, "sha1$")
+		}
+		crate.add(e)
+		refs = append(refs, roRef(location))
+	})
+	return
+}
+
+// walkDataFiles calls fn for every CWL File and Directory with a location in the value, including secondaryFiles and listings
+func walkDataFiles(value interface{}, fn func(class string, location string, v map[string]interface{})) {
+	if value == nil {
+		return
+	}
+	// CWL types marshal to plain JSON objects with "class" and "location"
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	var generic interface{}
+	if err = json.Unmarshal(raw, &generic); err != nil {
+		return
+	}
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case []interface{}:
+			for _, element := range v {
+				walk(element)
+			}
+		case map[string]interface{}:
+			class, _ := v["class"].(string)
+			location, _ := v["location"].(string)
+			if (class == "File" || class == "Directory") && location != "" {
+				fn(class, location, v)
+			}
+			for _, element := range v {
+				walk(element)
+			}
+		}
+	}
+	walk(generic)
+}
+
+// WriteZip writes the crate as zip archive
+func (crate *ROCrate) WriteZip(w io.Writer) (err error) {
+	archive := zip.NewWriter(w)
+
+	metadata := map[string]interface{}{"@context": roCrateContext, "@graph": crate.Graph}
+	err = writeZipJSON(archive, "ro-crate-metadata.json", metadata)
+	if err != nil {
+		err = fmt.Errorf("(ROCrate/WriteZip) %s", err.Error())
+		return
+	}
+	for _, name := range crate.fileNames {
+		err = writeZipJSON(archive, name, crate.files[name])
+		if err != nil {
+			err = fmt.Errorf("(ROCrate/WriteZip) %s", err.Error())
+			return
+		}
+	}
+
+	err = archive.Close()
+	if err != nil {
+		err = fmt.Errorf("(ROCrate/WriteZip) archive.Close returned: %s", err.Error())
+	}
+	return
+}
+
+func writeZipJSON(archive *zip.Writer, name string, content interface{}) (err error) {
+	var f io.Writer
+	f, err = archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		err = fmt.Errorf("archive.Create %s returned: %s", name, err.Error())
+		return
+	}
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(content)
+	if err != nil {
+		err = fmt.Errorf("json encoding of %s returned: %s", name, err.Error())
+	}
+	return
+}
+
+// newROCrateStep collects the provenance of a task
+func newROCrateStep(job *Job, wi *WorkflowInstance, task *Task, perf *JobPerf) (step *ROCrateStep, err error) {
+	step = &ROCrateStep{
+		Task:             task.TaskName,
+		Step:             task.WorkflowStepID,
+		WorkflowInstance: task.WorkflowInstanceID,
+		State:            task.State,
+		CreatedDate:      task.CreatedDate,
+		StartedDate:      task.StartedDate,
+		CompletedDate:    task.CompletedDate,
+		ComputeTime:      task.ComputeTime,
+	}
+
+	if task.StepOutput != nil {
+		step.Outputs = jobDocumentMap(task.StepOutput)
+	} else if task.StepOutputInterface != nil {
+		var outputs *cwl.Job_document
+		outputs, err = cwl.NewJob_documentFromNamedTypes(task.StepOutputInterface, job.WorkflowContext)
+		if err != nil {
+			err = fmt.Errorf("(newROCrateStep) task %s: NewJob_documentFromNamedTypes returned: %s", task.TaskName, err.Error())
+			return
+		}
+		step.Outputs = jobDocumentMap(outputs)
+	}
+
+	// inputs of scatter children are single elements of the inputs of the scatter parent
+	if task.WorkflowStep != nil && task.ScatterParent == nil && task.State == TASK_STAT_COMPLETED {
+		inputs, ierr := stepInputs(job, wi, task.WorkflowStep)
+		if ierr != nil {
+			step.InputsError = ierr.Error()
+		} else {
+			step.Inputs = inputs
+		}
+	}
+
+	if perf != nil {
+		var taskStr string
+		taskStr, err = task.String()
+		if err != nil {
+			err = fmt.Errorf("(newROCrateStep) task.String returned: %s", err.Error())
+			return
+		}
+		for workStr, wp := range perf.Pworks {
+			rank := strings.TrimPrefix(workStr, taskStr+"_")
+			if rank == workStr {
+				continue
+			}
+			if _, aerr := strconv.Atoi(rank); aerr != nil {
+				continue
+			}
+			if step.Workunits == nil {
+				step.Workunits = map[string]*WorkPerf{}
+			}
+			step.Workunits[rank] = wp
+		}
+	}
+	return
+}
+
+// stepInputs resolves the inputs of a step the same way as for the workunits, needs the server
+func stepInputs(job *Job, wi *WorkflowInstance, workflowStep *cwl.WorkflowStep) (inputs map[string]interface{}, err error) {
+	if QMgr == nil {
+		return
+	}
+	var workflowStepInputs []*cwl.WorkflowStepInput
+	workflowStepInputs, err = workflowStep.GetStepInputs()
+	if err != nil {
+		return
+	}
+	var inputMap cwl.JobDocMap
+	var reason string
+	var ok bool
+	inputMap, ok, reason, err = QMgr.GetStepInputObjects(job, wi, wi.Inputs.GetMap(), workflowStepInputs, job.WorkflowContext, "NewROCrate")
+	if err != nil {
+		return
+	}
+	if !ok {
+		err = fmt.Errorf("inputs not available: %s", reason)
+		return
+	}
+	inputs = map[string]interface{}{}
+	for id, value := range inputMap {
+		inputs[path.Base(id)] = value
+	}
+	return
+}
+
+// jobDocumentMap CWL job document as in a job file, ids are relative
+func jobDocumentMap(doc *cwl.Job_document) (m map[string]interface{}) {
+	if doc == nil {
+		return
+	}
+	m = map[string]interface{}{}
+	for _, named := range *doc {
+		m[path.Base(named.ID)] = named.Value
+	}
+	return
+}
+
+// roStepName file name and @id fragment for a task, e.g. #main/sub/step -> main_sub_step. Task names that
+// map to a name already in use (#main/a_b and #main/a/b) get a suffix, e.g. main_a_b-2
+func roStepName(taskName string, used map[string]bool) (name string) {
+	base := strings.Replace(strings.TrimPrefix(taskName, "#"), "/", "_", -1)
+	name = base
+	for i := 2; used[name]; i++ {
+		name = fmt.Sprintf("%s-%d", base, i)
+	}
+	used[name] = true
+	return
+}
+
+// roStepRun id of the tool in the packed workflow, "" if the tool is embedded
+func roStepRun(task *Task) string {
+	if task.WorkflowStep == nil {
+		return ""
+	}
+	run, ok := task.WorkflowStep.Run.(string)
+	if !ok || !strings.HasPrefix(run, "#") {
+		return ""
+	}
+	return run
+}
+
+func roActionStatus(state string) string {
+	switch state {
+	case JOB_STAT_COMPLETED: // same as TASK_STAT_COMPLETED
+		return "http://schema.org/CompletedActionStatus"
+	case JOB_STAT_FAILED_PERMANENT, TASK_STAT_FAILED:
+		return "http://schema.org/FailedActionStatus"
+	case JOB_STAT_INIT, JOB_STAT_QUEUING, JOB_STAT_QUEUED, TASK_STAT_PENDING, TASK_STAT_READY:
+		return "http://schema.org/PotentialActionStatus"
+	}
+	return "http://schema.org/ActiveActionStatus"
+}
+
+// LoadROCrateJobPerf performance data of the job, of the running job if it is active, nil if there is none
+func LoadROCrateJobPerf(id string) (perf *JobPerf) {
+	if QMgr != nil {
+		var ok bool
+		if perf, ok = QMgr.getActJob(id); ok {
+			return
+		}
+	}
+	perf, err := LoadJobPerf(id)
+	if err != nil {
+		perf = nil
+	}
+	return
+}
@@ -0,0 +1,101 @@
+package core_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	. "github.com/MG-RAST/AWE/lib/core"
+	"github.com/MG-RAST/AWE/lib/core/cwl"
+)
+
+func TestROCrate(t *testing.T) {
+	file := cwl.NewFile()
+	file.Basename = "input.fasta"
+	file.Location = "http://shock/node/a?download"
+
+	job := NewJob()
+	job.ID = "job1"
+	job.IsCWL = true
+	job.Entrypoint = "#main"
+	job.Info = NewInfo()
+	job.State = JOB_STAT_COMPLETED
+	job.WorkflowContext = cwl.NewWorkflowContext()
+	job.WorkflowContext.Graph = []interface{}{map[string]interface{}{"id": "#main", "class": "Workflow"}}
+
+	task := &Task{}
+	task.TaskName = "#main/step"
+	task.JobId = job.ID
+	task.WorkflowStepID = "#main/step"
+	task.State = TASK_STAT_COMPLETED
+	task.StepOutput = &cwl.Job_document{cwl.NewNamedCWLType("#main/step/output", cwl.NewString("done"))}
+
+	// task names that map to the same file name
+	underscore := &Task{}
+	underscore.TaskName = "#main/a_b"
+	underscore.JobId = job.ID
+	nested := &Task{}
+	nested.TaskName = "#main/a/b"
+	nested.JobId = job.ID
+
+	wi := &WorkflowInstance{LocalID: "#main", JobID: job.ID, Tasks: []*Task{task, underscore, nested}}
+	wi.Inputs = cwl.Job_document{cwl.NewNamedCWLType("#main/input", file)}
+	job.WorkflowInstancesMap = map[string]*WorkflowInstance{"#main": wi}
+
+	perf := NewJobPerf(job.ID)
+	perf.Pworks["job1_#main/step_0"] = &WorkPerf{ClientId: "client1", DockerImage: "ubuntu:18.04", DockerImageId: "sha256:abcd"}
+	perf.Pworks["job1_#main/step2_0"] = &WorkPerf{ClientId: "client2"}
+
+	crate, err := NewROCrate(job, perf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err = crate.WriteZip(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{}
+	for _, f := range archive.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var content bytes.Buffer
+		content.ReadFrom(r)
+		r.Close()
+		if _, ok := files[f.Name]; ok {
+			t.Errorf("%s twice in crate", f.Name)
+		}
+		files[f.Name] = content.Bytes()
+	}
+	for _, name := range []string{"ro-crate-metadata.json", "workflow/packed.cwl", "workflow/primary-job.json", "provenance/steps/main_step.json", "provenance/steps/main_a_b.json", "provenance/steps/main_a_b-2.json"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("%s missing in crate", name)
+		}
+	}
+
+	var step ROCrateStep
+	if err = json.Unmarshal(files["provenance/steps/main_step.json"], &step); err != nil {
+		t.Fatal(err)
+	}
+	if len(step.Workunits) != 1 || step.Workunits["0"].ClientId != "client1" {
+		t.Errorf("expected the workunit of the step only, got %v", step.Workunits)
+	}
+	if step.Outputs["output"] != "done" {
+		t.Errorf("step output missing: %v", step.Outputs)
+	}
+
+	metadata := string(files["ro-crate-metadata.json"])
+	for _, expected := range []string{`"#image-abcd"`, `"#client-client1"`, `"http://shock/node/a?download"`, `"HowToStep"`} {
+		if !strings.Contains(metadata, expected) {
+			t.Errorf("%s missing in ro-crate-metadata.json", expected)
+		}
+	}
+}
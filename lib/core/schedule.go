@@ -0,0 +1,372 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MG-RAST/AWE/lib/acl"
+	"github.com/MG-RAST/AWE/lib/conf"
+	"github.com/MG-RAST/AWE/lib/db"
+	"github.com/MG-RAST/AWE/lib/logger"
+	"github.com/MG-RAST/AWE/lib/logger/event"
+	"github.com/MG-RAST/AWE/lib/user"
+	uuid "github.com/MG-RAST/golib/go-uuid/uuid"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Schedule submits a new job from a stored CWL workflow and job input template at every tick of the cron expression
+type Schedule struct {
+	ID           string        `bson:"id" json:"id"`
+	Name         string        `bson:"name" json:"name"`
+	Cron         string        `bson:"cron" json:"cron"`
+	ACL          acl.Acl       `bson:"acl" json:"acl"` // copied to every job of the schedule
+	ClientGroups string        `bson:"clientgroups" json:"clientgroups"`
+	Entrypoint   string        `bson:"entrypoint" json:"entrypoint"`
+	WorkflowName string        `bson:"workflow_name" json:"workflow_name"`
+	Workflow     string        `bson:"workflow" json:"-"`  // CWL graph document
+	JobInput     string        `bson:"job_input" json:"-"` // job input document, see ExpandJobInput
+	DataToken    string        `bson:"datatoken" json:"-"`
+	Paused       bool          `bson:"paused" json:"paused"`
+	SkipIfActive bool          `bson:"skip_if_active" json:"skip_if_active"` // no new job while the previous one has not finished
+	KeepRuns     int           `bson:"keep_runs" json:"keep_runs"`
+	RunCount     int           `bson:"run_count" json:"run_count"`
+	Runs         []ScheduleRun `bson:"runs" json:"runs"` // latest last
+	NextRun      time.Time     `bson:"next_run" json:"next_run"`
+	LastRun      time.Time     `bson:"last_run" json:"last_run"`
+	CreatedOn    time.Time     `bson:"created_on" json:"created_on"`
+	LastModified time.Time     `bson:"last_modified" json:"last_modified"`
+}
+
+// ScheduleRun one tick of a schedule, either a job was submitted or the reason why not
+type ScheduleRun struct {
+	Time    time.Time `bson:"time" json:"time"`
+	JobID   string    `bson:"job_id,omitempty" json:"job_id,omitempty"`
+	Skipped string    `bson:"skipped,omitempty" json:"skipped,omitempty"`
+	Error   string    `bson:"error,omitempty" json:"error,omitempty"`
+}
+
+// Schedules _
+type Schedules []*Schedule
+
+// InitScheduleDB _
+func InitScheduleDB() {
+	session := db.Connection.Session.Copy()
+	defer session.Close()
+	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_SCHEDULES)
+	c.EnsureIndex(mgo.Index{Key: []string{"id"}, Unique: true})
+	c.EnsureIndex(mgo.Index{Key: []string{"acl.owner"}, Background: true})
+	c.EnsureIndex(mgo.Index{Key: []string{"next_run"}, Background: true})
+}
+
+// NewSchedule validates the cron expression, the schedule is not saved yet
+func NewSchedule(u *user.User, name string, cron string, workflowName string, workflow []byte, jobInput []byte) (s *Schedule, err error) {
+	if len(workflow) == 0 {
+		err = errors.New("(NewSchedule) workflow document is missing")
+		return
+	}
+	if _, err = ParseCron(cron); err != nil {
+		return
+	}
+
+	t := time.Now()
+	s = &Schedule{
+		ID:           uuid.New(),
+		Name:         name,
+		Cron:         cron,
+		ClientGroups: conf.CLIENT_GROUP,
+		WorkflowName: workflowName,
+		Workflow:     string(workflow),
+		JobInput:     string(jobInput),
+		KeepRuns:     conf.SCHEDULE_KEEP_RUNS,
+		Runs:         []ScheduleRun{},
+		CreatedOn:    t,
+	}
+	if s.Name == "" {
+		s.Name = workflowName
+	}
+	s.ACL.SetOwner(u.Uuid)
+	s.ACL.Set(u.Uuid, acl.Rights{"read": true, "write": true, "delete": true})
+	err = s.SetNextRun(t)
+	return
+}
+
+// SetNextRun computes the next tick after t
+func (s *Schedule) SetNextRun(t time.Time) (err error) {
+	var c *CronExpression
+	c, err = ParseCron(s.Cron)
+	if err != nil {
+		return
+	}
+	s.NextRun = c.Next(t)
+	return
+}
+
+// ExpandJobInput replaces the placeholders ${SCHEDULE_DATE}, ${SCHEDULE_TIME} and ${SCHEDULE_RUN} in the job input template
+func (s *Schedule) ExpandJobInput(t time.Time, run int) string {
+	r := strings.NewReplacer(
+		"${SCHEDULE_DATE}", t.UTC().Format("2006-01-02"),
+		"${SCHEDULE_TIME}", t.UTC().Format(time.RFC3339),
+		"${SCHEDULE_RUN}", strconv.Itoa(run),
+	)
+	return r.Replace(s.JobInput)
+}
+
+// Save _
+func (s *Schedule) Save() (err error) {
+	s.LastModified = time.Now()
+	session := db.Connection.Session.Copy()
+	defer session.Close()
+	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_SCHEDULES)
+	_, err = c.Upsert(bson.M{"id": s.ID}, s)
+	if err != nil {
+		err = fmt.Errorf("(Schedule/Save) error saving schedule %s: %s", s.ID, err.Error())
+	}
+	return
+}
+
+// UpdateSchedule sets only the given fields, runs written by ScheduleHandle in the meantime are kept.
+// keepRuns > 0 drops the oldest runs beyond it.
+func UpdateSchedule(id string, set bson.M, keepRuns int) (err error) {
+	set["last_modified"] = time.Now()
+	update := bson.M{"$set": set}
+	if keepRuns > 0 {
+		update["$push"] = bson.M{"runs": bson.M{"$each": []ScheduleRun{}, "$slice": -keepRuns}}
+	}
+	session := db.Connection.Session.Copy()
+	defer session.Close()
+	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_SCHEDULES)
+	err = c.Update(bson.M{"id": id}, update)
+	if err != nil {
+		err = fmt.Errorf("(UpdateSchedule) schedule %s: %s", id, err.Error())
+	}
+	return
+}
+
+// LoadSchedule _
+func LoadSchedule(id string) (s *Schedule, err error) {
+	s = new(Schedule)
+	session := db.Connection.Session.Copy()
+	defer session.Close()
+	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_SCHEDULES)
+	if err = c.Find(bson.M{"id": id}).One(&s); err != nil {
+		s = nil
+	}
+	return
+}
+
+// DeleteSchedule removes the schedule, jobs it has submitted are not affected
+func DeleteSchedule(id string) (err error) {
+	session := db.Connection.Session.Copy()
+	defer session.Close()
+	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_SCHEDULES)
+	err = c.Remove(bson.M{"id": id})
+	return
+}
+
+// GetPaginated _
+func (s *Schedules) GetPaginated(q bson.M, limit int, offset int, order string, direction string) (count int, err error) {
+	if direction == "desc" {
+		order = "-" + order
+	}
+	session := db.Connection.Session.Copy()
+	defer session.Close()
+	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_SCHEDULES)
+	query := c.Find(q).Select(bson.M{"workflow": 0, "job_input": 0})
+	if count, err = query.Count(); err != nil {
+		return
+	}
+	err = query.Sort(order).Limit(limit).Skip(offset).All(s)
+	return
+}
+
+// previousRunActive returns the state of the last submitted job if it has not finished yet
+func (s *Schedule) previousRunActive() (active bool, jobID string, state string, err error) {
+	for i := len(s.Runs) - 1; i >= 0; i-- {
+		if s.Runs[i].JobID == "" {
+			continue
+		}
+		jobID = s.Runs[i].JobID
+		var found bool
+		state, found, err = getUpstreamJobState(jobID)
+		if err != nil || !found {
+			return
+		}
+		active = state == JOB_STAT_INIT || contains(JOB_STATS_ACTIVE, state)
+		return
+	}
+	return
+}
+
+// submit creates, saves and loads a new job like a CWL submission of the owner, with the same validation
+func (s *Schedule) submit(t time.Time, run int) (job *Job, err error) {
+	var u *user.User
+	u, err = user.FindByUuid(s.ACL.Owner)
+	if err != nil {
+		err = fmt.Errorf("(Schedule/submit) owner %s not found: %s", s.ACL.Owner, err.Error())
+		return
+	}
+
+	err = Quotas.CheckSubmission(u.Uuid, s.ClientGroups)
+	if err != nil {
+		return
+	}
+
+	// the workflow document is attached to the job like an uploaded file
+	tmpPath := fmt.Sprintf("%s/temp/%s.%d", conf.DATA_PATH, s.ID, run)
+	err = ioutil.WriteFile(tmpPath, []byte(s.Workflow), 0644)
+	if err != nil {
+		err = fmt.Errorf("(Schedule/submit) could not write workflow: %s", err.Error())
+		return
+	}
+	defer os.Remove(tmpPath)
+	files := FormFiles{"cwl": FormFile{Name: s.WorkflowName, Path: tmpPath, Checksum: make(map[string]string)}}
+
+	var jobStream []byte
+	if s.JobInput != "" {
+		jobStream = []byte(s.ExpandJobInput(t, run))
+	}
+
+	job, err = CreateJobFromCWL(u, files, []byte(s.Workflow), s.WorkflowName, jobStream, s.Entrypoint)
+	if err != nil {
+		err = fmt.Errorf("(Schedule/submit) CreateJobFromCWL returned: %s", err.Error())
+		return
+	}
+
+	for _, id := range s.ACL.Read {
+		job.ACL.Set(id, acl.Rights{"read": true})
+	}
+	for _, id := range s.ACL.Write {
+		job.ACL.Set(id, acl.Rights{"write": true})
+	}
+	for _, id := range s.ACL.Delete {
+		job.ACL.Set(id, acl.Rights{"delete": true})
+	}
+
+	job.Info.Name = fmt.Sprintf("%s-%s", s.Name, t.UTC().Format("20060102T1504"))
+	job.Info.Pipeline = s.WorkflowName
+	job.Info.ClientGroups = s.ClientGroups
+	job.Info.UserAttr = map[string]interface{}{"schedule": s.ID, "schedule_run": run}
+	if s.DataToken != "" {
+		job.Info.DataToken = s.DataToken
+		job.Info.Auth = true
+	}
+
+	err = ValidateJobSubmission(job, u)
+	if err != nil {
+		err = fmt.Errorf("(Schedule/submit) %s", err.Error())
+		return
+	}
+	err = CheckProjectSubmission(u, job.Info.Project)
+	if err != nil {
+		err = fmt.Errorf("(Schedule/submit) %s", err.Error())
+		return
+	}
+
+	PinJobDockerImages(job)
+
+	err = job.Save()
+	if err != nil {
+		err = fmt.Errorf("(Schedule/submit) job.Save returned: %s", err.Error())
+		return
+	}
+
+	// load the job from mongo, as JobController.Create does for CWL jobs
+	job, err = GetJob(job.ID)
+	if err != nil {
+		err = fmt.Errorf("(Schedule/submit) GetJob returned: %s", err.Error())
+		return
+	}
+	logger.Event(event.JOB_SCHEDULED, "jobid="+job.ID+";schedule="+s.ID+";name="+job.Info.Name+";user="+u.Uuid)
+	return
+}
+
+// Run handles one tick: submits a job unless the previous one is still active, records the run and computes the next tick
+func (s *Schedule) Run(t time.Time) (run ScheduleRun) {
+	run.Time = t
+
+	skip := false
+	if s.SkipIfActive {
+		active, jobID, state, err := s.previousRunActive()
+		if err != nil {
+			logger.Error("(Schedule/Run) schedule %s: previousRunActive returned: %s", s.ID, err.Error())
+		}
+		if active {
+			skip = true
+			run.Skipped = fmt.Sprintf("previous run %s still active (state: %s)", jobID, state)
+		}
+	}
+
+	if !skip {
+		s.RunCount++
+		job, err := s.submit(t, s.RunCount)
+		if err != nil {
+			logger.Error("(Schedule/Run) schedule %s: %s", s.ID, err.Error())
+			run.Error = err.Error()
+		} else {
+			run.JobID = job.ID
+		}
+	}
+
+	s.Runs = append(s.Runs, run)
+	keep := s.KeepRuns
+	if keep <= 0 {
+		keep = conf.SCHEDULE_KEEP_RUNS
+	}
+	if len(s.Runs) > keep {
+		s.Runs = s.Runs[len(s.Runs)-keep:]
+	}
+	s.LastRun = t
+
+	err := s.SetNextRun(t)
+	if err != nil {
+		logger.Error("(Schedule/Run) schedule %s: %s", s.ID, err.Error())
+	}
+	// only the run fields are written, the schedule may have been changed (e.g. paused) in the meantime
+	err = dbUpdateScheduleRuns(s)
+	if err != nil {
+		logger.Error("(Schedule/Run) %s", err.Error())
+	}
+	return
+}
+
+func dbUpdateScheduleRuns(s *Schedule) (err error) {
+	session := db.Connection.Session.Copy()
+	defer session.Close()
+	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_SCHEDULES)
+	update := bson.M{"$set": bson.M{"runs": s.Runs, "run_count": s.RunCount, "last_run": s.LastRun, "next_run": s.NextRun}}
+	err = c.Update(bson.M{"id": s.ID}, update)
+	if err != nil {
+		err = fmt.Errorf("(dbUpdateScheduleRuns) schedule %s: %s", s.ID, err.Error())
+	}
+	return
+}
+
+// ScheduleHandle runs the schedules that are due, once per minute
+func ScheduleHandle() {
+	for {
+		now := time.Now()
+		time.Sleep(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+
+		now = time.Now()
+		due := Schedules{}
+		session := db.Connection.Session.Copy()
+		c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_SCHEDULES)
+		err := c.Find(bson.M{"paused": false, "next_run": bson.M{"$lte": now}}).All(&due)
+		session.Close()
+		if err != nil {
+			logger.Error("(ScheduleHandle) could not read schedules: %s", err.Error())
+			continue
+		}
+
+		// missed ticks (e.g. server downtime) result in a single run
+		for _, s := range due {
+			s.Run(now)
+		}
+	}
+}
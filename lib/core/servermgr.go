@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"math"
+	"net/url"
 	"os"
 	"path"
 	"reflect"
@@ -327,7 +328,35 @@ func (qm *ServerMgr) updateWorkflowInstancesMapTask(workflowInstance *WorkflowIn
 		//subworkflow_str := []string{}
 		noSteps := false
 
-		if cwlWorkflow.Steps != nil {
+		// a subworkflow step whose when evaluates to false gets no steps and completes with null outputs
+		skipped := workflowInstance.Skipped
+		if !skipped && path.Dir(wiLocalID) != "." {
+			var wiStep *cwl.WorkflowStep
+			wiStep, err = workflowInstance.GetWorkflowStep(job)
+			if err != nil {
+				err = fmt.Errorf("(updateWorkflowInstancesMapTask) workflowInstance.GetWorkflowStep returned: %s", err.Error())
+				return
+			}
+			if wiStep != nil && len(wiStep.Scatter) == 0 && wiStep.When != "" {
+				var run bool
+				run, err = wiStep.EvaluateWhen(workflowInstance.Inputs.GetMap(), context)
+				if err != nil {
+					err = fmt.Errorf("(updateWorkflowInstancesMapTask) wiStep.EvaluateWhen returned: %s", err.Error())
+					return
+				}
+				if !run {
+					logger.Debug(2, "(updateWorkflowInstancesMapTask) skipping %s, when evaluated to false", wiLocalID)
+					err = workflowInstance.SetSkipped(true, true)
+					if err != nil {
+						err = fmt.Errorf("(updateWorkflowInstancesMapTask) workflowInstance.SetSkipped returned: %s", err.Error())
+						return
+					}
+					skipped = true
+				}
+			}
+		}
+
+		if cwlWorkflow.Steps != nil && !skipped {
 
 			logger.Debug(3, "(updateWorkflowInstancesMapTask) %s len(cwlWorkflow.Steps)=%d", workflowInstance.LocalID, len(cwlWorkflow.Steps))
 
@@ -1664,6 +1693,16 @@ func (qm *ServerMgr) handleNoticeWorkDelivered(notice Notice) (err error) {
 		return
 	}
 
+	// CPU time counts against the daily quota of the job owner, also if the workunit failed
+	if client != nil && noticeStatus != WORK_STAT_QUEUED {
+		cores := int64(1)
+		if work.Resources != nil && work.Resources.Cores > 1 {
+			cores = work.Resources.Cores
+		}
+		Quotas.AddCPUTime(work.Owner, client.Group, float64(int64(notice.ComputeTime)*cores))
+		observeWorkunitResult(client.Group, noticeStatus, notice.Failure)
+	}
+
 	err = task.LockNamed("handleNoticeWorkDelivered/noretry")
 	if err != nil {
 		return
@@ -1672,8 +1711,10 @@ func (qm *ServerMgr) handleNoticeWorkDelivered(notice Notice) (err error) {
 	task.Unlock()
 
 	var MAX_FAILURE int
+	retryPolicy := work.Retry
 	if noretry == true {
 		MAX_FAILURE = 1
+		retryPolicy = nil
 	} else {
 		MAX_FAILURE = conf.MAX_WORK_FAILURE
 	}
@@ -1699,11 +1740,20 @@ func (qm *ServerMgr) handleNoticeWorkDelivered(notice Notice) (err error) {
 		//      ******************
 		//      * WORK_STAT_DONE *
 		//      ******************
+		qm.recordRuntime(work, workStr, int64(notice.ComputeTime))
 		err = qm.handleWorkStatDone(client, clientid, task, workID, &notice)
 		if err != nil {
 			err = fmt.Errorf("(handleNoticeWorkDelivered) handleWorkStatDone returned: %s", err.Error())
 			return
 		}
+		if clientid != "_internal" {
+			err = saveCallCache(work, task, notice.Results)
+			if err != nil {
+				// the job is not affected, the outputs are just not reused later
+				logger.Error("(handleNoticeWorkDelivered) saveCallCache returned: %s", err.Error())
+				err = nil
+			}
+		}
 	case WORK_STAT_FAILED_PERMANENT: // (special case !) failed and cannot be recovered
 
 		logger.Event(event.WORK_FAILED, "workid="+workStr+";clientid="+clientid)
@@ -1743,12 +1793,17 @@ func (qm *ServerMgr) handleNoticeWorkDelivered(notice Notice) (err error) {
 
 		work.Failed++
 
-		if work.Failed < MAX_FAILURE {
-			qm.workQueue.StatusChange(Workunit_Unique_Identifier{}, work, WORK_STAT_QUEUED, "")
+		retry, delay, retryNote := retryPolicy.Check(work.Failed, MAX_FAILURE, notice.Failure, notice.ExitStatus)
+		work.Notes = append(work.Notes, retryNote)
+		logger.Debug(3, "(handleNoticeWorkDelivered) workid=%s %s", workStr, retryNote)
+
+		if retry {
+			work.NotBefore = time.Now().Add(delay)
+			qm.workQueue.StatusChange(Workunit_Unique_Identifier{}, work, WORK_STAT_QUEUED, retryNote)
 			logger.Event(event.WORK_REQUEUE, "workid="+workStr)
 		} else {
-			//failure time exceeds limit, suspend workunit, task, job
-			err = qm.workQueue.StatusChange(Workunit_Unique_Identifier{}, work, WORK_STAT_SUSPEND, "work.Failed >= MAX_FAILURE")
+			//failure time exceeds limit or failure is not retried, suspend workunit, task, job
+			err = qm.workQueue.StatusChange(Workunit_Unique_Identifier{}, work, WORK_STAT_SUSPEND, retryNote)
 			if err != nil {
 				err = fmt.Errorf("(handleNoticeWorkDelivered) qm.workQueue.StatusChange returned: %s", err.Error())
 				return
@@ -1771,7 +1826,7 @@ func (qm *ServerMgr) handleNoticeWorkDelivered(notice Notice) (err error) {
 				ClientFailed: clientid,
 				WorkFailed:   workStr,
 				TaskFailed:   taskStr,
-				ServerNotes:  fmt.Sprintf("workunit failed %d time(s)", MAX_FAILURE),
+				ServerNotes:  fmt.Sprintf("workunit failed %d time(s), %s", work.Failed, retryNote),
 				WorkNotes:    notes,
 				AppError:     notice.Stderr,
 				Status:       JOB_STAT_SUSPEND,
@@ -1836,8 +1891,10 @@ func (qm *ServerMgr) handleNoticeWorkDelivered(notice Notice) (err error) {
 		if lastFailed >= conf.MAX_CLIENT_FAILURE {
 			qm.SuspendClient(clientid, client, "MAX_CLIENT_FAILURE on client reached", true)
 		}
+	case WORK_STAT_QUEUED: // returned by a draining worker before it was started, see ReturnWorkunit
+		logger.Debug(3, "(handleNoticeWorkDelivered) workid=%s returned by client %s", workStr, clientid)
 	default:
-		err = fmt.Errorf("No handler for workunit status '%s' implemented (allowd: %s, %s, %s)", noticeStatus, WORK_STAT_DONE, WORK_STAT_FAILED_PERMANENT, WORK_STAT_ERROR)
+		err = fmt.Errorf("No handler for workunit status '%s' implemented (allowd: %s, %s, %s, %s)", noticeStatus, WORK_STAT_DONE, WORK_STAT_FAILED_PERMANENT, WORK_STAT_ERROR, WORK_STAT_QUEUED)
 		return
 	}
 	return
@@ -2116,6 +2173,8 @@ func (qm *ServerMgr) SaveStdLog(id Workunit_Unique_Identifier, logname string, t
 		return err
 	}
 	os.Rename(tmppath, savedpath)
+	// the complete log replaces the output streamed while the workunit was running
+	os.Remove(savedpath + ".1")
 	return
 }
 
@@ -2133,6 +2192,10 @@ func (qm *ServerMgr) GetReportMsg(id Workunit_Unique_Identifier, logname string)
 	if err != nil {
 		return "", err
 	}
+	// older part of a log streamed by a running workunit
+	if rotated, rerr := ioutil.ReadFile(logpath + ".1"); rerr == nil {
+		content = append(rotated, content...)
+	}
 	return string(content), err
 }
 
@@ -2152,7 +2215,8 @@ func getStdLogPathByWorkID(id Workunit_Unique_Identifier, logname string) (saved
 		return
 	}
 
-	savedpath = fmt.Sprintf("%s/%s.%s", logdir, workStr, logname)
+	// CWL task names contain slashes
+	savedpath = fmt.Sprintf("%s/%s.%s", logdir, url.PathEscape(workStr), logname)
 	return
 }
 
@@ -2537,6 +2601,34 @@ func (qm *ServerMgr) isTaskReady(taskID Task_Unique_Identifier, task *Task) (rea
 				logger.Debug(3, "(isTaskReady %s) StartAt field is in the past, can execute now (now: %s, StartAt: %s)", taskIDStr, time.Now(), info.StartAt)
 			}
 		}
+
+		if len(info.AfterJobs) > 0 {
+			var afterJobsReady bool
+			var upstreamFailed string
+			afterJobsReady, reason, upstreamFailed, err = info.CheckAfterJobs()
+			if err != nil {
+				err = fmt.Errorf("(isTaskReady) CheckAfterJobs returned: %s", err.Error())
+				return
+			}
+			if upstreamFailed != "" && jobState != JOB_STAT_FAILED_PERMANENT {
+				// the job can never start, propagate the failure
+				jerror := &JobError{
+					TaskFailed:  taskIDStr,
+					ServerNotes: upstreamFailed,
+					Status:      JOB_STAT_FAILED_PERMANENT,
+				}
+				err = qm.SuspendJob(taskID.JobId, job, jerror)
+				if err != nil {
+					err = fmt.Errorf("(isTaskReady) SuspendJob returned: %s", err.Error())
+					return
+				}
+			}
+			if !afterJobsReady {
+				logger.Debug(3, "(isTaskReady %s) %s", taskIDStr, reason)
+				return
+			}
+			reason = "all ok"
+		}
 	}
 
 	if task.WorkflowStep != nil {
@@ -2968,6 +3060,7 @@ func (qm *ServerMgr) processInstanceEnQueueScatter(parentWorkflowInstance *Workf
 
 	}
 	scatterType := ""
+	var scatterShape []int // only used by nested_crossproduct
 
 	processStr := processInstance.GetIDStr()
 
@@ -2982,26 +3075,10 @@ func (qm *ServerMgr) processInstanceEnQueueScatter(parentWorkflowInstance *Workf
 		// nested_crossproduct and flat_crossproduct differ only in how results are merged
 
 		if strings.ToLower(scatterMethod) == "nested_crossproduct" {
-			// if task != nil {
-			// 	task.SetState(TASK_STAT_SUSPEND, true)
-			// } else {
-			// 	workflowInstance.SetState(WIStateSuspended, true)
-			// }
-			processInstance.SetState(ProcessStatSuspend, true, "processInstanceEnQueueScatter")
-
-			//taskStr, _ := task.String()
-
-			jobID, _ := job.GetID(true)
-
-			jerror := &JobError{
-				TaskFailed:  "process failed: " + processStr,
-				ServerNotes: fmt.Sprintf("nested_crossproduct not supported yet"),
-				Status:      JOB_STAT_SUSPEND,
-			}
-			err = qm.SuspendJob(jobID, job, jerror)
-			if err != nil {
-				err = fmt.Errorf("(processInstanceEnQueueScatter) qm.SuspendJob returned: %s", err.Error())
-				return
+			// the counter iterates in the same order as the nesting, remember the shape to nest the outputs later
+			scatterShape = make([]int, countOfScatterArrays)
+			for i := range scatterInputArrays {
+				scatterShape[i] = scatterInputArrays[i].Len()
 			}
 		}
 
@@ -3129,6 +3206,13 @@ func (qm *ServerMgr) processInstanceEnQueueScatter(parentWorkflowInstance *Workf
 			//fmt.Printf("outname: %s\n", out_name)
 
 			newArray := &cwl.Array{}
+			if scatterShape != nil {
+				newArray, err = NestCrossproduct([]cwl.CWLType{}, scatterShape)
+				if err != nil {
+					err = fmt.Errorf("(processInstanceEnQueueScatter) NestCrossproduct returned: %s", err.Error())
+					return
+				}
+			}
 
 			//new_out := cwl.NewNamedCWLType(out_name, new_array)
 			//spew.Dump(*notice.Results)
@@ -3279,6 +3363,13 @@ func (qm *ServerMgr) processInstanceEnQueueScatter(parentWorkflowInstance *Workf
 			err = fmt.Errorf("(processInstanceEnQueueScatter) task.SetScatterChildren returned: %s", err.Error())
 			return
 		}
+		if scatterShape != nil {
+			err = task.SetScatterShape(scatterShape, true)
+			if err != nil {
+				err = fmt.Errorf("(processInstanceEnQueueScatter) task.SetScatterShape returned: %s", err.Error())
+				return
+			}
+		}
 	} else {
 
 	}
@@ -3433,6 +3524,25 @@ func (qm *ServerMgr) taskEnQueue(taskID Task_Unique_Identifier, task *Task, job
 			}
 
 		}
+
+		// scatter steps evaluate when for each child
+		if taskType != ProcessTypeScatter && task.WorkflowStep.When != "" {
+			var run bool
+			run, err = qm.evaluateStepWhen(job, workflowInstance, workflowInputMap, task.WorkflowStep)
+			if err != nil {
+				err = fmt.Errorf("(taskEnQueue) evaluateStepWhen returned: %s", err.Error())
+				return
+			}
+			if !run {
+				logger.Debug(2, "(taskEnQueue) skipping task %s, when evaluated to false", taskIDStr)
+				notice, err = qm.skipTask(task, job)
+				if err != nil {
+					err = fmt.Errorf("(taskEnQueue) skipTask returned: %s", err.Error())
+					return
+				}
+				skipWorkunit = true
+			}
+		}
 	}
 	logger.Debug(2, "(taskEnQueue) task %s has type %s", taskIDStr, taskType)
 	if taskType == ProcessTypeScatter {
@@ -3485,7 +3595,7 @@ func (qm *ServerMgr) taskEnQueue(taskID Task_Unique_Identifier, task *Task, job
 		logger.Debug(3, "(taskEnQueue) create Workunits")
 		workunitStart := time.Now()
 		var count int
-		count, err = qm.CreateAndEnqueueWorkunits(task, job)
+		count, notice, err = qm.CreateAndEnqueueWorkunits(task, job)
 		if err != nil {
 			err = fmt.Errorf("(taskEnQueue) %s CreateAndEnqueueWorkunits returned: %s", taskIDStr, err.Error())
 			return
@@ -3562,6 +3672,71 @@ func (qm *ServerMgr) locateInputs(task *Task, job *Job) (err error) {
 	return
 }
 
+// evaluateStepWhen evaluates the CWL v1.2 when expression of a step, with the step inputs that a workunit would get
+func (qm *ServerMgr) evaluateStepWhen(job *Job, workflowInstance *WorkflowInstance, workflowInputMap cwl.JobDocMap, step *cwl.WorkflowStep) (run bool, err error) {
+
+	var stepInputs []*cwl.WorkflowStepInput
+	stepInputs, err = step.GetStepInputs()
+	if err != nil {
+		err = fmt.Errorf("(evaluateStepWhen) step.GetStepInputs returned: %s", err.Error())
+		return
+	}
+
+	var inputs cwl.JobDocMap
+	var ok bool
+	var reason string
+	inputs, ok, reason, err = qm.GetStepInputObjects(job, workflowInstance, workflowInputMap, stepInputs, job.WorkflowContext, "evaluateStepWhen")
+	if err != nil {
+		err = fmt.Errorf("(evaluateStepWhen) GetStepInputObjects returned: %s", err.Error())
+		return
+	}
+	if !ok {
+		err = fmt.Errorf("(evaluateStepWhen) step inputs not ready, reason: %s", reason)
+		return
+	}
+
+	run, err = step.EvaluateWhen(inputs, job.WorkflowContext)
+	if err != nil {
+		err = fmt.Errorf("(evaluateStepWhen) step.EvaluateWhen returned: %s", err.Error())
+	}
+	return
+}
+
+// skipTask marks the task as skipped and returns the internal notice that completes it with null outputs.
+// A placeholder workunit in state checkout is added to the work queue, the notice handler expects one.
+func (qm *ServerMgr) skipTask(task *Task, job *Job) (notice *Notice, err error) {
+
+	err = task.SetSkipped(true, true)
+	if err != nil {
+		err = fmt.Errorf("(skipTask) task.SetSkipped returned: %s", err.Error())
+		return
+	}
+
+	var workunit *Workunit
+	workunit, err = NewWorkunit(qm, task, 0, job)
+	if err != nil {
+		err = fmt.Errorf("(skipTask) NewWorkunit returned: %s", err.Error())
+		return
+	}
+
+	err = qm.workQueue.AddInternal(workunit, "step skipped")
+	if err != nil {
+		err = fmt.Errorf("(skipTask) qm.workQueue.AddInternal returned: %s", err.Error())
+		return
+	}
+
+	notice = &Notice{}
+	notice.WorkerID = "_internal"
+	notice.ID = workunit.Workunit_Unique_Identifier
+	notice.Status = WORK_STAT_DONE
+	notice.Notes = "skipped, when evaluated to false"
+	notice.Results = &cwl.Job_document{}
+	for _, out := range task.WorkflowStep.Out {
+		notice.Results = notice.Results.Add(path.Base(out.Id), cwl.NewNull())
+	}
+	return
+}
+
 func (qm *ServerMgr) getCWLSourceArray(workflowInstance *WorkflowInstance, workflowInputMap map[string]cwl.CWLType, job *Job, srcArray []string, errorOnMissingTask bool) (obj cwl.Array, ok bool, err error) {
 
 	obj = cwl.Array{}
@@ -4559,6 +4734,19 @@ func (qm *ServerMgr) GetStepInputObject(job *Job, workflowInstance *WorkflowInst
 
 				workunitInputMap[cmdID] = &cwlArray
 
+				if input.PickValue != nil {
+					var picked cwl.CWLType
+					picked = &cwlArray
+					if linkMergeMethod == "merge_nested" && len(cwlArray) == 1 {
+						picked = cwlArray[0] // a single source is not merged
+					}
+					picked, err = cwl.PickValue(*input.PickValue, picked)
+					if err != nil {
+						err = fmt.Errorf("(GetStepInputObject) (input %s) cwl.PickValue returned: %s", id, err.Error())
+						return
+					}
+					workunitInputMap[cmdID] = picked
+				}
 			}
 		} else {
 			fmt.Printf("(GetStepInputObject) source is NOT a array: %s", spew.Sdump(input.Source))
@@ -4732,6 +4920,13 @@ func (qm *ServerMgr) GetStepInputObject(job *Job, workflowInstance *WorkflowInst
 				//fmt.Printf("(GetStepInputObject) cmd_id=%s element=%s real_source_index=%d\n", cmd_id, element, real_source_index)
 				workunitInputMap[cmdID] = element
 			} else {
+				if input.PickValue != nil {
+					jobObj, err = cwl.PickValue(*input.PickValue, jobObj)
+					if err != nil {
+						err = fmt.Errorf("(GetStepInputObject) (input %s) cwl.PickValue returned: %s", id, err.Error())
+						return
+					}
+				}
 				workunitInputMap[cmdID] = jobObj
 			}
 		}
@@ -5096,8 +5291,8 @@ VALUE_FROM_LOOP:
 	return
 }
 
-// CreateAndEnqueueWorkunits _
-func (qm *ServerMgr) CreateAndEnqueueWorkunits(task *Task, job *Job) (count int, err error) {
+// CreateAndEnqueueWorkunits a CWL workunit whose outputs are found in the call cache is not handed out, the returned internal notice completes it.
+func (qm *ServerMgr) CreateAndEnqueueWorkunits(task *Task, job *Job) (count int, notice *Notice, err error) {
 	//logger.Debug(3, "(CreateAndEnqueueWorkunits) starting")
 	//fmt.Println("--CreateAndEnqueueWorkunits--")
 	//spew.Dump(task)
@@ -5108,7 +5303,19 @@ func (qm *ServerMgr) CreateAndEnqueueWorkunits(task *Task, job *Job) (count int,
 		return
 	}
 	for _, wu := range workunits {
-		err = qm.workQueue.Add(wu)
+		// CWL tasks have a single workunit, it is looked up in the call cache before a worker can check it out
+		if len(workunits) == 1 && wu.CWLWorkunit != nil {
+			notice, err = qm.checkCallCache(job, task, wu)
+			if err != nil {
+				err = fmt.Errorf("(CreateAndEnqueueWorkunits) qm.checkCallCache returned: %s", err.Error())
+				return
+			}
+		}
+		if notice != nil {
+			err = qm.workQueue.AddInternal(wu, notice.Notes)
+		} else {
+			err = qm.workQueue.Add(wu)
+		}
 		if err != nil {
 			err = fmt.Errorf("(CreateAndEnqueueWorkunits) qm.workQueue.Add returned: %s", err.Error())
 			return
@@ -5359,7 +5566,15 @@ func (qm *ServerMgr) taskCompletedScatter(job *Job, wi *WorkflowInstance, task *
 		// }
 		//fmt.Println("final output_array:")
 		//spew.Dump(output_array)
-		scatterParentTask.StepOutput = scatterParentTask.StepOutput.Add(workflowStepOutputID, &outputArray)
+		finalOutputArray := &outputArray
+		if strings.ToLower(scatterParentStep.ScatterMethod) == "nested_crossproduct" && len(scatterParentTask.ScatterShape) > 1 {
+			finalOutputArray, err = NestCrossproduct(outputArray, scatterParentTask.ScatterShape)
+			if err != nil {
+				err = fmt.Errorf("(taskCompletedScatter) NestCrossproduct returned: %s", err.Error())
+				return
+			}
+		}
+		scatterParentTask.StepOutput = scatterParentTask.StepOutput.Add(workflowStepOutputID, finalOutputArray)
 
 	}
 
@@ -5485,6 +5700,12 @@ func (qm *ServerMgr) completeSubworkflow(job *Job, workflowInstance *WorkflowIns
 
 		outputID := output.Id
 
+		if workflowInstance.Skipped {
+			// when of the subworkflow step evaluated to false
+			workflowOutputsMap[outputID] = cwl.NewNull()
+			continue
+		}
+
 		if output.OutputBinding != nil {
 			// see http://www.commonwl.org/v1.0/Workflow.html#CommandOutputBinding
 			//spew.Dump(output.OutputBinding)
@@ -5575,6 +5796,13 @@ func (qm *ServerMgr) completeSubworkflow(job *Job, workflowInstance *WorkflowIns
 				err = fmt.Errorf("(completeSubworkflow) A) getCWLSource returns: %s", err.Error())
 				return
 			}
+			if ok && output.PickValue != "" {
+				obj, err = cwl.PickValue(output.PickValue, obj)
+				if err != nil {
+					err = fmt.Errorf("(completeSubworkflow) A) (output %s) cwl.PickValue returned: %s", outputID, err.Error())
+					return
+				}
+			}
 			skip := false
 			if !ok {
 				if isOptional {
@@ -5608,6 +5836,42 @@ func (qm *ServerMgr) completeSubworkflow(job *Job, workflowInstance *WorkflowIns
 				}
 			}
 
+			if output.PickValue != "" {
+				// sources that were not found count as null, the picked value has to match the output type
+				sourceValues := cwl.Array{}
+				for _, outputSourceString := range outputSourceArrayOfString {
+					var obj cwl.CWLType
+					obj, ok, _, err = qm.getCWLSource(job, workflowInstance, workflowInputsMap, outputSourceString, true, job.WorkflowContext)
+					if err != nil {
+						err = fmt.Errorf("(completeSubworkflow) C) (%s) getCWLSource returns: %s", workflowInstanceID, err.Error())
+						return
+					}
+					if !ok {
+						obj = cwl.NewNull()
+					}
+					sourceValues = append(sourceValues, obj)
+				}
+
+				var picked cwl.CWLType
+				picked, err = cwl.PickValue(output.PickValue, &sourceValues)
+				if err != nil {
+					err = fmt.Errorf("(completeSubworkflow) C) (output %s) cwl.PickValue returned: %s", outputID, err.Error())
+					return
+				}
+
+				hasType, xerr := cwl.TypeIsCorrect(expectedTypes, picked, context)
+				if xerr != nil {
+					err = fmt.Errorf("(completeSubworkflow) TypeIsCorrect: %s", xerr.Error())
+					return
+				}
+				if !hasType {
+					err = fmt.Errorf("(completeSubworkflow) C) workflow_ouput %s (type: %s), does not match expected types %s", outputID, reflect.TypeOf(picked), expectedTypes)
+					return
+				}
+				workflowOutputsMap[outputID] = picked
+				continue
+			}
+
 			output_array := cwl.Array{}
 
 			for _, outputSourceString := range outputSourceArrayOfString {
@@ -5994,6 +6258,7 @@ func (qm *ServerMgr) finalizeJob(job *Job) (err error) {
 		err = fmt.Errorf("(updateJobTask) job.SetState returned: %s", err.Error())
 		return
 	}
+	job.Notify(JOB_STAT_COMPLETED, nil)
 
 	var jobid string
 	jobid, err = job.GetID(true)
@@ -6058,6 +6323,14 @@ func (qm *ServerMgr) finalizeJob(job *Job) (err error) {
 			}
 		}
 	}
+
+	// delete intermediate step outputs now or later, depending on the retention policy
+	err = job.scheduleRetention()
+	if err != nil {
+		err = fmt.Errorf("(updateJobTask) job.scheduleRetention returned: %s", err.Error())
+		return
+	}
+
 	//log event about job done (JD)
 	logger.Event(event.JOB_DONE, "jobid="+job.ID+";name="+job.Info.Name+";project="+job.Info.Project+";user="+job.Info.User)
 
@@ -6212,11 +6485,17 @@ func (qm *ServerMgr) SuspendJob(jobid string, job *Job, jerror *JobError) (err e
 		reason = jerror.WorkNotes
 	}
 	logger.Event(this_event, "jobid="+jobid+";reason="+reason)
+	job.Notify(jerror.Status, jerror)
 	return
 }
 
 // DeleteJobByUser _
 func (qm *ServerMgr) DeleteJobByUser(jobid string, u *user.User, full bool) (err error) {
+	return qm.deleteJobByUser(jobid, u, NewUserRoles(u), full)
+}
+
+// deleteJobByUser roles of the user are passed in to delete many jobs with one query for the projects
+func (qm *ServerMgr) deleteJobByUser(jobid string, u *user.User, roles *UserRoles, full bool) (err error) {
 	var job *Job
 	job, err = GetJob(jobid)
 	if err != nil {
@@ -6224,7 +6503,7 @@ func (qm *ServerMgr) DeleteJobByUser(jobid string, u *user.User, full bool) (err
 	}
 	// User must have delete permissions on job or be job owner or be an admin
 	rights := job.ACL.Check(u.Uuid)
-	if job.ACL.Owner != u.Uuid && rights["delete"] == false && u.Admin == false {
+	if job.ACL.Owner != u.Uuid && rights["delete"] == false && u.Admin == false && roles.ProjectRights(job.Info.Project)["delete"] == false {
 		return errors.New(e.UnAuth)
 	}
 	if err = job.SetState(JOB_STAT_DELETED, nil); err != nil {
@@ -6270,8 +6549,9 @@ func (qm *ServerMgr) DeleteJobByUser(jobid string, u *user.User, full bool) (err
 }
 
 func (qm *ServerMgr) DeleteSuspendedJobsByUser(u *user.User, full bool) (num int) {
+	roles := NewUserRoles(u)
 	for id := range qm.GetSuspendJobs() {
-		if err := qm.DeleteJobByUser(id, u, full); err == nil {
+		if err := qm.deleteJobByUser(id, u, roles, full); err == nil {
 			num += 1
 		}
 	}
@@ -6279,8 +6559,9 @@ func (qm *ServerMgr) DeleteSuspendedJobsByUser(u *user.User, full bool) (num int
 }
 
 func (qm *ServerMgr) ResumeSuspendedJobsByUser(u *user.User) (num int) {
+	roles := NewUserRoles(u)
 	for id := range qm.GetSuspendJobs() {
-		if err := qm.ResumeSuspendedJobByUser(id, u); err == nil {
+		if err := qm.resumeSuspendedJobByUser(id, u, roles); err == nil {
 			num += 1
 		}
 	}
@@ -6296,9 +6577,10 @@ func (qm *ServerMgr) DeleteZombieJobsByUser(u *user.User, full bool) (num int) {
 		logger.Error("DeleteZombieJobs()->GetAllLimitOffset():" + err.Error())
 		return
 	}
+	roles := NewUserRoles(u)
 	for _, dbjob := range *dbjobs {
 		if !qm.isActJob(dbjob.ID) {
-			if err := qm.DeleteJobByUser(dbjob.ID, u, full); err == nil {
+			if err := qm.deleteJobByUser(dbjob.ID, u, roles, full); err == nil {
 				num += 1
 			}
 		}
@@ -6308,6 +6590,10 @@ func (qm *ServerMgr) DeleteZombieJobsByUser(u *user.User, full bool) (num int) {
 
 //resubmit a suspended job if the user is authorized
 func (qm *ServerMgr) ResumeSuspendedJobByUser(id string, u *user.User) (err error) {
+	return qm.resumeSuspendedJobByUser(id, u, NewUserRoles(u))
+}
+
+func (qm *ServerMgr) resumeSuspendedJobByUser(id string, u *user.User, roles *UserRoles) (err error) {
 	//Load job by id
 	dbjob, err := GetJob(id)
 	if err != nil {
@@ -6323,7 +6609,7 @@ func (qm *ServerMgr) ResumeSuspendedJobByUser(id string, u *user.User) (err erro
 
 	// User must have write permissions on job or be job owner or be an admin
 	rights := dbjob.ACL.Check(u.Uuid)
-	if dbjob.ACL.Owner != u.Uuid && rights["write"] == false && u.Admin == false {
+	if dbjob.ACL.Owner != u.Uuid && rights["write"] == false && u.Admin == false && roles.ProjectRights(dbjob.Info.Project)["write"] == false {
 		err = errors.New(e.UnAuth)
 		return
 	}
@@ -6865,6 +7151,7 @@ func (qm *ServerMgr) FinalizeWorkPerf(id Workunit_Unique_Identifier, reportfile
 	}
 
 	workperf.Queued = jobperf.Pworks[workStr].Queued
+	workperf.Tool = jobperf.Pworks[workStr].Tool
 	workperf.Done = time.Now().Unix()
 	workperf.Resp = workperf.Done - workperf.Queued
 	jobperf.Pworks[workStr] = workperf
@@ -6873,6 +7160,26 @@ func (qm *ServerMgr) FinalizeWorkPerf(id Workunit_Unique_Identifier, reportfile
 	return
 }
 
+// recordRuntime adds the runtime of a completed workunit to the SJF statistics and keeps it with the
+// work perf of the job, stored work perfs seed the statistics when the server starts (see InitRuntimeStats)
+func (qm *ServerMgr) recordRuntime(work *Workunit, workStr string, seconds int64) {
+	qm.workQueue.Runtimes.Add(work, seconds)
+	jobperf, ok := qm.getActJob(work.JobId)
+	if !ok {
+		return
+	}
+	workperf, ok := jobperf.Pworks[workStr]
+	if !ok {
+		workperf = NewWorkPerf()
+		jobperf.Pworks[workStr] = workperf
+	}
+	workperf.Tool = runtimeKey(work)
+	if workperf.Runtime == 0 {
+		workperf.Runtime = seconds
+	}
+	qm.putActJob(jobperf)
+}
+
 func (qm *ServerMgr) LogJobPerf(jobid string) {
 	if perf, ok := qm.getActJob(jobid); ok {
 		perfstr, _ := json.Marshal(perf)
@@ -1,6 +1,8 @@
 package core
 
 import (
+	"fmt"
+
 	"github.com/MG-RAST/AWE/lib/core/cwl"
 	"github.com/MG-RAST/AWE/lib/logger"
 )
@@ -80,3 +82,38 @@ func (sc *SetCounter) Increment() (ok bool) {
 	return
 
 }
+
+// NestCrossproduct turns the outputs of a nested_crossproduct scatter into nested arrays, one level per scatter input.
+// values have to be in the order of the counter (last scatter input changes fastest), shape is the length of each scatter array.
+func NestCrossproduct(values []cwl.CWLType, shape []int) (result *cwl.Array, err error) {
+	if len(shape) == 0 {
+		err = fmt.Errorf("(NestCrossproduct) shape is empty")
+		return
+	}
+	expected := 1
+	for _, length := range shape {
+		expected *= length
+	}
+	if len(values) != expected {
+		err = fmt.Errorf("(NestCrossproduct) expected %d values for shape %v, got %d", expected, shape, len(values))
+		return
+	}
+	result = nestArray(values, shape)
+	return
+}
+
+func nestArray(values []cwl.CWLType, shape []int) *cwl.Array {
+	result := cwl.Array{}
+	if len(shape) == 1 {
+		result = append(result, values...)
+		return &result
+	}
+	if shape[0] == 0 {
+		return &result
+	}
+	size := len(values) / shape[0]
+	for i := 0; i < shape[0]; i++ {
+		result = append(result, nestArray(values[i*size:(i+1)*size], shape[1:]))
+	}
+	return &result
+}
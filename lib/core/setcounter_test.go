@@ -0,0 +1,48 @@
+package core_test
+
+import (
+	"testing"
+
+	. "github.com/MG-RAST/AWE/lib/core"
+	"github.com/MG-RAST/AWE/lib/core/cwl"
+)
+
+func TestNestCrossproduct(t *testing.T) {
+	values := []cwl.CWLType{}
+	for i := 0; i < 6; i++ {
+		value, _ := cwl.NewInt(i, nil)
+		values = append(values, value)
+	}
+
+	nested, err := NestCrossproduct(values, []int{2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nested.Len() != 2 {
+		t.Fatalf("expected 2 rows, got %d", nested.Len())
+	}
+	for i, row := range *nested {
+		rowArray, ok := row.(*cwl.Array)
+		if !ok || rowArray.Len() != 3 {
+			t.Fatalf("row %d is not an array of length 3", i)
+		}
+		for j, value := range *rowArray {
+			if int(*value.(*cwl.Int)) != i*3+j {
+				t.Errorf("unexpected value at [%d][%d]: %s", i, j, value.String())
+			}
+		}
+	}
+
+	// an empty inner array still produces the outer level
+	nested, err = NestCrossproduct([]cwl.CWLType{}, []int{2, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nested.Len() != 2 || (*nested)[0].(*cwl.Array).Len() != 0 {
+		t.Errorf("expected [[],[]], got %d elements", nested.Len())
+	}
+
+	if _, err = NestCrossproduct(values, []int{4, 2}); err == nil {
+		t.Errorf("expected an error for a wrong shape")
+	}
+}
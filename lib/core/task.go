@@ -74,6 +74,7 @@ type TaskRaw struct {
 	ComputeTime   int                    `bson:"computetime" json:"computetime" mapstructure:"computetime"`
 	UserAttr      map[string]interface{} `bson:"userattr" json:"userattr" mapstructure:"userattr"`
 	ClientGroups  string                 `bson:"clientgroups" json:"clientgroups" mapstructure:"clientgroups"`
+	Retry         *RetryPolicy           `bson:"retry,omitempty" json:"retry,omitempty" mapstructure:"retry,omitempty"` // overrides max_work_failure for the workunits of this task
 	//WorkflowStep           *cwl.WorkflowStep      `bson:"workflowStep" json:"workflowStep" mapstructure:"workflowStep"`    // CWL-only
 	StepOutputInterface    interface{}       `bson:"stepOutput" json:"stepOutput" mapstructure:"stepOutput"`          // CWL-only
 	ProcessOutputInterface interface{}       `bson:"processOutput" json:"processOutput" mapstructure:"processOutput"` // CWL-only
@@ -89,6 +90,7 @@ type TaskRaw struct {
 	WorkflowInstanceUUID string                  `bson:"workflow_instance_uuid" json:"workflow_instance_uuid" mapstructure:"workflow_instance_uuid"`    // CWL-only
 	job                  *Job                    `bson:"-"  mapstructure:"-"`                                                                           // caching only
 	NotReadyReason       string                  `bson:"notReadyReason" json:"notReadyReason" mapstructure:"-"`
+	ReusedFrom           string                  `bson:"reused_from,omitempty" json:"reused_from,omitempty" mapstructure:"-"` // job that computed the outputs, if they were taken from the call cache
 	//WorkflowParent      *Task_Unique_Identifier  `bson:"workflow_parent" json:"workflow_parent" mapstructure:"workflow_parent"`                         // CWL-only parent that created subworkflow
 }
 
@@ -442,6 +444,13 @@ func (task *Task) Init(job *Job, job_id string) (changed bool, err error) {
 		changed = true
 	}
 
+	if task.Retry != nil {
+		err = task.Retry.Validate()
+		if err != nil {
+			return
+		}
+	}
+
 	// set node / host / url for files
 	for _, io := range task.Inputs {
 		if io.Node == "" {
@@ -628,6 +637,64 @@ func (task *TaskRaw) SetScatterChildren(qm *ServerMgr, scatterChildren []string,
 	return
 }
 
+// SetScatterShape _
+func (task *TaskRaw) SetScatterShape(scatterShape []int, writelock bool) (err error) {
+
+	if writelock {
+		err = task.LockNamed("SetScatterShape")
+		if err != nil {
+			return
+		}
+		defer task.Unlock()
+	}
+
+	if task.WorkflowInstanceID == "" {
+		err = dbUpdateJobTaskField(task.JobId, task.WorkflowInstanceID, task.ID, "scatterShape", scatterShape)
+		if err != nil {
+			err = fmt.Errorf("(SetScatterShape) dbUpdateJobTaskField returned: %s", err.Error())
+			return
+		}
+	} else {
+		err = dbUpdateTaskField(task.WorkflowInstanceUUID, task.ID, "scatterShape", scatterShape)
+		if err != nil {
+			err = fmt.Errorf("(SetScatterShape) dbUpdateTaskField returned: %s", err.Error())
+			return
+		}
+	}
+
+	task.ScatterShape = scatterShape
+	return
+}
+
+// SetSkipped _
+func (task *TaskRaw) SetSkipped(skipped bool, writelock bool) (err error) {
+
+	if writelock {
+		err = task.LockNamed("SetSkipped")
+		if err != nil {
+			return
+		}
+		defer task.Unlock()
+	}
+
+	if task.WorkflowInstanceID == "" {
+		err = dbUpdateJobTaskField(task.JobId, task.WorkflowInstanceID, task.ID, "skipped", skipped)
+		if err != nil {
+			err = fmt.Errorf("(SetSkipped) dbUpdateJobTaskField returned: %s", err.Error())
+			return
+		}
+	} else {
+		err = dbUpdateTaskField(task.WorkflowInstanceUUID, task.ID, "skipped", skipped)
+		if err != nil {
+			err = fmt.Errorf("(SetSkipped) dbUpdateTaskField returned: %s", err.Error())
+			return
+		}
+	}
+
+	task.Skipped = skipped
+	return
+}
+
 // GetScatterChildren _
 func (task *TaskRaw) GetScatterChildren(wi *WorkflowInstance, qm *ServerMgr) (children []*Task, err error) {
 	lock, err := task.RLockNamed("GetScatterChildren")
@@ -886,6 +953,34 @@ func (task *TaskRaw) SetCompletedDate(t time.Time, lock bool) (err error) {
 	return
 }
 
+// SetReusedFrom records the job whose outputs were reused from the call cache, these outputs are never deleted with this job
+func (task *TaskRaw) SetReusedFrom(jobID string, lock bool) (err error) {
+	if lock {
+		err = task.LockNamed("SetReusedFrom")
+		if err != nil {
+			return
+		}
+		defer task.Unlock()
+	}
+
+	if task.WorkflowInstanceID == "" {
+		err = dbUpdateJobTaskString(task.JobId, task.WorkflowInstanceID, task.ID, "reused_from", jobID)
+		if err != nil {
+			err = fmt.Errorf("(task/SetReusedFrom) dbUpdateJobTaskString returned: %s", err.Error())
+			return
+		}
+	} else {
+		err = dbUpdateTaskString(task.WorkflowInstanceUUID, task.ID, "reused_from", jobID)
+		if err != nil {
+			err = fmt.Errorf("(task/SetReusedFrom) dbUpdateTaskString returned: %s", err.Error())
+			return
+		}
+	}
+
+	task.ReusedFrom = jobID
+	return
+}
+
 // SetStepOutput _
 func (task *TaskRaw) SetStepOutput(jd cwl.Job_document, lock bool) (err error) {
 	if lock {
@@ -1091,6 +1186,8 @@ func (task *TaskRaw) SetState(newState string, writeLock bool, caller string) (e
 
 	logger.Debug(3, "(TaskRaw/SetState) %s new state: \"%s\" (old state \"%s\")", taskid, newState, oldState)
 	task.State = newState
+	taskStr, _ := task.String()
+	RecordStateChange(&StateChange{JobID: jobid, Type: HISTORY_TASK, ID: taskStr, OldState: oldState, NewState: newState, Caller: caller})
 
 	if newState == TASK_STAT_COMPLETED {
 		var wi *WorkflowInstance
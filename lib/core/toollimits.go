@@ -0,0 +1,50 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/MG-RAST/AWE/lib/core/cwl"
+)
+
+// NewToolLimitsFromCWL reads the CWL v1.1 requirements the worker enforces when it runs the tool, a requirement has precedence over a hint.
+// The requirements have to be evaluated already.
+// timeLimit is ToolTimeLimit in seconds (0 means no limit), networkAccess is nil if the tool has no NetworkAccess requirement,
+// loadListing is the value of LoadListingRequirement (empty if there is none).
+func NewToolLimitsFromCWL(requirements []cwl.Requirement, hints []cwl.Requirement) (timeLimit int64, networkAccess *bool, loadListing string, err error) {
+
+	ttl := cwl.GetToolTimeLimit(requirements)
+	if ttl == nil {
+		ttl = cwl.GetToolTimeLimit(hints)
+	}
+	if ttl != nil {
+		timeLimit, err = ttl.GetSeconds()
+		if err != nil {
+			err = fmt.Errorf("(NewToolLimitsFromCWL) ToolTimeLimit: %s", err.Error())
+			return
+		}
+	}
+
+	na := cwl.GetNetworkAccess(requirements)
+	if na == nil {
+		na = cwl.GetNetworkAccess(hints)
+	}
+	if na != nil {
+		var enabled bool
+		enabled, err = na.IsEnabled()
+		if err != nil {
+			err = fmt.Errorf("(NewToolLimitsFromCWL) NetworkAccess: %s", err.Error())
+			return
+		}
+		networkAccess = &enabled
+	}
+
+	llr := cwl.GetLoadListingRequirement(requirements)
+	if llr == nil {
+		llr = cwl.GetLoadListingRequirement(hints)
+	}
+	if llr != nil {
+		loadListing = llr.LoadListing
+	}
+
+	return
+}
@@ -0,0 +1,31 @@
+package core
+
+import (
+	"sync"
+)
+
+// workNotifier wakes up checkout requests that wait for work (long-poll), all waiting requests are woken at once
+type workNotifier struct {
+	sync.Mutex
+	ch chan struct{}
+}
+
+// Wait returns a channel that is closed the next time Notify is called
+func (n *workNotifier) Wait() <-chan struct{} {
+	n.Lock()
+	defer n.Unlock()
+	if n.ch == nil {
+		n.ch = make(chan struct{})
+	}
+	return n.ch
+}
+
+// Notify is called when a workunit has been queued
+func (n *workNotifier) Notify() {
+	n.Lock()
+	defer n.Unlock()
+	if n.ch != nil {
+		close(n.ch)
+		n.ch = nil
+	}
+}
@@ -0,0 +1,308 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/MG-RAST/AWE/lib/conf"
+	"github.com/MG-RAST/AWE/lib/db"
+	"github.com/MG-RAST/AWE/lib/logger"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// workunit selection policies, can be set per client group or per checkout request
+const (
+	WORK_POLICY_FCFS              = "FCFS"             // priority first, then submission time
+	WORK_POLICY_FAIRSHARE_USER    = "FairShareUser"    // users with fewest workunits in checkout are served first
+	WORK_POLICY_FAIRSHARE_PROJECT = "FairShareProject" // projects with fewest workunits in checkout are served first
+	WORK_POLICY_SJF               = "SJF"              // shortest expected runtime first, based on runtimes of completed workunits
+	WORK_POLICY_LOCALITY          = "Locality"         // prefer workunits of jobs the client has recently worked on
+)
+
+// WorkPolicies all supported workunit selection policies
+var WorkPolicies = []string{WORK_POLICY_FCFS, WORK_POLICY_FAIRSHARE_USER, WORK_POLICY_FAIRSHARE_PROJECT, WORK_POLICY_SJF, WORK_POLICY_LOCALITY}
+
+// number of jobs per client remembered for the Locality policy
+const recentJobsMax = 10
+
+var scatterSuffix = regexp.MustCompile(`_scatter[0-9]+`)
+
+// WorkPolicy orders the workunits that are eligible for a client, the first workunits are handed out first
+type WorkPolicy interface {
+	Order(workunits WorkList, client *Client) WorkList
+}
+
+// IsValidWorkPolicy _
+func IsValidWorkPolicy(name string) bool {
+	return contains(WorkPolicies, name)
+}
+
+// NewWorkPolicy returns the policy with the given name, empty name means server default.
+// All policies order by effective priority (see AgingPolicy) before applying their own criteria, aging may be nil.
+func NewWorkPolicy(name string, wq *WorkQueue, aging *AgingPolicy) (policy WorkPolicy, err error) {
+	if name == "" {
+		name = conf.WORK_POLICY
+	}
+	switch name {
+	case "", WORK_POLICY_FCFS:
+		policy = &fcfsPolicy{aging: aging}
+	case WORK_POLICY_FAIRSHARE_USER:
+		policy = &fairSharePolicy{wq: wq, aging: aging, key: func(w *Workunit) string { return w.Info.User }}
+	case WORK_POLICY_FAIRSHARE_PROJECT:
+		policy = &fairSharePolicy{wq: wq, aging: aging, key: func(w *Workunit) string { return w.Info.Project }}
+	case WORK_POLICY_SJF:
+		policy = &sjfPolicy{runtimes: wq.Runtimes, aging: aging}
+	case WORK_POLICY_LOCALITY:
+		policy = &localityPolicy{aging: aging}
+	default:
+		err = fmt.Errorf("(NewWorkPolicy) unknown policy \"%s\" (supported: %s)", name, strings.Join(WorkPolicies, ", "))
+	}
+	return
+}
+
+// ------------- FCFS -------------
+
+type fcfsPolicy struct {
+	aging *AgingPolicy
+}
+
+func (p *fcfsPolicy) Order(workunits WorkList, client *Client) WorkList {
+	sort.Sort(newByFCFS(workunits, p.aging))
+	return workunits
+}
+
+// ------------- fair-share -------------
+
+// fairSharePolicy hands out workunits round-robin between owners (user or project),
+// starting with the owner that has the fewest workunits checked out right now
+type fairSharePolicy struct {
+	wq    *WorkQueue
+	aging *AgingPolicy
+	key   func(*Workunit) string
+}
+
+func (p *fairSharePolicy) Order(workunits WorkList, client *Client) (ordered WorkList) {
+
+	running := make(map[string]int)
+	checkedOut, err := p.wq.Checkout.GetWorkunits()
+	if err == nil {
+		for _, work := range checkedOut {
+			if work.Info != nil {
+				running[p.key(work)]++
+			}
+		}
+	}
+
+	// within an owner, keep FCFS order
+	sort.Sort(newByFCFS(workunits, p.aging))
+
+	groups := make(map[string]WorkList)
+	owners := []string{} // in order of first appearance, i.e. ties are resolved by priority
+	for _, work := range workunits {
+		owner := p.key(work)
+		if _, ok := groups[owner]; !ok {
+			owners = append(owners, owner)
+		}
+		groups[owner] = append(groups[owner], work)
+	}
+
+	ordered = make(WorkList, 0, len(workunits))
+	for len(ordered) < len(workunits) {
+		best := ""
+		found := false
+		for _, owner := range owners {
+			if len(groups[owner]) == 0 {
+				continue
+			}
+			if !found || running[owner] < running[best] {
+				best = owner
+				found = true
+			}
+		}
+		ordered = append(ordered, groups[best][0])
+		groups[best] = groups[best][1:]
+		running[best]++
+	}
+	return
+}
+
+// ------------- shortest-job-first -------------
+
+type sjfPolicy struct {
+	runtimes *RuntimeStats
+	aging    *AgingPolicy
+}
+
+func (p *sjfPolicy) Order(workunits WorkList, client *Client) WorkList {
+	sort.Sort(newByFCFS(workunits, p.aging))
+
+	// workunits of unknown tools are assumed to take an average amount of time
+	defaultEstimate := p.runtimes.Average()
+	estimates := make([]float64, len(workunits))
+	for i, work := range workunits {
+		estimate, ok := p.runtimes.Estimate(work)
+		if !ok {
+			estimate = defaultEstimate
+		}
+		estimates[i] = estimate
+	}
+	sort.Stable(byEstimate{WorkList: workunits, estimates: estimates})
+	return workunits
+}
+
+type byEstimate struct {
+	WorkList
+	estimates []float64
+}
+
+func (s byEstimate) Swap(i, j int) {
+	s.WorkList[i], s.WorkList[j] = s.WorkList[j], s.WorkList[i]
+	s.estimates[i], s.estimates[j] = s.estimates[j], s.estimates[i]
+}
+
+func (s byEstimate) Less(i, j int) bool {
+	return s.estimates[i] < s.estimates[j]
+}
+
+// ------------- data locality -------------
+
+// localityPolicy prefers workunits of jobs the client worked on recently, their
+// intermediate files and predata are likely still cached on the worker
+type localityPolicy struct {
+	aging *AgingPolicy
+}
+
+func (p *localityPolicy) Order(workunits WorkList, client *Client) WorkList {
+	sort.Sort(newByFCFS(workunits, p.aging))
+	if client == nil || len(client.RecentJobs) == 0 {
+		return workunits
+	}
+
+	// most recent job has the highest rank
+	rank := make(map[string]int, len(client.RecentJobs))
+	for i, jobid := range client.RecentJobs {
+		rank[jobid] = len(client.RecentJobs) - i
+	}
+	ranks := make([]float64, len(workunits))
+	for i, work := range workunits {
+		ranks[i] = -float64(rank[work.JobId])
+	}
+	sort.Stable(byEstimate{WorkList: workunits, estimates: ranks})
+	return workunits
+}
+
+// ------------- runtime statistics -------------
+
+type runtimeAverage struct {
+	count int64
+	mean  float64
+}
+
+// RuntimeStats running average of workunit runtimes (in seconds) per tool, used by the SJF policy
+type RuntimeStats struct {
+	sync.RWMutex
+	tools map[string]*runtimeAverage
+	all   runtimeAverage
+}
+
+// NewRuntimeStats _
+func NewRuntimeStats() *RuntimeStats {
+	return &RuntimeStats{tools: make(map[string]*runtimeAverage)}
+}
+
+// runtimeKey identifies the tool of a workunit independent of job and scatter position
+func runtimeKey(work *Workunit) string {
+	if work.Cmd != nil && work.Cmd.Name != "" {
+		return work.Cmd.Name
+	}
+	pipeline := ""
+	if work.Info != nil {
+		pipeline = work.Info.Pipeline
+	}
+	return pipeline + ":" + scatterSuffix.ReplaceAllString(work.TaskName, "")
+}
+
+// Add records the runtime of a completed workunit (WorkPerf.Runtime as reported by the worker)
+func (rs *RuntimeStats) Add(work *Workunit, seconds int64) {
+	rs.add(runtimeKey(work), seconds)
+}
+
+func (rs *RuntimeStats) add(key string, seconds int64) {
+	if seconds < 0 {
+		return
+	}
+	rs.Lock()
+	defer rs.Unlock()
+	avg, ok := rs.tools[key]
+	if !ok {
+		avg = &runtimeAverage{}
+		rs.tools[key] = avg
+	}
+	avg.count++
+	avg.mean += (float64(seconds) - avg.mean) / float64(avg.count)
+	rs.all.count++
+	rs.all.mean += (float64(seconds) - rs.all.mean) / float64(rs.all.count)
+}
+
+// Estimate returns the average runtime of the tool of this workunit
+func (rs *RuntimeStats) Estimate(work *Workunit) (seconds float64, ok bool) {
+	key := runtimeKey(work)
+	rs.RLock()
+	defer rs.RUnlock()
+	avg, ok := rs.tools[key]
+	if !ok {
+		return
+	}
+	seconds = avg.mean
+	return
+}
+
+// Average returns the average runtime over all tools
+func (rs *RuntimeStats) Average() float64 {
+	rs.RLock()
+	defer rs.RUnlock()
+	return rs.all.mean
+}
+
+// runtimeHistoryJobs number of most recent job perfs the runtime statistics are seeded from
+const runtimeHistoryJobs = 1000
+
+// Load seeds the statistics with the stored work perfs of recently completed jobs,
+// only work perfs with a tool (set by the server since the SJF policy exists) are used
+func (rs *RuntimeStats) Load() (count int, err error) {
+	session := db.Connection.Session.Copy()
+	defer session.Close()
+	c := session.DB(conf.MONGODB_DATABASE).C(conf.DB_COLL_PERF)
+	perfs := []*JobPerf{}
+	err = c.Find(bson.M{"work_stats": bson.M{"$exists": true}}).Sort("-_id").Limit(runtimeHistoryJobs).All(&perfs)
+	if err != nil {
+		err = fmt.Errorf("(RuntimeStats/Load) %s", err.Error())
+		return
+	}
+	for _, perf := range perfs {
+		for _, workperf := range perf.Pworks {
+			if workperf == nil || workperf.Tool == "" || workperf.Runtime <= 0 {
+				continue
+			}
+			rs.add(workperf.Tool, workperf.Runtime)
+			count++
+		}
+	}
+	return
+}
+
+// InitRuntimeStats seeds the runtime statistics of the SJF policy when the server starts
+func InitRuntimeStats() {
+	if QMgr == nil {
+		return
+	}
+	count, err := QMgr.workQueue.Runtimes.Load()
+	if err != nil {
+		logger.Error("(InitRuntimeStats) %s", err.Error())
+		return
+	}
+	logger.Info("(InitRuntimeStats) runtimes of %d workunits loaded", count)
+}
@@ -0,0 +1,196 @@
+package core_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MG-RAST/AWE/lib/conf"
+	. "github.com/MG-RAST/AWE/lib/core"
+)
+
+func newTestWorkunit(user string, taskName string, submit time.Time) *Workunit {
+	work := &Workunit{Info: &Info{User: user, Priority: 1, SubmitTime: submit}, Cmd: &Command{Name: taskName}}
+	work.TaskName = taskName
+	return work
+}
+
+func TestFairShareUser(t *testing.T) {
+	wq := NewWorkQueue()
+	now := time.Now()
+
+	// user "a" submitted many workunits before user "b"
+	workunits := WorkList{}
+	for i := 0; i < 5; i++ {
+		workunits = append(workunits, newTestWorkunit("a", "tool", now.Add(time.Duration(i)*time.Second)))
+	}
+	workunits = append(workunits, newTestWorkunit("b", "tool", now.Add(time.Minute)))
+
+	policy, err := NewWorkPolicy(WORK_POLICY_FAIRSHARE_USER, wq, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ordered := policy.Order(workunits, nil)
+	if ordered[0].Info.User != "a" || ordered[1].Info.User != "b" {
+		t.Errorf("expected user b to get the second workunit, got order %s, %s", ordered[0].Info.User, ordered[1].Info.User)
+	}
+	if len(ordered) != len(workunits) {
+		t.Errorf("expected %d workunits, got %d", len(workunits), len(ordered))
+	}
+}
+
+func TestShortestJobFirst(t *testing.T) {
+	wq := NewWorkQueue()
+	now := time.Now()
+
+	long := newTestWorkunit("a", "assembly", now)
+	short := newTestWorkunit("a", "qc", now.Add(time.Second))
+	wq.Runtimes.Add(long, 3600)
+	wq.Runtimes.Add(short, 10)
+
+	policy, err := NewWorkPolicy(WORK_POLICY_SJF, wq, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ordered := policy.Order(WorkList{long, short}, nil)
+	if ordered[0] != short {
+		t.Errorf("expected short workunit first")
+	}
+}
+
+func TestUnknownPolicy(t *testing.T) {
+	if _, err := NewWorkPolicy("LIFO", NewWorkQueue(), nil); err == nil {
+		t.Errorf("expected error for unknown policy")
+	}
+}
+
+func TestPriorityAging(t *testing.T) {
+	now := time.Now()
+
+	// low priority workunit waiting for 10 hours, high priority workunit just queued
+	old := newTestWorkunit("a", "tool", now.Add(-10*time.Hour))
+	old.State = WORK_STAT_QUEUED
+	recent := newTestWorkunit("b", "tool", now)
+	recent.State = WORK_STAT_QUEUED
+	recent.Info.Priority = 5
+
+	policy, err := NewWorkPolicy(WORK_POLICY_FCFS, NewWorkQueue(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ordered := policy.Order(WorkList{old, recent}, nil); ordered[0] != recent {
+		t.Errorf("expected higher priority first without aging")
+	}
+
+	aging := &AgingPolicy{Rate: 1, Max: 8}
+	if p := aging.WorkunitPriority(old, now); p != 9 {
+		t.Errorf("expected effective priority 9 (capped), got %d", p)
+	}
+	policy, err = NewWorkPolicy(WORK_POLICY_FCFS, NewWorkQueue(), aging)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ordered := policy.Order(WorkList{recent, old}, nil); ordered[0] != old {
+		t.Errorf("expected aged workunit first")
+	}
+}
+
+func TestEffectivePriorities(t *testing.T) {
+	conf.PRIORITY_AGING_RATE = 1
+	conf.PRIORITY_AGING_MAX = 0
+	defer func() { conf.PRIORITY_AGING_RATE = 0 }()
+
+	work := newTestWorkunit("a", "tool", time.Now().Add(-3*time.Hour))
+	work.State = WORK_STAT_QUEUED
+
+	listed := EffectivePriorities([]*Workunit{work}, "")
+	if listed[0] == work || work.EffectivePriority != 0 {
+		t.Errorf("expected a copy, the queued workunit must not be modified")
+	}
+	if listed[0].EffectivePriority != 4 {
+		t.Errorf("expected effective priority 4, got %d", listed[0].EffectivePriority)
+	}
+}
+
+func TestAgingPriority(t *testing.T) {
+	now := time.Now()
+	for _, test := range []struct {
+		aging    *AgingPolicy
+		waited   time.Duration
+		expected int
+	}{
+		{&AgingPolicy{Rate: 2}, 3 * time.Hour, 7},
+		{&AgingPolicy{Rate: 2, Max: 4}, 3 * time.Hour, 5},
+		{&AgingPolicy{Rate: 2, Max: 10}, 3 * time.Hour, 7},
+		{&AgingPolicy{Rate: 2}, 30 * time.Minute, 2},
+		{&AgingPolicy{Rate: 0, Max: 4}, 3 * time.Hour, 1},
+		{&AgingPolicy{Rate: -1}, 3 * time.Hour, 1},
+		{nil, 3 * time.Hour, 1},
+		{&AgingPolicy{Rate: 2}, -time.Hour, 1}, // queued in the future
+	} {
+		if p := test.aging.Priority(1, now.Add(-test.waited), now); p != test.expected {
+			t.Errorf("%+v after %s: expected priority %d, got %d", test.aging, test.waited, test.expected, p)
+		}
+	}
+	if p := (&AgingPolicy{Rate: 2}).Priority(1, time.Time{}, now); p != 1 {
+		t.Errorf("expected base priority without queue time, got %d", p)
+	}
+
+	// only queued workunits age, from the time they were queued or else submitted
+	aging := &AgingPolicy{Rate: 1}
+	work := newTestWorkunit("a", "tool", now.Add(-5*time.Hour))
+	for _, state := range []string{WORK_STAT_CHECKOUT, WORK_STAT_RESERVED, WORK_STAT_SUSPEND, WORK_STAT_DONE} {
+		work.State = state
+		if p := aging.WorkunitPriority(work, now); p != 1 {
+			t.Errorf("%s: expected base priority 1, got %d", state, p)
+		}
+	}
+	work.State = WORK_STAT_QUEUED
+	if p := aging.WorkunitPriority(work, now); p != 6 {
+		t.Errorf("expected priority 6 since submission, got %d", p)
+	}
+	work.QueuedTime = now.Add(-2 * time.Hour)
+	if p := aging.WorkunitPriority(work, now); p != 3 {
+		t.Errorf("expected priority 3 since queued, got %d", p)
+	}
+	if p := (*AgingPolicy)(nil).WorkunitPriority(work, now); p != 1 {
+		t.Errorf("expected base priority without policy, got %d", p)
+	}
+}
+
+func TestAgingClientGroup(t *testing.T) {
+	for _, test := range []struct {
+		clientgroups string
+		requested    string
+		expected     string
+	}{
+		{"", "", ""},
+		{"", "gpu", "gpu"},
+		{"gpu", "", "gpu"},
+		{" gpu ", "", "gpu"},
+		{"gpu,cpu", "", ""},
+		{"gpu, cpu", "cpu", "cpu"},
+		{"gpu,cpu", "big", ""},
+		{"gpu", "big", "gpu"},
+	} {
+		if name := AgingClientGroup(test.clientgroups, test.requested); name != test.expected {
+			t.Errorf("clientgroups \"%s\", aging_clientgroup \"%s\": expected \"%s\", got \"%s\"", test.clientgroups, test.requested, test.expected, name)
+		}
+	}
+
+	// clientgroups without policy use the server default, rate 0 disables aging
+	conf.PRIORITY_AGING_RATE = 3
+	conf.PRIORITY_AGING_MAX = 0
+	defer func() { conf.PRIORITY_AGING_RATE = 0 }()
+	if aging := (*ClientGroup)(nil).GetAgingPolicy(); aging == nil || aging.Rate != 3 {
+		t.Errorf("expected server default, got %+v", aging)
+	}
+	if aging := (&ClientGroup{}).GetAgingPolicy(); aging == nil || aging.Rate != 3 {
+		t.Errorf("expected server default, got %+v", aging)
+	}
+	if aging := (&ClientGroup{Aging: &AgingPolicy{Rate: 0}}).GetAgingPolicy(); aging != nil {
+		t.Errorf("expected aging disabled, got %+v", aging)
+	}
+	if aging := (&ClientGroup{Aging: &AgingPolicy{Rate: 1, Max: 5}}).GetAgingPolicy(); aging == nil || aging.Max != 5 {
+		t.Errorf("expected the policy of the clientgroup, got %+v", aging)
+	}
+}
@@ -274,6 +274,27 @@ func (wi *WorkflowInstance) SetScatterChildren(scatterChildren []string, writelo
 	return
 }
 
+// SetSkipped _
+func (wi *WorkflowInstance) SetSkipped(skipped bool, writelock bool) (err error) {
+
+	if writelock {
+		err = wi.LockNamed("SetSkipped")
+		if err != nil {
+			return
+		}
+		defer wi.Unlock()
+	}
+
+	err = dbUpdateWorkflowInstancesField(wi.ID, "skipped", skipped)
+	if err != nil {
+		err = fmt.Errorf("(WorkflowInstance/SetSkipped) dbUpdateWorkflowInstancesField returned: %s", err.Error())
+		return
+	}
+
+	wi.Skipped = skipped
+	return
+}
+
 // AddTask db_sync is a string because a bool would be misunderstood as a lock indicator ("db_sync_no", db_sync_yes)
 func (wi *WorkflowInstance) AddTask(job *Job, task *Task, dbSync bool, writeLock bool) (err error) {
 	fmt.Println("(WorkflowInstance/AddTask) start")
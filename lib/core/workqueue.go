@@ -2,22 +2,25 @@ package core
 
 import (
 	"errors"
-	"sort"
 
 	e "github.com/MG-RAST/AWE/lib/errors"
 	"github.com/MG-RAST/AWE/lib/logger"
 
 	//"sync"
 	"fmt"
+	"time"
 )
 
 type WorkQueue struct {
 	//sync.RWMutex
 	//workMap  map[string]*Workunit //all parsed workunits
 	all      WorkunitMap
-	Queue    WorkunitMap // WORK_STAT_QUEUED - waiting workunits
-	Checkout WorkunitMap // WORK_STAT_CHECKOUT - workunits being checked out
-	Suspend  WorkunitMap // WORK_STAT_SUSPEND - suspended workunits
+	Queue    WorkunitMap   // WORK_STAT_QUEUED - waiting workunits
+	Checkout WorkunitMap   // WORK_STAT_CHECKOUT - workunits being checked out
+	Suspend  WorkunitMap   // WORK_STAT_SUSPEND - suspended workunits
+	Runtimes *RuntimeStats // runtimes of completed workunits, used by the SJF policy
+
+	queued *workNotifier // wakes up long-polling checkout requests
 }
 
 func NewWorkQueue() *WorkQueue {
@@ -26,6 +29,8 @@ func NewWorkQueue() *WorkQueue {
 		Queue:    *NewWorkunitMap(), // these workunits that are ready to be checked out
 		Checkout: *NewWorkunitMap(), // workunits that are checked out right now
 		Suspend:  *NewWorkunitMap(),
+		Runtimes: NewRuntimeStats(),
+		queued:   &workNotifier{},
 	}
 
 	wq.all.Init("WorkQueue/workMap")
@@ -43,6 +48,16 @@ func (wq *WorkQueue) Len() (int, error) {
 }
 
 func (wq *WorkQueue) Add(workunit *Workunit) (err error) {
+	return wq.add(workunit, WORK_STAT_QUEUED, "")
+}
+
+// AddInternal adds a workunit the server completes itself (call cache hit, skipped step) in state checkout,
+// it is never queued and cannot be checked out by a worker
+func (wq *WorkQueue) AddInternal(workunit *Workunit, reason string) (err error) {
+	return wq.add(workunit, WORK_STAT_CHECKOUT, "internal processing, "+reason)
+}
+
+func (wq *WorkQueue) add(workunit *Workunit, state string, reason string) (err error) {
 	if workunit.ID == "" {
 		return errors.New("try to push a workunit with an empty id")
 	}
@@ -73,13 +88,39 @@ func (wq *WorkQueue) Add(workunit *Workunit) (err error) {
 	if err != nil {
 		return
 	}
-	err = wq.StatusChange(Workunit_Unique_Identifier{}, workunit, WORK_STAT_QUEUED, "")
+	err = wq.StatusChange(Workunit_Unique_Identifier{}, workunit, state, reason)
 	if err != nil {
 		return
 	}
 	return
 }
 
+// WaitForWork long-poll of checkout requests: attempt is called until it returns false or wait has passed, between
+// attempts it waits until a workunit is queued
+func (wq *WorkQueue) WaitForWork(clientID string, wait time.Duration, attempt func() (retry bool)) {
+	deadline := time.Now().Add(wait)
+	for {
+		// get the channel before the attempt, a workunit queued in the meantime must wake us up
+		queued := wq.queued.Wait()
+
+		if !attempt() {
+			return
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return
+		}
+		logger.Debug(3, "(WaitForWork) %s waits up to %s for work", clientID, remaining)
+		timer := time.NewTimer(remaining)
+		select {
+		case <-queued:
+			timer.Stop()
+		case <-timer.C:
+			// one more attempt, something may have been requeued without notification (e.g. backoff ended)
+		}
+	}
+}
+
 func (wq *WorkQueue) Get(id Workunit_Unique_Identifier) (w *Workunit, ok bool, err error) {
 	w, ok, err = wq.all.Get(id)
 	return
@@ -126,6 +167,8 @@ func (wq *WorkQueue) Clean() (workunits []*Workunit) {
 }
 
 func (wq *WorkQueue) Delete(id Workunit_Unique_Identifier) (err error) {
+	forgetLiveLogs(id)
+	Quotas.Released(id)
 	err = wq.Queue.Delete(id)
 	if err != nil {
 		return
@@ -180,6 +223,12 @@ func (wq *WorkQueue) StatusChange(id Workunit_Unique_Identifier, workunit *Worku
 	if workunit.State == new_status {
 		return
 	}
+	if workunit.State == WORK_STAT_CHECKOUT {
+		defer forgetLiveLogs(id)
+		defer Quotas.Released(id)
+	}
+	change := &StateChange{JobID: id.JobId, Type: HISTORY_WORKUNIT, OldState: workunit.State, NewState: new_status, Client: workunit.Client, Reason: reason}
+	change.ID, _ = id.String()
 	if workunit.State != WORK_STAT_CHECKOUT && workunit.State != WORK_STAT_RESERVED {
 		workunit.Client = ""
 	}
@@ -201,7 +250,9 @@ func (wq *WorkQueue) StatusChange(id Workunit_Unique_Identifier, workunit *Worku
 		if err != nil {
 			return
 		}
+		workunit.QueuedTime = time.Now()
 		wq.Queue.Set(workunit)
+		wq.queued.Notify()
 
 	case WORK_STAT_SUSPEND:
 		if reason == "" {
@@ -226,17 +277,22 @@ func (wq *WorkQueue) StatusChange(id Workunit_Unique_Identifier, workunit *Worku
 		}
 	}
 
+	RecordStateChange(change)
 	return
 }
 
 //select workunits, return a slice of ids based on given queuing policy and requested count
 //if available is a positive value, filter by workunit input size
-func (wq *WorkQueue) selectWorkunits(workunits WorkList, policy string, available int64, count int) (selected []*Workunit, err error) {
+func (wq *WorkQueue) selectWorkunits(workunits WorkList, policyName string, aging *AgingPolicy, client *Client, available int64, count int) (selected []*Workunit, err error) {
 	logger.Debug(3, "starting selectWorkunits")
 
-	if policy == "FCFS" {
-		sort.Sort(byFCFS{workunits})
+	var policy WorkPolicy
+	policy, err = NewWorkPolicy(policyName, wq, aging)
+	if err != nil {
+		return
 	}
+	workunits = policy.Order(workunits, client)
+
 	added := 0
 	for _, work := range workunits {
 		if added == count {
@@ -270,12 +326,31 @@ type WorkList []*Workunit
 func (wl WorkList) Len() int      { return len(wl) }
 func (wl WorkList) Swap(i, j int) { wl[i], wl[j] = wl[j], wl[i] }
 
-type byFCFS struct{ WorkList }
+// byFCFS priorities are the effective priorities of the workunits, see newByFCFS
+type byFCFS struct {
+	WorkList
+	priorities []int
+}
+
+// newByFCFS computes the priorities once, with aging if the policy is not nil
+func newByFCFS(workunits WorkList, aging *AgingPolicy) byFCFS {
+	now := time.Now()
+	priorities := make([]int, len(workunits))
+	for i, work := range workunits {
+		priorities[i] = aging.WorkunitPriority(work, now)
+	}
+	return byFCFS{WorkList: workunits, priorities: priorities}
+}
+
+func (s byFCFS) Swap(i, j int) {
+	s.WorkList[i], s.WorkList[j] = s.WorkList[j], s.WorkList[i]
+	s.priorities[i], s.priorities[j] = s.priorities[j], s.priorities[i]
+}
 
 //compare priority first, then FCFS (if priorities are the same)
 func (s byFCFS) Less(i, j int) (ret bool) {
-	p_i := s.WorkList[i].Info.Priority
-	p_j := s.WorkList[j].Info.Priority
+	p_i := s.priorities[i]
+	p_j := s.priorities[j]
 	switch {
 	case p_i > p_j:
 		return true
@@ -0,0 +1,88 @@
+package core_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/MG-RAST/AWE/lib/core"
+)
+
+func queueWorkunit(taskName string) *Workunit {
+	id := Workunit_Unique_Identifier{Task_Unique_Identifier: Task_Unique_Identifier{JobId: "job1", TaskName: taskName}}
+	return &Workunit{Workunit_Unique_Identifier: id, ID: "work_" + taskName}
+}
+
+func TestWaitForWorkQueued(t *testing.T) {
+	wq := NewWorkQueue()
+
+	// the request waits until a workunit is queued, not until the deadline
+	attempts := 0
+	done := make(chan bool)
+	start := time.Now()
+	go func() {
+		wq.WaitForWork("client1", 10*time.Second, func() bool {
+			attempts++
+			n, _ := wq.Queue.Len()
+			return n == 0
+		})
+		close(done)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	// workunits the server completes itself do not wake up requests
+	if err := wq.AddInternal(queueWorkunit("internal"), "test"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+		t.Fatal("WaitForWork returned without a queued workunit")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := wq.Add(queueWorkunit("step")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("WaitForWork did not return after a workunit was queued")
+	}
+	if time.Since(start) >= 10*time.Second {
+		t.Errorf("WaitForWork waited until the deadline")
+	}
+	if attempts != 2 {
+		t.Errorf("expected 2 attempts, got %d", attempts)
+	}
+}
+
+func TestWaitForWorkDeadline(t *testing.T) {
+	wq := NewWorkQueue()
+
+	// without work there is one more attempt after the deadline
+	attempts := 0
+	start := time.Now()
+	wq.WaitForWork("client1", 100*time.Millisecond, func() bool {
+		attempts++
+		return true
+	})
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond || elapsed > 5*time.Second {
+		t.Errorf("expected to return after the deadline, returned after %s", elapsed)
+	}
+	if attempts != 2 {
+		t.Errorf("expected 2 attempts, got %d", attempts)
+	}
+
+	// no waiting if the attempt succeeds or if there is no wait time
+	attempts = 0
+	wq.WaitForWork("client1", time.Hour, func() bool {
+		attempts++
+		return false
+	})
+	wq.WaitForWork("client1", 0, func() bool {
+		attempts++
+		return true
+	})
+	if attempts != 2 {
+		t.Errorf("expected one attempt each, got %d", attempts)
+	}
+}
@@ -61,6 +61,17 @@ type Workunit struct {
 	UserAttr                   map[string]interface{} `bson:"userattr,omitempty" json:"userattr,omitempty" mapstructure:"userattr,omitempty"`
 	ShockHost                  string                 `bson:"shockhost,omitempty" json:"shockhost,omitempty" mapstructure:"shockhost,omitempty"` // specifies default Shock host for outputs
 	CWLWorkunit                *CWLWorkunit           `bson:"cwl,omitempty" json:"cwl,omitempty" mapstructure:"cwl,omitempty"`
+	Resources                  *WorkResources         `bson:"resources,omitempty" json:"resources,omitempty" mapstructure:"resources,omitempty"` // minimum resources, checked when matching workunits to clients
+	Retry                      *RetryPolicy           `bson:"retry,omitempty" json:"retry,omitempty" mapstructure:"retry,omitempty"`
+	TimeLimit                  int64                  `bson:"time_limit,omitempty" json:"time_limit,omitempty" mapstructure:"time_limit,omitempty"`
+	NetworkAccess              *bool                  `bson:"network_access,omitempty" json:"network_access,omitempty" mapstructure:"network_access,omitempty"`
+	LoadListing                string                 `bson:"load_listing,omitempty" json:"load_listing,omitempty" mapstructure:"load_listing,omitempty"`
+	Failure                    string                 `bson:"failure,omitempty" json:"failure,omitempty" mapstructure:"failure,omitempty"`          // client only: FAILURE_APP, FAILURE_INFRASTRUCTURE, FAILURE_TIMEOUT or FAILURE_OOM
+	NotBefore                  time.Time              `bson:"not_before,omitempty" json:"not_before,omitempty" mapstructure:"not_before,omitempty"` // server only: retry backoff, not checked out before this time
+	Owner                      string                 `bson:"owner,omitempty" json:"-" mapstructure:"-"`                                            // server only: uuid of the job owner, for quotas
+	CallCacheKey               string                 `bson:"call_cache_key,omitempty" json:"-" mapstructure:"-"`                                   // server only: outputs are saved in the call cache under this key
+	QueuedTime                 time.Time              `bson:"queued_time,omitempty" json:"-" mapstructure:"-"`                                      // server only: last time the workunit was queued, for metrics
+	EffectivePriority          int                    `bson:"-" json:"effective_priority,omitempty" mapstructure:"-"`                               // server only: priority including aging, set in listings
 	WorkPath                   string                 // this is the working directory. If empty, it will be computed.
 	WorkPerf                   *WorkPerf
 	Context                    *cwl.WorkflowContext `bson:"-" json:"-" mapstructure:"-"`
@@ -99,9 +110,13 @@ func NewWorkunit(qm *ServerMgr, task *Task, rank int, job *Job) (workunit *Worku
 
 		UserAttr:   task.UserAttr,
 		ExitStatus: -1,
+		Retry:      task.Retry,
 
 		//AppVariables: task.AppVariables // not needed yet
 	}
+	if task.Cmd != nil {
+		workunit.Resources = task.Cmd.Resources
+	}
 	var workStr string
 	workStr, err = workunit.String()
 	if err != nil {
@@ -114,6 +129,10 @@ func NewWorkunit(qm *ServerMgr, task *Task, rank int, job *Job) (workunit *Worku
 	workunit.ID = workStr
 	workunit.WuID = workStr
 
+	if job != nil {
+		workunit.Owner = job.ACL.Owner
+	}
+
 	if task.WorkflowStep != nil {
 
 		workflowStep := task.WorkflowStep
@@ -183,27 +202,33 @@ func NewWorkunit(qm *ServerMgr, task *Task, rank int, job *Job) (workunit *Worku
 			return
 		}
 
-		var shockRequirement *cwl.ShockRequirement
-		shockRequirement = job.CWL_ShockRequirement
-		if shockRequirement == nil {
-			err = fmt.Errorf("(NewWorkunit) shockRequirement == nil")
-			return
-		}
-		//shock_requirement, err = cwl.GetShockRequirement(requirements)
-		//if err != nil {
-		//fmt.Println("process:")
-		//spew.Dump(process)
-		//	err = fmt.Errorf("(NewWorkunit) ShockRequirement not found , err: %s", err.Error())
-		//	return
-		//}
+		if job.CWL_StorageRequirement != nil {
+			// StorageRequirement takes precedence over ShockRequirement
+			workunit.CWLWorkunit.Storage = job.CWL_StorageRequirement
+			workunit.ShockHost = job.ShockHost
+		} else {
+			var shockRequirement *cwl.ShockRequirement
+			shockRequirement = job.CWL_ShockRequirement
+			if shockRequirement == nil {
+				err = fmt.Errorf("(NewWorkunit) shockRequirement == nil")
+				return
+			}
+			//shock_requirement, err = cwl.GetShockRequirement(requirements)
+			//if err != nil {
+			//fmt.Println("process:")
+			//spew.Dump(process)
+			//	err = fmt.Errorf("(NewWorkunit) ShockRequirement not found , err: %s", err.Error())
+			//	return
+			//}
+
+			if shockRequirement.Shock_api_url == "" {
+				err = fmt.Errorf("(NewWorkunit) Shock_api_url in ShockRequirement is empty")
+				return
+			}
 
-		if shockRequirement.Shock_api_url == "" {
-			err = fmt.Errorf("(NewWorkunit) Shock_api_url in ShockRequirement is empty")
-			return
+			workunit.ShockHost = shockRequirement.Shock_api_url
 		}
 
-		workunit.ShockHost = shockRequirement.Shock_api_url
-
 		workunit.CWLWorkunit.Tool = process
 
 		//}
@@ -341,6 +366,28 @@ func NewWorkunit(qm *ServerMgr, task *Task, rank int, job *Job) (workunit *Worku
 			return
 		}
 
+		if clt != nil {
+			workunit.Resources, err = NewWorkResources(clt.Requirements, clt.Hints)
+			if err != nil {
+				err = fmt.Errorf("(NewWorkunit) NewWorkResources returned: %s", err.Error())
+				return
+			}
+			var retry *RetryPolicy
+			retry, err = NewRetryPolicyFromCWL(clt.Requirements, clt.Hints)
+			if err != nil {
+				err = fmt.Errorf("(NewWorkunit) NewRetryPolicyFromCWL returned: %s", err.Error())
+				return
+			}
+			if retry != nil {
+				workunit.Retry = retry
+			}
+			workunit.TimeLimit, workunit.NetworkAccess, workunit.LoadListing, err = NewToolLimitsFromCWL(clt.Requirements, clt.Hints)
+			if err != nil {
+				err = fmt.Errorf("(NewWorkunit) NewToolLimitsFromCWL returned: %s", err.Error())
+				return
+			}
+		}
+
 		jobInput := cwl.Job_document{}
 
 		for elemID, elem := range workunitInputMapAll {
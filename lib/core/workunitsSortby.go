@@ -47,5 +47,7 @@ func (w WorkunitsSortby) Less(i, j int) bool {
 		return w.Workunits[i].Failed < w.Workunits[j].Failed
 	case "info.priority":
 		return w.Workunits[i].Info.Priority < w.Workunits[j].Info.Priority
+	case "effective_priority":
+		return w.Workunits[i].EffectivePriority < w.Workunits[j].EffectivePriority
 	}
 }
@@ -15,8 +15,11 @@ const (
 	DEBUG_LEVEL          = "DL" //debug level changed
 	QUEUE_RESUME         = "QR" //awe-server queue resumed if suspended
 	QUEUE_SUSPEND        = "QS" //awe-server queue suspended, not handing out work
+	QUEUE_QUOTA          = "QU" //quota of a user or clientgroup changed
+	CLIENT_COMMAND       = "CC" //command for a client issued, delivered with the next heartbeat
 	JOB_SUBMISSION       = "JQ" //job submitted
 	JOB_IMPORT           = "JI" //job imported
+	JOB_SCHEDULED        = "JS" //job submitted by a recurring job schedule
 	TASK_ENQUEUE         = "TQ" //task parsed and enqueue
 	WORK_DONE            = "WD" //workunit received successful feedback from client
 	WORK_REQUEUE         = "WR" //workunit requeue after receive failed feedback from client
@@ -27,6 +30,7 @@ const (
 	JOB_SUSPEND          = "JP" //job suspended
 	JOB_DELETED          = "JL" //job deleted
 	JOB_EXPIRED          = "JE" //job expired
+	JOB_DATA_COLLECTED   = "JG" //intermediate step outputs of a job deleted (data retention)
 	JOB_FULL_DELETE      = "JR" //job removed form mongodb (deleted fully)
 	JOB_FAILED_PERMANENT = "JF" //job failed permanently
 	//client only events
@@ -63,8 +67,11 @@ var EventDiscription = map[string]map[string]string{
 		"DL": "debug level changed",
 		"QR": "awe-server queue resumed if suspended",
 		"QS": "awe-server queue suspended, not handing out work",
+		"QU": "quota of a user or clientgroup changed",
+		"CC": "command for a client issued, delivered with the next heartbeat",
 		"JQ": "job submitted",
 		"JI": "job imported",
+		"JS": "job submitted by a recurring job schedule",
 		"TQ": "task parsed and enqueue",
 		"WD": "workunit received successful feedback from client",
 		"WR": "workunit requeue after receive failed feedback from client",
@@ -75,6 +82,7 @@ var EventDiscription = map[string]map[string]string{
 		"JP": "job suspended",
 		"JL": "job deleted",
 		"JE": "job expired",
+		"JG": "intermediate step outputs of a job deleted (data retention)",
 		"JR": "job removed form mongodb (deleted fully)",
 		"JF": "job failed permanently",
 	},
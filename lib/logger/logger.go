@@ -3,6 +3,7 @@ package logger
 import (
 	"fmt"
 	"os"
+	"sync/atomic"
 
 	"github.com/MG-RAST/AWE/lib/conf"
 	l4g "github.com/MG-RAST/golib/log4go"
@@ -25,6 +26,25 @@ var (
 	Log *Logger
 )
 
+// debugLevel set at runtime with SetDebugLevel, -1 until then and conf.DEBUG_LEVEL applies
+var debugLevel int64 = -1
+
+// DebugLevel current debug level
+func DebugLevel() int {
+	if level := atomic.LoadInt64(&debugLevel); level >= 0 {
+		return int(level)
+	}
+	return conf.DEBUG_LEVEL
+}
+
+// SetDebugLevel changes the debug level while other goroutines are logging
+func SetDebugLevel(level int) {
+	if level < 0 {
+		level = 0
+	}
+	atomic.StoreInt64(&debugLevel, int64(level))
+}
+
 // Initialialize sets up package var Log for use in Info(), Error(), and Perf()
 func Initialize(name string) {
 	Log = NewLogger(name)
@@ -196,7 +216,7 @@ func (l *Logger) Log(log string, lvl l4g.Level, message string) {
 //}
 
 func (l *Logger) Debug(level int, format string, a ...interface{}) {
-	if level <= conf.DEBUG_LEVEL {
+	if level <= DebugLevel() {
 		l.Log("debug", l4g.DEBUG, fmt.Sprintf(format, a...))
 	}
 	return
@@ -0,0 +1,288 @@
+// Package metrics collects counters, gauges and histograms and exposes them in the Prometheus text format
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType of the Prometheus text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets for durations in seconds
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300, 900, 3600}
+
+// Registry metrics exposed by one process
+type Registry struct {
+	sync.Mutex
+	metrics    []metric
+	collectors []func()
+}
+
+// metric one metric family
+type metric interface {
+	write(w io.Writer)
+}
+
+// NewRegistry _
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// OnCollect registers a function that is called before the metrics are written, e.g. to set gauges from the current state
+func (r *Registry) OnCollect(f func()) {
+	r.Lock()
+	defer r.Unlock()
+	r.collectors = append(r.collectors, f)
+}
+
+func (r *Registry) register(m metric) {
+	r.Lock()
+	defer r.Unlock()
+	r.metrics = append(r.metrics, m)
+}
+
+// Write writes all metrics in the Prometheus text format
+func (r *Registry) Write(w io.Writer) (err error) {
+	r.Lock()
+	collectors := r.collectors
+	metrics := r.metrics
+	r.Unlock()
+
+	for _, f := range collectors {
+		f()
+	}
+
+	buf := &bytes.Buffer{}
+	for _, m := range metrics {
+		m.write(buf)
+	}
+	_, err = buf.WriteTo(w)
+	return
+}
+
+// ServeHTTP makes the registry a http.Handler
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	r.Write(w)
+}
+
+// desc name, help and label names of a metric family
+type desc struct {
+	name   string
+	help   string
+	labels []string
+}
+
+func (d *desc) writeHeader(w io.Writer, metricType string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.name, strings.Replace(strings.Replace(d.help, "\\", "\\\\", -1), "\n", "\\n", -1))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.name, metricType)
+}
+
+// labelString formats label pairs, extra is appended (used for the histogram "le" label)
+func (d *desc) labelString(values []string, extra ...string) string {
+	pairs := []string{}
+	for i, name := range d.labels {
+		pairs = append(pairs, name+"=\""+escapeLabelValue(values[i])+"\"")
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+"=\""+escapeLabelValue(extra[i+1])+"\"")
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func (d *desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metric %s: expected %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+func escapeLabelValue(s string) string {
+	s = strings.Replace(s, "\\", "\\\\", -1)
+	s = strings.Replace(s, "\"", "\\\"", -1)
+	return strings.Replace(s, "\n", "\\n", -1)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+type value struct {
+	labels []string
+	value  float64
+}
+
+// valueVec shared by counters and gauges
+type valueVec struct {
+	sync.Mutex
+	desc
+	metricType string
+	values     map[string]*value
+}
+
+func (v *valueVec) add(delta float64, labels []string) {
+	key := v.key(labels)
+	v.Lock()
+	defer v.Unlock()
+	val, ok := v.values[key]
+	if !ok {
+		val = &value{labels: append([]string{}, labels...)}
+		v.values[key] = val
+	}
+	val.value += delta
+}
+
+func (v *valueVec) write(w io.Writer) {
+	v.Lock()
+	defer v.Unlock()
+	v.writeHeader(w, v.metricType)
+	keys := make([]string, 0, len(v.values))
+	for key := range v.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		val := v.values[key]
+		fmt.Fprintf(w, "%s%s %s\n", v.name, v.labelString(val.labels), formatFloat(val.value))
+	}
+}
+
+// CounterVec counter, only goes up
+type CounterVec struct {
+	valueVec
+}
+
+// NewCounterVec creates and registers a counter
+func (r *Registry) NewCounterVec(name string, help string, labels ...string) *CounterVec {
+	c := &CounterVec{valueVec{desc: desc{name: name, help: help, labels: labels}, metricType: "counter", values: map[string]*value{}}}
+	r.register(c)
+	return c
+}
+
+// Inc _
+func (c *CounterVec) Inc(labels ...string) {
+	c.add(1, labels)
+}
+
+// Add delta must not be negative
+func (c *CounterVec) Add(delta float64, labels ...string) {
+	if delta < 0 {
+		return
+	}
+	c.add(delta, labels)
+}
+
+// GaugeVec value that can go up and down
+type GaugeVec struct {
+	valueVec
+}
+
+// NewGaugeVec creates and registers a gauge
+func (r *Registry) NewGaugeVec(name string, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{valueVec{desc: desc{name: name, help: help, labels: labels}, metricType: "gauge", values: map[string]*value{}}}
+	r.register(g)
+	return g
+}
+
+// Set _
+func (g *GaugeVec) Set(v float64, labels ...string) {
+	key := g.key(labels)
+	g.Lock()
+	defer g.Unlock()
+	g.values[key] = &value{labels: append([]string{}, labels...), value: v}
+}
+
+// Add _
+func (g *GaugeVec) Add(delta float64, labels ...string) {
+	g.add(delta, labels)
+}
+
+// Reset removes all label combinations, used by gauges that are recomputed on collect
+func (g *GaugeVec) Reset() {
+	g.Lock()
+	defer g.Unlock()
+	g.values = map[string]*value{}
+}
+
+type histogram struct {
+	labels []string
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// HistogramVec counts observations in buckets
+type HistogramVec struct {
+	sync.Mutex
+	desc
+	buckets []float64
+	values  map[string]*histogram
+}
+
+// NewHistogramVec creates and registers a histogram, buckets are upper bounds in ascending order
+func (r *Registry) NewHistogramVec(name string, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{desc: desc{name: name, help: help, labels: labels}, buckets: buckets, values: map[string]*histogram{}}
+	r.register(h)
+	return h
+}
+
+// Observe _
+func (h *HistogramVec) Observe(v float64, labels ...string) {
+	key := h.key(labels)
+	h.Lock()
+	defer h.Unlock()
+	hist, ok := h.values[key]
+	if !ok {
+		hist = &histogram{labels: append([]string{}, labels...), counts: make([]uint64, len(h.buckets))}
+		h.values[key] = hist
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			hist.counts[i]++
+			break
+		}
+	}
+	hist.count++
+	hist.sum += v
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.Lock()
+	defer h.Unlock()
+	h.writeHeader(w, "histogram")
+	keys := make([]string, 0, len(h.values))
+	for key := range h.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		hist := h.values[key]
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += hist.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelString(hist.labels, "le", formatFloat(upper)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelString(hist.labels, "le", "+Inf"), hist.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labelString(hist.labels), formatFloat(hist.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labelString(hist.labels), hist.count)
+	}
+}
@@ -0,0 +1,58 @@
+package metrics_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/MG-RAST/AWE/lib/metrics"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	r := metrics.NewRegistry()
+	counter := r.NewCounterVec("test_total", "a counter", "group")
+	gauge := r.NewGaugeVec("test_gauge", "a gauge")
+	histogram := r.NewHistogramVec("test_seconds", "a histogram", []float64{1, 10}, "stage")
+
+	r.OnCollect(func() {
+		gauge.Set(3)
+	})
+
+	counter.Inc("b")
+	counter.Inc("a\"x")
+	counter.Add(2, "b")
+	histogram.Observe(0.5, "run")
+	histogram.Observe(5, "run")
+	histogram.Observe(50, "run")
+
+	buf := &bytes.Buffer{}
+	err := r.Write(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `# HELP test_total a counter
+# TYPE test_total counter
+test_total{group="a\"x"} 1
+test_total{group="b"} 3
+# HELP test_gauge a gauge
+# TYPE test_gauge gauge
+test_gauge 3
+# HELP test_seconds a histogram
+# TYPE test_seconds histogram
+test_seconds_bucket{stage="run",le="1"} 1
+test_seconds_bucket{stage="run",le="10"} 2
+test_seconds_bucket{stage="run",le="+Inf"} 3
+test_seconds_sum{stage="run"} 55.5
+test_seconds_count{stage="run"} 3
+`
+	if buf.String() != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+
+	gauge.Reset()
+	buf.Reset()
+	r.Write(buf)
+	if !bytes.Contains(buf.Bytes(), []byte("test_gauge 3\n")) {
+		t.Fatalf("gauge should be set again on collect:\n%s", buf.String())
+	}
+}
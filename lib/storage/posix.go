@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/MG-RAST/AWE/lib/conf"
+)
+
+// PosixStorage stores files in a directory on a filesystem that is mounted on the submitter and all workers,
+// locations are file:// urls. The root comes from the job, it must be within one of the posix_storage_roots.
+type PosixStorage struct {
+	Root string
+}
+
+// NewPosixStorage _
+func NewPosixStorage(root string) (s *PosixStorage, err error) {
+	if !path.IsAbs(root) {
+		err = fmt.Errorf("(NewPosixStorage) path must be absolute: %s", root)
+		return
+	}
+	root = path.Clean(root)
+	allowed := false
+	for _, r := range conf.POSIX_STORAGE_ROOTS {
+		if within(root, r) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		err = fmt.Errorf("(NewPosixStorage) %s is not within the allowed posix storage roots (see posix_storage_roots)", root)
+		return
+	}
+	s = &PosixStorage{Root: root}
+	return
+}
+
+// within true if the clean absolute path is dir or below dir
+func within(p string, dir string) bool {
+	return p == dir || strings.HasPrefix(p, strings.TrimSuffix(dir, "/")+"/")
+}
+
+// localPath the clean path of a location in Root, symbolic links must not leave Root either
+func (s *PosixStorage) localPath(location string) (filePath string, err error) {
+	if !strings.HasPrefix(location, "file://") {
+		err = fmt.Errorf("not a file:// location: %s", location)
+		return
+	}
+	filePath = path.Clean(strings.TrimPrefix(location, "file://"))
+	if !path.IsAbs(filePath) || !within(filePath, s.Root) || filePath == s.Root {
+		err = fmt.Errorf("location %s is not in posix storage %s", location, s.Root)
+		return
+	}
+	var real, realRoot string
+	real, err = filepath.EvalSymlinks(filePath)
+	if err != nil {
+		err = fmt.Errorf("filepath.EvalSymlinks returned: %s", err.Error())
+		return
+	}
+	realRoot, err = filepath.EvalSymlinks(s.Root)
+	if err != nil {
+		err = fmt.Errorf("filepath.EvalSymlinks returned: %s", err.Error())
+		return
+	}
+	if !within(real, realRoot) {
+		err = fmt.Errorf("location %s links outside of posix storage %s", location, s.Root)
+	}
+	return
+}
+
+func (s *PosixStorage) location(filePath string) string {
+	return "file://" + filePath
+}
+
+// Upload copies the file into Root, files that already are in Root are not copied
+func (s *PosixStorage) Upload(filePath string, contents string, name string, lazy bool) (location string, err error) {
+	if filePath != "" {
+		var absPath string
+		absPath, err = filepath.Abs(filePath)
+		if err != nil {
+			err = fmt.Errorf("(PosixStorage/Upload) filepath.Abs returned: %s", err.Error())
+			return
+		}
+		if absPath != s.Root && within(absPath, s.Root) {
+			location = s.location(absPath)
+			return
+		}
+	}
+
+	var key string
+	key, err = objectKey("", filePath, contents, name)
+	if err != nil {
+		err = fmt.Errorf("(PosixStorage/Upload) %s", err.Error())
+		return
+	}
+	target := path.Join(s.Root, key)
+	location = s.location(target)
+
+	if lazy {
+		if _, serr := os.Stat(target); serr == nil {
+			return
+		}
+	}
+
+	err = os.MkdirAll(path.Dir(target), 0777)
+	if err != nil {
+		err = fmt.Errorf("(PosixStorage/Upload) os.MkdirAll returned: %s", err.Error())
+		return
+	}
+
+	reader, _, err := openData(filePath, contents)
+	if err != nil {
+		err = fmt.Errorf("(PosixStorage/Upload) %s", err.Error())
+		return
+	}
+	defer reader.Close()
+
+	_, err = writeFile(target, reader)
+	if err != nil {
+		err = fmt.Errorf("(PosixStorage/Upload) %s", err.Error())
+	}
+	return
+}
+
+// Download copies the file, files in Root are shared by all jobs with the same data and a hard link
+// would let a tool that modifies its inputs change them for every job
+func (s *PosixStorage) Download(location string, filePath string) (size int64, err error) {
+	var source string
+	source, err = s.localPath(location)
+	if err != nil {
+		err = fmt.Errorf("(PosixStorage/Download) %s", err.Error())
+		return
+	}
+
+	var f *os.File
+	f, err = os.Open(source)
+	if err != nil {
+		err = fmt.Errorf("(PosixStorage/Download) os.Open returned: %s", err.Error())
+		return
+	}
+	defer f.Close()
+
+	size, err = writeFile(filePath, f)
+	if err != nil {
+		err = fmt.Errorf("(PosixStorage/Download) %s", err.Error())
+	}
+	return
+}
+
+// Owns _
+func (s *PosixStorage) Owns(location string) bool {
+	if !strings.HasPrefix(location, "file://") {
+		return false
+	}
+	filePath := path.Clean(strings.TrimPrefix(location, "file://"))
+	return path.IsAbs(filePath) && filePath != s.Root && within(filePath, s.Root)
+}
+
+// writeFile writes to a temporary file first, so that readers never see partial files
+func writeFile(filePath string, reader io.Reader) (size int64, err error) {
+	tmpPath := filePath + ".part"
+	var f *os.File
+	f, err = os.Create(tmpPath)
+	if err != nil {
+		err = fmt.Errorf("(writeFile) os.Create returned: %s", err.Error())
+		return
+	}
+	size, err = io.Copy(f, reader)
+	f.Close()
+	if err != nil {
+		os.Remove(tmpPath)
+		err = fmt.Errorf("(writeFile) io.Copy returned: %s", err.Error())
+		return
+	}
+	err = os.Rename(tmpPath, filePath)
+	if err != nil {
+		err = fmt.Errorf("(writeFile) os.Rename returned: %s", err.Error())
+	}
+	return
+}
@@ -0,0 +1,228 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// S3Storage stores files in a bucket of an S3-compatible server (AWS, MinIO, Ceph), locations are s3://<bucket>/<key>.
+// Requests use path-style urls and are signed with AWS signature version 4, without keys requests are anonymous.
+type S3Storage struct {
+	Endpoint  string
+	Bucket    string
+	Region    string
+	Prefix    string
+	AccessKey string
+	SecretKey string
+	Client    *http.Client
+}
+
+// NewS3Storage _
+func NewS3Storage(endpoint string, bucket string, region string, prefix string, accessKey string, secretKey string) *S3Storage {
+	if region == "" {
+		region = "us-east-1"
+	}
+	return &S3Storage{
+		Endpoint:  strings.TrimSuffix(endpoint, "/"),
+		Bucket:    bucket,
+		Region:    region,
+		Prefix:    prefix,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		Client:    &http.Client{},
+	}
+}
+
+func (s *S3Storage) location(key string) string {
+	return "s3://" + s.Bucket + "/" + key
+}
+
+// objectURL returns the path-style url of the object
+func (s *S3Storage) objectURL(key string) string {
+	segments := strings.Split(key, "/")
+	for i := range segments {
+		segments[i] = uriEncode(segments[i])
+	}
+	return s.Endpoint + "/" + uriEncode(s.Bucket) + "/" + strings.Join(segments, "/")
+}
+
+// uriEncode escapes everything except the unreserved characters, as required for the canonical request
+func uriEncode(segment string) string {
+	var b strings.Builder
+	for i := 0; i < len(segment); i++ {
+		c := segment[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') || c == '-' || c == '.' || c == '_' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// Upload _
+func (s *S3Storage) Upload(filePath string, contents string, name string, lazy bool) (location string, err error) {
+	var key string
+	key, err = objectKey(s.Prefix, filePath, contents, name)
+	if err != nil {
+		err = fmt.Errorf("(S3Storage/Upload) %s", err.Error())
+		return
+	}
+	location = s.location(key)
+
+	if lazy {
+		var exists bool
+		exists, err = s.exists(key)
+		if err != nil {
+			err = fmt.Errorf("(S3Storage/Upload) %s", err.Error())
+			return
+		}
+		if exists {
+			return
+		}
+	}
+
+	reader, size, err := openData(filePath, contents)
+	if err != nil {
+		err = fmt.Errorf("(S3Storage/Upload) %s", err.Error())
+		return
+	}
+	defer reader.Close()
+
+	var req *http.Request
+	req, err = http.NewRequest("PUT", s.objectURL(key), reader)
+	if err != nil {
+		err = fmt.Errorf("(S3Storage/Upload) http.NewRequest returned: %s", err.Error())
+		return
+	}
+	req.ContentLength = size
+
+	var resp *http.Response
+	resp, err = s.do(req)
+	if err != nil {
+		err = fmt.Errorf("(S3Storage/Upload) PUT %s returned: %s", location, err.Error())
+		return
+	}
+	resp.Body.Close()
+	return
+}
+
+// exists sends a HEAD request for the key
+func (s *S3Storage) exists(key string) (exists bool, err error) {
+	var req *http.Request
+	req, err = http.NewRequest("HEAD", s.objectURL(key), nil)
+	if err != nil {
+		return
+	}
+	var resp *http.Response
+	resp, err = s.do(req)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			err = nil
+		}
+		return
+	}
+	resp.Body.Close()
+	exists = true
+	return
+}
+
+// Download _
+func (s *S3Storage) Download(location string, filePath string) (size int64, err error) {
+	if !s.Owns(location) {
+		err = fmt.Errorf("(S3Storage/Download) location %s is not in bucket %s", location, s.Bucket)
+		return
+	}
+	key := strings.TrimPrefix(location, s.location(""))
+
+	var req *http.Request
+	req, err = http.NewRequest("GET", s.objectURL(key), nil)
+	if err != nil {
+		err = fmt.Errorf("(S3Storage/Download) http.NewRequest returned: %s", err.Error())
+		return
+	}
+
+	var resp *http.Response
+	resp, err = s.do(req)
+	if err != nil {
+		err = fmt.Errorf("(S3Storage/Download) GET %s returned: %s", location, err.Error())
+		return
+	}
+	defer resp.Body.Close()
+
+	size, err = writeFile(filePath, resp.Body)
+	if err != nil {
+		err = fmt.Errorf("(S3Storage/Download) %s", err.Error())
+	}
+	return
+}
+
+// Owns _
+func (s *S3Storage) Owns(location string) bool {
+	return strings.HasPrefix(location, s.location(""))
+}
+
+// do signs and sends the request, status codes other than 2xx are returned as error (the response is closed then)
+func (s *S3Storage) do(req *http.Request) (resp *http.Response, err error) {
+	if s.AccessKey != "" {
+		s.sign(req, time.Now())
+	}
+	resp, err = s.Client.Do(req)
+	if err != nil {
+		return
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		err = fmt.Errorf("status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return
+}
+
+// sign adds the headers of AWS signature version 4, the payload is not signed (UNSIGNED-PAYLOAD) to allow streaming
+func (s *S3Storage) sign(req *http.Request, t time.Time) {
+	amzDate := t.UTC().Format("20060102T150405Z")
+	day := amzDate[:8]
+	payloadHash := "UNSIGNED-PAYLOAD"
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + s.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), day)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.AccessKey+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
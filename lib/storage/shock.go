@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	shock "github.com/MG-RAST/go-shock-client"
+)
+
+// ShockStorage stores files as Shock nodes, locations are download urls of the nodes
+type ShockStorage struct {
+	Client *shock.ShockClient
+}
+
+// NewShockStorage _
+func NewShockStorage(host string, token string) *ShockStorage {
+	return &ShockStorage{Client: shock.NewShockClient(host, token, false)}
+}
+
+// Upload _
+func (s *ShockStorage) Upload(filePath string, contents string, name string, lazy bool) (location string, err error) {
+	var nodeid string
+	if lazy {
+		nodeid, err = s.Client.PostFileLazy(filePath, name, contents, false)
+		if err != nil {
+			err = fmt.Errorf("(ShockStorage/Upload) PostFileLazy returned: %s", err.Error())
+			return
+		}
+	} else {
+		nodeid, err = s.Client.PostFile(filePath, contents, name)
+		if err != nil {
+			err = fmt.Errorf("(ShockStorage/Upload) PostFile returned: %s", err.Error())
+			return
+		}
+	}
+
+	var locationURL *url.URL
+	locationURL, err = url.Parse(s.Client.Host) // Host includes a path to the API !
+	if err != nil {
+		err = fmt.Errorf("(ShockStorage/Upload) url.Parse returned: %s", err.Error())
+		return
+	}
+	locationURL.Path = path.Join(locationURL.Path, "node", nodeid)
+	locationURL.RawQuery = strings.TrimPrefix(shock.DATA_SUFFIX, "?")
+	location = locationURL.String()
+	return
+}
+
+// Download _
+func (s *ShockStorage) Download(location string, filePath string) (size int64, err error) {
+	size, _, err = shock.FetchFile(filePath, location, s.Client.Token, "", false)
+	if err != nil {
+		err = fmt.Errorf("(ShockStorage/Download) shock.FetchFile returned: %s (location: %s, TokenLength: %d)", err.Error(), location, len(s.Client.Token))
+	}
+	return
+}
+
+// Owns _
+func (s *ShockStorage) Owns(location string) bool {
+	return s.Client.Host != "" && strings.HasPrefix(location, s.Client.Host)
+}
+
+// Delete deletes the node of the location, every upload creates a new node
+func (s *ShockStorage) Delete(location string) (err error) {
+	var locationURL *url.URL
+	locationURL, err = url.Parse(location)
+	if err != nil {
+		err = fmt.Errorf("(ShockStorage/Delete) url.Parse returned: %s", err.Error())
+		return
+	}
+	dir, nodeid := path.Split(strings.TrimSuffix(locationURL.Path, "/"))
+	if path.Base(dir) != "node" || nodeid == "" {
+		err = fmt.Errorf("(ShockStorage/Delete) not a node url: %s", location)
+		return
+	}
+	err = shock.ShockDelete(s.Client.Host, nodeid, s.Client.Token)
+	if err != nil {
+		err = fmt.Errorf("(ShockStorage/Delete) ShockDelete returned: %s (node %s)", err.Error(), nodeid)
+	}
+	return
+}
@@ -0,0 +1,118 @@
+// Package storage moves input and output files of CWL jobs between workers, submitter and a storage backend.
+// The backend is selected per job by the StorageRequirement, Shock is the default.
+package storage
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/MG-RAST/AWE/lib/conf"
+	"github.com/MG-RAST/AWE/lib/core/cwl"
+)
+
+// storage types, used in StorageRequirement
+const (
+	TYPE_SHOCK = "shock"
+	TYPE_S3    = "s3"
+	TYPE_POSIX = "posix"
+)
+
+// Storage _
+type Storage interface {
+	// Upload stores the local file filePath, or contents if filePath is empty, as name and returns its location.
+	// With lazy, data that already exists in the storage is not uploaded again.
+	Upload(filePath string, contents string, name string, lazy bool) (location string, err error)
+	// Download fetches the data at location into filePath
+	Download(location string, filePath string) (size int64, err error)
+	// Owns is true if location refers to data in this storage
+	Owns(location string) bool
+}
+
+// Deleter is implemented by storages that can remove the data of one job without affecting other jobs.
+// S3 and posix storage store identical data only once (see objectKey), their data may be shared between jobs.
+type Deleter interface {
+	// Delete removes the data at location
+	Delete(location string) (err error)
+}
+
+// New returns the storage described by the requirement, token is the data token of the job (used by Shock)
+func New(r *cwl.StorageRequirement, token string) (s Storage, err error) {
+	err = r.Check()
+	if err != nil {
+		return
+	}
+
+	switch r.Type {
+	case TYPE_SHOCK:
+		s = NewShockStorage(r.Url, token)
+	case TYPE_S3:
+		accessKey := conf.S3_ACCESS_KEY
+		if accessKey == "" {
+			accessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+		}
+		secretKey := conf.S3_SECRET_KEY
+		if secretKey == "" {
+			secretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		}
+		s = NewS3Storage(r.Url, r.Bucket, r.Region, r.Path, accessKey, secretKey)
+	case TYPE_POSIX:
+		s, err = NewPosixStorage(r.Path)
+	}
+	return
+}
+
+// objectKey returns "<prefix>/<sha1>/<name>", so identical data is stored only once
+func objectKey(prefix string, filePath string, contents string, name string) (key string, err error) {
+	h := sha1.New()
+	if filePath == "" {
+		io.WriteString(h, contents)
+	} else {
+		var f *os.File
+		f, err = os.Open(filePath)
+		if err != nil {
+			err = fmt.Errorf("(objectKey) os.Open returned: %s", err.Error())
+			return
+		}
+		defer f.Close()
+		_, err = io.Copy(h, f)
+		if err != nil {
+			err = fmt.Errorf("(objectKey) io.Copy returned: %s", err.Error())
+			return
+		}
+	}
+
+	key = hex.EncodeToString(h.Sum(nil)) + "/" + name
+	prefix = strings.Trim(prefix, "/")
+	if prefix != "" {
+		key = prefix + "/" + key
+	}
+	return
+}
+
+// openData returns a reader for the file, or for contents if filePath is empty
+func openData(filePath string, contents string) (reader io.ReadCloser, size int64, err error) {
+	if filePath == "" {
+		reader = ioutil.NopCloser(strings.NewReader(contents))
+		size = int64(len(contents))
+		return
+	}
+	var f *os.File
+	f, err = os.Open(filePath)
+	if err != nil {
+		return
+	}
+	var fi os.FileInfo
+	fi, err = f.Stat()
+	if err != nil {
+		f.Close()
+		return
+	}
+	reader = f
+	size = fi.Size()
+	return
+}
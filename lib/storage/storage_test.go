@@ -0,0 +1,199 @@
+package storage_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/MG-RAST/AWE/lib/conf"
+	. "github.com/MG-RAST/AWE/lib/storage"
+)
+
+// s3StandIn is a minimal S3-compatible server (PUT, HEAD, GET on path-style urls)
+type s3StandIn struct {
+	sync.Mutex
+	objects map[string][]byte
+	puts    int
+}
+
+func (s *s3StandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=minio/") || r.Header.Get("x-amz-date") == "" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	switch r.Method {
+	case "PUT":
+		data, _ := ioutil.ReadAll(r.Body)
+		s.objects[r.URL.Path] = data
+		s.puts++
+	case "HEAD", "GET":
+		data, ok := s.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == "GET" {
+			w.Write(data)
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestS3Storage(t *testing.T) {
+	standIn := &s3StandIn{objects: make(map[string][]byte)}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "awe-storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	input := path.Join(dir, "input.txt")
+	if err = ioutil.WriteFile(input, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewS3Storage(server.URL, "awe", "", "jobs", "minio", "minio123")
+	location, err := s.Upload(input, "", "in put.txt", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(location, "s3://awe/jobs/") || !strings.HasSuffix(location, "/in put.txt") || !s.Owns(location) {
+		t.Fatalf("unexpected location %s", location)
+	}
+
+	// lazy upload of the same data does not PUT again
+	if _, err = s.Upload(input, "", "in put.txt", true); err != nil {
+		t.Fatal(err)
+	}
+	if standIn.puts != 1 {
+		t.Errorf("expected 1 PUT, got %d", standIn.puts)
+	}
+
+	output := path.Join(dir, "output.txt")
+	size, err := s.Download(location, output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadFile(output)
+	if size != 5 || string(data) != "hello" {
+		t.Errorf("downloaded %d bytes: %q", size, data)
+	}
+
+	if _, err = s.Download("s3://awe/jobs/missing", output); err == nil {
+		t.Errorf("expected an error for a missing object")
+	}
+	if s.Owns("s3://other/jobs/x") {
+		t.Errorf("location in other bucket is owned")
+	}
+}
+
+func TestPosixStorage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "awe-storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	root := path.Join(dir, "shared")
+	conf.POSIX_STORAGE_ROOTS = []string{root}
+	defer func() { conf.POSIX_STORAGE_ROOTS = []string{} }()
+	s, err := NewPosixStorage(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	location, err := s.Upload("", "literal contents", "literal.txt", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Owns(location) || !strings.HasSuffix(location, "/literal.txt") {
+		t.Fatalf("unexpected location %s", location)
+	}
+
+	output := path.Join(dir, "literal.txt")
+	if _, err = s.Download(location, output); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadFile(output)
+	if string(data) != "literal contents" {
+		t.Errorf("downloaded %q", data)
+	}
+
+	// files that already are on the shared filesystem are not copied
+	shared := strings.TrimPrefix(location, "file://")
+	again, err := s.Upload(shared, "", "literal.txt", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != location {
+		t.Errorf("expected %s, got %s", location, again)
+	}
+
+	// the downloaded file is a copy, changing it does not change the shared file
+	if err = ioutil.WriteFile(output, []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = ioutil.ReadFile(shared)
+	if string(data) != "literal contents" {
+		t.Errorf("shared file changed to %q", data)
+	}
+
+	if _, err = NewPosixStorage("relative/path"); err == nil {
+		t.Errorf("expected an error for a relative path")
+	}
+}
+
+func TestPosixStorageRoots(t *testing.T) {
+	dir, err := ioutil.TempDir("", "awe-storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	root := path.Join(dir, "shared")
+	conf.POSIX_STORAGE_ROOTS = []string{root}
+	defer func() { conf.POSIX_STORAGE_ROOTS = []string{} }()
+
+	// the root of a job must be within the configured roots
+	for _, r := range []string{"/", dir, root + "/../secret", root + "-other"} {
+		if _, err = NewPosixStorage(r); err == nil {
+			t.Errorf("expected root %s to be rejected", r)
+		}
+	}
+	s, err := NewPosixStorage(path.Join(root, "project"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret := path.Join(dir, "secret")
+	if err = ioutil.WriteFile(secret, []byte("secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.MkdirAll(s.Root, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Symlink(secret, path.Join(s.Root, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	output := path.Join(dir, "output")
+	for _, location := range []string{"file://" + secret, "file://" + s.Root + "/../../secret", "file://" + s.Root + "/link"} {
+		if _, err = s.Download(location, output); err == nil {
+			t.Errorf("expected download of %s to be rejected", location)
+		}
+	}
+	if s.Owns("file://"+secret) || s.Owns("file://"+s.Root+"/../../secret") {
+		t.Errorf("locations outside of the root must not be owned")
+	}
+}
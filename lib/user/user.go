@@ -73,6 +73,17 @@ func FindByUuid(uuid string) (u *User, err error) {
 	return
 }
 
+func FindByUsername(username string) (u *User, err error) {
+	session := db.Connection.Session.Copy()
+	defer session.Close()
+	c := session.DB(conf.MONGODB_DATABASE).C("Users")
+	u = &User{}
+	if err = c.Find(bson.M{"username": username}).One(&u); err != nil {
+		return nil, err
+	}
+	return
+}
+
 func FindByUsernamePassword(username string, password string) (u *User, err error) {
 	session := db.Connection.Session.Copy()
 	defer session.Close()
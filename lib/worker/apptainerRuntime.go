@@ -0,0 +1,295 @@
+package worker
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"sync"
+	"syscall"
+
+	"github.com/MG-RAST/AWE/lib/conf"
+	"github.com/MG-RAST/AWE/lib/logger"
+)
+
+// apptainerRuntime runs containers as child processes of the worker with "apptainer exec", no daemon is needed.
+// Docker images are converted to SIF files in container_image_dir.
+type apptainerRuntime struct {
+	sync.Mutex
+	binary     string
+	imageDir   string
+	containers map[string]*apptainerContainer
+	building   map[string]*sync.Mutex // one build per image at a time
+}
+
+// apptainerContainer a container created with CreateContainer, the id is the name of the container
+type apptainerContainer struct {
+	cmd    *exec.Cmd
+	stderr bytes.Buffer // messages of apptainer itself, the tool writes into files in the work dir
+	done   chan bool    // closed when the process has exited
+	status int
+	err    error
+}
+
+func newApptainerRuntime(binary string) *apptainerRuntime {
+	imageDir := conf.CONTAINER_IMAGE_DIR
+	if imageDir == "" {
+		imageDir = path.Join(conf.DATA_PATH, "images")
+	}
+	return &apptainerRuntime{binary: binary, imageDir: imageDir, containers: map[string]*apptainerContainer{}, building: map[string]*sync.Mutex{}}
+}
+
+// Name _
+func (r *apptainerRuntime) Name() string {
+	return "apptainer"
+}
+
+func (r *apptainerRuntime) sifPath(image string) string {
+	return path.Join(r.imageDir, DockerizeName(image)+".sif")
+}
+
+// InspectImage images are available if there is a SIF file, otherwise apptainer would pull them every time
+func (r *apptainerRuntime) InspectImage(image string) (image_id string, err error) {
+	_, err = os.Stat(r.sifPath(image))
+	if err != nil {
+		err = fmt.Errorf("(apptainerRuntime/InspectImage) image %s not found: %s", image, err.Error())
+		return
+	}
+	image_id = image
+	return
+}
+
+// buildLock serializes builds of the same image by the slots of this worker
+func (r *apptainerRuntime) buildLock(image string) *sync.Mutex {
+	r.Lock()
+	defer r.Unlock()
+	lock, ok := r.building[image]
+	if !ok {
+		lock = &sync.Mutex{}
+		r.building[image] = lock
+	}
+	return lock
+}
+
+// build converts an image into a SIF file, the file is renamed at the end so that other slots and workers sharing
+// the image dir never see half-written images
+func (r *apptainerRuntime) build(image string, source string, args ...string) (err error) {
+	lock := r.buildLock(image)
+	lock.Lock()
+	defer lock.Unlock()
+
+	err = os.MkdirAll(r.imageDir, 0777)
+	if err != nil {
+		err = fmt.Errorf("(apptainerRuntime/build) could not create %s: %s", r.imageDir, err.Error())
+		return
+	}
+	sif := r.sifPath(image)
+	tmpfile, err := ioutil.TempFile(r.imageDir, DockerizeName(image)+"-*.sif.tmp")
+	if err != nil {
+		err = fmt.Errorf("(apptainerRuntime/build) ioutil.TempFile returned: %s", err.Error())
+		return
+	}
+	tmpfile.Close()
+	tmp := tmpfile.Name()
+	defer os.Remove(tmp)
+
+	args = append(args, tmp, source)
+	stdo, stde, err := RunCommand(r.binary, args...)
+	if err != nil {
+		err = fmt.Errorf("(apptainerRuntime/build) %s %s %s: %s (%s)", r.binary, args[0], source, err.Error(), bytes.TrimSpace(stde))
+		return
+	}
+	logger.Debug(1, "(apptainerRuntime/build) %s", stdo)
+
+	err = os.Rename(tmp, sif)
+	return
+}
+
+// PullImage pulls from a docker registry
+func (r *apptainerRuntime) PullImage(image string) (err error) {
+	return r.build(image, "docker://"+image, "pull", "--force")
+}
+
+// LoadImage _
+func (r *apptainerRuntime) LoadImage(image_id string, archive io.Reader) (err error) {
+	err = os.MkdirAll(r.imageDir, 0777)
+	if err != nil {
+		err = fmt.Errorf("(apptainerRuntime/LoadImage) could not create %s: %s", r.imageDir, err.Error())
+		return
+	}
+	tarball, err := ioutil.TempFile(r.imageDir, "load-*.tar")
+	if err != nil {
+		err = fmt.Errorf("(apptainerRuntime/LoadImage) ioutil.TempFile returned: %s", err.Error())
+		return
+	}
+	defer os.Remove(tarball.Name())
+
+	_, err = io.Copy(tarball, archive)
+	tarball.Close()
+	if err != nil {
+		err = fmt.Errorf("(apptainerRuntime/LoadImage) could not write %s: %s", tarball.Name(), err.Error())
+		return
+	}
+
+	return r.build(image_id, "docker-archive://"+tarball.Name(), "build", "--force")
+}
+
+// TagImage not needed, images are found by the name of the SIF file
+func (r *apptainerRuntime) TagImage(image_id string, repository string, tag string) (err error) {
+	return
+}
+
+// CreateContainer prepares the command, the process is started by StartContainer
+func (r *apptainerRuntime) CreateContainer(spec *ContainerSpec) (container_id string, err error) {
+	image := "docker://" + spec.Image
+	if _, serr := os.Stat(r.sifPath(spec.Image)); serr == nil {
+		image = r.sifPath(spec.Image)
+	}
+
+	args := []string{"exec", "--containall", "--cleanenv", "--pwd", spec.WorkDir}
+	for _, bind := range spec.Binds {
+		args = append(args, "--bind", bind)
+	}
+	for _, env := range spec.Env {
+		args = append(args, "--env", env)
+	}
+	if spec.NoNetwork {
+		args = append(args, "--net", "--network", "none")
+	}
+	if spec.Limits != nil {
+		args = append(args, spec.Limits.apptainerArgs()...)
+	}
+	args = append(args, image)
+	args = append(args, spec.Cmd...)
+
+	logger.Debug(1, "(apptainerRuntime/CreateContainer) cmd: %s %v", r.binary, args)
+
+	c := &apptainerContainer{cmd: exec.Command(r.binary, args...), done: make(chan bool)}
+	c.cmd.Stderr = &c.stderr
+
+	r.Lock()
+	r.containers[spec.Name] = c
+	r.Unlock()
+
+	container_id = spec.Name
+	return
+}
+
+func (r *apptainerRuntime) get(container_id string) (c *apptainerContainer, err error) {
+	r.Lock()
+	defer r.Unlock()
+	c, ok := r.containers[container_id]
+	if !ok {
+		err = fmt.Errorf("(apptainerRuntime) container %s not found", container_id)
+	}
+	return
+}
+
+// StartContainer _
+func (r *apptainerRuntime) StartContainer(container_id string) (err error) {
+	c, err := r.get(container_id)
+	if err != nil {
+		return
+	}
+	err = c.cmd.Start()
+	if err != nil {
+		err = fmt.Errorf("(apptainerRuntime/StartContainer) cmd.Start returned: %s", err.Error())
+		return
+	}
+	go func() {
+		werr := c.cmd.Wait()
+		c.status = 0
+		if werr != nil {
+			c.status = -1
+			c.err = werr
+			if exiterr, ok := werr.(*exec.ExitError); ok {
+				c.err = nil
+				if ws, ok := exiterr.Sys().(syscall.WaitStatus); ok {
+					c.status = ws.ExitStatus()
+					if ws.Signaled() {
+						c.status = 128 + int(ws.Signal()) // same as docker
+					}
+				}
+			}
+		}
+		if c.status != 0 {
+			logger.Debug(1, "(apptainerRuntime) container %s exited with status %d: %s", container_id, c.status, c.stderr.String())
+		}
+		close(c.done)
+	}()
+	return
+}
+
+// WaitContainer _
+func (r *apptainerRuntime) WaitContainer(container_id string) (status int, err error) {
+	c, err := r.get(container_id)
+	if err != nil {
+		return
+	}
+	<-c.done
+	status = c.status
+	err = c.err
+	return
+}
+
+// KillContainer _
+func (r *apptainerRuntime) KillContainer(container_id string) (err error) {
+	c, err := r.get(container_id)
+	if err != nil {
+		return
+	}
+	if c.cmd.Process == nil {
+		return
+	}
+	select {
+	case <-c.done:
+		return
+	default:
+	}
+	err = c.cmd.Process.Kill()
+	return
+}
+
+// RemoveContainer _
+func (r *apptainerRuntime) RemoveContainer(name string) (err error) {
+	_, gerr := r.get(name)
+	if gerr != nil {
+		return
+	}
+	err = r.KillContainer(name)
+	r.Lock()
+	delete(r.containers, name)
+	r.Unlock()
+	return
+}
+
+// Stats only works if apptainer created a cgroup for the container, i.e. with resource limits on a system with cgroup v2
+func (r *apptainerRuntime) Stats(container_id string) (stats *ContainerStats, err error) {
+	c, err := r.get(container_id)
+	if err != nil {
+		return
+	}
+	if c.cmd.Process == nil {
+		err = fmt.Errorf("(apptainerRuntime/Stats) container %s not started", container_id)
+		return
+	}
+	filename, err := cgroupMemoryStatOfPid(c.cmd.Process.Pid)
+	if err != nil {
+		return
+	}
+	own, _ := cgroupMemoryStatOfPid(os.Getpid())
+	if filename == own {
+		err = fmt.Errorf("(apptainerRuntime/Stats) container %s has no cgroup of its own", container_id)
+		return
+	}
+	return memoryStats(filename)
+}
+
+// OOMKilled OOM kills are not detected with apptainer: it does not report them and removes the cgroup of the container,
+// including its memory.events, when the container exits. They show up as exit status 137 and are not reported as oom failure.
+func (r *apptainerRuntime) OOMKilled(container_id string) (oom bool, err error) {
+	return
+}
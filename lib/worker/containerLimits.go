@@ -0,0 +1,204 @@
+package worker
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/MG-RAST/AWE/lib/conf"
+	"github.com/MG-RAST/AWE/lib/core"
+	"github.com/fsouza/go-dockerclient"
+)
+
+// CFS period used for the CPU quota, in microseconds (docker default)
+const cpuPeriod = 100000
+
+// containerLimits resource limits of the container of a workunit, zero means no limit
+type containerLimits struct {
+	Memory    int64 // bytes, also the limit for memory+swap, i.e. the container cannot swap
+	CPUShares int64 // relative weight, 1024 per core
+	CPUQuota  int64 // microseconds per cpuPeriod
+	Tmpfs     int64 // MiB, size of /tmp
+}
+
+// newContainerLimits derives the limits from the resources of the workunit (CWL ResourceRequirement or Command.Resources).
+// The maximum is used if set, otherwise the minimum. Returns nil if there is nothing to limit.
+func newContainerLimits(r *core.WorkResources) (l *containerLimits) {
+	if r == nil {
+		return
+	}
+	l = &containerLimits{}
+
+	cores := r.CoresMax
+	if cores < r.Cores {
+		cores = r.Cores
+	}
+	if cores > 0 {
+		l.CPUQuota = cores * cpuPeriod
+	}
+	if r.Cores > 0 {
+		l.CPUShares = r.Cores * 1024
+	}
+
+	l.Tmpfs = r.Tmpdir
+
+	ram := r.RAMMax
+	if ram < r.RAM {
+		ram = r.RAM
+	}
+	if ram > 0 {
+		// files in a tmpfs count as memory of the container
+		l.Memory = (ram + l.Tmpfs) * 1024 * 1024
+	}
+
+	if l.Memory == 0 && l.CPUShares == 0 && l.CPUQuota == 0 && l.Tmpfs == 0 {
+		l = nil
+	}
+	return
+}
+
+// apply sets the limits for the docker API
+func (l *containerLimits) apply(hostConfig *docker.HostConfig) {
+	if l.Memory > 0 {
+		hostConfig.Memory = l.Memory
+		hostConfig.MemorySwap = l.Memory
+	}
+	if l.CPUShares > 0 {
+		hostConfig.CPUShares = l.CPUShares
+	}
+	if l.CPUQuota > 0 {
+		hostConfig.CPUQuota = l.CPUQuota
+		hostConfig.CPUPeriod = cpuPeriod
+	}
+	if l.Tmpfs > 0 {
+		hostConfig.Tmpfs = map[string]string{"/tmp": fmt.Sprintf("size=%dm", l.Tmpfs)}
+	}
+}
+
+// args returns the limits as arguments for "docker create"
+func (l *containerLimits) args() (args []string) {
+	if l.Memory > 0 {
+		args = append(args, fmt.Sprintf("--memory=%d", l.Memory), fmt.Sprintf("--memory-swap=%d", l.Memory))
+	}
+	if l.CPUShares > 0 {
+		args = append(args, fmt.Sprintf("--cpu-shares=%d", l.CPUShares))
+	}
+	if l.CPUQuota > 0 {
+		args = append(args, fmt.Sprintf("--cpu-quota=%d", l.CPUQuota), fmt.Sprintf("--cpu-period=%d", cpuPeriod))
+	}
+	if l.Tmpfs > 0 {
+		args = append(args, fmt.Sprintf("--tmpfs=/tmp:size=%dm", l.Tmpfs))
+	}
+	return
+}
+
+// apptainerArgs returns the limits as arguments for "apptainer exec", apptainer has no option for the size of /tmp
+func (l *containerLimits) apptainerArgs() (args []string) {
+	if l.Memory > 0 {
+		args = append(args, "--memory", strconv.FormatInt(l.Memory, 10), "--memory-swap", strconv.FormatInt(l.Memory, 10))
+	}
+	if l.CPUShares > 0 {
+		args = append(args, "--cpu-shares", strconv.FormatInt(l.CPUShares, 10))
+	}
+	if l.CPUQuota > 0 {
+		args = append(args, "--cpus", strconv.FormatFloat(float64(l.CPUQuota)/cpuPeriod, 'f', -1, 64))
+	}
+	return
+}
+
+// findMemoryStat returns the memory.stat file of the container, the configured cgroup_memory_docker_dir is tried
+// first, then the default locations of cgroup v1 and v2 (systemd and cgroupfs driver). Returns "" if none exists.
+func findMemoryStat(container_id string) string {
+	candidates := []string{
+		strings.Replace(conf.CGROUP_MEMORY_DOCKER_DIR, "[ID]", container_id, -1),
+		"/sys/fs/cgroup/memory/docker/" + container_id + "/memory.stat",
+		"/sys/fs/cgroup/memory/system.slice/docker-" + container_id + ".scope/memory.stat",
+		"/sys/fs/cgroup/system.slice/docker-" + container_id + ".scope/memory.stat", // v2
+		"/sys/fs/cgroup/docker/" + container_id + "/memory.stat",                    // v2
+	}
+	for _, filename := range candidates {
+		if _, err := os.Stat(filename); err == nil {
+			return filename
+		}
+	}
+	return ""
+}
+
+// cgroupMemoryStatOfPid finds the memory.stat of the cgroup of a process, for runtimes without a fixed cgroup path (e.g. podman)
+func cgroupMemoryStatOfPid(pid int) (filename string, err error) {
+	content, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		err = fmt.Errorf("(cgroupMemoryStatOfPid) %s", err.Error())
+		return
+	}
+	// lines are hierarchy-ID:controller-list:cgroup-path, cgroup v2 has 0::path
+	for _, line := range strings.Split(string(content), "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		candidate := ""
+		if parts[0] == "0" && parts[1] == "" {
+			candidate = path.Join("/sys/fs/cgroup", parts[2], "memory.stat")
+		} else if strings.Contains(","+parts[1]+",", ",memory,") {
+			candidate = path.Join("/sys/fs/cgroup/memory", parts[2], "memory.stat")
+		} else {
+			continue
+		}
+		if _, serr := os.Stat(candidate); serr == nil {
+			filename = candidate
+			return
+		}
+	}
+	err = fmt.Errorf("(cgroupMemoryStatOfPid) no memory cgroup found for pid %d", pid)
+	return
+}
+
+// readCgroupMemory reads rss and swap usage in bytes from a memory.stat file.
+// cgroup v1 has total_rss and total_swap, cgroup v2 has anon and the swap usage in memory.swap.current next to memory.stat.
+// Values that cannot be read are -1.
+func readCgroupMemory(filename string) (rss int64, swap int64, err error) {
+	rss = -1
+	swap = -1
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	v2 := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		switch fields[0] {
+		case "total_rss": // TODO what is total_rss_huge
+			rss, _ = strconv.ParseInt(fields[1], 10, 64)
+		case "total_swap":
+			swap, _ = strconv.ParseInt(fields[1], 10, 64)
+		case "anon":
+			rss, _ = strconv.ParseInt(fields[1], 10, 64)
+			v2 = true
+		}
+	}
+	err = scanner.Err()
+	if err != nil {
+		return
+	}
+
+	if v2 {
+		swap = 0 // no memory.swap.current if swap is disabled
+		content, rerr := ioutil.ReadFile(path.Join(path.Dir(filename), "memory.swap.current"))
+		if rerr == nil {
+			swap, _ = strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+		}
+	}
+	return
+}
@@ -0,0 +1,85 @@
+package worker
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/MG-RAST/AWE/lib/conf"
+	"github.com/fsouza/go-dockerclient"
+)
+
+// ContainerRuntime runs the containers of workunits with a docker image, selected with container_runtime in the worker config
+type ContainerRuntime interface {
+	Name() string
+	InspectImage(image string) (image_id string, err error) // returns an error if the image is not available locally
+	PullImage(image string) (err error)
+	LoadImage(image_id string, archive io.Reader) (err error) // archive created by "docker save", uncompressed
+	TagImage(image_id string, repository string, tag string) (err error)
+	CreateContainer(spec *ContainerSpec) (container_id string, err error)
+	StartContainer(container_id string) (err error)
+	WaitContainer(container_id string) (status int, err error)
+	KillContainer(container_id string) (err error)
+	RemoveContainer(name string) (err error) // by name or id, a running container is killed, no error if it does not exist
+	Stats(container_id string) (stats *ContainerStats, err error)
+	OOMKilled(container_id string) (oom bool, err error)
+}
+
+// ContainerSpec describes the container of a workunit
+type ContainerSpec struct {
+	Name      string
+	Image     string // id or name
+	Cmd       []string
+	WorkDir   string
+	Binds     []string // host_path:container_path[:ro]
+	Env       []string // KEY=value
+	NoNetwork bool
+	Limits    *containerLimits // may be nil
+}
+
+// ContainerStats memory usage of a running container in bytes, -1 if unknown
+type ContainerStats struct {
+	MemoryRss  int64
+	MemorySwap int64
+}
+
+// NewContainerRuntime creates the runtime configured in container_runtime
+func NewContainerRuntime() (runtime ContainerRuntime, err error) {
+	switch conf.CONTAINER_RUNTIME {
+	case "", "docker":
+		if conf.DOCKER_BINARY != "API" {
+			runtime = newCLIRuntime("docker", conf.DOCKER_BINARY)
+			return
+		}
+		var client *docker.Client
+		client, err = docker.NewClient(conf.DOCKER_SOCKET)
+		if err != nil {
+			err = fmt.Errorf("(NewContainerRuntime) error creating docker client: %s", err.Error())
+			return
+		}
+		runtime = &dockerAPIRuntime{client: client}
+	case "podman":
+		runtime = newCLIRuntime("podman", containerBinary("podman"))
+	case "apptainer", "singularity":
+		runtime = newApptainerRuntime(containerBinary(conf.CONTAINER_RUNTIME))
+	default:
+		err = fmt.Errorf("(NewContainerRuntime) unknown container_runtime \"%s\" (supported: docker, podman, apptainer)", conf.CONTAINER_RUNTIME)
+	}
+	return
+}
+
+func containerBinary(name string) string {
+	if conf.CONTAINER_BINARY != "" {
+		return conf.CONTAINER_BINARY
+	}
+	return name
+}
+
+// memoryStats reads the memory usage from the cgroup of the container
+func memoryStats(filename string) (stats *ContainerStats, err error) {
+	rss, swap, err := readCgroupMemory(filename)
+	if err != nil {
+		return
+	}
+	stats = &ContainerStats{MemoryRss: rss, MemorySwap: swap}
+	return
+}
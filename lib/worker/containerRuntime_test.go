@@ -0,0 +1,195 @@
+package worker_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/MG-RAST/AWE/lib/conf"
+	. "github.com/MG-RAST/AWE/lib/worker"
+)
+
+// fakeContainerBinary writes a script that records its arguments, one per line, and prints a container id
+func fakeContainerBinary(t *testing.T, dir string) (binary string, argsFile string) {
+	binary = path.Join(dir, "runtime")
+	argsFile = path.Join(dir, "args")
+	script := "#!/bin/sh\nfor a in \"$@\"; do echo \"$a\"; done > " + argsFile + "\necho container1\n"
+	if err := ioutil.WriteFile(binary, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func recordedArgs(t *testing.T, argsFile string) []string {
+	content, err := ioutil.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+}
+
+func setContainerConf(runtime string, binary string, dockerBinary string) func() {
+	oldRuntime, oldBinary, oldDocker := conf.CONTAINER_RUNTIME, conf.CONTAINER_BINARY, conf.DOCKER_BINARY
+	conf.CONTAINER_RUNTIME, conf.CONTAINER_BINARY, conf.DOCKER_BINARY = runtime, binary, dockerBinary
+	return func() {
+		conf.CONTAINER_RUNTIME, conf.CONTAINER_BINARY, conf.DOCKER_BINARY = oldRuntime, oldBinary, oldDocker
+	}
+}
+
+func TestNewContainerRuntime(t *testing.T) {
+	// the docker client does not connect before the first request
+	oldSocket := conf.DOCKER_SOCKET
+	conf.DOCKER_SOCKET = "unix:///var/run/docker.sock"
+	defer func() { conf.DOCKER_SOCKET = oldSocket }()
+
+	for _, test := range []struct {
+		runtime      string
+		dockerBinary string
+		name         string
+	}{
+		{"", "API", "docker"},
+		{"docker", "/usr/bin/docker", "docker"},
+		{"podman", "API", "podman"},
+		{"apptainer", "API", "apptainer"},
+		{"singularity", "API", "apptainer"},
+	} {
+		restore := setContainerConf(test.runtime, "", test.dockerBinary)
+		runtime, err := NewContainerRuntime()
+		restore()
+		if err != nil {
+			t.Errorf("%s: %s", test.runtime, err.Error())
+			continue
+		}
+		if runtime.Name() != test.name {
+			t.Errorf("%s: expected runtime %s, got %s", test.runtime, test.name, runtime.Name())
+		}
+	}
+
+	restore := setContainerConf("rkt", "", "API")
+	defer restore()
+	if _, err := NewContainerRuntime(); err == nil {
+		t.Errorf("expected error for an unknown runtime")
+	}
+}
+
+func TestCLIRuntimeArgs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "awe-runtime")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	binary, argsFile := fakeContainerBinary(t, dir)
+
+	restore := setContainerConf("podman", binary, "API")
+	defer restore()
+	runtime, err := NewContainerRuntime()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spec := &ContainerSpec{
+		Name:      "AWE_workunit_1",
+		Image:     "ubuntu:18.04",
+		Cmd:       []string{"echo", "hello world"},
+		WorkDir:   "/workdir",
+		Binds:     []string{"/data/work:/workdir", "/data/db:/db:ro"},
+		Env:       []string{"TMPDIR=/workdir"},
+		NoNetwork: true,
+	}
+	id, err := runtime.CreateContainer(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "container1" {
+		t.Errorf("expected container id container1, got %s", id)
+	}
+	expected := []string{"create", "--name=AWE_workunit_1", "--workdir=/workdir", "--volume=/data/work:/workdir", "--volume=/data/db:/db:ro", "--env=TMPDIR=/workdir", "--network=none", "ubuntu:18.04", "echo", "hello world"}
+	if args := recordedArgs(t, argsFile); strings.Join(args, "|") != strings.Join(expected, "|") {
+		t.Errorf("expected args %q, got %q", expected, args)
+	}
+
+	if err = runtime.RemoveContainer("AWE_workunit_1"); err != nil {
+		t.Fatal(err)
+	}
+	if args := recordedArgs(t, argsFile); strings.Join(args, " ") != "rm -f AWE_workunit_1" {
+		t.Errorf("unexpected args for rm: %q", args)
+	}
+}
+
+func TestApptainerRuntimeArgs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "awe-runtime")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	binary, argsFile := fakeContainerBinary(t, dir)
+
+	restore := setContainerConf("apptainer", binary, "API")
+	defer restore()
+	oldImageDir := conf.CONTAINER_IMAGE_DIR
+	conf.CONTAINER_IMAGE_DIR = dir
+	defer func() { conf.CONTAINER_IMAGE_DIR = oldImageDir }()
+
+	runtime, err := NewContainerRuntime()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(spec *ContainerSpec) []string {
+		id, err := runtime.CreateContainer(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != spec.Name {
+			t.Errorf("expected the container name as id, got %s", id)
+		}
+		if err = runtime.StartContainer(id); err != nil {
+			t.Fatal(err)
+		}
+		status, err := runtime.WaitContainer(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status != 0 {
+			t.Errorf("expected status 0, got %d", status)
+		}
+		return recordedArgs(t, argsFile)
+	}
+
+	spec := &ContainerSpec{
+		Name:      "AWE_workunit_1",
+		Image:     "ubuntu:18.04",
+		Cmd:       []string{"echo", "hello world"},
+		WorkDir:   "/workdir",
+		Binds:     []string{"/data/work:/workdir"},
+		Env:       []string{"TMPDIR=/workdir"},
+		NoNetwork: true,
+	}
+	expected := []string{"exec", "--containall", "--cleanenv", "--pwd", "/workdir", "--bind", "/data/work:/workdir", "--env", "TMPDIR=/workdir", "--net", "--network", "none", "docker://ubuntu:18.04", "echo", "hello world"}
+	if args := run(spec); strings.Join(args, "|") != strings.Join(expected, "|") {
+		t.Errorf("expected args %q, got %q", expected, args)
+	}
+
+	// an image converted to a SIF file is used instead of the registry
+	if _, err = runtime.InspectImage("ubuntu:18.04"); err == nil {
+		t.Errorf("expected the image to be missing")
+	}
+	sif := path.Join(dir, DockerizeName("ubuntu:18.04")+".sif")
+	if err = ioutil.WriteFile(sif, []byte{}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = runtime.InspectImage("ubuntu:18.04"); err != nil {
+		t.Errorf("image not found: %s", err.Error())
+	}
+	spec.Name = "AWE_workunit_2"
+	spec.NoNetwork = false
+	args := run(spec)
+	if len(args) < 3 || args[len(args)-3] != sif {
+		t.Errorf("expected %s as image, got %q", sif, args)
+	}
+	if err = runtime.RemoveContainer("AWE_workunit_1"); err != nil {
+		t.Error(err)
+	}
+}
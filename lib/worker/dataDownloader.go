@@ -293,12 +293,17 @@ func downloadWorkunitData(workunit *core.Workunit) (err error) {
 			switch cwl_tool.(type) {
 			case *cwl.CommandLineTool:
 				cwl_tool_clt := cwl_tool.(*cwl.CommandLineTool)
-				// remove ShockRequirement (CWL-Runner does not know it)
+				// remove ShockRequirement and StorageRequirement (CWL-Runner does not know them)
 				cwl_tool_clt.Requirements, err = cwl.DeleteRequirement("ShockRequirement", cwl_tool_clt.Requirements)
 				if err != nil {
 					err = fmt.Errorf("(downloadWorkunitData) DeleteRequirement/CommandLineTool returned: %s", err.Error())
 					return
 				}
+				cwl_tool_clt.Requirements, err = cwl.DeleteRequirement("StorageRequirement", cwl_tool_clt.Requirements)
+				if err != nil {
+					err = fmt.Errorf("(downloadWorkunitData) DeleteRequirement/CommandLineTool returned: %s", err.Error())
+					return
+				}
 				cwl_tool_clt.ID = ""
 				cwl_tool_bytes, err = yaml.Marshal(cwl_tool_clt)
 				if err != nil {
@@ -311,6 +316,11 @@ func downloadWorkunitData(workunit *core.Workunit) (err error) {
 					err = fmt.Errorf("(downloadWorkunitData) DeleteRequirement/ExpressionTool returned: %s", err.Error())
 					return
 				}
+				cwl_tool_et.Requirements, err = cwl.DeleteRequirement("StorageRequirement", cwl_tool_et.Requirements)
+				if err != nil {
+					err = fmt.Errorf("(downloadWorkunitData) DeleteRequirement/ExpressionTool returned: %s", err.Error())
+					return
+				}
 				cwl_tool_et.ID = ""
 				cwl_tool_bytes, err = yaml.Marshal(cwl_tool_et)
 				if err != nil {
@@ -321,6 +331,11 @@ func downloadWorkunitData(workunit *core.Workunit) (err error) {
 				return
 			}
 
+			// CWL LoadListingRequirement, all files have been downloaded, only the listing the tool gets to see is truncated
+			if workunit.LoadListing != "" {
+				cwl.ApplyLoadListing(job_input, workunit.LoadListing)
+			}
+
 			// convert job_input into a map
 			job_input_map := job_input.GetMap()
 
@@ -13,8 +13,6 @@ import (
 	e "github.com/MG-RAST/AWE/lib/errors"
 	"github.com/MG-RAST/AWE/lib/logger"
 	"github.com/MG-RAST/AWE/lib/logger/event"
-
-	shock "github.com/MG-RAST/go-shock-client"
 )
 
 func deliverer(control chan int) {
@@ -33,10 +31,11 @@ func deliverer_run(control chan int) (err error) { // TODO return all errors
 
 	logger.Debug(3, "deliverer_run")
 
-	// this makes sure new work is only requested when deliverer is done
-	defer func() { <-chanPermit }()
 	workunit := <-fromProcessor
 
+	// this makes sure new work is only requested when deliverer is done
+	defer slots.Release(workunit.Workunit_Unique_Identifier)
+
 	if Client_mode == "offline" {
 		return
 	}
@@ -66,14 +65,16 @@ func deliverer_run(control chan int) (err error) { // TODO return all errors
 		workmap.Set(work_id, ID_DELIVERER, "deliverer")
 		perfstat := workunit.WorkPerf
 
-		// post-process for works computed successfully: push output data to Shock
+		// post-process for works computed successfully: push output data to the storage of the job (Shock by default)
 		move_start := time.Now().UnixNano()
 		logger.Debug(3, "(deliverer_run) work.State: %s", workunit.State)
 		if workunit.State == core.WORK_STAT_COMPUTED {
 
-			shock_client := &shock.ShockClient{Host: workunit.ShockHost, Token: workunit.Info.DataToken, Debug: false}
-
-			data_moved, err := cache.UploadOutputData(workunit, shock_client, nil)
+			var data_moved int64
+			store, err := cache.WorkunitStorage(workunit)
+			if err == nil {
+				data_moved, err = cache.UploadOutputData(workunit, store, nil)
+			}
 			if err != nil {
 				workunit.SetState(core.WORK_STAT_ERROR, "UploadOutputData failed")
 				logger.Error("(deliverer_run) UploadOutputData returns workid=" + work_str + ", err=" + err.Error())
@@ -88,6 +89,12 @@ func deliverer_run(control chan int) (err error) { // TODO return all errors
 		perfstat.Deliver = int64(move_end / 1e9)
 		perfstat.ClientResp = perfstat.Deliver - perfstat.Checkout
 		perfstat.ClientId = core.Self.ID
+		observeWorkPerf(workunit, perfstat)
+
+		// failures before or after the tool ran (download, upload) are infrastructure errors
+		if workunit.State == core.WORK_STAT_ERROR && workunit.Failure == "" {
+			workunit.Failure = core.FAILURE_INFRASTRUCTURE
+		}
 
 		// notify server the final process results; send perflog, stdout, and stderr if needed
 		// detect e.ClientNotFound
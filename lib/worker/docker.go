@@ -5,12 +5,10 @@ import (
 
 	"github.com/MG-RAST/AWE/lib/logger"
 	//"github.com/MG-RAST/AWE/lib/logger/event"
-	"bufio"
 	"bytes"
 	"compress/gzip"
 	"errors"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"net/url"
 	"os/exec"
@@ -254,20 +252,6 @@ func TagImage(client *docker.Client, dockerimage_id string, tag_opts docker.TagI
 	return err
 }
 
-func KillContainer(container_id string) (err error) {
-	logger.Debug(1, fmt.Sprintf("(KillContainer) %s:", container_id))
-	cmd := exec.Command(conf.DOCKER_BINARY, "kill", container_id)
-
-	err = cmd.Start()
-	if err != nil {
-		err = fmt.Errorf("(KillContainer) cmd.Start returned: %s", err.Error())
-		return err
-	}
-
-	err = cmd.Wait()
-	return nil
-}
-
 // execute command, wait, and return stdout and stderr ; do not use for large outputs !
 // it returns both stdout and stderr
 func RunCommand(name string, arg ...string) (stdo []byte, stde []byte, err error) {
@@ -310,174 +294,6 @@ func RunCommand(name string, arg ...string) (stdo []byte, stde []byte, err error
 
 }
 
-func CreateContainer(create_args []string) (container_id string, err error) {
-
-	//docker create [OPTIONS] IMAGE [COMMAND] [ARG...]
-	// first line contains ID
-	// must have "-t" to attach, this is not documented in docker.
-
-	// prepend "create"
-	create_args = append([]string{"create"}, create_args...)
-
-	logger.Debug(1, fmt.Sprintf("(CreateContainer) cmd: %s %s", conf.DOCKER_BINARY, strings.Join(create_args, " ")))
-
-	stdo, _, err := RunCommand(conf.DOCKER_BINARY, create_args...)
-
-	if err != nil {
-
-		logger.Debug(1, fmt.Sprintf("(CreateContainer) cmd.Wait returned error: %s", err.Error()))
-		err = fmt.Errorf("(CreateContainer) RunCommand returned: %s", err.Error())
-
-		return "", err
-	}
-
-	// extract only first line
-	endofline := bytes.IndexByte(stdo, '\n')
-
-	stdout_line := ""
-	if endofline >= 0 {
-		stdout_line = string(stdo[0 : endofline-1])
-	} else {
-		err = errors.New("docker create returned empty string")
-	}
-
-	return stdout_line, err
-
-}
-
-// ** not tested **
-func RunContainer(run_args []string) (container_id string, err error) {
-	logger.Debug(1, fmt.Sprintf("(RunContainer) %s:", container_id))
-	//docker run [OPTIONS] IMAGE [COMMAND] [ARG...]
-	// first line contains ID
-	// must have "-t" to attach, this is not documented in docker.
-
-	// prepend "create"
-	run_args = append([]string{"run"}, run_args...)
-
-	logger.Debug(1, fmt.Sprintf("(RunContainer) cmd: %s %s", conf.DOCKER_BINARY, strings.Join(run_args, " ")))
-
-	cmd := exec.Command(conf.DOCKER_BINARY, run_args...)
-
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		err = fmt.Errorf("(RunContainer) StdoutPipe returned: %s", err.Error())
-		return "", err
-	}
-
-	rd := bufio.NewReader(stdout)
-	//stderr, err := cmd.StderrPipe()
-	//if err != nil {
-	//	return nil, err
-	//}
-
-	if err = cmd.Start(); err != nil {
-		err = fmt.Errorf("(RunContainer) cmd.Start returned: %s", err.Error())
-		return "", err
-	}
-
-	err = cmd.Wait()
-	var stdout_line string
-	for {
-
-		stdout_line, err = rd.ReadString('\n')
-
-		if err == io.EOF {
-			return "", err
-		}
-		break
-	}
-
-	if err != nil {
-		return "", err
-	}
-
-	return stdout_line, err
-
-}
-
-func RemoveContainer(container_id string) (err error) {
-	logger.Debug(1, fmt.Sprintf("(RemoveContainer) %s:", container_id))
-	cmd := exec.Command(conf.DOCKER_BINARY, "rm", "-f", container_id)
-	if err = cmd.Start(); err != nil {
-		err = fmt.Errorf("(RemoveContainer) cmd.Start returned: %s", err.Error())
-		return err
-	}
-	err = cmd.Wait()
-	return nil
-}
-
-func StartContainer(container_id string, args string) (err error) {
-	// docker start CONTAINER [CONTAINER...]
-	logger.Debug(1, fmt.Sprintf("(StartContainer) %s:", container_id))
-
-	stdo, stde, err := RunCommand(conf.DOCKER_BINARY, []string{"start", container_id}...)
-
-	_ = stdo
-	_ = stde
-
-	if err != nil {
-		logger.Debug(1, fmt.Sprintf("(StartContainer) cmd.Wait returned error: %s", err.Error()))
-
-		logger.Debug(1, fmt.Sprintf("(StartContainer) cmd.Wait stdout: %s", stdo))
-		logger.Debug(1, fmt.Sprintf("(StartContainer) cmd.Wait stderr: %s", stde))
-		err = fmt.Errorf("(StartContainer) cmd.Start returned: %s", err.Error())
-	}
-
-	return err
-
-}
-
-func WaitContainer(container_id string) (status int, err error) {
-	logger.Debug(1, fmt.Sprintf("(WaitContainer) container id: %s", container_id))
-
-	stdo, stde, err := RunCommand(conf.DOCKER_BINARY, []string{"wait", container_id}...)
-
-	_ = stde
-
-	if err != nil {
-		logger.Debug(1, fmt.Sprintf("(WaitContainer) cmd.Wait returned error: %s", err.Error()))
-
-		logger.Debug(1, fmt.Sprintf("(WaitContainer) cmd.Wait stdout: %s", stdo))
-		logger.Debug(1, fmt.Sprintf("(WaitContainer) cmd.Wait stderr: %s", stde))
-		err = fmt.Errorf("(WaitContainer) RunCommand returned: %s", err.Error())
-		return 0, err
-	}
-
-	// extract only first line
-	endofline := bytes.IndexByte(stdo, '\n')
-
-	stdout_line := ""
-	if endofline > 0 {
-		stdout_line = string(stdo[0:endofline])
-	} else {
-		err = errors.New("(WaitContainer) docker create returned empty string")
-		return 0, err
-	}
-
-	negative_status := false
-
-	if strings.HasPrefix(stdout_line, "-") {
-		stdout_line = strings.TrimPrefix(stdout_line, "-")
-		negative_status = true
-	}
-
-	status, err = strconv.Atoi(stdout_line)
-	if err != nil {
-		logger.Debug(1, fmt.Sprintf("(WaitContainer) could not interpret status code: \"%s\"", stdout_line))
-		// handle error
-		err = fmt.Errorf("(WaitContainer) strconv.Atoi( returned: %s", err.Error())
-		return 0, err
-	}
-
-	if negative_status {
-		status *= -1
-	}
-
-	return status, nil
-
-}
-
 func dockerBuildImage(client *docker.Client, Dockerimage string) (err error) {
 	logger.Debug(1, fmt.Sprintf("(dockerBuildImage) %s:", Dockerimage))
 
@@ -719,51 +535,27 @@ func findDockerImageInShock(Dockerimage string, datatoken string) (node *shock.S
 	return
 }
 
-func dockerLoadImage(client *docker.Client, download_url string, datatoken string) (err error) {
+func dockerLoadImage(container_runtime ContainerRuntime, image_id string, download_url string, datatoken string) (err error) {
 
 	image_stream, err := shock.FetchShockStream(download_url, datatoken) // token empty here, assume that images are public
 	if err != nil {
 		return errors.New(fmt.Sprintf("Error getting Shock stream, err=%s", err.Error()))
 	}
+	defer image_stream.Close()
 
-	gr, err := gzip.NewReader(image_stream) //returns (*Reader, error) // TODO not sure if I have to close gr later ?
+	gr, err := gzip.NewReader(image_stream)
+	if err != nil {
+		return fmt.Errorf("(dockerLoadImage) gzip.NewReader returned: %s", err.Error())
+	}
+	defer gr.Close()
 
 	logger.Debug(1, fmt.Sprintf("loading image..."))
-	//go io.Copy(image_writer, image_stream)
-
-	var buf bytes.Buffer
-
-	if client != nil {
-		opts := docker.LoadImageOptions{InputStream: gr}
-		err = client.LoadImage(opts)
-
-	} else {
-
-		//pipe stream into docker binary "docker load"
-		cmd := exec.Command(conf.DOCKER_BINARY, "load")
-
-		stdin, err := cmd.StdinPipe() // stdin is a io.WriteCloser
-
-		//stderr, err := cmd.StderrPipe()
-		//if err != nil {
-		//	return err
-		//}
-
-		if err = cmd.Start(); err != nil {
-			err = fmt.Errorf("(dockerLoadImage) cmd.Start returned: %s", err.Error())
-			return err
-		}
-
-		go io.Copy(stdin, gr)
-
-		err = cmd.Wait()
-
-	}
 
+	err = container_runtime.LoadImage(image_id, gr)
 	if err != nil {
 		return errors.New(fmt.Sprintf("Error loading image, err=%s", err.Error()))
 	}
-	logger.Debug(1, fmt.Sprintf("load image returned: %v", &buf))
+	logger.Debug(1, "loaded image %s", image_id)
 
 	return
 }
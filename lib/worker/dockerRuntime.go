@@ -0,0 +1,341 @@
+package worker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/MG-RAST/AWE/lib/logger"
+	"github.com/fsouza/go-dockerclient"
+)
+
+// dockerAPIRuntime uses the API of the docker daemon (docker_binary=API)
+type dockerAPIRuntime struct {
+	client *docker.Client
+}
+
+// Name _
+func (r *dockerAPIRuntime) Name() string {
+	return "docker"
+}
+
+// InspectImage _
+func (r *dockerAPIRuntime) InspectImage(image string) (image_id string, err error) {
+	var img *docker.Image
+	img, err = InspectImage(r.client, image)
+	if err != nil {
+		return
+	}
+	image_id = img.ID
+	return
+}
+
+// PullImage _
+func (r *dockerAPIRuntime) PullImage(image string) (err error) {
+	var buf bytes.Buffer
+	pio := docker.PullImageOptions{Repository: image, OutputStream: &buf}
+	err = r.client.PullImage(pio, docker.AuthConfiguration{})
+	logger.Debug(3, "docker pull response: %s", buf.String())
+	if err != nil {
+		err = fmt.Errorf("(dockerAPIRuntime/PullImage) client.PullImage returned: %s", err.Error())
+	}
+	return
+}
+
+// LoadImage _
+func (r *dockerAPIRuntime) LoadImage(image_id string, archive io.Reader) (err error) {
+	err = r.client.LoadImage(docker.LoadImageOptions{InputStream: archive})
+	if err != nil {
+		err = fmt.Errorf("(dockerAPIRuntime/LoadImage) client.LoadImage returned: %s", err.Error())
+	}
+	return
+}
+
+// TagImage _
+func (r *dockerAPIRuntime) TagImage(image_id string, repository string, tag string) (err error) {
+	return TagImage(r.client, image_id, docker.TagImageOptions{Repo: repository, Tag: tag})
+}
+
+// CreateContainer _
+func (r *dockerAPIRuntime) CreateContainer(spec *ContainerSpec) (container_id string, err error) {
+	config := docker.Config{Image: spec.Image,
+		WorkingDir:   spec.WorkDir,
+		AttachStdout: true,
+		AttachStderr: true,
+		AttachStdin:  false,
+		Cmd:          spec.Cmd,
+		Volumes:      map[string]struct{}{},
+		Env:          spec.Env,
+	}
+	for _, bind := range spec.Binds {
+		parts := strings.Split(bind, ":")
+		if len(parts) > 1 {
+			config.Volumes[parts[1]] = struct{}{}
+		}
+	}
+
+	hostConfig := docker.HostConfig{Binds: spec.Binds}
+	if spec.NoNetwork {
+		hostConfig.NetworkMode = "none"
+	}
+	if spec.Limits != nil {
+		spec.Limits.apply(&hostConfig)
+	}
+
+	// note: docker binary mounts on creation, while docker API mounts on start of container
+	container, err := r.client.CreateContainer(docker.CreateContainerOptions{Name: spec.Name, Config: &config, HostConfig: &hostConfig})
+	if err != nil {
+		err = fmt.Errorf("(dockerAPIRuntime/CreateContainer) client.CreateContainer returned: %s", err.Error())
+		return
+	}
+	container_id = container.ID
+	return
+}
+
+// StartContainer _
+func (r *dockerAPIRuntime) StartContainer(container_id string) (err error) {
+	return r.client.StartContainer(container_id, nil)
+}
+
+// WaitContainer _
+func (r *dockerAPIRuntime) WaitContainer(container_id string) (status int, err error) {
+	return r.client.WaitContainer(container_id)
+}
+
+// KillContainer _
+func (r *dockerAPIRuntime) KillContainer(container_id string) (err error) {
+	return r.client.KillContainer(docker.KillContainerOptions{ID: container_id})
+}
+
+// RemoveContainer _
+func (r *dockerAPIRuntime) RemoveContainer(name string) (err error) {
+	return RemoveOldAWEContainers(r.client, name)
+}
+
+// Stats _
+func (r *dockerAPIRuntime) Stats(container_id string) (stats *ContainerStats, err error) {
+	filename := findMemoryStat(container_id)
+	if filename == "" {
+		var cont *docker.Container
+		cont, err = r.client.InspectContainer(container_id)
+		if err != nil {
+			err = fmt.Errorf("(dockerAPIRuntime/Stats) client.InspectContainer returned: %s", err.Error())
+			return
+		}
+		filename, err = cgroupMemoryStatOfPid(cont.State.Pid)
+		if err != nil {
+			return
+		}
+	}
+	return memoryStats(filename)
+}
+
+// OOMKilled _
+func (r *dockerAPIRuntime) OOMKilled(container_id string) (oom bool, err error) {
+	cont, err := r.client.InspectContainer(container_id)
+	if err != nil {
+		err = fmt.Errorf("(dockerAPIRuntime/OOMKilled) client.InspectContainer returned: %s", err.Error())
+		return
+	}
+	oom = cont.State.OOMKilled
+	return
+}
+
+// writeInspect writes the output of inspect into a file, for debugging
+func (r *dockerAPIRuntime) writeInspect(container_id string, filename string) (err error) {
+	cont, err := r.client.InspectContainer(container_id)
+	if err != nil {
+		err = fmt.Errorf("(dockerAPIRuntime/writeInspect) client.InspectContainer returned: %s", err.Error())
+		return
+	}
+	logger.Debug(3, "Container status: %s", cont.State.Status)
+
+	b_inspect, _ := json.MarshalIndent(cont, "", "    ")
+	err = ioutil.WriteFile(filename, b_inspect, 0666)
+	return
+}
+
+// cliRuntime calls a docker compatible command line, i.e. docker (docker_binary other than API) or podman
+type cliRuntime struct {
+	name   string
+	binary string
+}
+
+func newCLIRuntime(name string, binary string) *cliRuntime {
+	return &cliRuntime{name: name, binary: binary}
+}
+
+// run executes the binary, stderr is added to the error
+func (r *cliRuntime) run(args ...string) (stdout string, err error) {
+	stdo, stde, err := RunCommand(r.binary, args...)
+	if err != nil {
+		err = fmt.Errorf("%s %s: %s (%s)", r.binary, args[0], err.Error(), strings.TrimSpace(string(stde)))
+		return
+	}
+	stdout = strings.TrimSpace(string(stdo))
+	return
+}
+
+// Name _
+func (r *cliRuntime) Name() string {
+	return r.name
+}
+
+// InspectImage _
+func (r *cliRuntime) InspectImage(image string) (image_id string, err error) {
+	image_id, err = r.run("image", "inspect", "--format", "{{.Id}}", image)
+	if err != nil {
+		err = fmt.Errorf("(cliRuntime/InspectImage) %s", err.Error())
+	}
+	return
+}
+
+// PullImage _
+func (r *cliRuntime) PullImage(image string) (err error) {
+	_, err = r.run("pull", image)
+	if err != nil {
+		err = fmt.Errorf("(cliRuntime/PullImage) %s", err.Error())
+	}
+	return
+}
+
+// LoadImage _
+func (r *cliRuntime) LoadImage(image_id string, archive io.Reader) (err error) {
+	cmd := exec.Command(r.binary, "load")
+	cmd.Stdin = archive
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		err = fmt.Errorf("(cliRuntime/LoadImage) %s load: %s (%s)", r.binary, err.Error(), strings.TrimSpace(string(output)))
+		return
+	}
+	logger.Debug(1, "(cliRuntime/LoadImage) %s", output)
+	return
+}
+
+// TagImage _
+func (r *cliRuntime) TagImage(image_id string, repository string, tag string) (err error) {
+	_, err = r.run("tag", image_id, repository+":"+tag)
+	if err != nil {
+		err = fmt.Errorf("(cliRuntime/TagImage) %s", err.Error())
+	}
+	return
+}
+
+// CreateContainer _
+func (r *cliRuntime) CreateContainer(spec *ContainerSpec) (container_id string, err error) {
+	args := []string{"create", "--name=" + spec.Name, "--workdir=" + spec.WorkDir}
+	for _, bind := range spec.Binds {
+		args = append(args, "--volume="+bind)
+	}
+	for _, env := range spec.Env {
+		args = append(args, "--env="+env)
+	}
+	if spec.NoNetwork {
+		args = append(args, "--network=none")
+	}
+	if spec.Limits != nil {
+		args = append(args, spec.Limits.args()...)
+	}
+	args = append(args, spec.Image)
+	args = append(args, spec.Cmd...)
+
+	logger.Debug(1, "(cliRuntime/CreateContainer) cmd: %s %s", r.binary, strings.Join(args, " "))
+
+	stdout, err := r.run(args...)
+	if err != nil {
+		err = fmt.Errorf("(cliRuntime/CreateContainer) %s", err.Error())
+		return
+	}
+	// the id is on the last line, podman may print pull progress before
+	lines := strings.Split(stdout, "\n")
+	container_id = strings.TrimSpace(lines[len(lines)-1])
+	if container_id == "" {
+		err = fmt.Errorf("(cliRuntime/CreateContainer) %s create returned empty string", r.binary)
+	}
+	return
+}
+
+// StartContainer _
+func (r *cliRuntime) StartContainer(container_id string) (err error) {
+	_, err = r.run("start", container_id)
+	if err != nil {
+		err = fmt.Errorf("(cliRuntime/StartContainer) %s", err.Error())
+	}
+	return
+}
+
+// WaitContainer _
+func (r *cliRuntime) WaitContainer(container_id string) (status int, err error) {
+	stdout, err := r.run("wait", container_id)
+	if err != nil {
+		err = fmt.Errorf("(cliRuntime/WaitContainer) %s", err.Error())
+		return
+	}
+	status, err = strconv.Atoi(strings.Split(stdout, "\n")[0])
+	if err != nil {
+		err = fmt.Errorf("(cliRuntime/WaitContainer) could not interpret status code \"%s\": %s", stdout, err.Error())
+	}
+	return
+}
+
+// KillContainer _
+func (r *cliRuntime) KillContainer(container_id string) (err error) {
+	_, err = r.run("kill", container_id)
+	if err != nil {
+		err = fmt.Errorf("(cliRuntime/KillContainer) %s", err.Error())
+	}
+	return
+}
+
+// RemoveContainer _
+func (r *cliRuntime) RemoveContainer(name string) (err error) {
+	_, rerr := r.run("rm", "-f", name)
+	if rerr != nil {
+		// fails if the container does not exist
+		logger.Debug(1, "(cliRuntime/RemoveContainer) %s", rerr.Error())
+	}
+	return
+}
+
+// Stats _
+func (r *cliRuntime) Stats(container_id string) (stats *ContainerStats, err error) {
+	filename := ""
+	if r.name == "docker" {
+		filename = findMemoryStat(container_id)
+	}
+	if filename == "" {
+		var stdout string
+		stdout, err = r.run("inspect", "--format", "{{.State.Pid}}", container_id)
+		if err != nil {
+			err = fmt.Errorf("(cliRuntime/Stats) %s", err.Error())
+			return
+		}
+		var pid int
+		pid, err = strconv.Atoi(stdout)
+		if err != nil {
+			err = fmt.Errorf("(cliRuntime/Stats) could not interpret pid \"%s\": %s", stdout, err.Error())
+			return
+		}
+		filename, err = cgroupMemoryStatOfPid(pid)
+		if err != nil {
+			return
+		}
+	}
+	return memoryStats(filename)
+}
+
+// OOMKilled _
+func (r *cliRuntime) OOMKilled(container_id string) (oom bool, err error) {
+	stdout, err := r.run("inspect", "--format", "{{.State.OOMKilled}}", container_id)
+	if err != nil {
+		err = fmt.Errorf("(cliRuntime/OOMKilled) %s", err.Error())
+		return
+	}
+	oom = stdout == "true"
+	return
+}
@@ -9,9 +9,13 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"path"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/MG-RAST/AWE/lib/conf"
@@ -85,7 +89,20 @@ func ExitWorker(newServerUUID string) {
 
 // SendHeartBeat client sends heartbeat to server to maintain active status and re-register when needed
 func SendHeartBeat() (err error) {
+	// restart after the heartbeat that acknowledges the restart command, otherwise the server would repeat it
+	restart := false
+	if restartRequested.get() {
+		restart, _ = core.Self.CurrentWork.IsEmpty(false)
+	}
+
 	hbmsg, err := heartbeating(conf.SERVER_URL, core.Self.ID)
+	if err == nil && restart {
+		err = RestartClient()
+		if err != nil {
+			logger.Error("(SendHeartBeat) RestartClient returned: %s", err.Error())
+			err = nil
+		}
+	}
 	if err != nil {
 		logger.Debug(3, "(SendHeartBeat) heartbeat returned error: "+err.Error())
 		if strings.Contains(err.Error(), e.ClientNotFound) {
@@ -135,23 +152,90 @@ func SendHeartBeat() (err error) {
 				}
 				_ = DiscardWorkunit(work_id)
 			}
-		} else if op == "restart" {
-			RestartClient()
 		} else if op == "stop" {
 			StopClient()
-		} else if op == "clean" {
-			CleanDisk()
+		} else if cmd := core.ParseClientCommand(op, objs); cmd != nil {
+			runClientCommand(cmd)
 		}
 	}
 	return
 }
 
+// restartFlag set by the restart command, the worker restarts when the current workunits are done
+type restartFlag struct {
+	sync.Mutex
+	requested bool
+}
+
+func (f *restartFlag) set(requested bool) {
+	f.Lock()
+	f.requested = requested
+	f.Unlock()
+}
+
+func (f *restartFlag) get() bool {
+	f.Lock()
+	defer f.Unlock()
+	return f.requested
+}
+
+var restartRequested = &restartFlag{}
+
+// runClientCommand executes a command issued with PUT /client/{id}, the result is sent to the server with the next heartbeat
+func runClientCommand(cmd *core.ClientCommand) {
+	if core.Self.HasCommandResult(cmd.ID) {
+		// server has not yet seen the result
+		return
+	}
+	logger.Info("(runClientCommand) received command %s %s (id %s)", cmd.Op, cmd.Arg, cmd.ID)
+
+	result := &core.ClientCommandResult{ID: cmd.ID, Op: cmd.Op, Received: time.Now()}
+	var err error
+	switch cmd.Op {
+	case core.CLIENT_CMD_DRAIN:
+		err = core.Self.SetDraining(true, true)
+		result.Result = "draining, no new workunits are checked out"
+	case core.CLIENT_CMD_UNDRAIN:
+		err = core.Self.SetDraining(false, true)
+		restartRequested.set(false)
+		result.Result = "checking out workunits again"
+	case core.CLIENT_CMD_RESTART:
+		err = core.Self.SetDraining(true, true)
+		restartRequested.set(true)
+		result.Result = "restarting when the current workunits are done"
+	case core.CLIENT_CMD_CLEANDISK:
+		var removed int
+		removed, err = CleanDisk()
+		result.Result = fmt.Sprintf("removed %d work directories", removed)
+	case core.CLIENT_CMD_DEBUGLEVEL:
+		var level int
+		level, err = strconv.Atoi(cmd.Arg)
+		if err == nil {
+			logger.SetDebugLevel(level)
+			logger.Event(event.DEBUG_LEVEL, "level="+cmd.Arg)
+			result.Result = "debuglevel=" + cmd.Arg
+		}
+	}
+	if err != nil {
+		result.Error = err.Error()
+		logger.Error("(runClientCommand) command %s failed: %s", cmd.Op, err.Error())
+	}
+	core.Self.AddCommandResult(result)
+}
+
 func heartbeating(host string, clientid string) (msg core.HeartbeatInstructions, err error) {
 	response := new(HeartbeatResponse)
 	targeturl := fmt.Sprintf("%s/client/%s?heartbeat", host, clientid)
 	//res, err := http.Get(targeturl)
 
+	updateResources()
+
+	readLock, err := core.Self.RLockNamed("heartbeating")
+	if err != nil {
+		return
+	}
 	worker_state_b, err := json.Marshal(core.Self.WorkerState)
+	core.Self.RUnlockNamed(readLock)
 	if err != nil {
 		err = fmt.Errorf("(heartbeating) json.Marshal failed: %s", err.Error())
 		return
@@ -423,6 +507,12 @@ func ComposeProfile() (profile *core.Client, err error) {
 
 	profile.Group = conf.CLIENT_GROUP
 	profile.CPUs = runtime.NumCPU()
+	profile.Slots = conf.WORKER_SLOTS
+	profile.Memory, _, err = getMemory()
+	if err != nil {
+		logger.Warning("(ComposeProfile) could not determine memory: %s", err.Error())
+		err = nil
+	}
 	profile.Domain = conf.CLIENT_DOMAIN
 	profile.Version = conf.VERSION
 	//profile.GitCommitHash = conf.GIT_COMMIT_HASH
@@ -463,7 +553,7 @@ func DiscardWorkunit(id core.Workunit_Unique_Identifier) (err error) {
 	}
 	if ok {
 		if stage == ID_WORKER {
-			chankill <- true
+			_ = workmap.Kill(id)
 		}
 
 		workmap.Set(id, ID_DISCARDED, "DiscardWorkunit")
@@ -481,9 +571,18 @@ func DiscardWorkunit(id core.Workunit_Unique_Identifier) (err error) {
 	return
 }
 
+// RestartClient replaces the worker process with a new one with the same arguments, the pid stays the same
 func RestartClient() (err error) {
-	//fmt.Printf("try to restart client\n")
-	//to-do: implementation here
+	executable, err := os.Executable()
+	if err != nil {
+		err = fmt.Errorf("(RestartClient) os.Executable returned: %s", err.Error())
+		return
+	}
+	logger.Info("(RestartClient) restarting %s", executable)
+	logger.Event(event.CLIENT_UNREGISTER, "clientid="+core.Self.ID)
+	err = syscall.Exec(executable, os.Args, os.Environ())
+	// only returns on error
+	err = fmt.Errorf("(RestartClient) syscall.Exec returned: %s", err.Error())
 	return
 }
 
@@ -493,9 +592,40 @@ func StopClient() (err error) {
 	return
 }
 
-func CleanDisk() (err error) {
-	//fmt.Printf("try to clean disk space\n")
-	//to-do: implementation here
+// CleanDisk removes the work directories of workunits that are not running, e.g. left over because of keep_work_dir
+// or a crash. Directories are WORK_PATH/xx/xx/xx/<jobid>_<task>_<rank>, see Workunit.Path.
+func CleanDisk() (removed int, err error) {
+	running := map[string]bool{}
+	currentWork, err := core.Self.CurrentWork.Get_list(true)
+	if err != nil {
+		err = fmt.Errorf("(CleanDisk) CurrentWork.Get_list returned: %s", err.Error())
+		return
+	}
+	for _, id := range currentWork {
+		work := &core.Workunit{Workunit_Unique_Identifier: id}
+		workPath, xerr := work.Path()
+		if xerr != nil {
+			continue
+		}
+		running[workPath] = true
+	}
+
+	dirs, err := filepath.Glob(path.Join(conf.WORK_PATH, "??", "??", "??", "*"))
+	if err != nil {
+		err = fmt.Errorf("(CleanDisk) filepath.Glob returned: %s", err.Error())
+		return
+	}
+	for _, dir := range dirs {
+		if running[dir] {
+			continue
+		}
+		if xerr := os.RemoveAll(dir); xerr != nil {
+			logger.Error("(CleanDisk) could not remove %s: %s", dir, xerr.Error())
+			continue
+		}
+		removed++
+	}
+	logger.Info("(CleanDisk) removed %d work directories", removed)
 	return
 }
 func getMetaDataField(metadata_url string, field string) (result string, err error) {
@@ -0,0 +1,90 @@
+package worker
+
+import (
+	"io"
+	"os"
+	"path"
+	"time"
+
+	"github.com/MG-RAST/AWE/lib/conf"
+	"github.com/MG-RAST/AWE/lib/core"
+	"github.com/MG-RAST/AWE/lib/logger"
+)
+
+// max bytes sent in one chunk
+const logStreamChunkSize = 1024 * 1024
+
+// streamLogs periodically sends new stdout/stderr output of a running workunit to the server, until stop is closed.
+// The complete logs are still sent by the deliverer when the workunit is done.
+func streamLogs(workunit *core.Workunit, stop chan bool) {
+	work_path, err := workunit.Path()
+	if err != nil {
+		logger.Error("(streamLogs) workunit.Path returned: %s", err.Error())
+		return
+	}
+
+	logfiles := map[string]string{
+		"stdout": path.Join(work_path, conf.STDOUT_FILENAME),
+		"stderr": path.Join(work_path, conf.STDERR_FILENAME),
+	}
+	offsets := map[string]int64{}
+
+	ticker := time.NewTicker(time.Duration(conf.LOG_STREAM_INTERVAL) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		for logname, logfile := range logfiles {
+			offset, err := pushLogChunks(workunit, logname, logfile, offsets[logname])
+			offsets[logname] = offset
+			if err != nil {
+				// try again next time, the complete log is sent at the end anyway
+				logger.Debug(1, "(streamLogs) could not send %s: %s", logname, err.Error())
+			}
+		}
+	}
+}
+
+// pushLogChunks sends everything after offset, returns the new offset
+func pushLogChunks(workunit *core.Workunit, logname string, logfile string, offset int64) (newOffset int64, err error) {
+	newOffset = offset
+
+	file, err := os.Open(logfile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	defer file.Close()
+
+	_, err = file.Seek(offset, io.SeekStart)
+	if err != nil {
+		return
+	}
+
+	buffer := make([]byte, logStreamChunkSize)
+	for {
+		var n int
+		n, err = io.ReadFull(file, buffer)
+		if n > 0 {
+			perr := core.PushWorkunitLog(workunit, logname, newOffset, buffer[:n])
+			if perr != nil {
+				err = perr
+				return
+			}
+			newOffset += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = nil
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
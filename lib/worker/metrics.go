@@ -0,0 +1,52 @@
+package worker
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/MG-RAST/AWE/lib/conf"
+	"github.com/MG-RAST/AWE/lib/core"
+	"github.com/MG-RAST/AWE/lib/logger"
+	"github.com/MG-RAST/AWE/lib/metrics"
+)
+
+// WorkerMetrics metrics of the worker, served on conf.WORKER_METRICS_PORT
+var WorkerMetrics = metrics.NewRegistry()
+
+var (
+	metricWorkunits = WorkerMetrics.NewCounterVec("awe_worker_workunits_total", "number of workunits processed by the worker by final state", "state")
+	metricDataBytes = WorkerMetrics.NewCounterVec("awe_worker_data_bytes_total", "bytes moved over the network: predata and input files (in), output files (out)", "direction")
+	metricDuration  = WorkerMetrics.NewHistogramVec("awe_worker_stage_duration_seconds", "duration of the stages of a workunit: predata_in, data_in, docker_prep, runtime, data_out", metrics.DefaultBuckets, "stage")
+)
+
+// observeWorkPerf records the performance statistics of a delivered workunit
+func observeWorkPerf(workunit *core.Workunit, perf *core.WorkPerf) {
+	metricWorkunits.Inc(workunit.State)
+	if perf == nil {
+		return
+	}
+	metricDataBytes.Add(float64(perf.PreDataSize+perf.InFileSize), "in")
+	metricDataBytes.Add(float64(perf.OutFileSize), "out")
+
+	metricDuration.Observe(perf.PreDataIn, "predata_in")
+	metricDuration.Observe(perf.DataIn, "data_in")
+	metricDuration.Observe(float64(perf.DockerPrep), "docker_prep")
+	metricDuration.Observe(float64(perf.Runtime), "runtime")
+	metricDuration.Observe(perf.DataOut, "data_out")
+}
+
+// startMetricsListener serves GET /metrics if a port is configured
+func startMetricsListener() {
+	if conf.WORKER_METRICS_PORT == 0 {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", WorkerMetrics)
+	go func() {
+		err := http.ListenAndServe(fmt.Sprintf(":%d", conf.WORKER_METRICS_PORT), mux)
+		if err != nil {
+			logger.Error("(startMetricsListener) http.ListenAndServe returned: %s", err.Error())
+		}
+	}()
+	return
+}
@@ -2,7 +2,6 @@ package worker
 
 import (
 	"bufio"
-	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -23,12 +22,9 @@ import (
 	"os/exec"
 	"path"
 	"runtime"
-	"strconv"
 	"strings"
 	"syscall"
 	"time"
-
-	"github.com/fsouza/go-dockerclient"
 )
 
 type Shock_Dockerimage_attributes struct {
@@ -87,8 +83,7 @@ func processor_run(control chan int) (err error) {
 
 	workmap.Set(work_id, ID_WORKER, "processor")
 
-	var envkeys []string
-	_ = envkeys
+	var env []string
 
 	wants_docker := false
 	if workunit.Cmd.Dockerimage != "" || workunit.Cmd.DockerPull != "" {
@@ -96,10 +91,10 @@ func processor_run(control chan int) (err error) {
 	}
 
 	if !wants_docker {
-		envkeys, err = SetEnv(workunit)
+		env, err = WorkunitEnv(workunit)
 		if err != nil {
-			logger.Error("(processor) SetEnv(): workid=" + work_str + ", " + err.Error())
-			workunit.Notes = append(workunit.Notes, "[processor#SetEnv]"+err.Error())
+			logger.Error("(processor) WorkunitEnv(): workid=" + work_str + ", " + err.Error())
+			workunit.Notes = append(workunit.Notes, "[processor#WorkunitEnv]"+err.Error())
 			workunit.SetState(core.WORK_STAT_ERROR, "see notes")
 			//release the permit lock, for work overlap inhibitted mode only
 			//if !conf.WORKER_OVERLAP && core.Service != "proxy" {
@@ -111,7 +106,7 @@ func processor_run(control chan int) (err error) {
 	run_start := time.Now().Unix()
 
 	var pstat *core.WorkPerf
-	pstat, err = RunWorkunit(workunit)
+	pstat, err = RunWorkunit(workunit, env)
 	exit_status := workunit.ExitStatus
 	logger.Debug(1, "(processor) ExitStatus of process: %d", exit_status)
 	if err != nil {
@@ -123,6 +118,15 @@ func processor_run(control chan int) (err error) {
 		} else {
 			workunit.SetState(core.WORK_STAT_ERROR, "RunWorkunit failed")
 		}
+		// without an exit code the tool did not run, e.g. the container could not be started
+		switch {
+		case workunit.Failure == core.FAILURE_TIMEOUT, workunit.Failure == core.FAILURE_OOM:
+			// the tool was killed after the time limit or by the OOM killer, set by RunWorkunit
+		case exit_status > 0:
+			workunit.Failure = core.FAILURE_APP
+		default:
+			workunit.Failure = core.FAILURE_INFRASTRUCTURE
+		}
 		err = nil
 	} else {
 		logger.Debug(1, "(processor) RunWorkunit() returned without error, workid=%s", work_str)
@@ -132,17 +136,19 @@ func processor_run(control chan int) (err error) {
 		workunit.WorkPerf.MaxMemoryTotalSwap = pstat.MaxMemoryTotalSwap
 
 		workunit.WorkPerf.DockerPrep = pstat.DockerPrep
+		workunit.WorkPerf.DockerImage = pstat.DockerImage
+		workunit.WorkPerf.DockerImageId = pstat.DockerImageId
 	}
 	run_end := time.Now().Unix()
 	computetime := run_end - run_start
 	workunit.WorkPerf.Runtime = computetime
 	workunit.ComputeTime = int(computetime)
 
-	if !wants_docker {
-		if len(envkeys) > 0 {
-			UnSetEnv(envkeys)
-		}
+	// with worker overlap the next workunit can be checked out while this one is delivered
+	if conf.WORKER_OVERLAP {
+		slots.Release(work_id)
 	}
+
 	logger.Debug(1, "(processor) sending work to datamover")
 	fromProcessor <- workunit
 
@@ -175,10 +181,16 @@ func processor(control chan int) {
 	//control <- ID_WORKER //we are ending
 }
 
-func RunWorkunit(workunit *core.Workunit) (pstats *core.WorkPerf, err error) {
+func RunWorkunit(workunit *core.Workunit, env []string) (pstats *core.WorkPerf, err error) {
 
 	stderr_exists := false
 
+	if conf.PRINT_APP_MSG && conf.LOG_STREAM_INTERVAL > 0 && Client_mode != "offline" {
+		stopStreaming := make(chan bool)
+		defer close(stopStreaming)
+		go streamLogs(workunit, stopStreaming)
+	}
+
 	if workunit.Cmd.Dockerimage != "" || workunit.Cmd.DockerPull != "" {
 		pstats, err = RunWorkunitDocker(workunit)
 		if err != nil {
@@ -186,7 +198,7 @@ func RunWorkunit(workunit *core.Workunit) (pstats *core.WorkPerf, err error) {
 			return
 		}
 	} else {
-		pstats, stderr_exists, err = RunWorkunitDirect(workunit)
+		pstats, stderr_exists, err = RunWorkunitDirect(workunit, env)
 		if err != nil {
 			err = fmt.Errorf("(RunWorkunit) RunWorkunitDirect returned: %s", err.Error())
 			return
@@ -271,26 +283,50 @@ func RunWorkunit(workunit *core.Workunit) (pstats *core.WorkPerf, err error) {
 		//spew.Dump(result_doc)
 		workunit.CWLWorkunit.Outputs = result_doc
 
+		// cwl-runner starts the container of the tool itself
+		if pstats.DockerImage == "" {
+			pstats.DockerImage, pstats.DockerImageId = cwlToolImage(workunit)
+		}
+
 	}
 
 	return
 }
 
+// cwlToolImage name and image id of the docker image of a CWL CommandLineTool, the id is empty if the
+// container runtime does not know the image. Both are empty if the tool has no DockerRequirement.
+func cwlToolImage(workunit *core.Workunit) (image string, image_id string) {
+	clt, ok := workunit.CWLWorkunit.Tool.(*cwl.CommandLineTool)
+	if !ok {
+		return
+	}
+	image = core.DockerImageOfTool(clt)
+	if image == "" {
+		return
+	}
+	container_runtime, err := NewContainerRuntime()
+	if err != nil {
+		return
+	}
+	image_id, err = container_runtime.InspectImage(image)
+	if err != nil {
+		logger.Debug(1, "(cwlToolImage) InspectImage returned: %s", err.Error())
+		image_id = ""
+	}
+	return
+}
+
 func RunWorkunitDocker(workunit *core.Workunit) (pstats *core.WorkPerf, err error) {
+	chankill, err := workmap.KillChannel(workunit.Workunit_Unique_Identifier)
+	if err != nil {
+		return
+	}
 	pstats = new(core.WorkPerf)
 	pstats.MaxMemUsage = -1
 	pstats.MaxMemoryTotalRss = -1
 	pstats.MaxMemoryTotalSwap = -1
 	args := workunit.Cmd.ParsedArgs
 
-	//change cwd to the workunit's working directory
-	err = workunit.CDworkpath()
-	if err != nil {
-		err = fmt.Errorf("(RunWorkunitDocker) CDworkpath returned: %s", err.Error())
-		pstats = nil
-		return
-	}
-
 	docker_preparation_start := time.Now().Unix()
 
 	commandName := workunit.Cmd.Name
@@ -329,7 +365,13 @@ func RunWorkunitDocker(workunit *core.Workunit) (pstats *core.WorkPerf, err erro
 
 	//cmd := exec.Command(commandName, args...)
 
-	container_name := "AWE_workunit_" + DockerizeName(conf.CLIENT_NAME)
+	// the workunit id keeps the containers of the slots apart
+	work_str, err := workunit.String()
+	if err != nil {
+		err = fmt.Errorf("(RunWorkunitDocker) workunit.String returned: %s", err.Error())
+		return
+	}
+	container_name := "AWE_workunit_" + DockerizeName(conf.CLIENT_NAME) + "_" + DockerizeName(work_str)
 
 	if workunit.Cmd.Dockerimage != "" {
 		logger.Debug(1, "using Dockerimage: %s", workunit.Cmd.Dockerimage)
@@ -363,33 +405,17 @@ func RunWorkunitDocker(workunit *core.Workunit) (pstats *core.WorkPerf, err erro
 	Dockerimage_normalized := dockerimage_repo + ":" + dockerimage_tag
 	logger.Debug(3, "Dockerimage_normalized: %s", Dockerimage_normalized)
 
-	use_docker_api := true
-	if conf.DOCKER_BINARY != "API" {
-		use_docker_api = false
-	}
-
-	var client *docker.Client = nil
-
-	if use_docker_api {
-		logger.Debug(1, "Using docker API...")
-		client, err = docker.NewClient(conf.DOCKER_SOCKET)
-		if err != nil {
-			err = fmt.Errorf("(RunWorkunitDocker) error creating docker client: %s", err.Error())
-			return
-		}
-	} else {
-		logger.Debug(1, "Using docker docker binary...")
+	container_runtime, err := NewContainerRuntime()
+	if err != nil {
+		err = fmt.Errorf("(RunWorkunitDocker) NewContainerRuntime returned: %s", err.Error())
+		return
 	}
-
-	//imgs, _ := client.ListImages(false)
-	//for _, img := range imgs {
-	//	spew.Dump(img)
-	//}
+	logger.Debug(1, "Using container runtime %s...", container_runtime.Name())
 
 	// delete any old AWE_container
-	err = RemoveOldAWEContainers(client, container_name)
+	err = container_runtime.RemoveContainer(container_name)
 	if err != nil {
-		err = fmt.Errorf("(RunWorkunitDocker) RemoveOldAWEContainers returned: %s", err.Error())
+		err = fmt.Errorf("(RunWorkunitDocker) RemoveContainer returned: %s", err.Error())
 		return nil, err
 	}
 
@@ -427,8 +453,8 @@ func RunWorkunitDocker(workunit *core.Workunit) (pstats *core.WorkPerf, err erro
 		logger.Debug(1, "using dockerimage id %s instead of name %s ", dockerimage_id, Dockerimage_normalized)
 
 		// *** find/inspect image
-		var image *docker.Image
-		image, err = InspectImage(client, dockerimage_id)
+		var image_id string
+		image_id, err = container_runtime.InspectImage(dockerimage_id)
 
 		if err != nil {
 
@@ -437,29 +463,30 @@ func RunWorkunitDocker(workunit *core.Workunit) (pstats *core.WorkPerf, err erro
 
 			image_retrieval := "load" // TODO only load is guaraneed to workunit
 			//image_retrieval := "pull"
+			api_runtime, has_api := container_runtime.(*dockerAPIRuntime)
 			switch {
 			case image_retrieval == "load":
 				{ // for images that have been saved
 					logger.Debug(1, "Loading image %s", dockerimage_download_url)
-					err = dockerLoadImage(client, dockerimage_download_url, workunit.Info.DataToken)
+					err = dockerLoadImage(container_runtime, dockerimage_id, dockerimage_download_url, workunit.Info.DataToken)
 					if err != nil {
 						err = fmt.Errorf("Docker image was not correctly loaded, err=%s", err.Error())
 						return
 					}
 				}
-			case image_retrieval == "import":
+			case image_retrieval == "import" && has_api:
 				{ // for containers that have been exported
 					logger.Debug(1, "Importing image %s", Dockerimage_normalized)
-					xerr := dockerImportImage(client, Dockerimage_normalized, workunit.Info.DataToken)
+					xerr := dockerImportImage(api_runtime.client, Dockerimage_normalized, workunit.Info.DataToken)
 					if xerr != nil {
 						err = fmt.Errorf("Docker image was not correctly imported, err=%s", xerr.Error())
 						return
 					}
 				}
-			case image_retrieval == "build":
+			case image_retrieval == "build" && has_api:
 				{ // to create image from Dockerfile
 					logger.Debug(1, "Building image %s", Dockerimage_normalized)
-					xerr := dockerBuildImage(client, Dockerimage_normalized)
+					xerr := dockerBuildImage(api_runtime.client, Dockerimage_normalized)
 					if xerr != nil {
 						err = fmt.Errorf("Docker image was not correctly built, err=%s", xerr.Error())
 						return
@@ -468,10 +495,7 @@ func RunWorkunitDocker(workunit *core.Workunit) (pstats *core.WorkPerf, err erro
 			case image_retrieval == "pull":
 				{ // pull from docker hub
 					logger.Debug(1, "Pulling image %s", Dockerimage_normalized)
-					var buf bytes.Buffer
-					pio := docker.PullImageOptions{Repository: Dockerimage_normalized, OutputStream: &buf}
-					xerr := client.PullImage(pio, docker.AuthConfiguration{})
-					logger.Debug(3, "docker pull response: %s", buf.String())
+					xerr := container_runtime.PullImage(Dockerimage_normalized)
 					if xerr != nil {
 						err = fmt.Errorf("Docker image was not correctly pulled, err=%s", xerr.Error())
 						return
@@ -485,33 +509,24 @@ func RunWorkunitDocker(workunit *core.Workunit) (pstats *core.WorkPerf, err erro
 			// download http://shock.metagenomics.anl.gov/node/ed0a6b20-c535-40d7-92e8-754bb8b6b48f?download
 
 			// last test
-			if dockerimage_id != "" {
-				image, err = InspectImage(client, dockerimage_id)
-				if err != nil {
-					err = fmt.Errorf("(InspectImage) Docker image (%s , %s) was not correctly imported or built, err=%s", Dockerimage_normalized, dockerimage_id, err.Error())
-					return
-				}
-			} else {
-				image, err = InspectImage(client, Dockerimage_normalized)
-				if xerr != nil {
-					err = fmt.Errorf("(InspectImage) Docker image (%s) was not correctly imported or built, err=%s", Dockerimage_normalized, err.Error())
-					return
-				}
+			image_id, err = container_runtime.InspectImage(dockerimage_id)
+			if err != nil {
+				err = fmt.Errorf("(InspectImage) Docker image (%s , %s) was not correctly imported or built, err=%s", Dockerimage_normalized, dockerimage_id, err.Error())
+				return
 			}
 
 		} else {
 			logger.Debug(1, "docker image %s is already in local repository", Dockerimage_normalized)
 		}
 
-		if dockerimage_id != image.ID {
-			err = fmt.Errorf("error: dockerimage_id != image.ID, %s != %s (%s)", dockerimage_id, image.ID, Dockerimage_normalized)
+		// podman reports ids without "sha256:"
+		if strings.TrimPrefix(dockerimage_id, "sha256:") != strings.TrimPrefix(image_id, "sha256:") {
+			err = fmt.Errorf("error: dockerimage_id != image.ID, %s != %s (%s)", dockerimage_id, image_id, Dockerimage_normalized)
 			return
 		}
 
 		// tag image to make debugging easier
-		tag_opts := docker.TagImageOptions{Repo: dockerimage_repo, Tag: dockerimage_tag}
-
-		err = TagImage(client, dockerimage_id, tag_opts)
+		err = container_runtime.TagImage(dockerimage_id, dockerimage_repo, dockerimage_tag)
 		if err != nil {
 			logger.Error("warning: tagging of image %s with %s failed, err: %s", dockerimage_id, Dockerimage_normalized, err.Error())
 		}
@@ -520,10 +535,7 @@ func RunWorkunitDocker(workunit *core.Workunit) (pstats *core.WorkPerf, err erro
 
 		if false {
 			logger.Debug(1, "Pulling image %s from Docker Hub", Dockerimage_normalized)
-			var buf bytes.Buffer
-			pio := docker.PullImageOptions{Repository: Dockerimage_normalized, OutputStream: &buf}
-			err = client.PullImage(pio, docker.AuthConfiguration{})
-			logger.Debug(3, "docker pull response: %s", buf.String())
+			err = container_runtime.PullImage(Dockerimage_normalized)
 			if err != nil {
 				err = fmt.Errorf("Docker image was not correctly pulled, err=%s", err.Error())
 				return
@@ -537,6 +549,13 @@ func RunWorkunitDocker(workunit *core.Workunit) (pstats *core.WorkPerf, err erro
 		return
 	}
 
+	// for the provenance of the workunit, the runtime resolves tags to the image id
+	pstats.DockerImage = Dockerimage_normalized
+	pstats.DockerImageId = dockerimage_id
+	if image_id, ierr := container_runtime.InspectImage(dockerimage_id); ierr == nil && image_id != "" {
+		pstats.DockerImageId = image_id
+	}
+
 	// collect environment
 	var docker_environment []string
 	docker_environment_string := "" // this is only for the debug output
@@ -560,7 +579,6 @@ func RunWorkunitDocker(workunit *core.Workunit) (pstats *core.WorkPerf, err erro
 	} else {
 		logger.Debug(3, "HasPrivateEnv false")
 	}
-
 	proxyVariables := [4]string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy"}
 	for _, proxyVar := range proxyVariables {
 		proxyVarValue := os.Getenv(proxyVar)
@@ -630,62 +648,31 @@ func RunWorkunitDocker(workunit *core.Workunit) (pstats *core.WorkPerf, err erro
 
 	logger.Debug(1, "volume_str: "+volume_str)
 
-	// version for docker command line
-	docker_commandline_create := []string{
-		// "-t" would be required if I want to attach to the container later, check again documentation if needed
-		"--name=" + container_name,
-		"--workdir=" + conf.DOCKER_WORK_DIR,
-		volume_str, // for workdir and optionally predata
-	}
-
-	if docker_environment_string != "" {
-		docker_commandline_create = append(docker_commandline_create, docker_environment_string)
-	}
-
-	// version for docker API
-	config := docker.Config{Image: dockerimage_id,
-		WorkingDir:   conf.DOCKER_WORK_DIR,
-		AttachStdout: true,
-		AttachStderr: true,
-		AttachStdin:  false,
-		Cmd:          container_cmd,
-		Volumes:      map[string]struct{}{conf.DOCKER_WORK_DIR: struct{}{}}, // old version
-		//Volumes: map[string]struct{}{bindstr_workdir: struct{}{}},
-
-		Env: docker_environment,
+	spec := &ContainerSpec{
+		Name:    container_name,
+		Image:   dockerimage_id,
+		Cmd:     container_cmd,
+		WorkDir: conf.DOCKER_WORK_DIR,
+		Binds:   bindarray,
+		Env:     docker_environment,
+		// CWL NetworkAccess, the container is only cut off from the network if the tool explicitly says it does not need it
+		NoNetwork: workunit.NetworkAccess != nil && !*workunit.NetworkAccess,
+		// CWL ResourceRequirement or Command.Resources
+		Limits: newContainerLimits(workunit.Resources),
 	}
-
-	if len(workunit.Predata) > 0 {
-		config.Volumes[bindstr_predata] = struct{}{}
+	if spec.Limits != nil {
+		logger.Debug(1, "container limits: %v", spec.Limits.args())
 	}
 
-	docker_commandline_create = append(docker_commandline_create, dockerimage_id)   //
-	docker_commandline_create = append(docker_commandline_create, container_cmd...) // argument to the "docker create" command
-
-	opts := docker.CreateContainerOptions{Name: container_name, Config: &config, HostConfig: &docker.HostConfig{Binds: bindarray}}
-
-	// note: docker binary mounts on creation, while docker API mounts on start of container
-
 	container_id := ""
 
 	// *** create container
-	logger.Debug(1, "creating docker container %s from image %s (%s)", container_name, Dockerimage_normalized, dockerimage_id)
-
-	if client != nil {
+	logger.Debug(1, "creating %s container %s from image %s (%s)", container_runtime.Name(), container_name, Dockerimage_normalized, dockerimage_id)
 
-		container_obj, xerr := client.CreateContainer(opts)
-		if xerr == nil {
-			container_id = container_obj.ID
-		} else {
-			err = fmt.Errorf("error creating container, err=%s", xerr.Error())
-			return
-		}
-	} else {
-		container_id, err = CreateContainer(docker_commandline_create)
-		if err != nil {
-			err = fmt.Errorf("error creating container, err=%s", err.Error())
-			return
-		}
+	container_id, err = container_runtime.CreateContainer(spec)
+	if err != nil {
+		err = fmt.Errorf("error creating container, err=%s", err.Error())
+		return
 	}
 	logger.Debug(3, "Container created.")
 
@@ -694,27 +681,19 @@ func RunWorkunitDocker(workunit *core.Workunit) (pstats *core.WorkPerf, err erro
 		return
 	}
 
-	logger.Debug(1, "created docker container with ID: %s", container_id)
+	logger.Debug(1, "created %s container with ID: %s", container_runtime.Name(), container_id)
 
 	// *** start container
 
 	fake_docker_cmd := "sudo docker run -t -i --name test " + volume_str + " " + docker_environment_string + " --workdir=" + conf.DOCKER_WORK_DIR + " " + dockerimage_id + " " + strings.Join(container_cmd, " ")
 	logger.Debug(1, "fake_docker_cmd ("+Dockerimage_normalized+"): "+fake_docker_cmd)
-	logger.Debug(1, "starting docker container...")
+	logger.Debug(1, "starting container...")
 
 	docker_preparation_end := time.Now().Unix()
 	pstats.DockerPrep = docker_preparation_end - docker_preparation_start
 	logger.Debug(1, "DockerPrep time in seconds: %d", pstats.DockerPrep)
 
-	if client != nil {
-		//err = client.StartContainer(container_id, &docker.HostConfig{Binds: bindarray}) // weired, seems to be needed
-		err = client.StartContainer(container_id, nil)
-		//err = client.StartContainer(container_id, &docker.HostConfig{})
-
-	} else {
-		err = StartContainer(container_id, volume_str)
-	}
-
+	err = container_runtime.StartContainer(container_id)
 	if err != nil {
 		err = fmt.Errorf("error starting container, id=%s, err=%s", container_id, err.Error())
 		return
@@ -724,150 +703,76 @@ func RunWorkunitDocker(workunit *core.Workunit) (pstats *core.WorkPerf, err erro
 	defer func(container_id string) {
 		// *** clean up
 		// ** kill container
-		var err_kill error
-		if client != nil {
-			err_kill = client.KillContainer(docker.KillContainerOptions{ID: container_id})
-		} else {
-			err_kill = KillContainer(container_id)
-		}
+		err_kill := container_runtime.KillContainer(container_id)
 		if err_kill != nil {
 			logger.Debug(3, "(deferred func) (clean-up after running container) could not kill container id=%s, err=%s", container_id, err_kill.Error())
 		}
 
 	}(container_id)
 
-	if client != nil {
-		var cont *docker.Container
-		cont, err = client.InspectContainer(container_id)
-		if err != nil {
-			logger.Error("error inspecting container=%s, err=%s", container_id, err.Error())
-			return
-		}
-
-		logger.Debug(3, "Container status: %s", cont.State.Status)
-
+	if api_runtime, ok := container_runtime.(*dockerAPIRuntime); ok {
 		inspect_filename := path.Join(work_path, "container_inspect.json")
-
-		b_inspect, _ := json.MarshalIndent(cont, "", "    ")
-
-		err = ioutil.WriteFile(inspect_filename, b_inspect, 0666)
+		err = api_runtime.writeInspect(container_id, inspect_filename)
 		if err != nil {
 			err = fmt.Errorf("error writing inspect file for container=%s, err=%s", container_id, err.Error())
 			return
-		} else {
-			logger.Debug(1, "wrote %s for container %s", inspect_filename, container_id)
 		}
+		logger.Debug(1, "wrote %s for container %s", inspect_filename, container_id)
 	}
 
 	// wait for container to finish
 	done := make(chan WaitContainerResult)
+	memcheck_done := make(chan bool)
 	go func() {
 
 		//time.Sleep(300 * time.Second)
 
-		var errwait error
-		var status int = -1
-		if client != nil {
-			status, errwait = client.WaitContainer(container_id)
-		} else {
-			status, errwait = WaitContainer(container_id)
-		}
+		status, errwait := container_runtime.WaitContainer(container_id)
 
 		cresult := WaitContainerResult{errwait, status}
 
-		done <- cresult // inform main function
-		if conf.MEM_CHECK_INTERVAL != 0 {
-			done <- cresult // inform memory checker
-		}
+		close(memcheck_done) // inform memory checker
+		done <- cresult      // inform main function
 	}()
 
 	var MaxMem int64 = -1
 	var max_memory_total_rss int64 = -1
 	var max_memory_total_swap int64 = -1
 
-	memory_stat_filename := ""
+	memory_check := false
 	if conf.MEM_CHECK_INTERVAL != 0 {
 
 		// documentation: https://docs.docker.com/articles/runmetrics/
 		// e.g. ubuntu: /sys/fs/cgroup/memory/docker/[ID]/memory.stat
 		//      coreos: /sys/fs/cgroup/memory/system.slice/docker-[ID].scope/memory.stat
+		//      cgroup v2: /sys/fs/cgroup/system.slice/docker-[ID].scope/memory.stat
 
-		memory_stat_filename = strings.Replace(conf.CGROUP_MEMORY_DOCKER_DIR, "[ID]", container_id, -1)
-
-		if _, err := os.Stat(memory_stat_filename); os.IsNotExist(err) {
-			logger.Error("warning: memory measurement requested, but no memory.stat found: %s" + memory_stat_filename)
-			memory_stat_filename = ""
+		_, err_mem := container_runtime.Stats(container_id)
+		if err_mem != nil {
+			logger.Error("warning: memory measurement requested, but no memory statistics available: %s", err_mem.Error())
+		} else {
+			memory_check = true
 		}
 
 	}
 
-	if conf.MEM_CHECK_INTERVAL != 0 && memory_stat_filename != "" {
+	if memory_check {
 		go func() { // memory checker
 
 			for {
 
 				select {
-				case cresult := <-done:
-					workunit.ExitStatus = cresult.Status
-					if cresult.Error != nil {
-						logger.Error("channel done returned error: " + cresult.Error.Error())
-					}
+				case <-memcheck_done:
 					return
 				default:
 				}
 
-				var memory_total_rss int64 = -1
-				var memory_total_swap int64 = -1
-				memory_stat_file, err_mem := os.Open(memory_stat_filename)
+				stats, err_mem := container_runtime.Stats(container_id)
 				if err_mem != nil {
-
-					logger.Error("warning: error opening memory_stat_file file:" + err_mem.Error())
-					time.Sleep(conf.MEM_CHECK_INTERVAL)
-					continue
-				}
-
-				// Closes the file when we leave the scope of the current function,
-				// this makes sure we never forget to close the file if the
-				// function can exit in multiple places.
-
-				memory_stat_file_scanner := bufio.NewScanner(memory_stat_file)
-
-				memory_total_rss_read := false
-				memory_total_swap_read := false
-				// scanner.Scan() advances to the next token returning false if an error was encountered
-				for memory_stat_file_scanner.Scan() {
-					line := memory_stat_file_scanner.Text()
-					if strings.HasPrefix(line, "total_rss ") { // TODO what is total_rss_huge
-						//logger.Debug(1, fmt.Sprint("inspecting container with memory line=", line))
-
-						memory_total_rss, err = strconv.ParseInt(strings.TrimPrefix(line, "total_rss "), 10, 64)
-						if err != nil {
-							memory_total_rss = -1
-						}
-						memory_total_rss_read = true
-					} else if strings.HasPrefix(line, "total_swap ") { // TODO what is total_rss_huge
-						//logger.Debug(1, fmt.Sprint("inspecting container with memory line=", line))
-
-						memory_total_swap, err = strconv.ParseInt(strings.TrimPrefix(line, "total_swap "), 10, 64)
-						if err != nil {
-							memory_total_swap = -1
-						}
-						memory_total_swap_read = true
-					} else {
-						continue
-					}
-					if memory_total_rss_read && memory_total_swap_read { // we found all information we need, leave the loop
-						break
-					}
-
-				}
-
-				// When finished scanning if any error other than io.EOF occured
-				// it will be returned by scanner.Err().
-				if err := memory_stat_file_scanner.Err(); err != nil {
-					logger.Error("warning: could no read memory usage from cgroups=%s", memory_stat_file_scanner.Err())
-					//err = nil
+					logger.Error("warning: could no read memory usage from cgroups=%s", err_mem.Error())
 				} else {
+					memory_total_rss := stats.MemoryRss
+					memory_total_swap := stats.MemorySwap
 
 					// RSS maxium
 					if memory_total_rss >= 0 && memory_total_rss > max_memory_total_rss {
@@ -893,7 +798,6 @@ func RunWorkunitDocker(workunit *core.Workunit) (pstats *core.WorkPerf, err erro
 						memory_total_rss, memory_total_swap, max_memory_total_rss, max_memory_total_swap, MaxMem))
 
 				}
-				memory_stat_file.Close() // defer does not work in for loop !
 				//time.Sleep(5 * time.Second)
 				time.Sleep(conf.MEM_CHECK_INTERVAL)
 
@@ -905,16 +809,31 @@ func RunWorkunitDocker(workunit *core.Workunit) (pstats *core.WorkPerf, err erro
 
 	cresult := WaitContainerResult{nil, -1}
 
+	// CWL ToolTimeLimit, a nil channel never fires
+	var timeout <-chan time.Time
+	if workunit.TimeLimit > 0 {
+		timer := time.NewTimer(time.Duration(workunit.TimeLimit) * time.Second)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
 	select {
-	case <-chankill:
-		logger.Debug(1, "chankill, try to kill container %s... ", container_id)
+	case <-timeout:
+		logger.Debug(1, "time limit of %d seconds exceeded, try to kill container %s... ", workunit.TimeLimit, container_id)
 
-		if client != nil {
-			err = client.KillContainer(docker.KillContainerOptions{ID: container_id})
-		} else {
-			err = KillContainer(container_id)
+		err = container_runtime.KillContainer(container_id)
+		if err != nil {
+			return nil, fmt.Errorf("(timeout) error killing container id=%s, err=%s", container_id, err.Error())
 		}
 
+		<-done // allow goroutine to exit
+
+		workunit.Failure = core.FAILURE_TIMEOUT
+		return nil, fmt.Errorf("container killed, time limit of %d seconds exceeded", workunit.TimeLimit)
+	case <-chankill:
+		logger.Debug(1, "chankill, try to kill container %s... ", container_id)
+
+		err = container_runtime.KillContainer(container_id)
 		if err != nil {
 			return nil, errors.New(fmt.Sprintf("(chankill) error killing container id=%s, err=%s", container_id, err.Error()))
 		}
@@ -930,6 +849,17 @@ func RunWorkunitDocker(workunit *core.Workunit) (pstats *core.WorkPerf, err erro
 		}
 		if cresult.Status != 0 {
 			logger.Debug(3, "WaitContainer returned non-zero status=%d", cresult.Status)
+			oom, oom_err := container_runtime.OOMKilled(container_id)
+			if oom_err != nil {
+				logger.Error("could not check if container %s was killed by the OOM killer: %s", container_id, oom_err.Error())
+			}
+			if oom {
+				workunit.Failure = core.FAILURE_OOM
+				if spec.Limits != nil && spec.Limits.Memory > 0 {
+					return nil, fmt.Errorf("container killed by the OOM killer, memory limit of %d bytes exceeded (status=%d)", spec.Limits.Memory, cresult.Status)
+				}
+				return nil, fmt.Errorf("container killed by the OOM killer (status=%d)", cresult.Status)
+			}
 			return nil, fmt.Errorf("error WaitContainer returned non-zero status=%d", cresult.Status)
 		}
 	}
@@ -943,7 +873,11 @@ func RunWorkunitDocker(workunit *core.Workunit) (pstats *core.WorkPerf, err erro
 	return
 }
 
-func RunWorkunitDirect(workunit *core.Workunit) (pstats *core.WorkPerf, stderr_exists bool, err error) {
+func RunWorkunitDirect(workunit *core.Workunit, env []string) (pstats *core.WorkPerf, stderr_exists bool, err error) {
+	chankill, err := workmap.KillChannel(workunit.Workunit_Unique_Identifier)
+	if err != nil {
+		return
+	}
 	stderr_exists = false
 	var args []string
 
@@ -953,13 +887,12 @@ func RunWorkunitDirect(workunit *core.Workunit) (pstats *core.WorkPerf, stderr_e
 		args = workunit.Cmd.ParsedArgs
 	}
 
-	//change cwd to the workunit's working directory
-	err = workunit.CDworkpath()
+	work_path, err := workunit.Path()
 	if err != nil {
 		pstats = nil
-
 		return
 	}
+	logger.Debug(3, "(RunWorkunitDirect) Using workpath: %s", work_path)
 
 	commandName := workunit.Cmd.Name
 
@@ -968,7 +901,11 @@ func RunWorkunitDirect(workunit *core.Workunit) (pstats *core.WorkPerf, stderr_e
 		return
 	}
 
+	// the command runs in the workunit's working directory, the worker process does not change it as the
+	// workunits of other slots run at the same time
 	cmd := exec.Command(commandName, args...)
+	cmd.Env = env
+	cmd.Dir = work_path
 
 	msg := fmt.Sprintf("(RunWorkunitDirect) worker: start cmd=%s, args=%v", commandName, args)
 	//fmt.Println(msg)
@@ -991,13 +928,6 @@ func RunWorkunitDirect(workunit *core.Workunit) (pstats *core.WorkPerf, stderr_e
 		}
 	}
 
-	work_path, xerr := workunit.Path()
-	if xerr != nil {
-		err = xerr
-		return
-	}
-	logger.Debug(3, "(RunWorkunitDirect) Using workpath: %s", work_path)
-
 	stdoutFilePath := fmt.Sprintf("%s/%s", work_path, conf.STDOUT_FILENAME)
 	stderrFilePath := fmt.Sprintf("%s/%s", work_path, conf.STDERR_FILENAME)
 	outfile, err := os.Create(stdoutFilePath)
@@ -1055,10 +985,28 @@ func RunWorkunitDirect(workunit *core.Workunit) (pstats *core.WorkPerf, stderr_e
 		}
 	}()
 
+	// CWL ToolTimeLimit, a nil channel never fires
+	var timeout <-chan time.Time
+	if workunit.TimeLimit > 0 {
+		timer := time.NewTimer(time.Duration(workunit.TimeLimit) * time.Second)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
 	do_loop := true
 	for do_loop {
 		logger.Debug(3, "(RunWorkunitDirect) for-loop")
 		select {
+		case <-timeout:
+			if err := cmd.Process.Kill(); err != nil {
+				fmt.Println("(RunWorkunitDirect) failed to kill" + err.Error())
+			}
+			<-done // allow goroutine to exit
+			logger.Info("(RunWorkunitDirect) worker process was killed, time limit of %d seconds exceeded", workunit.TimeLimit)
+			pstats = nil
+			workunit.Failure = core.FAILURE_TIMEOUT
+			err = fmt.Errorf("(RunWorkunitDirect) process killed, time limit of %d seconds exceeded", workunit.TimeLimit)
+			return
 		case MaxMem_value := <-MaxMemChan:
 			logger.Debug(3, "(RunWorkunitDirect) received MaxMem_value %d", MaxMem_value)
 			MaxMem = MaxMem_value
@@ -1109,6 +1057,10 @@ func RunWorkunitDirect(workunit *core.Workunit) (pstats *core.WorkPerf, stderr_e
 }
 
 func runPreWorkExecutionScript(workunit *core.Workunit) (err error) {
+	chankill, err := workmap.KillChannel(workunit.Workunit_Unique_Identifier)
+	if err != nil {
+		return
+	}
 	// conf.PreWorkScript is a string
 	// conf.PreWorkScriptArgs is a string array
 	args := conf.PRE_WORK_SCRIPT_ARGS
@@ -1190,32 +1142,27 @@ func runPreWorkExecutionScript(workunit *core.Workunit) (err error) {
 	return
 }
 
-func SetEnv(workunit *core.Workunit) (envkeys []string, err error) {
+// WorkunitEnv environment of a workunit that does not run in a container: the environment of the worker
+// plus the public and private variables of the command. Slots run workunits concurrently, so the
+// variables are only set for the command and never in the worker process.
+func WorkunitEnv(workunit *core.Workunit) (env []string, err error) {
+	env = os.Environ()
 	for key, val := range workunit.Cmd.Environ.Public {
-		if err := os.Setenv(key, val); err == nil {
-			envkeys = append(envkeys, key)
-		}
+		env = append(env, key+"="+val)
 	}
 	if workunit.Cmd.HasPrivateEnv {
-		envs, err := FetchPrivateEnvByWorkId(workunit.ID)
+		var envs map[string]string
+		envs, err = FetchPrivateEnvByWorkId(workunit.ID)
 		if err != nil {
-			return envkeys, err
+			return
 		}
 		for key, val := range envs {
-			if err := os.Setenv(key, val); err == nil {
-				envkeys = append(envkeys, key)
-			}
+			env = append(env, key+"="+val)
 		}
 	}
 	return
 }
 
-func UnSetEnv(envkeys []string) {
-	for _, key := range envkeys {
-		os.Setenv(key, "")
-	}
-}
-
 func FetchPrivateEnvByWorkId(workid string) (envs map[string]string, err error) {
 	targeturl := fmt.Sprintf("%s/work/%s?privateenv&client=%s", conf.SERVER_URL, workid, core.Self.ID)
 	var headers httpclient.Header
@@ -0,0 +1,71 @@
+package worker
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/MG-RAST/AWE/lib/conf"
+	"github.com/MG-RAST/AWE/lib/core"
+	"github.com/MG-RAST/AWE/lib/logger"
+)
+
+// getMemory returns total and available RAM in MiB (Linux only, reads /proc/meminfo)
+func getMemory() (total int64, available int64, err error) {
+	file, err := os.Open("/proc/meminfo")
+	if err != nil {
+		err = fmt.Errorf("(getMemory) os.Open returned: %s", err.Error())
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// e.g. "MemTotal:       16318228 kB"
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		value, xerr := strconv.ParseInt(fields[1], 10, 64)
+		if xerr != nil {
+			continue
+		}
+		switch fields[0] {
+		case "MemTotal:":
+			total = value / 1024
+		case "MemAvailable:":
+			available = value / 1024
+		}
+	}
+	err = scanner.Err()
+	return
+}
+
+// getDiskFree returns the disk space in MiB available in the work directory
+func getDiskFree() (available int64, err error) {
+	var stat syscall.Statfs_t
+	err = syscall.Statfs(conf.WORK_PATH, &stat)
+	if err != nil {
+		err = fmt.Errorf("(getDiskFree) syscall.Statfs returned: %s", err.Error())
+		return
+	}
+	available = int64(stat.Bavail * uint64(stat.Bsize) / (1024 * 1024))
+	return
+}
+
+// updateResources refreshes available memory and disk space, these are sent to the server with every heartbeat
+func updateResources() {
+	_, memoryFree, err := getMemory()
+	if err != nil {
+		logger.Debug(3, "(updateResources) getMemory returned: %s", err.Error())
+	}
+	diskFree, err := getDiskFree()
+	if err != nil {
+		logger.Debug(3, "(updateResources) getDiskFree returned: %s", err.Error())
+	}
+	core.Self.MemoryFree = memoryFree
+	core.Self.DiskFree = diskFree
+}
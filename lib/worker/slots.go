@@ -0,0 +1,109 @@
+package worker
+
+import (
+	"sync"
+
+	"github.com/MG-RAST/AWE/lib/core"
+)
+
+// SlotMgr keeps track of the workunits running concurrently on this worker and of the cores and RAM they reserved
+type SlotMgr struct {
+	sync.Mutex
+	cond     *sync.Cond
+	slots    int
+	pending  int                // slots acquired for a checkout that has not returned yet
+	capacity core.WorkResources // RAM is zero if unknown
+	reserved map[core.Workunit_Unique_Identifier]core.WorkResources
+}
+
+// NewSlotMgr _
+func NewSlotMgr(slots int, cores int64, ram int64) *SlotMgr {
+	if slots < 1 {
+		slots = 1
+	}
+	if cores < 1 {
+		cores = 1
+	}
+	sm := &SlotMgr{slots: slots, capacity: core.WorkResources{Cores: cores, RAM: ram}}
+	sm.reserved = make(map[core.Workunit_Unique_Identifier]core.WorkResources)
+	sm.cond = sync.NewCond(&sm.Mutex)
+	return sm
+}
+
+// used cores and RAM, caller has to hold the lock
+func (sm *SlotMgr) used() (used core.WorkResources) {
+	for _, r := range sm.reserved {
+		used.Cores += r.Cores
+		used.RAM += r.RAM
+	}
+	return
+}
+
+// Acquire blocks until a slot and at least one core are free, the slot has to be passed on with Reserve or given back with Cancel
+func (sm *SlotMgr) Acquire() (waited bool) {
+	sm.Lock()
+	defer sm.Unlock()
+	for sm.pending+len(sm.reserved) >= sm.slots || sm.used().Cores >= sm.capacity.Cores {
+		waited = true
+		sm.cond.Wait()
+	}
+	sm.pending++
+	return
+}
+
+// Cancel gives back a slot from Acquire, e.g. if no workunit could be checked out
+func (sm *SlotMgr) Cancel() {
+	sm.Lock()
+	defer sm.Unlock()
+	if sm.pending > 0 {
+		sm.pending--
+	}
+	sm.cond.Broadcast()
+}
+
+// Reserve assigns the acquired slot to the workunit and reserves its cores (at least one) and RAM
+func (sm *SlotMgr) Reserve(workunit *core.Workunit) {
+	r := core.WorkResources{Cores: 1}
+	if workunit.Resources != nil {
+		if workunit.Resources.Cores > 1 {
+			r.Cores = workunit.Resources.Cores
+		}
+		r.RAM = workunit.Resources.RAM
+	}
+	sm.Lock()
+	defer sm.Unlock()
+	if sm.pending > 0 {
+		sm.pending--
+	}
+	sm.reserved[workunit.Workunit_Unique_Identifier] = r
+}
+
+// Release frees slot, cores and RAM of the workunit, releasing a workunit twice is harmless
+func (sm *SlotMgr) Release(id core.Workunit_Unique_Identifier) {
+	sm.Lock()
+	defer sm.Unlock()
+	if _, ok := sm.reserved[id]; !ok {
+		return
+	}
+	delete(sm.reserved, id)
+	sm.cond.Broadcast()
+}
+
+// Free returns cores and RAM that are not reserved, RAM is negative if unknown
+func (sm *SlotMgr) Free() (free core.WorkResources) {
+	sm.Lock()
+	defer sm.Unlock()
+	used := sm.used()
+	free.Cores = sm.capacity.Cores - used.Cores
+	free.RAM = -1
+	if sm.capacity.RAM > 0 {
+		free.RAM = sm.capacity.RAM - used.RAM
+		if free.RAM < 0 {
+			free.RAM = 0
+		}
+	}
+	if free.Cores < 0 {
+		free.Cores = 0
+	}
+	return
+}
@@ -0,0 +1,108 @@
+package worker_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MG-RAST/AWE/lib/core"
+	. "github.com/MG-RAST/AWE/lib/worker"
+)
+
+func slotWorkunit(taskName string, cores int64, ram int64) *core.Workunit {
+	work := &core.Workunit{}
+	work.JobId = "job1"
+	work.TaskName = taskName
+	if cores > 0 || ram > 0 {
+		work.Resources = &core.WorkResources{Cores: cores, RAM: ram}
+	}
+	return work
+}
+
+func TestSlotMgrResources(t *testing.T) {
+	sm := NewSlotMgr(3, 8, 1024)
+	if free := sm.Free(); free.Cores != 8 || free.RAM != 1024 {
+		t.Fatalf("expected all resources free, got %+v", free)
+	}
+
+	big := slotWorkunit("big", 4, 512)
+	sm.Acquire()
+	sm.Reserve(big)
+	small := slotWorkunit("small", 0, 0) // at least one core
+	sm.Acquire()
+	sm.Reserve(small)
+	if free := sm.Free(); free.Cores != 3 || free.RAM != 512 {
+		t.Errorf("expected 3 cores and 512 MiB free, got %+v", free)
+	}
+
+	sm.Release(big.Workunit_Unique_Identifier)
+	sm.Release(big.Workunit_Unique_Identifier) // twice is harmless
+	if free := sm.Free(); free.Cores != 7 || free.RAM != 1024 {
+		t.Errorf("expected 7 cores and 1024 MiB free, got %+v", free)
+	}
+
+	// more RAM reserved than the worker has is reported as none free, unknown RAM as negative
+	sm.Acquire()
+	sm.Reserve(slotWorkunit("huge", 1, 4096))
+	if free := sm.Free(); free.RAM != 0 {
+		t.Errorf("expected no RAM free, got %+v", free)
+	}
+	if free := NewSlotMgr(1, 1, 0).Free(); free.RAM >= 0 {
+		t.Errorf("expected unknown RAM, got %+v", free)
+	}
+}
+
+func TestSlotMgrAcquire(t *testing.T) {
+	sm := NewSlotMgr(2, 4, 0)
+
+	// a cancelled slot can be acquired again without waiting
+	sm.Acquire()
+	sm.Cancel()
+	first := slotWorkunit("first", 1, 0)
+	if waited := sm.Acquire(); waited {
+		t.Errorf("slot was not given back by Cancel")
+	}
+	sm.Reserve(first)
+	sm.Acquire()
+	sm.Reserve(slotWorkunit("second", 1, 0))
+
+	// all slots are taken, the next Acquire waits for a Release
+	acquired := make(chan bool)
+	go func() {
+		acquired <- sm.Acquire()
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("Acquire returned although all slots are taken")
+	case <-time.After(50 * time.Millisecond):
+	}
+	sm.Release(first.Workunit_Unique_Identifier)
+	select {
+	case waited := <-acquired:
+		if !waited {
+			t.Errorf("Acquire should report that it waited")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Acquire did not return after Release")
+	}
+	sm.Cancel()
+
+	// a slot is free but all cores are reserved
+	sm = NewSlotMgr(4, 2, 0)
+	wide := slotWorkunit("wide", 2, 0)
+	sm.Acquire()
+	sm.Reserve(wide)
+	go func() {
+		acquired <- sm.Acquire()
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("Acquire returned although all cores are reserved")
+	case <-time.After(50 * time.Millisecond):
+	}
+	sm.Release(wide.Workunit_Unique_Identifier)
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("Acquire did not return after the cores were released")
+	}
+}
@@ -49,12 +49,33 @@ func workStealerRun(control chan int, retry_previous int) (retry int, err error)
 		time.Sleep(time.Second * 10)
 	}
 
+	// drained by the server (heartbeat instruction), finish current workunits but check out nothing new
+	for draining, _ := core.Self.GetDraining(true); draining; draining, _ = core.Self.GetDraining(true) {
+		time.Sleep(time.Second * 10)
+	}
+
+	// wait for a free slot, slots are released by the deliverer (or by the processor if worker overlap is allowed)
+	if core.Service != "proxy" {
+		if waited := slots.Acquire(); waited && conf.WORKER_OVERLAP == false {
+			// sleep short time to allow server to finish processing last delivered work
+			time.Sleep(2 * time.Second)
+		}
+	}
+
+	checkoutStart := time.Now()
 	workunit, err := CheckoutWorkunitRemote()
 	if err != nil {
-		_ = core.Self.SetBusy(false, false)
+		slots.Cancel()
+		empty, _ := core.Self.CurrentWork.IsEmpty(false)
+		if empty {
+			_ = core.Self.SetBusy(false, false)
+		}
+		longPolled := false
 		if err.Error() == e.QueueEmpty || err.Error() == e.QueueSuspend || err.Error() == e.NoEligibleWorkunitFound {
 			//normal, do nothing
 			logger.Debug(3, "(workStealer) client %s received status %s from server %s", core.Self.ID, err.Error(), conf.SERVER_URL)
+			// no need to sleep if the server held the request, servers without long-polling answer right away
+			longPolled = conf.CHECKOUT_WAIT > 0 && time.Since(checkoutStart) >= time.Duration(conf.CHECKOUT_WAIT)*time.Second/2
 		} else if err.Error() == e.ClientBusy {
 			// client asked for work, but server has not finished processing its last delivered work
 			logger.Error("(workStealer) server responds: last work delivered by client not yet processed, retry=%d", retry)
@@ -79,7 +100,7 @@ func workStealerRun(control chan int, retry_previous int) (retry int, err error)
 			logger.Error("(workStealer) checking out workunit: %s, retry=%d", err.Error(), retry)
 			retry += 1
 		}
-		if core.Service != "proxy" { //proxy: event driven, client: timer driven
+		if core.Service != "proxy" && !longPolled { //proxy: event driven, client: timer driven
 			if retry <= 10 {
 				logger.Debug(3, "(workStealer) sleep 10 seconds")
 				time.Sleep(10 * time.Second)
@@ -97,18 +118,32 @@ func workStealerRun(control chan int, retry_previous int) (retry int, err error)
 	var work_str string
 	work_str, err = work_id.String()
 	if err != nil {
+		slots.Cancel()
 		err = fmt.Errorf("(workStealer) work_id.String() returned: %s", err.Error())
 		return
 	}
 	logger.Debug(1, "(workStealer) checked out workunit, id="+work_str)
+
+	// the drain command may have arrived while the checkout request was waiting, the workunit is not started then
+	if draining, _ := core.Self.GetDraining(true); draining {
+		rerr := core.ReturnWorkunit(workunit)
+		if rerr == nil {
+			slots.Cancel()
+			logger.Info("(workStealer) draining, returned workunit %s", work_str)
+			return
+		}
+		logger.Error("(workStealer) draining, but could not return workunit %s, running it: %s", work_str, rerr.Error())
+	}
 	//log event about work checktout (WC)
 	logger.Event(event.WORK_CHECKOUT, "workid="+work_str)
 
 	err = core.Self.CurrentWork.Add(work_id)
 	if err != nil {
+		slots.Cancel()
 		logger.Error("(workStealer) error: %s", err.Error())
 		return
 	}
+	slots.Reserve(workunit)
 
 	workmap.Set(work_id, ID_WORKSTEALER, "workStealer")
 
@@ -126,18 +161,19 @@ func workStealerRun(control chan int, retry_previous int) (retry int, err error)
 		workunit.Cmd.Name = "cwl-runner"
 		// "--provenance", "cwl_tool_provenance", "--disable-pull"
 		workunit.Cmd.ArgsArray = []string{"--leave-outputs", "--leave-tmpdir", "--tmp-outdir-prefix", "./tmp/", "--tmpdir-prefix", "./tmp/", "--rm-container", "--on-error", "stop", "./cwl_tool.yaml", "./cwl_job_input.yaml"}
+		// cwl-runner starts the containers itself
+		switch conf.CONTAINER_RUNTIME {
+		case "podman":
+			workunit.Cmd.ArgsArray = append([]string{"--podman"}, workunit.Cmd.ArgsArray...)
+		case "apptainer", "singularity":
+			workunit.Cmd.ArgsArray = append([]string{"--singularity"}, workunit.Cmd.ArgsArray...)
+		}
 
 	}
 
 	//FromStealer <- rawWork // sends to dataMover
 	FromStealer <- workunit // sends to dataMover
 
-	//if worker overlap is inhibited, wait until deliverer finishes processing the workunit
-	if conf.WORKER_OVERLAP == false && core.Service != "proxy" {
-		chanPermit <- true
-		// sleep short time to allow server to finish processing last delivered work
-		time.Sleep(2 * time.Second)
-	}
 	return
 }
 
@@ -172,6 +208,20 @@ func CheckoutWorkunitRemote() (workunit *core.Workunit, err error) {
 	}
 	targeturl := fmt.Sprintf("%s/work?client=%s&available=%d&server_uuid=%s", conf.SERVER_URL, core.Self.ID, availableBytes, core.ServerUUID)
 
+	// long-poll, the server answers as soon as a workunit is queued
+	if conf.CHECKOUT_WAIT > 0 {
+		targeturl += fmt.Sprintf("&wait=%d", conf.CHECKOUT_WAIT)
+	}
+
+	// multi-slot workers report cores and RAM that are not reserved by running workunits
+	if conf.WORKER_SLOTS > 1 {
+		free := slots.Free()
+		targeturl += fmt.Sprintf("&cores=%d", free.Cores)
+		if free.RAM >= 0 {
+			targeturl += fmt.Sprintf("&ram=%d", free.RAM)
+		}
+	}
+
 	var headers httpclient.Header
 	if conf.CLIENT_GROUP_TOKEN != "" {
 		headers = httpclient.Header{
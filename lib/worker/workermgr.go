@@ -4,6 +4,7 @@ import (
 	//"errors"
 	"fmt"
 
+	"github.com/MG-RAST/AWE/lib/conf"
 	"github.com/MG-RAST/AWE/lib/core"
 
 	//"github.com/MG-RAST/AWE/lib/core/cwl"
@@ -14,8 +15,7 @@ var (
 	FromStealer   chan *core.Workunit // workStealer -> dataMover
 	fromMover     chan *core.Workunit // dataMover -> processor
 	fromProcessor chan *core.Workunit // processor -> deliverer
-	chanPermit    chan bool
-	chankill      chan bool //heartbeater -> worker
+	slots         *SlotMgr            // workStealer only checks out work if a slot is free
 	workmap       *WorkMap
 	//workmap       map[string]int //workunit map [work_id]stage_id}
 	Client_mode string
@@ -43,8 +43,7 @@ func InitWorkers() {
 	FromStealer = make(chan *core.Workunit)   // workStealer -> dataMover
 	fromMover = make(chan *core.Workunit)     // dataMover -> processor
 	fromProcessor = make(chan *core.Workunit) // processor -> deliverer
-	chankill = make(chan bool)                //heartbeater -> processor
-	chanPermit = make(chan bool)
+	slots = NewSlotMgr(conf.WORKER_SLOTS, int64(core.Self.CPUs), core.Self.Memory)
 	//workmap = map[string]int{} //workunit map [work_id]stage_idgit
 	workmap = NewWorkMap()
 	return
@@ -58,10 +57,18 @@ func StartClientWorkers() {
 	if mode == "online" {
 		go heartBeater(control)
 		go workStealer(control)
+		startMetricsListener()
+	}
+	// one pipeline per slot, workunits are picked up by whichever stage is idle
+	numSlots := conf.WORKER_SLOTS
+	if numSlots < 1 || mode == "offline" {
+		numSlots = 1
+	}
+	for i := 0; i < numSlots; i++ {
+		go dataDownloader(control)
+		go processor(control)
+		go deliverer(control)
 	}
-	go dataDownloader(control)
-	go processor(control)
-	go deliverer(control)
 
 	for {
 		who := <-control //block till someone dies and then restart it
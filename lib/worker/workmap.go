@@ -8,10 +8,11 @@ import (
 type WorkMap struct {
 	rwmutex.RWMutex
 	_map map[core.Workunit_Unique_Identifier]int
+	kill map[core.Workunit_Unique_Identifier]chan bool // heartbeater -> processor of this workunit
 }
 
 func NewWorkMap() *WorkMap {
-	wm := &WorkMap{_map: make(map[core.Workunit_Unique_Identifier]int)}
+	wm := &WorkMap{_map: make(map[core.Workunit_Unique_Identifier]int), kill: make(map[core.Workunit_Unique_Identifier]chan bool)}
 	wm.RWMutex.Init("WorkMap")
 	return wm
 }
@@ -51,11 +52,40 @@ func (this *WorkMap) Set(id core.Workunit_Unique_Identifier, value int, name str
 }
 
 func (this *WorkMap) Delete(id core.Workunit_Unique_Identifier) (err error) {
-	rlock, err := this.RLockNamed("Get")
+	err = this.LockNamed("Delete")
 	if err != nil {
 		return
 	}
-	defer this.RUnlockNamed(rlock)
+	defer this.Unlock()
 	delete(this._map, id)
+	delete(this.kill, id)
+	return
+}
+
+// KillChannel returns the channel on which the processor of this workunit receives kill requests
+func (this *WorkMap) KillChannel(id core.Workunit_Unique_Identifier) (c chan bool, err error) {
+	err = this.LockNamed("KillChannel")
+	if err != nil {
+		return
+	}
+	defer this.Unlock()
+	c, ok := this.kill[id]
+	if !ok {
+		c = make(chan bool, 1)
+		this.kill[id] = c
+	}
+	return
+}
+
+// Kill asks the processor of this workunit to stop it, does not block
+func (this *WorkMap) Kill(id core.Workunit_Unique_Identifier) (err error) {
+	c, err := this.KillChannel(id)
+	if err != nil {
+		return
+	}
+	select {
+	case c <- true:
+	default: // kill request already pending
+	}
 	return
 }